@@ -2,28 +2,39 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"go-mongodb-sharding-poc/internal/config"
 	"go-mongodb-sharding-poc/internal/loadbalancer"
+	"go-mongodb-sharding-poc/internal/timeutil"
 	pb "go-mongodb-sharding-poc/proto/sharding/v1"
 )
 
-const database = "sharding_poc"
-const collection = "grpc_demo"
-
 func main() {
 	log.SetFlags(log.Ltime)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dbFlag := flag.String("db", cfg.AppDatabase, "database the demo RPCs target")
+	collFlag := flag.String("collection", "grpc_demo", "collection the demo RPCs target")
+	oplogFlag := flag.Bool("watch-oplog", false, "watch by tailing shard oplogs instead of a change stream")
+	flag.Parse()
+	database, collection := *dbFlag, *collFlag
 
 	log.Println("gRPC Client Demo (Client-Side Load Balancing)")
 	log.Println("")
@@ -43,7 +54,16 @@ func main() {
 	// this creates separate HTTP/2 connections to each resolved endpoint and
 	// distributes individual RPCs across them via round-robin.
 	target := cfg.GRPCTarget
-	conn, err := loadbalancer.NewClientConn(target)
+	//
+	// Unary RPCs are capped at 10s each so a hung backend fails fast instead
+	// of consuming the whole demo deadline.
+	//
+	// GRPC_LB_SUBSET_SIZE limits the client to a random subset of backends
+	// so large fleets don't cost one connection per pod per client.
+	conn, err := loadbalancer.NewClientConn(target,
+		loadbalancer.WithDefaultCallTimeout(10*time.Second),
+		loadbalancer.WithSubsetSize(cfg.GRPCSubsetSize),
+	)
 	if err != nil {
 		log.Fatalf("connect: %v", err)
 	}
@@ -55,6 +75,13 @@ func main() {
 	// All demos share one client — the balancer distributes RPCs internally
 	client := pb.NewShardingServiceClient(conn)
 
+	// Application-level liveness: the server is up and can reach MongoDB
+	if pong, err := client.Ping(ctx, &pb.PingRequest{}); err != nil {
+		log.Printf("[WARN] Ping: %v", err)
+	} else {
+		log.Printf("[OK] Ping: server uptime=%ds mongo latency=%dµs", pong.UptimeSecs, pong.MongoLatencyUs)
+	}
+
 	// Demo 1: Unary InsertDocument
 	log.Println("")
 	log.Println("=== Demo 1: Unary InsertDocument ===")
@@ -74,11 +101,13 @@ func main() {
 			Collection: collection,
 			Payload:    payload,
 		},
+		ReturnRouting: true,
 	})
 	if err != nil {
 		log.Printf("  [ERROR] InsertDocument: %v", err)
 	} else {
 		log.Printf("  Inserted: id=%s latency=%dµs", insertResp.InsertedId, insertResp.LatencyUs)
+		log.Printf("  Routing: shard=%s chunk=[%s, %s)", insertResp.Shard, insertResp.ChunkMin, insertResp.ChunkMax)
 	}
 
 	// Demo 2: Unary QueryDocuments
@@ -92,12 +121,16 @@ func main() {
 		Collection: collection,
 		Filter:     filter,
 		Limit:      10,
+		BatchSize:  10, // one cursor round trip for the whole limit
+		Explain:    true,
 	})
 	if err != nil {
 		log.Printf("  [ERROR] QueryDocuments: %v", err)
 	} else {
 		log.Printf("  Found: %d documents (total=%d) latency=%dµs",
 			len(queryResp.Documents), queryResp.TotalCount, queryResp.LatencyUs)
+		log.Printf("  Routing: shards=%d targeted=%q scatter_gather=%v",
+			queryResp.ShardsTouched, queryResp.TargetedShard, queryResp.ScatterGather)
 		for _, d := range queryResp.Documents {
 			log.Printf("    id=%s payload=%d bytes", d.Id, len(d.Payload))
 		}
@@ -144,49 +177,30 @@ func main() {
 		if err != nil {
 			log.Printf("  [ERROR] BulkInsert response: %v", err)
 		} else {
-			log.Printf("  Result: %d inserted in %d batches, latency=%dµs",
-				bulkResp.TotalInserted, bulkResp.BatchesReceived, bulkResp.TotalLatencyUs)
+			log.Printf("  Result: %d inserted in %d batches (%d writes), latency=%dµs",
+				bulkResp.TotalInserted, bulkResp.BatchesReceived, bulkResp.SubBatches, bulkResp.TotalLatencyUs)
 		}
 	}
 
 	// Demo 4: Bidirectional Streaming WatchUpdates
 	log.Println("")
 	log.Println("=== Demo 4: Bidi-Streaming WatchUpdates ===")
-	log.Println("Starting change stream watcher (5 second window)...")
+	source := pb.WatchRequest_CHANGE_STREAM
+	if *oplogFlag {
+		source = pb.WatchRequest_OPLOG
+	}
+	log.Printf("Starting %s watcher (5 second window)...", strings.ToLower(source.String()))
 
 	watchCtx, watchCancel := context.WithTimeout(ctx, 5*time.Second)
 	defer watchCancel()
 
-	watchStream, err := client.WatchUpdates(watchCtx)
-	if err != nil {
-		log.Printf("  [ERROR] WatchUpdates stream: %v", err)
-	} else {
-		if err := watchStream.Send(&pb.WatchRequest{
-			Database:        database,
-			Collection:      collection,
-			OperationFilter: pb.WatchRequest_INSERT,
-		}); err != nil {
-			log.Printf("  [ERROR] send watch request: %v", err)
-		} else {
-			log.Println("  Watch filter sent: INSERT operations only")
-			log.Println("  Listening for events (5s)...")
-
-			eventCount := 0
-			for {
-				event, err := watchStream.Recv()
-				if err != nil {
-					break
-				}
-				eventCount++
-				log.Printf("    Event: op=%s id=%s payload=%d bytes",
-					event.Operation, event.DocumentId, len(event.FullDocument))
-				if eventCount >= 10 {
-					break
-				}
-			}
-			log.Printf("  Received %d events", eventCount)
-		}
-	}
+	eventCount := watchWithReconnect(watchCtx, client, &pb.WatchRequest{
+		Database:        database,
+		Collection:      collection,
+		OperationFilter: pb.WatchRequest_INSERT,
+		Source:          source,
+	}, 10)
+	log.Printf("  Received %d events", eventCount)
 
 	// Demo 5: Parallel RPCs to demonstrate round-robin distribution
 	log.Println("")
@@ -203,9 +217,12 @@ func main() {
 				Id: id, Database: database, Collection: collection, Payload: raw,
 			},
 		})
-		if err != nil {
+		switch {
+		case status.Code(err) == codes.AlreadyExists:
+			log.Printf("  [%02d] already inserted (re-run): %s", i, status.Convert(err).Message())
+		case err != nil:
 			log.Printf("  [%02d] ERROR: %v", i, err)
-		} else {
+		default:
 			log.Printf("  [%02d] id=%s latency=%dµs", i, resp.InsertedId, resp.LatencyUs)
 		}
 	}
@@ -215,6 +232,80 @@ func main() {
 	os.Exit(0)
 }
 
+// Reconnect backoff for watchWithReconnect: it doubles after every failed
+// attempt up to the cap, and starts over once an event arrives.
+const (
+	watchMinBackoff = 500 * time.Millisecond
+	watchMaxBackoff = 10 * time.Second
+)
+
+// watchWithReconnect consumes WatchUpdates until ctx ends or maxEvents
+// arrive. When the stream drops (e.g. a server pod is rolled), it reopens
+// the stream through the load balancer — which picks a healthy backend —
+// and resumes after the last received event using its resume token, so
+// no change is missed or delivered twice.
+func watchWithReconnect(ctx context.Context, client pb.ShardingServiceClient, req *pb.WatchRequest, maxEvents int) int {
+	eventCount := 0
+	backoff := watchMinBackoff
+
+	// retry waits out the current backoff and doubles it, up to the cap
+	retry := func() {
+		timeutil.Sleep(ctx, backoff)
+		backoff = min(2*backoff, watchMaxBackoff)
+	}
+
+	for ctx.Err() == nil && eventCount < maxEvents {
+		stream, err := client.WatchUpdates(ctx)
+		if err == nil {
+			err = stream.Send(req)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("  [WARN] open watch stream: %v (retrying in %v)", err, backoff)
+			retry()
+			continue
+		}
+
+		if len(req.ResumeAfter) > 0 {
+			log.Println("  [OK] Watch stream reopened from resume token")
+		} else {
+			log.Println("  Watch filter sent: INSERT operations only")
+			log.Println("  Listening for events (5s)...")
+		}
+
+		for eventCount < maxEvents {
+			event, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("  [WARN] watch stream dropped: %v — reconnecting in %v", err, backoff)
+				}
+				break
+			}
+			eventCount++
+			backoff = watchMinBackoff
+			req.ResumeAfter = event.ResumeToken
+			log.Printf("    Event: op=%s ns=%s.%s id=%s payload=%d bytes%s",
+				event.Operation, event.Database, event.Collection, event.DocumentId, len(event.FullDocument), shardSuffix(event.Shard))
+		}
+		stream.CloseSend()
+		if eventCount < maxEvents {
+			retry()
+		}
+	}
+	return eventCount
+}
+
+// shardSuffix labels an event with its source shard when the server
+// reports one (the oplog source does).
+func shardSuffix(shard string) string {
+	if shard == "" {
+		return ""
+	}
+	return " shard=" + shard
+}
+
 // Legacy GRPCPool has been replaced by client-side load balancing.
 // The round-robin balancer + DNS/static resolver distributes RPCs across
 // all backend pods automatically, without maintaining separate connections manually.
@@ -5,18 +5,17 @@ import (
 	"net"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"go.mongodb.org/mongo-driver/event"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 
+	"go-mongodb-sharding-poc/internal/cluster"
 	"go-mongodb-sharding-poc/internal/config"
 	"go-mongodb-sharding-poc/internal/grpcserver"
 	"go-mongodb-sharding-poc/internal/loadbalancer"
@@ -25,58 +24,68 @@ import (
 
 const grpcPort = ":50051"
 
+// healthDrainGrace is how long the server reports NOT_SERVING before
+// GracefulStop, giving clients time to evict it via Health/Watch.
+const healthDrainGrace = 2 * time.Second
+
+// poolStatsInterval is how often the connection pool summary is logged.
+const poolStatsInterval = time.Minute
+
 func main() {
 	log.SetFlags(log.Ltime)
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// MongoDB connection pool monitor — logs creation/close events to detect churn
-	poolMonitor := &event.PoolMonitor{
-		Event: func(e *event.PoolEvent) {
-			switch e.Type {
-			case event.ConnectionCreated:
-				log.Printf("[pool] connection created (addr=%s)", e.Address)
-			case event.ConnectionClosed:
-				log.Printf("[pool] connection closed (addr=%s reason=%s)", e.Address, e.Reason)
-			case event.PoolReady:
-				log.Printf("[pool] pool ready (addr=%s)", e.Address)
-			}
-		},
-	}
+	// MongoDB connection pool monitor — counts checkouts and their wait times,
+	// logs churn, failed checkouts (pool pressure) and clears (failover)
+	poolStats := cluster.NewPoolStats()
+	poolMonitor := poolStats.Monitor(func(e *event.PoolEvent) {
+		switch e.Type {
+		case event.ConnectionCreated:
+			log.Printf("[pool] connection created (addr=%s)", e.Address)
+		case event.ConnectionClosed:
+			log.Printf("[pool] connection closed (addr=%s reason=%s)", e.Address, e.Reason)
+		case event.PoolReady:
+			log.Printf("[pool] pool ready (addr=%s)", e.Address)
+		case event.GetFailed:
+			log.Printf("[pool] [WARN] checkout failed (addr=%s reason=%s wait=%v)", e.Address, e.Reason, e.Duration.Round(time.Millisecond))
+		case event.PoolCleared:
+			log.Printf("[pool] [WARN] pool cleared (addr=%s err=%v) — server marked unknown, likely a failover", e.Address, e.Error)
+		}
+	})
 
 	// Connect to both mongos routers for load distribution
-	mongosAddrs := strings.Join(cfg.MongosHosts, ",")
+	mongosAddrs := cfg.MongosSeedList()
 	uri := "mongodb://" + cfg.AdminUser + ":" + cfg.AdminPassword + "@" + mongosAddrs + "/?authSource=admin"
 
-	mongoOpts := options.Client().
-		ApplyURI(uri).
-		SetMinPoolSize(100).                        // Pre-warm 100 connections — eliminates latency spikes
-		SetMaxPoolSize(500).                        // Headroom for traffic bursts
-		SetMaxConnIdleTime(5 * time.Minute).        // Reclaim stale connections
+	// Pre-warmed pool with headroom for bursts (see cluster.ClientTuning.WithPool)
+	tuning := cluster.NewClientTuning(cfg).WithPool()
+	mongoOpts := tuning.Apply(options.Client().ApplyURI(uri)).
 		SetCompressors([]string{"zstd", "snappy"}). // Compress wire protocol traffic
-		SetTimeout(30 * time.Second).
 		SetPoolMonitor(poolMonitor)
 
-	mongoClient, err := mongo.Connect(ctx, mongoOpts)
+	// Retry while mongos is still starting (e.g. in the same compose/k8s rollout)
+	mongoClient, err := cluster.ConnectWithRetry(ctx, mongoOpts, cluster.DefaultConnectWait)
 	if err != nil {
 		log.Fatalf("MongoDB connect: %v", err)
 	}
-	if err := mongoClient.Ping(ctx, nil); err != nil {
-		log.Fatalf("MongoDB ping: %v", err)
-	}
 	log.Println("Connected to MongoDB sharded cluster")
 	log.Printf("  mongos routers: %s", mongosAddrs)
-	log.Printf("  pool: min=100 max=500 idle_timeout=5m compressors=zstd,snappy")
+	log.Printf("  pool: min=%d max=%d idle_timeout=%v compressors=zstd,snappy", tuning.MinPoolSize, tuning.MaxPoolSize, tuning.MaxConnIdleTime)
+	log.Printf("  heartbeat=%v server_selection_timeout=%v", tuning.HeartbeatInterval, tuning.ServerSelectionTimeout)
 
 	// gRPC server with high-throughput options
 	grpcServer := grpc.NewServer(
 		// Allow thousands of concurrent RPCs over a single TCP connection
 		grpc.MaxConcurrentStreams(5000),
-		// 16MB max message size for large bulk payloads
-		grpc.MaxRecvMsgSize(16*1024*1024),
-		grpc.MaxSendMsgSize(16*1024*1024),
+		// 16MB max message size for large bulk payloads, as clients send
+		grpc.MaxRecvMsgSize(loadbalancer.MaxMessageSize),
+		grpc.MaxSendMsgSize(loadbalancer.MaxMessageSize),
 		// Keepalive: server-side enforcement to prevent stale connections
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			MaxConnectionIdle:     5 * time.Minute,  // Close idle connections after 5m
@@ -91,13 +100,22 @@ func main() {
 		}),
 	)
 
-	shardingServer := grpcserver.NewServer(mongoClient)
-	pb.RegisterShardingServiceServer(grpcServer, shardingServer)
-	reflection.Register(grpcServer)
-
 	// Health checking — enables client-side LB to detect unhealthy pods
 	// and stop routing RPCs to them automatically
-	loadbalancer.RegisterHealthServer(grpcServer)
+	healthServer := loadbalancer.RegisterHealthServer(grpcServer)
+
+	// Shard credentials let WatchUpdates tail each shard's oplog directly.
+	// The circuit breaker fails RPCs fast while MongoDB is unreachable and
+	// reports NOT_SERVING so clients route to other pods until it recovers.
+	shardingServer := grpcserver.NewServer(mongoClient,
+		grpcserver.WithShardCredentials(cfg.AdminUser, cfg.AdminPassword),
+		grpcserver.WithCircuitBreaker(grpcserver.CircuitBreakerConfig{
+			Failures: cfg.GRPCBreakerFailures,
+			Cooldown: cfg.GRPCBreakerCooldown,
+			OnChange: func(open bool) { loadbalancer.SetServing(healthServer, !open) },
+		}))
+	pb.RegisterShardingServiceServer(grpcServer, shardingServer)
+	reflection.Register(grpcServer)
 
 	// Listen
 	lis, err := net.Listen("tcp", grpcPort)
@@ -106,10 +124,15 @@ func main() {
 	}
 
 	log.Printf("gRPC server listening on %s", grpcPort)
-	log.Println("  MaxConcurrentStreams=5000 MaxMsgSize=16MB")
+	log.Printf("  MaxConcurrentStreams=5000 MaxMsgSize=%dMB", loadbalancer.MaxMessageSize/(1024*1024))
 	log.Println("  Keepalive: idle=5m age=30m ping=60s")
 	log.Println("  Health: grpc.health.v1 registered (client-side LB support)")
-	log.Println("RPCs: InsertDocument, QueryDocuments, BulkInsert, WatchUpdates")
+	if cfg.GRPCBreakerFailures > 0 {
+		log.Printf("  Circuit breaker: open after %d MongoDB failures, probe every %v", cfg.GRPCBreakerFailures, cfg.GRPCBreakerCooldown)
+	}
+	log.Println("RPCs: InsertDocument, QueryDocuments, BulkInsert, WatchUpdates, GetReshardProgress, Ping")
+
+	go logPoolStats(poolStats, poolStatsInterval)
 
 	// Graceful shutdown
 	go func() {
@@ -117,11 +140,54 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 		log.Println("Shutting down gRPC server...")
-		grpcServer.GracefulStop()
+		// Stop the breaker first: a circuit closing mid-drain would
+		// report SERVING again and attract new traffic
+		shardingServer.Close()
+		// Tell watching clients to stop routing here before closing connections
+		loadbalancer.Drain(healthServer, healthDrainGrace)
+		stopWithTimeout(grpcServer, cfg.GRPCShutdownTimeout)
 		mongoClient.Disconnect(context.Background())
+		logPoolSnapshot(poolStats.Snapshot())
 	}()
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("serve: %v", err)
 	}
 }
+
+// stopWithTimeout stops srv gracefully, letting in-flight RPCs finish, but
+// forcefully closes every connection once timeout passes. GracefulStop
+// alone waits on open streams such as WatchUpdates indefinitely, which
+// would outlast the pod's termination grace period.
+func stopWithTimeout(srv *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("gRPC server stopped gracefully")
+	case <-time.After(timeout):
+		log.Printf("[WARN] Graceful stop exceeded %v; forcing stop (open streams are cancelled)", timeout)
+		srv.Stop()
+		<-done
+	}
+}
+
+// logPoolStats logs a connection pool summary every interval.
+func logPoolStats(stats *cluster.PoolStats, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		logPoolSnapshot(stats.Snapshot())
+	}
+}
+
+func logPoolSnapshot(s cluster.PoolSnapshot) {
+	log.Printf("[pool] checkouts=%d ok=%d failed=%d wait avg=%v p99=%v max=%v created=%d closed=%d cleared=%d",
+		s.CheckOutStarted, s.CheckedOut, s.FailedTotal(),
+		s.WaitAvg.Round(time.Microsecond), s.WaitP99.Round(time.Microsecond), s.WaitMax.Round(time.Millisecond),
+		s.Created, s.Closed, s.Cleared)
+}
@@ -2,66 +2,109 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"go-mongodb-sharding-poc/internal/cluster"
 	"go-mongodb-sharding-poc/internal/config"
 	"go-mongodb-sharding-poc/internal/ha"
+	"go-mongodb-sharding-poc/internal/runner"
+	"go-mongodb-sharding-poc/internal/sharding"
 )
 
 func main() {
 	log.SetFlags(log.Ltime)
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failoverColl := flag.String("failover-collection", ha.DefaultFailoverCollection, "collection the shard failover test drops, pins to shard1rs and verifies")
+	captureWrites := flag.Bool("capture-writes", false, "write continuously through the shard failover test, recording every attempt, its error details, and in-flight $currentOp, and print the timeline")
+	scale := flag.Float64("scale", cfg.DemoScale, "multiply every lab's document count (0.1 = quick smoke test, 100 = realistic scale)")
+	flag.Parse()
+	docScale := sharding.Scale(*scale)
+	if docScale != 1 {
+		log.Printf("Document counts scaled by %gx", *scale)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
+	// Ctrl-C cancels the labs instead of killing the process, so each lab's
+	// deferred node restore still runs
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
 
 	log.Println("MongoDB Sharding POC - HA Failure Scenario Labs")
 	log.Println("")
-	log.Println("WARNING: These tests will stop and start Docker containers.")
-	log.Println("         All containers will be restored after each test.")
+	nodes, err := ha.NewNodeController(cfg.HABackend, cfg.ContainerPrefix, cfg.K8sNamespace)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("WARNING: These tests will stop and start cluster nodes (%s backend).", cfg.HABackend)
+	log.Println("         All nodes will be restored after each test.")
 	log.Println("")
 
-	adminClient := connectWithAuth(ctx, cfg.MongosHosts[0], cfg.AdminUser, cfg.AdminPassword, "admin")
+	// Fast heartbeats and server selection so the labs observe elections
+	// and rejected operations within seconds
+	tuning := cluster.NewClientTuning(cfg).ForFailover()
+	log.Printf("Driver: heartbeat %v, server selection timeout %v", tuning.HeartbeatInterval, tuning.ServerSelectionTimeout)
+
+	adminClient, err := connectWithAuth(ctx, cfg.MongosHosts, cfg.AdminUser, cfg.AdminPassword, "admin", tuning)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer adminClient.Disconnect(ctx)
 
-	appClient := connectWithAuth(ctx, cfg.MongosHosts[0], cfg.AppUser, cfg.AppPassword, cfg.AppDatabase)
+	appClient, err := connectWithAuth(ctx, cfg.MongosHosts, cfg.AppUser, cfg.AppPassword, cfg.AppDatabase, tuning)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer appClient.Disconnect(ctx)
 
-	runLab("Shard Failover", func() error {
-		return ha.RunShardFailoverTest(ctx, appClient, cfg.AppDatabase)
+	labs := runner.Results{Kind: "lab"}
+	var failoverEvents *ha.FailoverLog
+	if *captureWrites {
+		failoverEvents = &ha.FailoverLog{}
+	}
+	labs.Run("Shard Failover", func() error {
+		return ha.RunShardFailoverTest(ctx, adminClient, appClient, cfg.AppDatabase, *failoverColl, nodes, failoverEvents)
 	})
+	if failoverEvents != nil {
+		log.Println("")
+		failoverEvents.PrintTimeline()
+		log.Println("")
+	}
 
-	runLab("Config Server Outage", func() error {
-		return ha.RunConfigServerOutageTest(ctx, appClient, cfg.AppDatabase)
+	labs.Run("Config Server Outage", func() error {
+		return ha.RunConfigServerOutageTest(ctx, appClient, cfg.AppDatabase, nodes, docScale)
 	})
 
-	runLab("Jumbo Chunk Analysis", func() error {
-		return ha.RunJumboChunkAnalysis(ctx, adminClient, appClient, cfg.AppDatabase)
+	labs.Run("Jumbo Chunk Analysis", func() error {
+		return ha.RunJumboChunkAnalysis(ctx, adminClient, appClient, cfg.AppDatabase, cfg.DocPaddingBytes, docScale)
 	})
 
 	log.Println("All HA labs complete")
-	os.Exit(0)
+	os.Exit(labs.Summarize())
 }
 
-func connectWithAuth(ctx context.Context, host, user, password, authDB string) *mongo.Client {
-	uri := "mongodb://" + user + ":" + password + "@" + host + "/?authSource=" + authDB
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(30*time.Second))
+// connectWithAuth connects through the mongos routers with tuning, retrying
+// while the cluster is still starting.
+func connectWithAuth(ctx context.Context, hosts []string, user, password, authDB string, tuning cluster.ClientTuning) (*mongo.Client, error) {
+	uri, err := config.BuildURI(hosts, user, password, authDB)
 	if err != nil {
-		log.Fatalf("connect as %s: %v", user, err)
+		return nil, err
 	}
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("ping as %s: %v", user, err)
-	}
-	return client
-}
-
-func runLab(name string, fn func() error) {
-	if err := fn(); err != nil {
-		log.Printf("[ERROR] %s lab failed: %v", name, err)
+	client, err := cluster.ConnectWithRetry(ctx, tuning.Apply(options.Client().ApplyURI(uri)), cluster.DefaultConnectWait)
+	if err != nil {
+		return nil, fmt.Errorf("connect as %s: %w", user, err)
 	}
+	return client, nil
 }
@@ -2,67 +2,118 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/tag"
 
+	"go-mongodb-sharding-poc/internal/cluster"
 	"go-mongodb-sharding-poc/internal/config"
 	"go-mongodb-sharding-poc/internal/operations"
+	"go-mongodb-sharding-poc/internal/runner"
+	"go-mongodb-sharding-poc/internal/sharding"
 )
 
 func main() {
 	log.SetFlags(log.Ltime)
 
-	cfg := config.Load()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scale := flag.Float64("scale", cfg.DemoScale, "multiply every lab's document count (0.1 = quick smoke test, 100 = realistic scale)")
+	flag.Parse()
+	docScale := sharding.Scale(*scale)
+	if docScale != 1 {
+		log.Printf("Document counts scaled by %gx", *scale)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
 	defer cancel()
 
 	log.Println("MongoDB Sharding POC - Operational Labs")
 
-	adminClient := connectWithAuth(ctx, cfg.MongosHosts[0], cfg.AdminUser, cfg.AdminPassword, "admin")
+	tuning := cluster.NewClientTuning(cfg).WithPool()
+
+	adminClient, err := connectWithAuth(ctx, cfg.MongosHosts, cfg.AdminUser, cfg.AdminPassword, "admin", tuning)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer adminClient.Disconnect(ctx)
 
-	appClient := connectWithAuth(ctx, cfg.MongosHosts[0], cfg.AppUser, cfg.AppPassword, cfg.AppDatabase)
+	appClient, err := connectWithAuth(ctx, cfg.MongosHosts, cfg.AppUser, cfg.AppPassword, cfg.AppDatabase, tuning)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer appClient.Disconnect(ctx)
 
-	runLab("Balancer", func() error {
-		return operations.RunBalancerLab(ctx, adminClient)
+	labs := runner.Results{Kind: "lab"}
+	labs.Run("Balancer", func() error {
+		return operations.RunBalancerLab(ctx, adminClient, cfg.AppDatabase, docScale)
+	})
+
+	labs.Run("Per-Collection Balancing", func() error {
+		return operations.RunCollectionBalancingLab(ctx, adminClient, cfg.AppDatabase)
+	})
+
+	labs.Run("Chunk Management", func() error {
+		return operations.RunChunkLab(ctx, adminClient, appClient, cfg.AppDatabase, cfg.DocPaddingBytes, docScale, nil)
+	})
+
+	labs.Run("Storage Reclaim", func() error {
+		return operations.RunStorageReclaimLab(ctx, adminClient, appClient, cfg.AppDatabase, cfg.AdminUser, cfg.AdminPassword)
+	})
+
+	labs.Run("Defragmentation", func() error {
+		return operations.RunDefragmentationLab(ctx, adminClient, appClient, cfg.AppDatabase, docScale)
+	})
+
+	labs.Run("Move Primary", func() error {
+		return operations.RunMovePrimaryLab(ctx, adminClient, cfg.AppDatabase, docScale)
+	})
+
+	labs.Run("Hedged Reads", func() error {
+		return operations.RunHedgedReadsLab(ctx, cfg.MongosSeedList(), cfg.AdminUser, cfg.AdminPassword, cfg.AppDatabase, docScale)
 	})
 
-	runLab("Chunk Management", func() error {
-		return operations.RunChunkLab(ctx, adminClient, appClient, cfg.AppDatabase)
+	labs.Run("Retryable Reads", func() error {
+		return operations.RunRetryableReadsLab(ctx, cfg.Shards[0], cfg.AdminUser, cfg.AdminPassword)
 	})
 
-	runLab("Hedged Reads", func() error {
-		return operations.RunHedgedReadsLab(ctx, cfg.MongosHosts[0], cfg.AdminUser, cfg.AdminPassword, cfg.AppDatabase)
+	labs.Run("Add Shard & Rebalance", func() error {
+		return operations.RunAddShardLab(ctx, adminClient, cfg.AppDatabase, cfg.SpareShard, cfg.AdminUser, cfg.AdminPassword, docScale)
+	})
+
+	labs.Run("Remove Shard & Drain", func() error {
+		return operations.RunRemoveShardLab(ctx, adminClient, cfg.AppDatabase, cfg.SpareShard.Name, docScale)
+	})
+
+	labs.Run("Tag-Set Reads", func() error {
+		tags := tag.Set{{Name: "dc", Value: "west"}}
+		return operations.RunTaggedReadsLab(ctx, cfg.MongosSeedList(), cfg.AdminUser, cfg.AdminPassword,
+			cfg.AppDatabase, tags, cfg.ShardHostsWithTag("dc", "west"), docScale)
 	})
 
 	log.Println("All operational labs complete")
-	os.Exit(0)
+	os.Exit(labs.Summarize())
 }
 
-func connectWithAuth(ctx context.Context, host, user, password, authDB string) *mongo.Client {
-	uri := "mongodb://" + user + ":" + password + "@" + host + "/?authSource=" + authDB
-	client, err := mongo.Connect(ctx, options.Client().
-		ApplyURI(uri).
-		SetMinPoolSize(100).
-		SetMaxPoolSize(500).
-		SetMaxConnIdleTime(5*time.Minute).
-		SetTimeout(30*time.Second))
+// connectWithAuth connects through the mongos routers with tuning, retrying
+// while the cluster is still starting.
+func connectWithAuth(ctx context.Context, hosts []string, user, password, authDB string, tuning cluster.ClientTuning) (*mongo.Client, error) {
+	uri, err := config.BuildURI(hosts, user, password, authDB)
 	if err != nil {
-		log.Fatalf("connect as %s: %v", user, err)
-	}
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("ping as %s: %v", user, err)
+		return nil, err
 	}
-	return client
-}
-
-func runLab(name string, fn func() error) {
-	if err := fn(); err != nil {
-		log.Printf("[ERROR] %s lab failed: %v", name, err)
+	client, err := cluster.ConnectWithRetry(ctx, tuning.Apply(options.Client().ApplyURI(uri)), cluster.DefaultConnectWait)
+	if err != nil {
+		return nil, fmt.Errorf("connect as %s: %w", user, err)
 	}
+	return client, nil
 }
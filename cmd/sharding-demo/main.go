@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -9,63 +12,187 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"go-mongodb-sharding-poc/internal/cluster"
 	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/runner"
 	"go-mongodb-sharding-poc/internal/sharding"
 )
 
 func main() {
 	log.SetFlags(log.Ltime)
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	demoList := flag.String("demos", "all", "comma-separated demos to run: hashed,ranged,compound,refinable,zones,reshard,copyswap,timeseries,unique,changestream")
+	database := flag.String("db", cfg.AppDatabase, "database the demos write to")
+	collection := flag.String("collection", "", "collection name override (only with a single demo)")
+	cleanup := flag.Bool("cleanup", false, "drop the selected demos' collections and sharding metadata, then exit")
+	jsonOut := flag.Bool("json", false, "print demo results as JSON on stdout (logs stay on stderr)")
+	scale := flag.Float64("scale", cfg.DemoScale, "multiply every demo's document count (0.1 = quick smoke test, 100 = realistic scale)")
+	zoneLatency := flag.Duration("zone-latency", 0, "simulate this cross-region delay on finds to non-EU shards during the zones demo (needs enableTestCommands)")
+	flag.Parse()
+	docScale := sharding.Scale(*scale)
+	if docScale != 1 {
+		log.Printf("Document counts scaled by %gx", *scale)
+	}
+
+	selected, err := sharding.SelectDemos(*demoList)
+	if err != nil {
+		log.Fatalf("-demos: %v", err)
+	}
+	if *collection != "" && len(selected) != 1 {
+		log.Fatalf("-collection requires exactly one demo in -demos (got %d)", len(selected))
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
 	log.Println("MongoDB Sharding POC - Sharding Strategy Demos")
 
-	adminClient := connectWithAuth(ctx, cfg.MongosHosts[0], cfg.AdminUser, cfg.AdminPassword, "admin")
+	tuning := cluster.NewClientTuning(cfg)
+	adminClient, err := connectWithAuth(ctx, cfg.MongosHosts, cfg.AdminUser, cfg.AdminPassword, "admin", tuning)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer adminClient.Disconnect(ctx)
 
-	appClient := connectWithAuth(ctx, cfg.MongosHosts[0], cfg.AppUser, cfg.AppPassword, cfg.AppDatabase)
-	defer appClient.Disconnect(ctx)
-
-	runDemo("Hashed", func() error {
-		return sharding.RunHashedDemo(ctx, adminClient, appClient, cfg.AppDatabase)
-	})
+	if *cleanup {
+		runCleanup(ctx, adminClient, selected, *database, *collection)
+		return
+	}
 
-	runDemo("Ranged", func() error {
-		return sharding.RunRangedDemo(ctx, adminClient, appClient, cfg.AppDatabase)
-	})
+	appClient, err := connectWithAuth(ctx, cfg.MongosHosts, cfg.AppUser, cfg.AppPassword, cfg.AppDatabase, tuning)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer appClient.Disconnect(ctx)
 
-	runDemo("Compound", func() error {
-		return sharding.RunCompoundDemo(ctx, adminClient, appClient, cfg.AppDatabase)
-	})
+	var results []*sharding.DemoResult
+	demos := runner.Results{Kind: "demo"}
+	for _, demo := range selected {
+		coll := demo.Collection
+		if *collection != "" {
+			coll = *collection
+		}
+		demos.Run(demo.Title, func() error {
+			if demo.Name == "zones" && *zoneLatency > 0 {
+				defer injectZoneLatency(ctx, cfg, *zoneLatency)()
+			}
+			result, err := demo.Run(ctx, adminClient, appClient, *database, coll, docScale)
+			if result != nil {
+				if err != nil {
+					result.Error = err.Error()
+				}
+				results = append(results, result)
+			}
+			return err
+		})
+	}
 
-	runDemo("Refinable", func() error {
-		return sharding.RunRefinableDemo(ctx, adminClient, appClient, cfg.AppDatabase)
-	})
+	log.Println("")
+	log.Println("=== Mongos Query Targeting (since mongos start) ===")
+	if stats, err := sharding.GetMongosStats(ctx, adminClient); err != nil {
+		log.Printf("  [WARN] %v", err)
+	} else {
+		sharding.PrintMongosStats(stats)
+	}
 
-	runDemo("Zone-Based", func() error {
-		return sharding.RunZoneDemo(ctx, adminClient, appClient, cfg.AppDatabase)
-	})
+	if *jsonOut {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("marshal demo results: %v", err)
+		}
+		fmt.Println(string(out))
+	}
 
 	log.Println("All demos complete")
-	os.Exit(0)
+	os.Exit(demos.Summarize())
 }
 
-func connectWithAuth(ctx context.Context, host, user, password, authDB string) *mongo.Client {
-	uri := "mongodb://" + user + ":" + password + "@" + host + "/?authSource=" + authDB
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(30*time.Second))
-	if err != nil {
-		log.Fatalf("connect as %s: %v", user, err)
+// runCleanup drops each selected demo's collection so the cluster can be
+// reset between runs.
+func runCleanup(ctx context.Context, adminClient *mongo.Client, demos []sharding.Demo, db, collection string) {
+	log.Println("=== Demo Cleanup ===")
+	failed := 0
+	for _, demo := range demos {
+		coll := demo.Collection
+		if collection != "" {
+			coll = collection
+		}
+		if err := sharding.CleanupDemo(ctx, adminClient, db, coll); err != nil {
+			log.Printf("  [WARN] %s: %v", demo.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("  [OK] Dropped %s.%s", db, coll)
+	}
+	if failed > 0 {
+		log.Fatalf("Cleanup failed for %d of %d demos", failed, len(demos))
 	}
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("ping as %s: %v", user, err)
+	log.Println("Cleanup complete")
+}
+
+// injectZoneLatency simulates a cross-region network path from an EU
+// client to every shard outside the EU zone: a failCommand fail point on
+// each shard's primary delays its finds by delay. It connects to the shards
+// directly, since mongos does not run test commands. The returned func
+// removes the fail points. Without test commands, the same effect comes
+// from a netem delay inside each non-EU shard container, e.g.
+// "tc qdisc add dev eth0 root netem delay 80ms".
+func injectZoneLatency(ctx context.Context, cfg *config.ClusterConfig, delay time.Duration) func() {
+	var clients []*mongo.Client
+	for _, shard := range cfg.Shards {
+		if shard.Name == sharding.EUZoneShard {
+			continue
+		}
+		hosts := make([]string, len(shard.Members))
+		for i, m := range shard.Members {
+			hosts[i] = m.Addr()
+		}
+		uri, err := config.BuildURI(hosts, cfg.AdminUser, cfg.AdminPassword, "admin")
+		if err != nil {
+			log.Printf("[WARN] zone latency on %s: %v", shard.Name, err)
+			continue
+		}
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).
+			SetReplicaSet(shard.Name).
+			SetTimeout(30*time.Second))
+		if err != nil {
+			log.Printf("[WARN] zone latency on %s: %v", shard.Name, err)
+			continue
+		}
+		if err := cluster.InjectCommandLatency(ctx, client, []string{"find"}, delay); err != nil {
+			client.Disconnect(ctx)
+			log.Printf("[SKIP] zone latency on %s: %v", shard.Name, err)
+			continue
+		}
+		log.Printf("[INFO] Simulating %v cross-region latency on %s finds", delay, shard.Name)
+		clients = append(clients, client)
+	}
+
+	return func() {
+		for _, client := range clients {
+			if err := cluster.ClearFailPoint(ctx, client, "failCommand"); err != nil {
+				log.Printf("[WARN] %v", err)
+			}
+			client.Disconnect(ctx)
+		}
 	}
-	return client
 }
 
-func runDemo(name string, fn func() error) {
-	if err := fn(); err != nil {
-		log.Printf("[ERROR] %s demo failed: %v", name, err)
+// connectWithAuth connects through the mongos routers with tuning, retrying
+// while the cluster is still starting.
+func connectWithAuth(ctx context.Context, hosts []string, user, password, authDB string, tuning cluster.ClientTuning) (*mongo.Client, error) {
+	uri, err := config.BuildURI(hosts, user, password, authDB)
+	if err != nil {
+		return nil, err
+	}
+	client, err := cluster.ConnectWithRetry(ctx, tuning.Apply(options.Client().ApplyURI(uri)), cluster.DefaultConnectWait)
+	if err != nil {
+		return nil, fmt.Errorf("connect as %s: %w", user, err)
 	}
+	return client, nil
 }
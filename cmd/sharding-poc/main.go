@@ -2,25 +2,51 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/errgroup"
 
 	"go-mongodb-sharding-poc/internal/cluster"
 	"go-mongodb-sharding-poc/internal/config"
 	"go-mongodb-sharding-poc/internal/security"
 )
 
+const (
+	// maxParallelHostChecks bounds concurrent node readiness pings.
+	maxParallelHostChecks = 8
+
+	// maxParallelShardInit bounds concurrent shard replica set initializations.
+	maxParallelShardInit = 4
+)
+
 func main() {
 	log.SetFlags(log.Ltime)
 
-	cfg := config.Load()
+	jsonOut := flag.Bool("json", false, "print the final cluster status as JSON on stdout instead of the formatted report")
+	verifyOnly := flag.Bool("verify", false, "skip setup and only verify an already-configured cluster; exits non-zero on failure")
+	configFile := flag.String("config", "", "read settings from this .env-style file; environment variables still take precedence")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
+	if *verifyOnly {
+		os.Exit(runVerify(ctx, cfg, *jsonOut))
+	}
+
 	log.Println("MongoDB Sharding POC - Cluster Setup")
 
 	waitForAllNodes(ctx, cfg)
@@ -31,24 +57,100 @@ func main() {
 	registerShards(ctx, cfg, mongosClient)
 	enableDatabaseSharding(ctx, cfg, mongosClient)
 	createRBACUsers(ctx, cfg, mongosClient)
-	verifyCluster(ctx, cfg, mongosClient)
-	verifyRBAC(ctx, cfg)
-	verifyMongosFailover(ctx, cfg)
-	printConnectionInfo(cfg)
+	status, verifyErr := runChecks(ctx, cfg, mongosClient, !*jsonOut)
+
+	// Keep stdout clean for JSON consumers
+	if *jsonOut {
+		printConnectionInfo(os.Stderr, cfg)
+		printStatusJSON(status)
+	} else {
+		printConnectionInfo(os.Stdout, cfg)
+	}
+
+	os.Exit(summarize(verifyErr))
+}
+
+// loadConfig reads the cluster config from path, or from the environment
+// alone when path is empty.
+func loadConfig(path string) (*config.ClusterConfig, error) {
+	if path == "" {
+		return config.Load()
+	}
+	return config.LoadFromFile(path)
+}
 
-	os.Exit(0)
+// runVerify checks an already set-up cluster without changing it, for use
+// as a CI smoke test or readiness probe. It returns the process exit code.
+func runVerify(ctx context.Context, cfg *config.ClusterConfig, jsonOut bool) int {
+	log.Println("MongoDB Sharding POC - Cluster Verification")
+
+	mongosClient := connectToMongos(ctx, cfg)
+	defer mongosClient.Disconnect(ctx)
+
+	status, verifyErr := runChecks(ctx, cfg, mongosClient, !jsonOut)
+	if jsonOut && status != nil {
+		printStatusJSON(status)
+	}
+	return summarize(verifyErr)
+}
+
+// runChecks runs every verification step, even after one fails, and
+// returns the cluster status alongside all failures joined together.
+func runChecks(ctx context.Context, cfg *config.ClusterConfig, client *mongo.Client, report bool) (*cluster.ClusterStatus, error) {
+	status, clusterErr := verifyCluster(ctx, cfg, client, report)
+	configErr := verifyConfigServer(ctx, cfg)
+	rbacErr := verifyRBAC(ctx, cfg)
+	failoverErr := verifyMongosFailover(ctx, cfg)
+	return status, errors.Join(clusterErr, configErr, rbacErr, failoverErr)
+}
+
+// summarize logs a final verdict for verifyErr and returns the exit code:
+// 0 when every check passed, 1 otherwise.
+func summarize(verifyErr error) int {
+	if verifyErr == nil {
+		log.Println("[OK] All verification checks passed")
+		return 0
+	}
+	failures := flattenErrors(verifyErr)
+	log.Printf("[FAIL] %d verification check(s) failed:", len(failures))
+	for _, err := range failures {
+		log.Printf("  - %v", err)
+	}
+	return 1
+}
+
+// flattenErrors expands errors.Join trees into their individual failures.
+func flattenErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var out []error
+	for _, e := range joined.Unwrap() {
+		out = append(out, flattenErrors(e)...)
+	}
+	return out
 }
 
 func waitForAllNodes(ctx context.Context, cfg *config.ClusterConfig) {
 	log.Println("Waiting for all nodes...")
-	for _, m := range cfg.ConfigRS.Members {
-		must(cluster.WaitForHost(ctx, m.Addr(), 60*time.Second), m.Addr())
-	}
+	members := append([]config.Member{}, cfg.ConfigRS.Members...)
 	for _, shard := range cfg.Shards {
-		for _, m := range shard.Members {
-			must(cluster.WaitForHost(ctx, m.Addr(), 60*time.Second), m.Addr())
-		}
+		members = append(members, shard.Members...)
+	}
+
+	// Each host keeps its own 60s timeout; the first failure cancels the rest
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxParallelHostChecks)
+	for _, m := range members {
+		g.Go(func() error {
+			if err := cluster.WaitForHost(gctx, m.Addr(), 60*time.Second); err != nil {
+				return fmt.Errorf("%s: %w", m.Addr(), err)
+			}
+			return nil
+		})
 	}
+	must(g.Wait(), "wait for nodes")
 }
 
 func initAllReplicaSets(ctx context.Context, cfg *config.ClusterConfig) {
@@ -56,11 +158,28 @@ func initAllReplicaSets(ctx context.Context, cfg *config.ClusterConfig) {
 	must(cluster.InitReplicaSet(ctx, cfg.ConfigRS.Name, cfg.ConfigRS.Members, true), "init "+cfg.ConfigRS.Name)
 	must(cluster.WaitForPrimary(ctx, cfg.ConfigRS.Members[0].Addr(), 60*time.Second), "primary "+cfg.ConfigRS.Name)
 
+	// Shards are independent of each other, so their elections can overlap
 	log.Println("Initializing shard replica sets...")
+	var mu sync.Mutex
+	var errs []error
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxParallelShardInit)
 	for _, shard := range cfg.Shards {
-		must(cluster.InitReplicaSet(ctx, shard.Name, shard.Members, false), "init "+shard.Name)
-		must(cluster.WaitForPrimary(ctx, shard.Members[0].Addr(), 60*time.Second), "primary "+shard.Name)
+		g.Go(func() error {
+			err := cluster.InitReplicaSet(gctx, shard.Name, shard.Members, false)
+			if err == nil {
+				err = cluster.WaitForPrimary(gctx, shard.Members[0].Addr(), 60*time.Second)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", shard.Name, err))
+				mu.Unlock()
+			}
+			return nil
+		})
 	}
+	g.Wait()
+	must(errors.Join(errs...), "init shard replica sets")
 }
 
 func createAdminUsers(ctx context.Context, cfg *config.ClusterConfig) {
@@ -76,7 +195,7 @@ func connectToMongos(ctx context.Context, cfg *config.ClusterConfig) *mongo.Clie
 	for _, host := range cfg.MongosHosts {
 		must(cluster.WaitForHost(ctx, host, 60*time.Second), "mongos "+host)
 	}
-	client, err := cluster.ConnectMongos(ctx, cfg.MongosHosts[0], cfg.AdminUser, cfg.AdminPassword)
+	client, err := cluster.ConnectMongosMulti(ctx, cfg.MongosHosts, cfg.AdminUser, cfg.AdminPassword)
 	if err != nil {
 		log.Fatalf("connect to mongos: %v", err)
 	}
@@ -91,62 +210,130 @@ func registerShards(ctx context.Context, cfg *config.ClusterConfig, client *mong
 }
 
 func enableDatabaseSharding(ctx context.Context, cfg *config.ClusterConfig, client *mongo.Client) {
-	log.Println("Enabling sharding on database...")
-	must(cluster.EnableSharding(ctx, client, cfg.AppDatabase), "enableSharding")
+	for _, db := range cfg.Databases {
+		log.Printf("Enabling sharding on database %s...", db.Name)
+		must(cluster.EnableSharding(ctx, client, db.Name), "enableSharding "+db.Name)
+
+		if len(db.ShardedCollections) > 0 {
+			log.Printf("Sharding %d configured collections in %s...", len(db.ShardedCollections), db.Name)
+			must(cluster.ShardCollections(ctx, client, db.Name, db.ShardedCollections), "shardCollections "+db.Name)
+		}
+	}
 }
 
 func createRBACUsers(ctx context.Context, cfg *config.ClusterConfig, client *mongo.Client) {
-	log.Println("Creating RBAC users...")
-	must(security.CreateAppUser(ctx, client, cfg.AppDatabase, cfg.AppUser, cfg.AppPassword), "create app user")
-	must(security.CreateReadOnlyUser(ctx, client, cfg.AppDatabase, cfg.ReadOnlyUser, cfg.ReadOnlyPassword), "create read-only user")
+	for _, db := range cfg.Databases {
+		log.Printf("Creating RBAC users in %s...", db.Name)
+		must(security.CreateAppUser(ctx, client, db.Name, db.AppUser, db.AppPassword), "create app user in "+db.Name)
+		must(security.CreateReadOnlyUser(ctx, client, db.Name, db.ReadOnlyUser, db.ReadOnlyPassword), "create read-only user in "+db.Name)
+	}
 }
 
-func verifyCluster(ctx context.Context, cfg *config.ClusterConfig, client *mongo.Client) {
+func verifyCluster(ctx context.Context, cfg *config.ClusterConfig, client *mongo.Client, report bool) (*cluster.ClusterStatus, error) {
 	log.Println("Verifying cluster...")
-	must(cluster.VerifyCluster(ctx, client, len(cfg.Shards)), "cluster verification")
+	var failed error
+	if err := cluster.VerifyCluster(ctx, client, len(cfg.Shards)); err != nil {
+		log.Printf("[WARN] cluster verification: %v", err)
+		failed = fmt.Errorf("cluster verification: %w", err)
+	}
 
 	status, err := cluster.GetClusterStatus(ctx, client)
 	if err != nil {
 		log.Printf("[WARN] status: %v", err)
-		return
+		return nil, errors.Join(failed, fmt.Errorf("cluster status: %w", err))
 	}
-	cluster.PrintClusterStatus(status)
+	if report {
+		cluster.PrintClusterStatus(status)
+		// Storage is informational: a full or skewed shard warns but does
+		// not fail verification
+		if storage, err := cluster.ClusterStorageReport(ctx, client); err != nil {
+			log.Printf("[WARN] storage report: %v", err)
+		} else {
+			cluster.PrintStorageReport(storage)
+		}
+	}
+	return status, failed
 }
 
-func verifyRBAC(ctx context.Context, cfg *config.ClusterConfig) {
-	log.Println("Verifying RBAC...")
-	if err := security.VerifyAppUser(ctx, cfg.MongosHosts[0], cfg.AppDatabase, cfg.AppUser, cfg.AppPassword); err != nil {
-		log.Printf("[WARN] app user: %v", err)
+// printStatusJSON writes status to stdout as indented JSON.
+func printStatusJSON(status *cluster.ClusterStatus) {
+	if status == nil {
+		log.Fatal("[FATAL] cluster status unavailable for -json output")
 	}
-	if err := security.VerifyReadOnlyUser(ctx, cfg.MongosHosts[0], cfg.AppDatabase, cfg.ReadOnlyUser, cfg.ReadOnlyPassword); err != nil {
-		log.Printf("[WARN] read-only user: %v", err)
+	out, err := json.MarshalIndent(status, "", "  ")
+	must(err, "marshal cluster status")
+	fmt.Println(string(out))
+}
+
+// verifyConfigServer checks the config server replica set's configsvr flag
+// and health directly, since mongos hides a misconfigured config server
+// until it needs it.
+func verifyConfigServer(ctx context.Context, cfg *config.ClusterConfig) error {
+	log.Println("Verifying config server replica set...")
+	members := make([]string, len(cfg.ConfigRS.Members))
+	for i, m := range cfg.ConfigRS.Members {
+		members[i] = m.Addr()
+	}
+	if _, err := cluster.VerifyConfigServer(ctx, members, cfg.AdminUser, cfg.AdminPassword); err != nil {
+		log.Printf("[WARN] config server: %v", err)
+		return fmt.Errorf("config server: %w", err)
+	}
+	return nil
+}
+
+// verifyRBAC logs each user check that fails and returns them all joined.
+func verifyRBAC(ctx context.Context, cfg *config.ClusterConfig) error {
+	log.Println("Verifying RBAC...")
+	var errs []error
+	for _, db := range cfg.Databases {
+		if err := security.VerifyAppUser(ctx, cfg.MongosSeedList(), db.Name, db.AppUser, db.AppPassword); err != nil {
+			log.Printf("[WARN] app user in %s: %v", db.Name, err)
+			errs = append(errs, fmt.Errorf("app user in %s: %w", db.Name, err))
+		}
+		if err := security.VerifyReadOnlyUser(ctx, cfg.MongosSeedList(), db.Name, db.ReadOnlyUser, db.ReadOnlyPassword); err != nil {
+			log.Printf("[WARN] read-only user in %s: %v", db.Name, err)
+			errs = append(errs, fmt.Errorf("read-only user in %s: %w", db.Name, err))
+		}
 	}
+	return errors.Join(errs...)
 }
 
-func verifyMongosFailover(ctx context.Context, cfg *config.ClusterConfig) {
+func verifyMongosFailover(ctx context.Context, cfg *config.ClusterConfig) error {
+	if len(cfg.MongosHosts) < 2 {
+		log.Println("[SKIP] Multi-mongos failover: only one mongos configured")
+		return nil
+	}
 	log.Println("Testing multi-mongos failover...")
 	client, err := cluster.ConnectMongosMulti(ctx, cfg.MongosHosts, cfg.AdminUser, cfg.AdminPassword)
 	if err != nil {
 		log.Printf("[WARN] multi-mongos: %v", err)
-		return
+		return fmt.Errorf("multi-mongos: %w", err)
 	}
 	defer client.Disconnect(ctx)
 
 	if err := cluster.VerifyCluster(ctx, client, len(cfg.Shards)); err != nil {
 		log.Printf("[WARN] multi-mongos verify: %v", err)
-		return
+		return fmt.Errorf("multi-mongos verify: %w", err)
 	}
 	log.Println("[OK] Multi-mongos failover works")
+	return nil
 }
 
-func printConnectionInfo(cfg *config.ClusterConfig) {
-	fmt.Println("")
-	fmt.Println("CLUSTER SETUP COMPLETE")
-	fmt.Println("")
-	fmt.Printf("  mongos-1:  mongodb://%s:%s@%s/?authSource=admin\n", cfg.AdminUser, cfg.AdminPassword, cfg.MongosHosts[0])
-	fmt.Printf("  mongos-2:  mongodb://%s:%s@%s/?authSource=admin\n", cfg.AdminUser, cfg.AdminPassword, cfg.MongosHosts[1])
-	fmt.Printf("  app user:  mongodb://%s:%s@%s/?authSource=%s\n", cfg.AppUser, cfg.AppPassword, cfg.MongosHosts[0], cfg.AppDatabase)
-	fmt.Println("")
+func printConnectionInfo(w io.Writer, cfg *config.ClusterConfig) {
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "CLUSTER SETUP COMPLETE")
+	fmt.Fprintln(w, "")
+	for i, host := range cfg.MongosHosts {
+		if uri, err := config.BuildURI([]string{host}, cfg.AdminUser, cfg.AdminPassword, "admin"); err == nil {
+			fmt.Fprintf(w, "  mongos-%d:  %s\n", i+1, uri)
+		}
+	}
+	for _, db := range cfg.Databases {
+		if uri, err := config.BuildURI(cfg.MongosHosts, db.AppUser, db.AppPassword, db.Name); err == nil {
+			fmt.Fprintf(w, "  app user:  %s\n", uri)
+		}
+	}
+	fmt.Fprintln(w, "")
 }
 
 // must exits with a fatal log if err is non-nil.
@@ -2,89 +2,280 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
-	"sort"
-	"strings"
+	"os/signal"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
 
+	"go-mongodb-sharding-poc/internal/cluster"
 	"go-mongodb-sharding-poc/internal/config"
-)
-
-const (
-	database   = "sharding_poc"
-	collection = "throughput_bench"
+	"go-mongodb-sharding-poc/internal/operations"
+	"go-mongodb-sharding-poc/internal/router"
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/stats"
+	"go-mongodb-sharding-poc/internal/timeutil"
 )
 
 func main() {
 	log.SetFlags(log.Ltime)
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	seed := flag.Int64("seed", 0, "random seed for generated data (0 = time-based)")
+	database := flag.String("db", cfg.AppDatabase, "database to benchmark in")
+	collection := flag.String("collection", cfg.BenchCollection, "collection to benchmark (dropped and recreated)")
+	shardKey := flag.String("shard-key", "_id:hashed", `shard key as "field[+field][:hashed]", or "none" to leave the collection unsharded`)
+	soakDuration := flag.Duration("soak-duration", 0, "run only the mixed workload for this long (e.g. 30m, 2h) and watch for degradation")
+	soakInterval := flag.Duration("soak-interval", 30*time.Second, "how often the soak run reports rolling stats")
+	distCSV := flag.String("distribution-csv", "", "write timestamped per-shard counts and sizes to this CSV file during the run")
+	distInterval := flag.Duration("distribution-interval", 5*time.Second, "how often -distribution-csv samples the shard distribution")
+	distSettle := flag.Duration("distribution-settle", 0, "keep sampling -distribution-csv this long after the load to chart balancer convergence")
+	writeConcern := flag.String("write-concern", "", `write concern for every benchmark as "w[+j]" (e.g. 1, 1+j, majority); empty uses the cluster default`)
+	compareWC := flag.Bool("compare-write-concerns", false, "also rerun the bulk insert under w:1, w:1+j and w:majority")
+	poolStress := flag.Duration("pool-stress", 0, "run only the connection pool saturation test for this long (e.g. 30s)")
+	shardAware := flag.Bool("shard-aware", false, "also compare naive bulk insert with client-side shard-aware routing on a presplit ranged collection")
+	poolWorkers := flag.Int("pool-stress-workers", 0, "concurrent workers for -pool-stress (0 = twice the pool capacity across all mongos)")
+	flag.Parse()
 	ctx := context.Background()
 
 	log.Println("Phase 7: Throughput & Latency Benchmark")
 	log.Println("========================================")
 
 	// Connect with production-grade pool settings
-	mongosAddrs := strings.Join(cfg.MongosHosts, ",")
+	mongosAddrs := cfg.MongosSeedList()
 	uri := "mongodb://" + cfg.AdminUser + ":" + cfg.AdminPassword + "@" + mongosAddrs + "/?authSource=admin"
 
-	mongoOpts := options.Client().
-		ApplyURI(uri).
-		SetMinPoolSize(100).
-		SetMaxPoolSize(500).
-		SetMaxConnIdleTime(5 * time.Minute).
-		SetCompressors([]string{"zstd", "snappy"}).
-		SetTimeout(30 * time.Second)
+	tuning := cluster.NewClientTuning(cfg).WithPool()
+	tuning.MaxPoolSize = maxPoolSize
+	mongoOpts := tuning.Apply(options.Client().ApplyURI(uri)).
+		SetCompressors([]string{"zstd", "snappy"})
 
-	client, err := mongo.Connect(ctx, mongoOpts)
+	client, err := cluster.ConnectWithRetry(ctx, mongoOpts, cluster.DefaultConnectWait)
 	if err != nil {
 		log.Fatalf("MongoDB connect: %v", err)
 	}
 	defer client.Disconnect(ctx)
 
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("MongoDB ping: %v", err)
-	}
+	log.Printf("Connected to %s (pool: min=100 max=%d)", mongosAddrs, maxPoolSize)
 
-	log.Printf("Connected to %s (pool: min=100 max=500)", mongosAddrs)
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Printf("Seed: %d (re-run with -seed=%d to reproduce)", *seed, *seed)
 
 	// Clean up from previous runs
-	coll := client.Database(database).Collection(collection)
+	log.Printf("Target: %s.%s", *database, *collection)
+	collOpts := options.Collection()
+	if *writeConcern != "" {
+		wc, err := config.ParseWriteConcern(*writeConcern)
+		if err != nil {
+			log.Fatalf("-write-concern: %v", err)
+		}
+		collOpts.SetWriteConcern(wc.Driver())
+		log.Printf("Write concern: %s", wc)
+	}
+	coll := client.Database(*database).Collection(*collection, collOpts)
 	coll.Drop(ctx)
 
+	if err := shardBenchCollection(ctx, client, *database, *collection, *shardKey); err != nil {
+		log.Fatalf("shard %s.%s: %v", *database, *collection, err)
+	}
+
 	log.Println("")
 
+	stopSampling := func() {}
+	if *distCSV != "" {
+		stopSampling, err = startDistributionCSV(ctx, client, *database, *collection, *distCSV, *distInterval)
+		if err != nil {
+			log.Fatalf("distribution CSV: %v", err)
+		}
+	}
+	finish := func(msg string) {
+		if *distCSV != "" {
+			if *distSettle > 0 {
+				log.Printf("Sampling distribution for another %v while the balancer settles...", *distSettle)
+				timeutil.Sleep(ctx, *distSettle)
+			}
+			stopSampling()
+			log.Printf("Distribution samples written to %s", *distCSV)
+		}
+		log.Println("")
+		log.Println(msg)
+		os.Exit(0)
+	}
+
+	if *soakDuration > 0 {
+		runSoakBenchmark(ctx, coll, *seed, *soakDuration, *soakInterval)
+		finish("Soak complete")
+	}
+
+	if *poolStress > 0 {
+		workers := *poolWorkers
+		if workers <= 0 {
+			workers = 2 * maxPoolSize * len(cfg.MongosHosts)
+		}
+		runPoolStressBenchmark(ctx, uri, coll, *seed, workers, len(cfg.MongosHosts), *poolStress)
+		finish("Pool stress complete")
+	}
+
 	// Benchmark 1: Concurrent Bulk Insert
-	runBulkInsertBenchmark(ctx, coll)
+	runBulkInsertBenchmark(ctx, coll, *seed)
+	reportShardBreakdown(ctx, coll)
 
 	log.Println("")
 
 	// Benchmark 2: Mixed Read/Write
-	runMixedBenchmark(ctx, coll)
+	runMixedBenchmark(ctx, coll, *seed)
 
 	log.Println("")
-	log.Println("Benchmark complete")
-	os.Exit(0)
+
+	// Benchmark 3: Secondary Read Scaling
+	runReadScalingBenchmark(ctx, coll, cfg, *seed)
+
+	// Benchmark 4: Write Concern Levels
+	if *compareWC {
+		log.Println("")
+		runWriteConcernBenchmark(ctx, coll, *seed)
+	}
+
+	// Benchmark 5: Shard-Aware Bulk Insert
+	if *shardAware {
+		log.Println("")
+		runShardAwareBenchmark(ctx, client, cfg, *database, *collection, *seed)
+	}
+
+	log.Println("")
+	log.Println("=== Mongos Query Targeting (since mongos start) ===")
+	if stats, err := sharding.GetMongosStats(ctx, client); err != nil {
+		log.Printf("  [WARN] %v", err)
+	} else {
+		sharding.PrintMongosStats(stats)
+	}
+
+	finish("Benchmark complete")
+}
+
+// startDistributionCSV samples the shard distribution of db.coll into path
+// in the background. The returned stop function takes a final sample,
+// waits for the sampler and closes the file.
+func startDistributionCSV(ctx context.Context, client *mongo.Client, db, coll, path string, interval time.Duration) (func(), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Sampling shard distribution every %v to %s", interval, path)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := sharding.SampleDistribution(ctx, client, db, coll, interval, f); err != nil {
+			log.Printf("[WARN] distribution CSV stopped: %v", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		if err := f.Close(); err != nil {
+			log.Printf("[WARN] close %s: %v", path, err)
+		}
+	}, nil
+}
+
+// shardBenchCollection shards the (empty) benchmark collection so writes
+// spread across shards instead of all landing on the database's primary
+// shard. A hashed key gets initial chunks on every shard up front; a ranged
+// key starts as one chunk that splits and balances as data arrives.
+func shardBenchCollection(ctx context.Context, client *mongo.Client, db, coll, keySpec string) error {
+	if keySpec == "none" {
+		log.Println("[INFO] -shard-key=none: benchmarking an unsharded collection (primary shard only)")
+		return nil
+	}
+	sc, err := config.ParseShardKey(coll, keySpec)
+	if err != nil {
+		return err
+	}
+
+	if err := cluster.EnableSharding(ctx, client, db); err != nil {
+		return err
+	}
+	if err := cluster.ShardCollections(ctx, client, db, []config.ShardedCollection{sc}); err != nil {
+		return err
+	}
+	if !sc.Hashed {
+		log.Println("[INFO] Ranged key: writes start on one chunk until it splits and the balancer moves chunks")
+	}
+	return nil
+}
+
+// dailyOpsTarget is the sizing target every benchmark reports against.
+const dailyOpsTarget = 30_000_000
+
+// maxPoolSize is the per-mongos connection pool limit, matching grpc-server.
+const maxPoolSize = 500
+
+// poolStressOpTimeout bounds each pool stress operation, including the wait
+// for a connection, so a saturated pool shows up as checkout timeouts.
+const poolStressOpTimeout = 2 * time.Second
+
+// bulkInsertDocs is how many documents one bulkInsert run writes.
+const bulkInsertDocs = 8 * 10 * 1000
+
+// bulkInsertResult is what one run of the bulk insert harness measured.
+type bulkInsertResult struct {
+	ops           int64
+	elapsed       time.Duration
+	opsPerSec     float64
+	dailyCapacity float64
+	p50, p95, p99 time.Duration
 }
 
 // runBulkInsertBenchmark tests concurrent unordered bulk inserts.
 // 8 goroutines × 10 batches × 1,000 docs = 80,000 inserts.
-func runBulkInsertBenchmark(ctx context.Context, coll *mongo.Collection) {
+func runBulkInsertBenchmark(ctx context.Context, coll *mongo.Collection, seed int64) {
 	log.Println("=== Benchmark 1: Concurrent Bulk Insert ===")
 	log.Println("8 goroutines × 10 batches × 1,000 docs = 80,000 inserts")
 
-	goroutines := 8
-	batchesPerWorker := 10
-	docsPerBatch := 1000
+	r := bulkInsert(seed, prefixedID("bench"), insertInto(ctx, coll))
+
+	log.Println("")
+	log.Println("--- Bulk Insert Results ---")
+	log.Printf("  Total ops:       %d", r.ops)
+	log.Printf("  Elapsed:         %v", r.elapsed.Round(time.Millisecond))
+	log.Printf("  Throughput:      %.0f ops/sec", r.opsPerSec)
+	log.Printf("  Daily capacity:  %.1fM ops/day", r.dailyCapacity/1_000_000)
+	log.Printf("  Batch latency p50: %v", r.p50.Round(time.Millisecond))
+	log.Printf("  Batch latency p95: %v", r.p95.Round(time.Millisecond))
+	log.Printf("  Batch latency p99: %v", r.p99.Round(time.Millisecond))
+	reportDailyTarget(r.dailyCapacity)
+}
+
+// bulkInsert runs the bulk insert harness: 8 workers each send 10 batches
+// of 1,000 documents through insert, timing every batch. id gives the _id
+// of the idx-th document, so repeated runs into one collection can keep
+// their IDs apart.
+func bulkInsert(seed int64, id func(idx int) interface{}, insert func(docs []bson.Raw) error) bulkInsertResult {
+	const goroutines = 8
+	const batchesPerWorker = 10
+	const docsPerBatch = bulkInsertDocs / (goroutines * batchesPerWorker)
 
 	var totalOps atomic.Int64
 	var mu sync.Mutex
@@ -97,30 +288,32 @@ func runBulkInsertBenchmark(ctx context.Context, coll *mongo.Collection) {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
+			rng := workerRand(seed, workerID)
 			var workerLatencies []time.Duration
 
 			for batch := 0; batch < batchesPerWorker; batch++ {
-				docs := make([]interface{}, 0, docsPerBatch)
+				docs := make([]bson.Raw, 0, docsPerBatch)
 				for i := 0; i < docsPerBatch; i++ {
 					idx := workerID*batchesPerWorker*docsPerBatch + batch*docsPerBatch + i
-					doc := bson.M{
-						"_id":       fmt.Sprintf("bench_%08d", idx),
-						"worker":    workerID,
-						"batch":     batch,
-						"index":     idx,
-						"category":  fmt.Sprintf("cat_%d", idx%50),
-						"value":     rand.Float64() * 10000,
-						"timestamp": time.Now(),
-						"data":      fmt.Sprintf("payload-data-for-document-%d", idx),
+					raw, err := bson.Marshal(bson.D{
+						{Key: "_id", Value: id(idx)},
+						{Key: "worker", Value: workerID},
+						{Key: "batch", Value: batch},
+						{Key: "index", Value: idx},
+						{Key: "category", Value: fmt.Sprintf("cat_%d", idx%50)},
+						{Key: "value", Value: rng.Float64() * 10000},
+						{Key: "timestamp", Value: sharding.DataEpoch.Add(time.Duration(idx) * time.Millisecond)},
+						{Key: "data", Value: fmt.Sprintf("payload-data-for-document-%d", idx)},
+					})
+					if err != nil {
+						log.Fatalf("marshal document: %v", err)
 					}
-					docs = append(docs, doc)
+					docs = append(docs, raw)
 				}
 
 				batchStart := time.Now()
-				_, err := coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
-				batchLatency := time.Since(batchStart)
-				workerLatencies = append(workerLatencies, batchLatency)
-
+				err := insert(docs)
+				workerLatencies = append(workerLatencies, time.Since(batchStart))
 				if err != nil {
 					log.Printf("  worker %d batch %d: %v", workerID, batch, err)
 				}
@@ -139,33 +332,131 @@ func runBulkInsertBenchmark(ctx context.Context, coll *mongo.Collection) {
 	// Calculate metrics
 	ops := totalOps.Load()
 	opsPerSec := float64(ops) / elapsed.Seconds()
-	dailyCapacity := opsPerSec * 86400
+	pcts := stats.Percentiles(allLatencies, 0.50, 0.95, 0.99)
 
-	sort.Slice(allLatencies, func(i, j int) bool { return allLatencies[i] < allLatencies[j] })
-	p50 := allLatencies[len(allLatencies)/2]
-	p95 := allLatencies[int(float64(len(allLatencies))*0.95)]
-	p99 := allLatencies[int(float64(len(allLatencies))*0.99)]
+	return bulkInsertResult{
+		ops:           ops,
+		elapsed:       elapsed,
+		opsPerSec:     opsPerSec,
+		dailyCapacity: opsPerSec * 86400,
+		p50:           pcts[0],
+		p95:           pcts[1],
+		p99:           pcts[2],
+	}
+}
 
-	log.Println("")
-	log.Println("--- Bulk Insert Results ---")
-	log.Printf("  Total ops:       %d", ops)
-	log.Printf("  Elapsed:         %v", elapsed.Round(time.Millisecond))
-	log.Printf("  Throughput:      %.0f ops/sec", opsPerSec)
-	log.Printf("  Daily capacity:  %.1fM ops/day", dailyCapacity/1_000_000)
-	log.Printf("  Batch latency p50: %v", p50.Round(time.Millisecond))
-	log.Printf("  Batch latency p95: %v", p95.Round(time.Millisecond))
-	log.Printf("  Batch latency p99: %v", p99.Round(time.Millisecond))
+// prefixedID returns string IDs "<prefix>_00000042" for bulkInsert.
+func prefixedID(prefix string) func(idx int) interface{} {
+	return func(idx int) interface{} {
+		return fmt.Sprintf("%s_%08d", prefix, idx)
+	}
+}
 
-	if dailyCapacity >= 30_000_000 {
+// insertInto is a bulkInsert insert func that writes each batch to coll
+// as one unordered InsertMany.
+func insertInto(ctx context.Context, coll *mongo.Collection) func(docs []bson.Raw) error {
+	return func(docs []bson.Raw) error {
+		batch := make([]interface{}, len(docs))
+		for i, d := range docs {
+			batch[i] = d
+		}
+		_, err := coll.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		return err
+	}
+}
+
+// reportDailyTarget logs how dailyCapacity compares to dailyOpsTarget.
+func reportDailyTarget(dailyCapacity float64) {
+	if dailyCapacity >= dailyOpsTarget {
 		log.Println("  [PASS] Exceeds 30M ops/day target")
 	} else {
-		log.Printf("  [INFO] %.1fM/30M ops/day (%.0f%% of target)", dailyCapacity/1_000_000, (dailyCapacity/30_000_000)*100)
+		log.Printf("  [INFO] %.1fM/30M ops/day (%.0f%% of target)", dailyCapacity/1_000_000, (dailyCapacity/dailyOpsTarget)*100)
+	}
+}
+
+// runWriteConcernBenchmark reruns the bulk insert harness under increasing
+// durability: w:1 is acknowledged by the primary alone, j:true adds the
+// primary's journal flush, and w:majority waits for a majority of each
+// shard to replicate and journal the write. Adding j:true to w:majority
+// changes nothing while writeConcernMajorityJournalDefault is on (the
+// default), so it is not measured separately.
+func runWriteConcernBenchmark(ctx context.Context, coll *mongo.Collection, seed int64) {
+	log.Println("=== Benchmark 4: Write Concern Levels ===")
+	log.Println("Bulk insert harness under w:1, w:1+j and w:majority")
+
+	levels := []config.WriteConcern{
+		{W: 1},
+		{W: 1, Journal: true},
+		{W: "majority"},
+	}
+
+	results := make([]bulkInsertResult, len(levels))
+	for i, wc := range levels {
+		wcColl, err := coll.Clone(options.Collection().SetWriteConcern(wc.Driver()))
+		if err != nil {
+			log.Printf("  [WARN] clone collection for %s: %v", wc, err)
+			continue
+		}
+		log.Printf("  Running %s...", wc)
+		results[i] = bulkInsert(seed, prefixedID(fmt.Sprintf("wc%d", i)), insertInto(ctx, wcColl))
+	}
+
+	log.Println("")
+	log.Println("--- Write Concern Results ---")
+	log.Printf("  %-20s %10s %8s %8s %8s %10s %8s", "concern", "ops/sec", "p50", "p95", "p99", "M ops/day", "target")
+	for i, wc := range levels {
+		r := results[i]
+		if r.ops == 0 {
+			continue
+		}
+		log.Printf("  %-20s %10.0f %8v %8v %8v %10.1f %7.0f%%", wc, r.opsPerSec,
+			r.p50.Round(time.Millisecond), r.p95.Round(time.Millisecond), r.p99.Round(time.Millisecond),
+			r.dailyCapacity/1_000_000, r.dailyCapacity/dailyOpsTarget*100)
+	}
+	if base := results[0].opsPerSec; base > 0 {
+		for i := 1; i < len(levels); i++ {
+			if results[i].opsPerSec > 0 {
+				log.Printf("  %s costs %.0f%% of w:1 throughput", levels[i], (1-results[i].opsPerSec/base)*100)
+			}
+		}
 	}
 }
 
+// reportShardBreakdown shows where the bulk insert's documents landed. High
+// aggregate throughput with everything on one shard means the shard key did
+// not parallelize the writes.
+func reportShardBreakdown(ctx context.Context, coll *mongo.Collection) {
+	client, db := coll.Database().Client(), coll.Database().Name()
+
+	log.Println("")
+	log.Println("--- Per-Shard Write Breakdown ---")
+	dist, err := sharding.GetShardDistribution(ctx, client, db, coll.Name())
+	if err != nil {
+		log.Printf("  [WARN] %v", err)
+		return
+	}
+	sharding.PrintDistribution(dist)
+
+	names, err := sharding.ShardNames(ctx, client)
+	if err != nil {
+		log.Printf("  [WARN] %v", err)
+		return
+	}
+	shardCount := len(names)
+	score := sharding.BalanceScore(dist, shardCount)
+	log.Printf("  Balance score:   %.2f (1.00 = even across %d shards)", score, shardCount)
+	if len(dist.Shards) <= 1 && shardCount > 1 {
+		log.Println("  [WARN] All writes went to one shard; the shard key did not spread the load")
+	}
+
+	log.Println("")
+	log.Println("--- Count vs Size Fairness ---")
+	sharding.PrintFairness(sharding.CompareCountAndSize(dist, names, sharding.DefaultMaxDeviation))
+}
+
 // runMixedBenchmark tests sustained mixed reads + writes (70/30 split).
 // 4 goroutines running for 10 seconds.
-func runMixedBenchmark(ctx context.Context, coll *mongo.Collection) {
+func runMixedBenchmark(ctx context.Context, coll *mongo.Collection, seed int64) {
 	log.Println("=== Benchmark 2: Mixed Read/Write (70% write, 30% read) ===")
 	log.Println("4 goroutines × 10 seconds")
 
@@ -186,45 +477,25 @@ func runMixedBenchmark(ctx context.Context, coll *mongo.Collection) {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
+			rng := workerRand(seed, workerID)
 			var localWriteLatencies []time.Duration
 			var localReadLatencies []time.Duration
 			opCounter := 0
 
 			for time.Now().Before(deadline) {
 				opCounter++
-				isWrite := rand.Float64() < 0.7
-
+				isWrite, lat, err := mixedOp(ctx, coll, rng, "mixed", workerID, opCounter)
 				if isWrite {
-					doc := bson.M{
-						"_id":       fmt.Sprintf("mixed_%d_%d", workerID, opCounter),
-						"worker":    workerID,
-						"op":        opCounter,
-						"category":  fmt.Sprintf("cat_%d", opCounter%50),
-						"value":     rand.Float64() * 10000,
-						"timestamp": time.Now(),
-					}
-
-					opStart := time.Now()
-					_, err := coll.InsertOne(ctx, doc)
-					lat := time.Since(opStart)
 					localWriteLatencies = append(localWriteLatencies, lat)
-
-					if err != nil {
-						continue
-					}
-					writeOps.Add(1)
 				} else {
-					filter := bson.M{"category": fmt.Sprintf("cat_%d", rand.Intn(50))}
-
-					opStart := time.Now()
-					cursor, err := coll.Find(ctx, filter, options.Find().SetLimit(10))
-					lat := time.Since(opStart)
 					localReadLatencies = append(localReadLatencies, lat)
-
-					if err != nil {
-						continue
-					}
-					cursor.Close(ctx)
+				}
+				if err != nil {
+					continue
+				}
+				if isWrite {
+					writeOps.Add(1)
+				} else {
 					readOps.Add(1)
 				}
 			}
@@ -252,25 +523,595 @@ func runMixedBenchmark(ctx context.Context, coll *mongo.Collection) {
 	log.Printf("  Throughput:      %.0f ops/sec", opsPerSec)
 	log.Printf("  Daily capacity:  %.1fM ops/day", dailyCapacity/1_000_000)
 
-	if len(writeLatencies) > 1 {
-		sort.Slice(writeLatencies, func(i, j int) bool { return writeLatencies[i] < writeLatencies[j] })
-		wp50 := writeLatencies[len(writeLatencies)/2]
-		wp95 := writeLatencies[int(float64(len(writeLatencies))*0.95)]
-		log.Printf("  Write latency p50: %v", wp50.Round(time.Microsecond))
-		log.Printf("  Write latency p95: %v", wp95.Round(time.Microsecond))
+	if len(writeLatencies) > 0 {
+		wp := stats.Percentiles(writeLatencies, 0.50, 0.95)
+		log.Printf("  Write latency p50: %v", wp[0].Round(time.Microsecond))
+		log.Printf("  Write latency p95: %v", wp[1].Round(time.Microsecond))
 	}
 
-	if len(readLatencies) > 1 {
-		sort.Slice(readLatencies, func(i, j int) bool { return readLatencies[i] < readLatencies[j] })
-		rp50 := readLatencies[len(readLatencies)/2]
-		rp95 := readLatencies[int(float64(len(readLatencies))*0.95)]
-		log.Printf("  Read latency  p50: %v", rp50.Round(time.Microsecond))
-		log.Printf("  Read latency  p95: %v", rp95.Round(time.Microsecond))
+	if len(readLatencies) > 0 {
+		rp := stats.Percentiles(readLatencies, 0.50, 0.95)
+		log.Printf("  Read latency  p50: %v", rp[0].Round(time.Microsecond))
+		log.Printf("  Read latency  p95: %v", rp[1].Round(time.Microsecond))
 	}
 
-	if dailyCapacity >= 30_000_000 {
-		log.Println("  [PASS] Exceeds 30M ops/day target")
+	reportDailyTarget(dailyCapacity)
+}
+
+// soakWindow collects the mixed workload's results between two soak
+// reports. Workers append to it; the reporter swaps it out each interval.
+type soakWindow struct {
+	mu           sync.Mutex
+	writeLatency []time.Duration
+	readLatency  []time.Duration
+	errors       int64
+	poolTimeouts int64
+	lastErr      error
+}
+
+func (w *soakWindow) record(isWrite bool, lat time.Duration, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		w.errors++
+		w.lastErr = err
+		if errors.As(err, &topology.WaitQueueTimeoutError{}) {
+			w.poolTimeouts++
+		}
+		return
+	}
+	if isWrite {
+		w.writeLatency = append(w.writeLatency, lat)
 	} else {
-		log.Printf("  [INFO] %.1fM/30M ops/day (%.0f%% of target)", dailyCapacity/1_000_000, (dailyCapacity/30_000_000)*100)
+		w.readLatency = append(w.readLatency, lat)
+	}
+}
+
+// take returns the current window's contents and starts a new one.
+func (w *soakWindow) take() (writes, reads []time.Duration, errCount, poolTimeouts int64, lastErr error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	writes, reads, errCount, poolTimeouts, lastErr = w.writeLatency, w.readLatency, w.errors, w.poolTimeouts, w.lastErr
+	w.writeLatency, w.readLatency, w.errors, w.poolTimeouts, w.lastErr = nil, nil, 0, 0, nil
+	return
+}
+
+// soakSample is one interval of the soak run.
+type soakSample struct {
+	opsPerSec  float64
+	p50, p99   time.Duration
+	errors     int64
+	heapMB     float64
+	goroutines int
+}
+
+// runSoakBenchmark runs the mixed 70/30 workload continuously for duration
+// (or until interrupted), logging rolling throughput, latency percentiles,
+// heap and goroutine counts every interval. The first interval is the
+// baseline: later ones are flagged when p99 doubles, throughput halves,
+// the pool times out, or heap/goroutines keep climbing.
+func runSoakBenchmark(ctx context.Context, coll *mongo.Collection, seed int64, duration, interval time.Duration) {
+	log.Println("=== Soak: Sustained Mixed Read/Write (70% write, 30% read) ===")
+	log.Printf("Goal: hold the mixed workload for %v and watch for degradation over time", duration)
+	log.Printf("8 goroutines, reporting every %v (Ctrl-C stops early with a summary)", interval)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	const goroutines = 8
+	window := &soakWindow{}
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			rng := workerRand(seed, workerID)
+			for opCounter := 1; ctx.Err() == nil; opCounter++ {
+				isWrite, lat, err := mixedOp(ctx, coll, rng, "soak", workerID, opCounter)
+				if ctx.Err() != nil {
+					return // the op was cut short by the deadline, not the cluster
+				}
+				window.record(isWrite, lat, err)
+			}
+		}(g)
+	}
+
+	var samples []soakSample
+	var totalOps, totalErrors, totalPoolTimeouts int64
+	start := time.Now()
+	last := start
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	report := func() {
+		now := time.Now()
+		writes, reads, errCount, poolTimeouts, lastErr := window.take()
+		all := append(append([]time.Duration{}, writes...), reads...)
+
+		pcts := stats.Percentiles(all, 0.50, 0.99)
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		sample := soakSample{
+			opsPerSec:  float64(len(all)) / now.Sub(last).Seconds(),
+			p50:        pcts[0],
+			p99:        pcts[1],
+			errors:     errCount,
+			heapMB:     float64(mem.HeapAlloc) / (1 << 20),
+			goroutines: runtime.NumGoroutine(),
+		}
+		last = now
+		totalOps += int64(len(all))
+		totalErrors += errCount
+		totalPoolTimeouts += poolTimeouts
+
+		log.Printf("  [%7v] %6.0f ops/sec  w=%d r=%d  p50=%v p99=%v  errors=%d  heap=%.1fMB goroutines=%d",
+			now.Sub(start).Round(time.Second), sample.opsPerSec, len(writes), len(reads),
+			sample.p50.Round(time.Microsecond), sample.p99.Round(time.Microsecond),
+			sample.errors, sample.heapMB, sample.goroutines)
+		if poolTimeouts > 0 {
+			log.Printf("  [WARN] %d connection pool checkout timeouts this interval (pool exhausted)", poolTimeouts)
+		} else if lastErr != nil {
+			log.Printf("  [WARN] %d failed ops this interval, last: %v", errCount, lastErr)
+		}
+
+		if len(samples) > 0 {
+			warnSoakDegradation(samples[0], sample)
+		}
+		samples = append(samples, sample)
+	}
+
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-ticker.C:
+			report()
+		}
+	}
+	wg.Wait()
+	if time.Since(last) >= time.Second {
+		report() // partial final interval
+	}
+
+	elapsed := time.Since(start)
+	log.Println("")
+	log.Println("--- Soak Results ---")
+	log.Printf("  Elapsed:         %v (%d intervals)", elapsed.Round(time.Second), len(samples))
+	log.Printf("  Total ops:       %d (%.0f ops/sec average)", totalOps, float64(totalOps)/elapsed.Seconds())
+	log.Printf("  Failed ops:      %d (pool timeouts: %d)", totalErrors, totalPoolTimeouts)
+	if len(samples) == 0 {
+		log.Println("  [WARN] No complete interval; use a -soak-duration longer than -soak-interval")
+		return
+	}
+
+	first, final := samples[0], samples[len(samples)-1]
+	worst := first
+	for _, s := range samples {
+		if s.p99 > worst.p99 {
+			worst = s
+		}
+	}
+	log.Printf("  Throughput:      %.0f -> %.0f ops/sec (first -> last interval)", first.opsPerSec, final.opsPerSec)
+	log.Printf("  Latency p99:     %v -> %v (worst %v)", first.p99.Round(time.Microsecond),
+		final.p99.Round(time.Microsecond), worst.p99.Round(time.Microsecond))
+	log.Printf("  Heap:            %.1fMB -> %.1fMB", first.heapMB, final.heapMB)
+	log.Printf("  Goroutines:      %d -> %d", first.goroutines, final.goroutines)
+	if totalPoolTimeouts == 0 && !soakDegraded(first, final) {
+		log.Println("  [PASS] No sustained degradation over the run")
+	}
+}
+
+// Degradation thresholds for the soak run, relative to its first interval.
+const (
+	soakMaxP99Growth      = 2.0 // p99 may at most double
+	soakMinThroughput     = 0.5 // throughput may at most halve
+	soakMaxHeapGrowth     = 2.0 // heap may at most double
+	soakMaxGoroutineDelta = 50  // extra goroutines beyond the baseline
+)
+
+func soakDegraded(base, s soakSample) bool {
+	return float64(s.p99) > float64(base.p99)*soakMaxP99Growth ||
+		s.opsPerSec < base.opsPerSec*soakMinThroughput ||
+		s.heapMB > base.heapMB*soakMaxHeapGrowth ||
+		s.goroutines > base.goroutines+soakMaxGoroutineDelta
+}
+
+// warnSoakDegradation logs which thresholds sample s crosses against base.
+func warnSoakDegradation(base, s soakSample) {
+	if float64(s.p99) > float64(base.p99)*soakMaxP99Growth {
+		log.Printf("  [WARN] p99 %v is more than %.0fx the baseline %v",
+			s.p99.Round(time.Microsecond), soakMaxP99Growth, base.p99.Round(time.Microsecond))
+	}
+	if s.opsPerSec < base.opsPerSec*soakMinThroughput {
+		log.Printf("  [WARN] Throughput %.0f ops/sec is below %.0f%% of the baseline %.0f",
+			s.opsPerSec, soakMinThroughput*100, base.opsPerSec)
+	}
+	if s.heapMB > base.heapMB*soakMaxHeapGrowth {
+		log.Printf("  [WARN] Heap %.1fMB has grown past %.0fx the baseline %.1fMB (possible leak)",
+			s.heapMB, soakMaxHeapGrowth, base.heapMB)
+	}
+	if s.goroutines > base.goroutines+soakMaxGoroutineDelta {
+		log.Printf("  [WARN] %d goroutines vs %d at baseline (possible leak)", s.goroutines, base.goroutines)
+	}
+}
+
+// runPoolStressBenchmark drives more concurrent operations than the pool
+// can serve and reports checkout waits and failures from the driver's pool
+// events. It uses its own client with the production pool settings and a
+// short operation timeout; each operation is an unindexed aggregation that
+// holds its connection long enough for workers to queue.
+func runPoolStressBenchmark(ctx context.Context, uri string, coll *mongo.Collection, seed int64, workers, mongosCount int, duration time.Duration) {
+	log.Println("=== Pool Stress: Connection Pool Saturation ===")
+	log.Printf("%d workers against maxPoolSize=%d × %d mongos for %v (op timeout %v)",
+		workers, maxPoolSize, mongosCount, duration, poolStressOpTimeout)
+
+	log.Println("Seeding data for the aggregation workload...")
+	bulkInsert(seed, prefixedID("pool"), insertInto(ctx, coll))
+
+	poolStats := cluster.NewPoolStats()
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(uri).
+		SetMinPoolSize(100).
+		SetMaxPoolSize(maxPoolSize).
+		SetTimeout(poolStressOpTimeout).
+		SetPoolMonitor(poolStats.Monitor(nil)))
+	if err != nil {
+		log.Printf("  [WARN] connect stress client: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+	stressColl := client.Database(coll.Database().Name()).Collection(coll.Name())
+
+	var okOps, failedOps atomic.Int64
+	start := time.Now()
+	deadline := start.Add(duration)
+	var wg sync.WaitGroup
+	for g := 0; g < workers; g++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			rng := workerRand(seed, workerID)
+			for time.Now().Before(deadline) {
+				pipeline := mongo.Pipeline{
+					{{Key: "$match", Value: bson.M{"category": fmt.Sprintf("cat_%d", rng.Intn(50))}}},
+					{{Key: "$group", Value: bson.M{"_id": "$worker", "total": bson.M{"$sum": "$value"}}}},
+				}
+				cursor, err := stressColl.Aggregate(ctx, pipeline)
+				if err != nil {
+					failedOps.Add(1)
+					continue
+				}
+				cursor.Close(ctx)
+				okOps.Add(1)
+			}
+		}(g)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	snap := poolStats.Snapshot()
+	log.Println("")
+	log.Println("--- Pool Stress Results ---")
+	log.Printf("  Operations:      %d ok, %d failed (%.0f ops/sec)", okOps.Load(), failedOps.Load(), float64(okOps.Load())/elapsed.Seconds())
+	log.Printf("  Checkouts:       %d started, %d succeeded, %d failed", snap.CheckOutStarted, snap.CheckedOut, snap.FailedTotal())
+	for reason, n := range snap.Failed {
+		log.Printf("    failed (%s): %d", reason, n)
+	}
+	log.Printf("  Checkout wait:   avg=%v p50=%v p99=%v max=%v", snap.WaitAvg.Round(time.Microsecond),
+		snap.WaitP50.Round(time.Microsecond), snap.WaitP99.Round(time.Microsecond), snap.WaitMax.Round(time.Millisecond))
+	log.Printf("  Connections:     %d created, %d closed, pool cleared %d times", snap.Created, snap.Closed, snap.Cleared)
+
+	switch {
+	case snap.Failed[event.ReasonTimedOut] > 0:
+		log.Printf("  [WARN] Pool saturated: %d checkouts timed out at %d workers; raise maxPoolSize or cap concurrency",
+			snap.Failed[event.ReasonTimedOut], workers)
+	case snap.WaitP99 > poolStressOpTimeout/10:
+		log.Printf("  [INFO] No checkout timeouts, but p99 wait %v is a large share of the %v op budget",
+			snap.WaitP99.Round(time.Millisecond), poolStressOpTimeout)
+	default:
+		log.Printf("  [PASS] maxPoolSize=%d absorbed %d concurrent workers without checkout timeouts", maxPoolSize, workers)
+	}
+}
+
+// mixedOp performs one operation of the 70% write / 30% read mix: an
+// InsertOne of a new document (IDs prefixed with idPrefix) or a limited
+// find by category. It reports which it was and how long it took.
+func mixedOp(ctx context.Context, coll *mongo.Collection, rng *rand.Rand, idPrefix string, workerID, opCounter int) (bool, time.Duration, error) {
+	if rng.Float64() < 0.7 {
+		doc := bson.M{
+			"_id":       fmt.Sprintf("%s_%d_%d", idPrefix, workerID, opCounter),
+			"worker":    workerID,
+			"op":        opCounter,
+			"category":  fmt.Sprintf("cat_%d", opCounter%50),
+			"value":     rng.Float64() * 10000,
+			"timestamp": sharding.DataEpoch.Add(time.Duration(opCounter) * time.Millisecond),
+		}
+
+		opStart := time.Now()
+		_, err := coll.InsertOne(ctx, doc)
+		return true, time.Since(opStart), err
+	}
+
+	filter := bson.M{"category": fmt.Sprintf("cat_%d", rng.Intn(50))}
+
+	opStart := time.Now()
+	cursor, err := coll.Find(ctx, filter, options.Find().SetLimit(10))
+	lat := time.Since(opStart)
+	if err == nil {
+		cursor.Close(ctx)
+	}
+	return false, lat, err
+}
+
+// runReadScalingBenchmark compares read throughput with primary-only reads
+// against secondaryPreferred reads on the loaded dataset. Per-member query
+// counters (serverStatus opcounters) show whether reads actually spread
+// across secondaries. 8 goroutines × 5 seconds per read preference.
+func runReadScalingBenchmark(ctx context.Context, coll *mongo.Collection, cfg *config.ClusterConfig, seed int64) {
+	log.Println("=== Benchmark 3: Secondary Read Scaling ===")
+	log.Println("primary vs secondaryPreferred, 8 goroutines × 5 seconds each")
+
+	var members []string
+	for _, shard := range cfg.Shards {
+		for _, m := range shard.Members {
+			members = append(members, m.Addr())
+		}
+	}
+
+	prefs := []struct {
+		name string
+		pref *readpref.ReadPref
+	}{
+		{"primary", readpref.Primary()},
+		{"secondaryPreferred", readpref.SecondaryPreferred()},
+	}
+
+	results := make(map[string]float64, len(prefs))
+	for _, p := range prefs {
+		readColl, err := coll.Clone(options.Collection().SetReadPreference(p.pref))
+		if err != nil {
+			log.Printf("  [WARN] clone collection for %s: %v", p.name, err)
+			continue
+		}
+
+		before := memberQueryCounters(ctx, members, cfg.AdminUser, cfg.AdminPassword)
+		ops, elapsed := runReadLoad(ctx, readColl, seed, 8, 5*time.Second)
+		after := memberQueryCounters(ctx, members, cfg.AdminUser, cfg.AdminPassword)
+
+		opsPerSec := float64(ops) / elapsed.Seconds()
+		results[p.name] = opsPerSec
+
+		log.Println("")
+		log.Printf("--- %s ---", p.name)
+		log.Printf("  Reads:       %d", ops)
+		log.Printf("  Throughput:  %.0f ops/sec", opsPerSec)
+
+		var primaryQueries, secondaryQueries int64
+		for _, addr := range members {
+			b, okB := before[addr]
+			a, okA := after[addr]
+			if !okB || !okA {
+				log.Printf("    %-16s unreachable", addr)
+				continue
+			}
+			delta := a.queries - b.queries
+			role := "PRIMARY"
+			if a.secondary {
+				role = "SECONDARY"
+				secondaryQueries += delta
+			} else {
+				primaryQueries += delta
+			}
+			log.Printf("    %-16s %-9s %d queries", addr, role, delta)
+		}
+
+		if total := primaryQueries + secondaryQueries; total > 0 {
+			log.Printf("  Served by secondaries: %.1f%%", float64(secondaryQueries)/float64(total)*100)
+		}
+	}
+
+	primary, secondary := results["primary"], results["secondaryPreferred"]
+	if primary > 0 && secondary > 0 {
+		log.Println("")
+		log.Println("--- Read Scaling Results ---")
+		log.Printf("  primary:             %.0f ops/sec", primary)
+		log.Printf("  secondaryPreferred:  %.0f ops/sec", secondary)
+		log.Printf("  Scaling factor:      %.2fx", secondary/primary)
+		if secondary <= primary {
+			log.Println("  [INFO] On a single host all members share CPU; scaling shows up")
+			log.Println("         when replica set members run on separate machines")
+		}
+	}
+}
+
+// runReadLoad runs find queries from n goroutines for duration and returns
+// the number of successful reads and the elapsed time.
+func runReadLoad(ctx context.Context, coll *mongo.Collection, seed int64, n int, duration time.Duration) (int64, time.Duration) {
+	var reads atomic.Int64
+	start := time.Now()
+	deadline := start.Add(duration)
+	var wg sync.WaitGroup
+
+	for g := 0; g < n; g++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			rng := workerRand(seed, workerID)
+			for time.Now().Before(deadline) {
+				filter := bson.M{"category": fmt.Sprintf("cat_%d", rng.Intn(50))}
+				cursor, err := coll.Find(ctx, filter, options.Find().SetLimit(10))
+				if err != nil {
+					continue
+				}
+				var docs []bson.M
+				cursor.All(ctx, &docs)
+				reads.Add(1)
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	return reads.Load(), time.Since(start)
+}
+
+// memberCounters is a snapshot of one mongod's query counter and role.
+type memberCounters struct {
+	queries   int64
+	secondary bool
+}
+
+// memberQueryCounters reads opcounters.query from each member directly.
+// Unreachable members are omitted from the result.
+func memberQueryCounters(ctx context.Context, members []string, user, password string) map[string]memberCounters {
+	counters := make(map[string]memberCounters, len(members))
+	for _, addr := range members {
+		uri, err := config.BuildURI([]string{addr}, user, password, "admin")
+		if err != nil {
+			continue
+		}
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetDirect(true).SetTimeout(5*time.Second))
+		if err != nil {
+			continue
+		}
+
+		var status struct {
+			Opcounters struct {
+				Query int64 `bson:"query"`
+			} `bson:"opcounters"`
+			Repl struct {
+				Secondary bool `bson:"secondary"`
+			} `bson:"repl"`
+		}
+		err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Decode(&status)
+		client.Disconnect(ctx)
+		if err != nil {
+			continue
+		}
+		counters[addr] = memberCounters{queries: status.Opcounters.Query, secondary: status.Repl.Secondary}
+	}
+	return counters
+}
+
+// workerRand returns a generator private to one goroutine, so workers never
+// contend on the global math/rand lock inside the measured path. Deriving it
+// from the run seed keeps each worker's sequence reproducible.
+func workerRand(seed int64, workerID int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(workerID)))
+}
+
+// routedKeySpace is the _id range of the shard-aware benchmark collection;
+// it is presplit into even ranges spread round-robin over the shards.
+const routedKeySpace = 1_000_000_000
+
+// routedChunksPerShard is how many presplit ranges each shard owns.
+const routedChunksPerShard = 4
+
+// runShardAwareBenchmark compares plain unordered InsertMany against
+// router.InsertMany on a ranged _id collection whose chunks are spread
+// across every shard. A plain batch is mixed: mongos splits it into one
+// sub-batch per shard and answers when the slowest returns. The router
+// does that grouping client-side from a cached chunk map and sends the
+// per-shard batches in parallel. Both go through the same mongos here, so
+// the difference is batching only; the locality gain of sending each batch
+// to a mongos near its shard (Router.UseCollection) needs a multi-region
+// deployment to show.
+func runShardAwareBenchmark(ctx context.Context, client *mongo.Client, cfg *config.ClusterConfig, db, baseColl string, seed int64) {
+	log.Println("=== Benchmark 5: Shard-Aware Bulk Insert ===")
+	log.Println("naive vs client-routed InsertMany, 8 goroutines × 10 batches × 1,000 docs each")
+
+	name := baseColl + "_routed"
+	ns := db + "." + name
+	coll := client.Database(db).Collection(name)
+	coll.Drop(ctx)
+
+	if err := shardRoutedCollection(ctx, client, cfg, db, name); err != nil {
+		log.Printf("  [WARN] prepare %s: %v", ns, err)
+		return
+	}
+	if info, err := operations.GetChunkInfo(ctx, client, ns); err == nil {
+		operations.PrintChunkReport(info)
+	}
+
+	r, err := router.New(ctx, client, ns, 0)
+	if err != nil {
+		log.Printf("  [WARN] load chunk map: %v", err)
+		return
+	}
+	log.Printf("  Chunk map: %d chunks, version %v", len(r.ChunkMap().Chunks), r.ChunkMap().Version)
+
+	naive := bulkInsert(seed, scatteredID(0), insertInto(ctx, coll))
+	routed := bulkInsert(seed, scatteredID(1), func(docs []bson.Raw) error {
+		_, err := r.InsertMany(ctx, coll, docs)
+		return err
+	})
+
+	log.Println("")
+	log.Println("--- Shard-Aware Bulk Insert Results ---")
+	log.Printf("  %-8s %10s %8s %8s %8s", "mode", "ops/sec", "p50", "p95", "p99")
+	for _, row := range []struct {
+		mode string
+		r    bulkInsertResult
+	}{{"naive", naive}, {"routed", routed}} {
+		log.Printf("  %-8s %10.0f %8v %8v %8v", row.mode, row.r.opsPerSec,
+			row.r.p50.Round(time.Millisecond), row.r.p95.Round(time.Millisecond), row.r.p99.Round(time.Millisecond))
+	}
+	if naive.opsPerSec > 0 {
+		change := (routed.opsPerSec/naive.opsPerSec - 1) * 100
+		if change > 0 {
+			log.Printf("  [INFO] Routed inserts: %.0f%% higher throughput than naive", change)
+		} else {
+			log.Printf("  [INFO] Routed inserts: %.0f%% lower throughput than naive; with one", -change)
+			log.Println("         mongos for every shard the client-side grouping only adds work")
+		}
+	}
+}
+
+// shardRoutedCollection shards db.coll on a ranged _id, presplits it into
+// routedChunksPerShard ranges per shard and moves them round-robin so that
+// every batch of scattered _ids touches every shard.
+func shardRoutedCollection(ctx context.Context, client *mongo.Client, cfg *config.ClusterConfig, db, coll string) error {
+	sc, err := config.ParseShardKey(coll, "_id")
+	if err != nil {
+		return err
+	}
+	if err := cluster.EnableSharding(ctx, client, db); err != nil {
+		return err
+	}
+	if err := cluster.ShardCollections(ctx, client, db, []config.ShardedCollection{sc}); err != nil {
+		return err
+	}
+
+	ranges := routedChunksPerShard * len(cfg.Shards)
+	bounds := make([]int64, ranges)
+	var points []bson.D
+	for i := 1; i < ranges; i++ {
+		bounds[i] = int64(i) * routedKeySpace / int64(ranges)
+		points = append(points, bson.D{{Key: "_id", Value: bounds[i]}})
+	}
+	if err := sharding.PreSplitChunks(ctx, client, db, coll, points); err != nil {
+		return err
+	}
+
+	ns := db + "." + coll
+	for i, lower := range bounds {
+		shard := cfg.Shards[i%len(cfg.Shards)].Name
+		if err := operations.MoveChunk(ctx, client, ns, bson.D{{Key: "_id", Value: lower}}, shard); err != nil {
+			// Usually "already on that shard"; the chunk stays where it is
+			log.Printf("  [SKIP] range starting at %d: %v", lower, err)
+		}
+	}
+	return nil
+}
+
+// scatteredID returns int64 IDs for bulkInsert scattered over
+// routedKeySpace by multiplying a unique index with a constant coprime to
+// it, so they never collide and consecutive documents land on different
+// shards. run keeps the index ranges of separate runs apart.
+func scatteredID(run int) func(idx int) interface{} {
+	return func(idx int) interface{} {
+		return int64(run*bulkInsertDocs+idx) * 618_033_989 % routedKeySpace
 	}
 }
@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+
+	"go-mongodb-sharding-poc/internal/config"
+)
+
+// readConcernLevels are the levels a read concern may request.
+var readConcernLevels = []string{"local", "available", "majority", "linearizable", "snapshot"}
+
+// RWConcernDefaults is the cluster-wide default read and write concern
+// reported by getDefaultRWConcern. A nil Write.W means the implicit server
+// default.
+type RWConcernDefaults struct {
+	Write     config.WriteConcern
+	ReadLevel string
+}
+
+// SetDefaultRWConcern sets the cluster-wide default read and write concern
+// with setDefaultRWConcern. It applies to every operation that does not
+// specify its own concern; an empty readLevel leaves the read default as is.
+func SetDefaultRWConcern(ctx context.Context, client *mongo.Client, wc config.WriteConcern, readLevel string) error {
+	if err := wc.Validate(); err != nil {
+		return err
+	}
+	cmd := bson.D{
+		{Key: "setDefaultRWConcern", Value: 1},
+		{Key: "defaultWriteConcern", Value: bson.D{{Key: "w", Value: wc.W}, {Key: "j", Value: wc.Journal}}},
+	}
+	if readLevel != "" {
+		if err := validateReadLevel(readLevel); err != nil {
+			return err
+		}
+		cmd = append(cmd, bson.E{Key: "defaultReadConcern", Value: bson.D{{Key: "level", Value: readLevel}}})
+	}
+	if err := client.Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("setDefaultRWConcern: %w", err)
+	}
+	return nil
+}
+
+// GetDefaultRWConcern returns the cluster-wide default read and write concern.
+func GetDefaultRWConcern(ctx context.Context, client *mongo.Client) (*RWConcernDefaults, error) {
+	var result struct {
+		DefaultWriteConcern struct {
+			W interface{} `bson:"w"`
+			J bool        `bson:"j"`
+		} `bson:"defaultWriteConcern"`
+		DefaultReadConcern struct {
+			Level string `bson:"level"`
+		} `bson:"defaultReadConcern"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "getDefaultRWConcern", Value: 1},
+	}).Decode(&result); err != nil {
+		return nil, fmt.Errorf("getDefaultRWConcern: %w", err)
+	}
+	return &RWConcernDefaults{
+		Write:     config.WriteConcern{W: result.DefaultWriteConcern.W, Journal: result.DefaultWriteConcern.J},
+		ReadLevel: result.DefaultReadConcern.Level,
+	}, nil
+}
+
+// SetCollectionWriteConcern returns a handle for ns whose writes carry wc
+// instead of the cluster default. MongoDB has no server-side
+// per-collection concern, so the default lives in the handle: use it for
+// every write to a critical collection. A numeric w is checked against the
+// smallest shard, since a w no shard can satisfy makes every write wait for
+// wtimeout or fail.
+func SetCollectionWriteConcern(ctx context.Context, client *mongo.Client, ns string, wc config.WriteConcern) (*mongo.Collection, error) {
+	db, coll, err := splitNamespace(ns)
+	if err != nil {
+		return nil, err
+	}
+	if err := wc.Validate(); err != nil {
+		return nil, err
+	}
+
+	if n, ok := wc.W.(int); ok && n > 1 {
+		smallest, err := smallestShardSize(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		if n > smallest {
+			return nil, fmt.Errorf("write concern w:%d on %s: smallest shard has only %d members", n, ns, smallest)
+		}
+	}
+
+	return client.Database(db).Collection(coll, options.Collection().SetWriteConcern(wc.Driver())), nil
+}
+
+// SetCollectionReadConcern returns a handle for ns whose reads use level
+// instead of the cluster default. Like SetCollectionWriteConcern, the
+// concern lives in the handle.
+func SetCollectionReadConcern(ctx context.Context, client *mongo.Client, ns, level string) (*mongo.Collection, error) {
+	db, coll, err := splitNamespace(ns)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateReadLevel(level); err != nil {
+		return nil, err
+	}
+	rc := &readconcern.ReadConcern{Level: level}
+	return client.Database(db).Collection(coll, options.Collection().SetReadConcern(rc)), nil
+}
+
+// smallestShardSize returns the member count of the smallest shard.
+func smallestShardSize(ctx context.Context, client *mongo.Client) (int, error) {
+	var result struct {
+		Shards []struct {
+			Host string `bson:"host"`
+		} `bson:"shards"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "listShards", Value: 1}}).Decode(&result); err != nil {
+		return 0, fmt.Errorf("listShards: %w", err)
+	}
+	if len(result.Shards) == 0 {
+		return 0, fmt.Errorf("no shards registered")
+	}
+	smallest := 0
+	for _, s := range result.Shards {
+		_, members := parseShardHost(s.Host)
+		if smallest == 0 || len(members) < smallest {
+			smallest = len(members)
+		}
+	}
+	return smallest, nil
+}
+
+func validateReadLevel(level string) error {
+	for _, l := range readConcernLevels {
+		if level == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown read concern level %q (valid: %s)", level, strings.Join(readConcernLevels, ", "))
+}
+
+// splitNamespace splits "db.collection" at the first dot.
+func splitNamespace(ns string) (string, string, error) {
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok || db == "" || coll == "" {
+		return "", "", fmt.Errorf("invalid namespace %q: want db.collection", ns)
+	}
+	return db, coll, nil
+}
@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/config"
+)
+
+// ConfigServerStatus is what VerifyConfigServer found on the config server
+// replica set.
+type ConfigServerStatus struct {
+	ReplicaSet string
+	ConfigSvr  bool // replica set config has configsvr: true
+	Primary    string
+	Members    []ConfigServerMember
+	Healthy    int // members that are up and PRIMARY or SECONDARY
+}
+
+// ConfigServerMember is one member's role as reported by replSetGetStatus.
+type ConfigServerMember struct {
+	Host    string
+	State   string // PRIMARY, SECONDARY, RECOVERING, (not reachable/healthy), ...
+	Healthy bool
+}
+
+// VerifyConfigServer connects to the config server replica set and checks
+// that it was initiated with configsvr: true, that a primary is elected and
+// that a majority of members are healthy. InitReplicaSet sets the flag, but
+// a config replica set brought up by hand without it still starts, and only
+// fails later when a mongos tries to use it. Member roles are logged and
+// returned either way.
+func VerifyConfigServer(ctx context.Context, members []string, user, password string) (*ConfigServerStatus, error) {
+	uri, err := config.BuildURI(members, user, password, "admin")
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(10*time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("connect to config servers: %w", err)
+	}
+	defer client.Disconnect(ctx)
+
+	var cfgResult struct {
+		Config struct {
+			ID        string `bson:"_id"`
+			ConfigSvr bool   `bson:"configsvr"`
+		} `bson:"config"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetConfig", Value: 1}}).Decode(&cfgResult); err != nil {
+		return nil, fmt.Errorf("replSetGetConfig: %w", err)
+	}
+
+	var rsStatus struct {
+		Members []struct {
+			Name     string  `bson:"name"`
+			StateStr string  `bson:"stateStr"`
+			Health   float64 `bson:"health"`
+		} `bson:"members"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&rsStatus); err != nil {
+		return nil, fmt.Errorf("replSetGetStatus: %w", err)
+	}
+
+	status := &ConfigServerStatus{
+		ReplicaSet: cfgResult.Config.ID,
+		ConfigSvr:  cfgResult.Config.ConfigSvr,
+	}
+	for _, m := range rsStatus.Members {
+		healthy := m.Health == 1 && (m.StateStr == "PRIMARY" || m.StateStr == "SECONDARY")
+		status.Members = append(status.Members, ConfigServerMember{Host: m.Name, State: m.StateStr, Healthy: healthy})
+		if healthy {
+			status.Healthy++
+		}
+		if m.StateStr == "PRIMARY" {
+			status.Primary = m.Name
+		}
+		log.Printf("[VERIFY] Config server %s: %s", m.Name, m.StateStr)
+	}
+
+	if !status.ConfigSvr {
+		return status, fmt.Errorf("replica set %s is not a config server: configsvr is not set in its replica set config", status.ReplicaSet)
+	}
+	if status.Primary == "" {
+		return status, fmt.Errorf("config server replica set %s has no primary", status.ReplicaSet)
+	}
+	if majority := len(status.Members)/2 + 1; status.Healthy < majority {
+		return status, fmt.Errorf("config server replica set %s: %d/%d members healthy, need %d", status.ReplicaSet, status.Healthy, len(status.Members), majority)
+	}
+	log.Printf("[VERIFY] Config server replica set '%s': configsvr=true, %d/%d healthy", status.ReplicaSet, status.Healthy, len(status.Members))
+	return status, nil
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTestCommandsDisabled is returned when a fail point is requested on a
+// server that was not started with enableTestCommands=1. Production
+// servers never enable test commands, so this keeps fail points confined
+// to test clusters such as the docker-compose topology.
+var ErrTestCommandsDisabled = errors.New("server not started with --setParameter enableTestCommands=1")
+
+// SetFailPoint enables a server fail point via configureFailPoint on the
+// node the client talks to (a mongos, or a mongod over a direct connection).
+// mode is e.g. {"times": 3} or {"activationProbability": 0.5}; nil means
+// alwaysOn. data is fail point specific, for example
+// {"failCommands": ["find"], "blockConnection": true, "blockTimeMS": 500}
+// for failCommand latency injection.
+func SetFailPoint(ctx context.Context, client *mongo.Client, name string, mode bson.M, data bson.M) error {
+	if err := requireTestCommands(ctx, client); err != nil {
+		return err
+	}
+
+	cmd := bson.D{{Key: "configureFailPoint", Value: name}}
+	if mode == nil {
+		cmd = append(cmd, bson.E{Key: "mode", Value: "alwaysOn"})
+	} else {
+		cmd = append(cmd, bson.E{Key: "mode", Value: mode})
+	}
+	if data != nil {
+		cmd = append(cmd, bson.E{Key: "data", Value: data})
+	}
+
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("configureFailPoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// ClearFailPoint turns a fail point off. Like SetFailPoint, it refuses
+// servers without test commands enabled.
+func ClearFailPoint(ctx context.Context, client *mongo.Client, name string) error {
+	if err := requireTestCommands(ctx, client); err != nil {
+		return err
+	}
+
+	var result bson.M
+	err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "configureFailPoint", Value: name},
+		{Key: "mode", Value: "off"},
+	}).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("clear fail point %s: %w", name, err)
+	}
+	return nil
+}
+
+// InjectCommandLatency delays every listed command on the node the client
+// talks to by delay, using a failCommand fail point that blocks the
+// connection before replying. It stands in for a slower network path to
+// that node; remove it with ClearFailPoint(ctx, client, "failCommand").
+// Commands arriving from mongos count as internal, which failCommand
+// ignores by default, so failInternalCommands is set: on a shard, the
+// routed reads are exactly the ones to slow down.
+func InjectCommandLatency(ctx context.Context, client *mongo.Client, commands []string, delay time.Duration) error {
+	return SetFailPoint(ctx, client, "failCommand", nil, bson.M{
+		"failCommands":         commands,
+		"failInternalCommands": true,
+		"blockConnection":      true,
+		"blockTimeMS":          delay.Milliseconds(),
+	})
+}
+
+// requireTestCommands checks the server's startup options for enableTestCommands.
+func requireTestCommands(ctx context.Context, client *mongo.Client) error {
+	var opts struct {
+		Parsed struct {
+			SetParameter map[string]interface{} `bson:"setParameter"`
+		} `bson:"parsed"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "getCmdLineOpts", Value: 1},
+	}).Decode(&opts); err != nil {
+		return fmt.Errorf("getCmdLineOpts: %w", err)
+	}
+
+	switch v := opts.Parsed.SetParameter["enableTestCommands"].(type) {
+	case string:
+		if v == "1" || v == "true" {
+			return nil
+		}
+	case bool:
+		if v {
+			return nil
+		}
+	}
+	return ErrTestCommandsDisabled
+}
@@ -2,9 +2,12 @@ package cluster
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,6 +15,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
 )
 
 // InitReplicaSet runs rs.initiate() on the first member of the set.
@@ -26,10 +31,14 @@ func InitReplicaSet(ctx context.Context, rsName string, members []config.Member,
 	// Build member list
 	memberDocs := bson.A{}
 	for i, m := range members {
-		memberDocs = append(memberDocs, bson.D{
+		doc := bson.D{
 			{Key: "_id", Value: i},
 			{Key: "host", Value: m.Addr()},
-		})
+		}
+		if len(m.Tags) > 0 {
+			doc = append(doc, bson.E{Key: "tags", Value: m.Tags})
+		}
+		memberDocs = append(memberDocs, doc)
 	}
 
 	rsConfig := bson.D{
@@ -68,10 +77,8 @@ func WaitForPrimary(ctx context.Context, host string, timeout time.Duration) err
 			log.Printf("[OK] PRIMARY elected on %s", host)
 			return nil
 		}
-		select {
-		case <-ctx.Done():
+		if !timeutil.Sleep(ctx, 2*time.Second) {
 			return ctx.Err()
-		case <-time.After(2 * time.Second):
 		}
 	}
 	return fmt.Errorf("timeout waiting for PRIMARY on %s", host)
@@ -100,6 +107,31 @@ func AddShard(ctx context.Context, mongosClient *mongo.Client, rsName string, me
 	return nil
 }
 
+// RemoveShardStatus is the removeShard command's report on a draining shard.
+// State is "started", "ongoing" or "completed".
+type RemoveShardStatus struct {
+	State     string `bson:"state"`
+	Remaining struct {
+		Chunks      int64 `bson:"chunks"`
+		DBs         int64 `bson:"dbs"`
+		JumboChunks int64 `bson:"jumboChunks"`
+	} `bson:"remaining"`
+	DBsToMove []string `bson:"dbsToMove"`
+}
+
+// RemoveShard starts or checks draining of a shard via mongos. The first
+// call starts the drain; repeat it to poll progress until State is
+// "completed". Databases in DBsToMove have the shard as primary and must
+// be moved with movePrimary before the removal can complete.
+func RemoveShard(ctx context.Context, mongosClient *mongo.Client, shardName string) (*RemoveShardStatus, error) {
+	var status RemoveShardStatus
+	err := mongosClient.Database("admin").RunCommand(ctx, bson.D{{Key: "removeShard", Value: shardName}}).Decode(&status)
+	if err != nil {
+		return nil, fmt.Errorf("removeShard %s: %w", shardName, err)
+	}
+	return &status, nil
+}
+
 // EnableSharding enables sharding on a database.
 // In MongoDB 7.0+ this is automatic, so errors are non-fatal.
 func EnableSharding(ctx context.Context, mongosClient *mongo.Client, dbName string) error {
@@ -113,6 +145,40 @@ func EnableSharding(ctx context.Context, mongosClient *mongo.Client, dbName stri
 	return nil
 }
 
+// ShardCollections shards each declared collection in dbName via mongos.
+// Collections that are already sharded are skipped.
+func ShardCollections(ctx context.Context, mongosClient *mongo.Client, dbName string, collections []config.ShardedCollection) error {
+	for _, sc := range collections {
+		ns := dbName + "." + sc.Collection
+
+		count, err := mongosClient.Database("config").Collection("collections").CountDocuments(ctx, bson.D{
+			{Key: "_id", Value: ns},
+			{Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}},
+		})
+		if err != nil {
+			return fmt.Errorf("check %s: %w", ns, err)
+		}
+		if count > 0 {
+			log.Printf("[OK] Collection '%s' already sharded", ns)
+			continue
+		}
+
+		key := bson.D{}
+		for i, field := range sc.Key {
+			if i == 0 && sc.Hashed {
+				key = append(key, bson.E{Key: field, Value: "hashed"})
+			} else {
+				key = append(key, bson.E{Key: field, Value: 1})
+			}
+		}
+		if err := sharding.ShardCollection(ctx, mongosClient, dbName, sc.Collection, key); err != nil {
+			return err
+		}
+		log.Printf("[OK] Collection '%s' sharded on %v", ns, key)
+	}
+	return nil
+}
+
 // CreateAdminUser creates a root admin on a replica set primary.
 func CreateAdminUser(ctx context.Context, host, user, password string) error {
 	uri := fmt.Sprintf("mongodb://%s/?directConnection=true", host)
@@ -146,7 +212,10 @@ func CreateAdminUser(ctx context.Context, host, user, password string) error {
 
 // ConnectMongos connects to a single mongos with auth.
 func ConnectMongos(ctx context.Context, host, user, password string) (*mongo.Client, error) {
-	uri := fmt.Sprintf("mongodb://%s:%s@%s/?authSource=admin", user, password, host)
+	uri, err := config.BuildURI([]string{host}, user, password, "admin")
+	if err != nil {
+		return nil, err
+	}
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(30*time.Second))
 	if err != nil {
 		return nil, fmt.Errorf("connect to mongos %s: %w", host, err)
@@ -158,9 +227,22 @@ func ConnectMongos(ctx context.Context, host, user, password string) (*mongo.Cli
 	return client, nil
 }
 
+// mongosPingTimeout bounds each router's pre-flight ping.
+const mongosPingTimeout = 3 * time.Second
+
 // ConnectMongosMulti connects to multiple mongos instances for failover.
+// Unreachable routers are dropped from the seed list first (see
+// HealthyMongos) so a restarting mongos doesn't slow server selection.
 func ConnectMongosMulti(ctx context.Context, hosts []string, user, password string) (*mongo.Client, error) {
-	uri := fmt.Sprintf("mongodb://%s:%s@%s/?authSource=admin", user, password, strings.Join(hosts, ","))
+	healthy := HealthyMongos(ctx, hosts, mongosPingTimeout)
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("no reachable mongos among %v", hosts)
+	}
+
+	uri, err := config.BuildURI(healthy, user, password, "admin")
+	if err != nil {
+		return nil, err
+	}
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(30*time.Second))
 	if err != nil {
 		return nil, fmt.Errorf("connect to mongos cluster: %w", err)
@@ -172,27 +254,161 @@ func ConnectMongosMulti(ctx context.Context, hosts []string, user, password stri
 	return client, nil
 }
 
+// HealthyMongos pings every host concurrently over a direct connection and
+// returns those that answered within timeout, in their original order.
+// Each router's state is logged.
+func HealthyMongos(ctx context.Context, hosts []string, timeout time.Duration) []string {
+	up := make([]bool, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			if err := pingHost(ctx, host, timeout); err != nil {
+				log.Printf("[WARN] mongos %s unreachable: %v", host, err)
+				return
+			}
+			up[i] = true
+		}(i, host)
+	}
+	wg.Wait()
+
+	var healthy []string
+	for i, host := range hosts {
+		if up[i] {
+			healthy = append(healthy, host)
+		}
+	}
+	log.Printf("[OK] %d/%d mongos routers up: %v", len(healthy), len(hosts), healthy)
+	return healthy
+}
+
+// pingHost runs a single ping against host without authentication.
+func pingHost(ctx context.Context, host string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	uri := fmt.Sprintf("mongodb://%s/?directConnection=true", host)
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetServerSelectionTimeout(timeout))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	return client.Ping(ctx, nil)
+}
+
 // WaitForHost blocks until a MongoDB host responds to ping.
+// Polls with exponential backoff capped at defaultMaxPollInterval.
 func WaitForHost(ctx context.Context, host string, timeout time.Duration) error {
+	return WaitForHostWithBackoff(ctx, host, timeout, defaultMaxPollInterval)
+}
+
+const (
+	initialPollInterval    = 100 * time.Millisecond
+	defaultMaxPollInterval = 5 * time.Second
+)
+
+// WaitForHostWithBackoff blocks until host responds to ping or timeout
+// elapses. A single client is reused across attempts; the wait between
+// pings doubles from 100ms up to maxInterval with ±50% jitter so many
+// callers started together do not poll in lockstep. maxInterval must be
+// positive; below 100ms it is also the first interval.
+func WaitForHostWithBackoff(ctx context.Context, host string, timeout, maxInterval time.Duration) error {
+	if maxInterval <= 0 {
+		return fmt.Errorf("wait for %s: maxInterval must be positive, got %v", host, maxInterval)
+	}
+	deadline := time.After(timeout)
+
 	uri := fmt.Sprintf("mongodb://%s/?directConnection=true&serverSelectionTimeout=5000", host)
-	deadline := time.Now().Add(timeout)
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(5*time.Second))
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", host, err)
+	}
+	defer client.Disconnect(ctx)
 
-	for time.Now().Before(deadline) {
-		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetTimeout(5*time.Second))
-		if err == nil {
-			if pingErr := client.Ping(ctx, nil); pingErr == nil {
-				client.Disconnect(ctx)
-				return nil
-			}
-			client.Disconnect(ctx)
+	interval := min(initialPollInterval, maxInterval)
+	for {
+		if err := client.Ping(ctx, nil); err == nil {
+			return nil
 		}
+
+		wait := jitter(interval)
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(2 * time.Second):
+		case <-deadline:
+			return fmt.Errorf("timeout waiting for %s", host)
+		case <-time.After(wait):
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// jitter spreads a poll interval by ±50%.
+func jitter(interval time.Duration) time.Duration {
+	return interval/2 + time.Duration(rand.Int63n(int64(interval)))
+}
+
+// DefaultConnectWait is how long the cmd tools keep retrying their first
+// connection while a freshly started cluster comes up.
+const DefaultConnectWait = time.Minute
+
+// connectPingTimeout bounds each initial ping so a retry is not stuck
+// behind the client's full operation timeout.
+const connectPingTimeout = 5 * time.Second
+
+// ConnectWithRetry connects with opts and pings until the deployment answers
+// or maxWait elapses, backing off like WaitForHostWithBackoff between
+// attempts. Authentication failures are returned at once since retrying
+// cannot fix them. On failure the client is disconnected.
+func ConnectWithRetry(ctx context.Context, opts *options.ClientOptions, maxWait time.Duration) (*mongo.Client, error) {
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	deadline := time.Now().Add(maxWait)
+	interval := initialPollInterval
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, connectPingTimeout)
+		err = client.Ping(pingCtx, nil)
+		cancel()
+		if err == nil {
+			return client, nil
 		}
+		if isAuthError(err) {
+			client.Disconnect(ctx)
+			return nil, fmt.Errorf("ping: %w", err)
+		}
+		if time.Now().After(deadline) {
+			client.Disconnect(ctx)
+			return nil, fmt.Errorf("ping failed after %d attempts over %v: %w", attempt, maxWait, err)
+		}
+		log.Printf("  [WARN] cluster not ready (attempt %d): %v", attempt, err)
+
+		if !timeutil.Sleep(ctx, jitter(interval)) {
+			client.Disconnect(context.Background())
+			return nil, ctx.Err()
+		}
+
+		interval *= 2
+		if interval > defaultMaxPollInterval {
+			interval = defaultMaxPollInterval
+		}
+	}
+}
+
+// isAuthError reports a rejected login (AuthenticationFailed).
+func isAuthError(err error) bool {
+	var se mongo.ServerError
+	if errors.As(err, &se) && se.HasErrorCode(18) {
+		return true
 	}
-	return fmt.Errorf("timeout waiting for %s", host)
+	return containsAny(err.Error(), "AuthenticationFailed", "Authentication failed")
 }
 
 // hasPrimary checks if any member in the replica set is PRIMARY.
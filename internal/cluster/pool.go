@@ -0,0 +1,209 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/stats"
+)
+
+// Server monitoring settings ForFailover applies: the driver notices an
+// election within a heartbeat or two, and an operation with no primary to
+// send to fails after seconds instead of waiting out a 30s selection.
+const (
+	FailoverServerSelectionTimeout = 5 * time.Second
+	FailoverHeartbeatInterval      = 500 * time.Millisecond // the driver's minimum
+)
+
+// ClientTuning is the connection pool and server monitoring configuration
+// the cmd tools connect with. Zero fields keep the driver default.
+type ClientTuning struct {
+	MinPoolSize            uint64
+	MaxPoolSize            uint64
+	MaxConnIdleTime        time.Duration
+	Timeout                time.Duration // per-operation client timeout
+	ServerSelectionTimeout time.Duration // how long an operation waits for a suitable server
+	HeartbeatInterval      time.Duration // how often each server is re-checked
+}
+
+// NewClientTuning returns a 30s operation timeout with the server selection
+// timeout and heartbeat interval from cfg, and the driver's default pool.
+func NewClientTuning(cfg *config.ClusterConfig) ClientTuning {
+	return ClientTuning{
+		Timeout:                30 * time.Second,
+		ServerSelectionTimeout: cfg.ServerSelectionTimeout,
+		HeartbeatInterval:      cfg.HeartbeatInterval,
+	}
+}
+
+// WithPool returns t with the pool grpc-server and the benchmarks run with:
+// 100 pre-warmed connections per mongos, up to 500, idle ones reclaimed
+// after 5 minutes.
+func (t ClientTuning) WithPool() ClientTuning {
+	t.MinPoolSize = 100
+	t.MaxPoolSize = 500
+	t.MaxConnIdleTime = 5 * time.Minute
+	return t
+}
+
+// ForFailover returns t with server selection and heartbeat lowered to the
+// Failover values, keeping either one if it is already configured lower.
+func (t ClientTuning) ForFailover() ClientTuning {
+	if t.ServerSelectionTimeout == 0 || t.ServerSelectionTimeout > FailoverServerSelectionTimeout {
+		t.ServerSelectionTimeout = FailoverServerSelectionTimeout
+	}
+	if t.HeartbeatInterval == 0 || t.HeartbeatInterval > FailoverHeartbeatInterval {
+		t.HeartbeatInterval = FailoverHeartbeatInterval
+	}
+	return t
+}
+
+// Apply sets t's non-zero fields on opts and returns opts.
+func (t ClientTuning) Apply(opts *options.ClientOptions) *options.ClientOptions {
+	if t.MinPoolSize > 0 {
+		opts.SetMinPoolSize(t.MinPoolSize)
+	}
+	if t.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(t.MaxPoolSize)
+	}
+	if t.MaxConnIdleTime > 0 {
+		opts.SetMaxConnIdleTime(t.MaxConnIdleTime)
+	}
+	if t.Timeout > 0 {
+		opts.SetTimeout(t.Timeout)
+	}
+	if t.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(t.ServerSelectionTimeout)
+	}
+	if t.HeartbeatInterval > 0 {
+		opts.SetHeartbeatInterval(t.HeartbeatInterval)
+	}
+	return opts
+}
+
+// poolWaitSamples bounds how many recent checkout waits PoolStats keeps for
+// percentiles, so a long-running process does not grow without limit.
+const poolWaitSamples = 4096
+
+// PoolStats accumulates driver connection pool events: connection churn,
+// checkouts and their wait times, failed checkouts by reason, and pool
+// clears. Install it with Monitor; it is safe for concurrent use.
+type PoolStats struct {
+	mu              sync.Mutex
+	created         int64
+	closed          int64
+	checkOutStarted int64
+	checkedOut      int64
+	failed          map[string]int64
+	cleared         int64
+	waitTotal       time.Duration
+	waitMax         time.Duration
+	waits           []time.Duration // ring buffer of the latest checkout waits
+	next            int
+}
+
+// PoolSnapshot is a point-in-time copy of PoolStats.
+type PoolSnapshot struct {
+	Created         int64
+	Closed          int64
+	CheckOutStarted int64
+	CheckedOut      int64
+	Failed          map[string]int64 // by reason: timeout, connectionError, poolClosed
+	Cleared         int64
+	WaitAvg         time.Duration
+	WaitP50         time.Duration
+	WaitP99         time.Duration // over the latest poolWaitSamples checkouts
+	WaitMax         time.Duration
+}
+
+// FailedTotal is the number of checkouts that failed for any reason.
+func (s PoolSnapshot) FailedTotal() int64 {
+	var n int64
+	for _, c := range s.Failed {
+		n += c
+	}
+	return n
+}
+
+// NewPoolStats returns an empty collector.
+func NewPoolStats() *PoolStats {
+	return &PoolStats{failed: make(map[string]int64)}
+}
+
+// Monitor returns a PoolMonitor that records every event into s and then
+// passes it to next, if non-nil, for logging.
+func (s *PoolStats) Monitor(next func(*event.PoolEvent)) *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			s.record(e)
+			if next != nil {
+				next(e)
+			}
+		},
+	}
+}
+
+func (s *PoolStats) record(e *event.PoolEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Type {
+	case event.ConnectionCreated:
+		s.created++
+	case event.ConnectionClosed:
+		s.closed++
+	case event.GetStarted:
+		s.checkOutStarted++
+	case event.GetSucceeded:
+		s.checkedOut++
+		s.addWait(e.Duration)
+	case event.GetFailed:
+		s.failed[e.Reason]++
+		s.addWait(e.Duration)
+	case event.PoolCleared:
+		s.cleared++
+	}
+}
+
+// addWait records one checkout wait; the caller holds s.mu.
+func (s *PoolStats) addWait(d time.Duration) {
+	s.waitTotal += d
+	if d > s.waitMax {
+		s.waitMax = d
+	}
+	if len(s.waits) < poolWaitSamples {
+		s.waits = append(s.waits, d)
+		return
+	}
+	s.waits[s.next] = d
+	s.next = (s.next + 1) % poolWaitSamples
+}
+
+// Snapshot returns the counts and checkout wait statistics so far.
+func (s *PoolStats) Snapshot() PoolSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := PoolSnapshot{
+		Created:         s.created,
+		Closed:          s.closed,
+		CheckOutStarted: s.checkOutStarted,
+		CheckedOut:      s.checkedOut,
+		Failed:          make(map[string]int64, len(s.failed)),
+		Cleared:         s.cleared,
+		WaitMax:         s.waitMax,
+	}
+	for reason, n := range s.failed {
+		snap.Failed[reason] = n
+	}
+	if n := s.checkedOut + snap.FailedTotal(); n > 0 {
+		snap.WaitAvg = s.waitTotal / time.Duration(n)
+	}
+
+	waits := stats.Percentiles(s.waits, 0.50, 0.99)
+	snap.WaitP50, snap.WaitP99 = waits[0], waits[1]
+	return snap
+}
@@ -11,30 +11,60 @@ import (
 )
 
 // ClusterStatus holds a snapshot of the sharded cluster state.
+// JSON tags make it suitable for scripting (see sharding-poc -json).
 type ClusterStatus struct {
-	Shards    []ShardInfo
-	Balancer  BalancerInfo
-	Databases []DatabaseInfo
+	Shards             []ShardInfo             `json:"shards"`
+	Balancer           BalancerInfo            `json:"balancer"`
+	Databases          []DatabaseInfo          `json:"databases"`
+	ShardedCollections []ShardedCollectionInfo `json:"shardedCollections"`
 }
 
 // ShardInfo represents one registered shard.
+// Host is the raw "rsName/host1,host2" string; ReplicaSet and Members are
+// parsed from it.
 type ShardInfo struct {
-	ID    string
-	Host  string
-	State int
+	ID         string   `json:"id"`
+	Host       string   `json:"host"`
+	ReplicaSet string   `json:"replicaSet"`
+	Members    []string `json:"members"`
+	State      int      `json:"state"`
 }
 
 // BalancerInfo represents the balancer state.
 type BalancerInfo struct {
-	Enabled bool
+	Enabled bool            `json:"enabled"`
+	Mode    string          `json:"mode"`
+	Window  *BalancerWindow `json:"activeWindow,omitempty"`
+}
+
+// BalancerWindow is the configured balancing window (server-local time).
+type BalancerWindow struct {
+	Start string `json:"start" bson:"start"`
+	Stop  string `json:"stop" bson:"stop"`
 }
 
 // DatabaseInfo represents a database in the cluster.
 type DatabaseInfo struct {
-	Name string
+	Name string `json:"name"`
+}
+
+// ShardedCollectionInfo is one entry from config.collections.
+// Key is a list rather than a map because shard key field order matters.
+type ShardedCollectionInfo struct {
+	Namespace string          `json:"namespace"`
+	Key       []ShardKeyField `json:"key"`
+	Unique    bool            `json:"unique"`
+	NoBalance bool            `json:"noBalance"`
 }
 
-// GetClusterStatus fetches shard, balancer, and database info from mongos.
+// ShardKeyField is one field of a shard key: 1 for ranged, "hashed" for hashed.
+type ShardKeyField struct {
+	Field string      `json:"field"`
+	Spec  interface{} `json:"spec"`
+}
+
+// GetClusterStatus fetches shard, balancer, database, and sharded
+// collection info from mongos.
 func GetClusterStatus(ctx context.Context, client *mongo.Client) (*ClusterStatus, error) {
 	status := &ClusterStatus{}
 
@@ -46,10 +76,14 @@ func GetClusterStatus(ctx context.Context, client *mongo.Client) (*ClusterStatus
 	if shards, ok := shardsResult["shards"].(bson.A); ok {
 		for _, s := range shards {
 			if m, ok := s.(bson.M); ok {
+				host := stringField(m, "host")
+				rs, members := parseShardHost(host)
 				status.Shards = append(status.Shards, ShardInfo{
-					ID:    stringField(m, "_id"),
-					Host:  stringField(m, "host"),
-					State: intField(m, "state"),
+					ID:         stringField(m, "_id"),
+					Host:       host,
+					ReplicaSet: rs,
+					Members:    members,
+					State:      intField(m, "state"),
 				})
 			}
 		}
@@ -59,10 +93,18 @@ func GetClusterStatus(ctx context.Context, client *mongo.Client) (*ClusterStatus
 	var balResult bson.M
 	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "balancerStatus", Value: 1}}).Decode(&balResult); err == nil {
 		if mode, ok := balResult["mode"].(string); ok {
+			status.Balancer.Mode = mode
 			status.Balancer.Enabled = (mode == "full")
 		}
 	}
 
+	var balSettings struct {
+		ActiveWindow *BalancerWindow `bson:"activeWindow"`
+	}
+	if err := client.Database("config").Collection("settings").FindOne(ctx, bson.M{"_id": "balancer"}).Decode(&balSettings); err == nil {
+		status.Balancer.Window = balSettings.ActiveWindow
+	}
+
 	// Fetch database list
 	var dbResult bson.M
 	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "listDatabases", Value: 1}}).Decode(&dbResult); err == nil {
@@ -77,9 +119,46 @@ func GetClusterStatus(ctx context.Context, client *mongo.Client) (*ClusterStatus
 		}
 	}
 
+	// Fetch sharded collections
+	cursor, err := client.Database("config").Collection("collections").Find(ctx,
+		bson.M{"dropped": bson.M{"$ne": true}})
+	if err == nil {
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var c struct {
+				ID        string `bson:"_id"`
+				Key       bson.D `bson:"key"`
+				Unique    bool   `bson:"unique"`
+				NoBalance bool   `bson:"noBalance"`
+			}
+			if cursor.Decode(&c) != nil || strings.HasPrefix(c.ID, "config.") {
+				continue
+			}
+			info := ShardedCollectionInfo{
+				Namespace: c.ID,
+				Unique:    c.Unique,
+				NoBalance: c.NoBalance,
+			}
+			for _, k := range c.Key {
+				info.Key = append(info.Key, ShardKeyField{Field: k.Key, Spec: k.Value})
+			}
+			status.ShardedCollections = append(status.ShardedCollections, info)
+		}
+	}
+
 	return status, nil
 }
 
+// parseShardHost splits "rsName/host1:port,host2:port" into the replica
+// set name and member list. A host without "/" is a standalone member.
+func parseShardHost(host string) (string, []string) {
+	rs, members, ok := strings.Cut(host, "/")
+	if !ok {
+		return "", []string{host}
+	}
+	return rs, strings.Split(members, ",")
+}
+
 // PrintClusterStatus prints a formatted cluster report.
 func PrintClusterStatus(s *ClusterStatus) {
 	log.Println("")
@@ -101,6 +180,9 @@ func PrintClusterStatus(s *ClusterStatus) {
 		balancer = "ENABLED"
 	}
 	log.Printf("  Balancer: %s", balancer)
+	if w := s.Balancer.Window; w != nil {
+		log.Printf("    Window: %s - %s", w.Start, w.Stop)
+	}
 
 	log.Println("")
 	log.Printf("  Databases: %d", len(s.Databases))
@@ -108,6 +190,16 @@ func PrintClusterStatus(s *ClusterStatus) {
 		log.Printf("    %s", db.Name)
 	}
 
+	log.Println("")
+	log.Printf("  Sharded collections: %d", len(s.ShardedCollections))
+	for _, c := range s.ShardedCollections {
+		fields := make([]string, 0, len(c.Key))
+		for _, k := range c.Key {
+			fields = append(fields, fmt.Sprintf("%s: %v", k.Field, k.Spec))
+		}
+		log.Printf("    %s { %s }", c.Namespace, strings.Join(fields, ", "))
+	}
+
 	log.Println("")
 	log.Println("=============================")
 	log.Println("")
@@ -0,0 +1,217 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// storageWarnUtilization is the filesystem usage at which a shard counts as
+// approaching capacity.
+const storageWarnUtilization = 0.80
+
+// storageMaxDeviation is how far a shard's data may stray from an even
+// share before the cluster counts as imbalanced.
+const storageMaxDeviation = 0.20
+
+// ShardStorage is one shard's on-disk footprint. DataBytes sums the
+// shard's sizeOnDisk over every database; FSUsedBytes and FSTotalBytes
+// describe the filesystem its dbPath lives on (0 when no database reported
+// them). DataDeviation is DataBytes' signed deviation from an even share.
+type ShardStorage struct {
+	Shard         string  `json:"shard"`
+	DataBytes     int64   `json:"dataBytes"`
+	FSUsedBytes   int64   `json:"fsUsedBytes"`
+	FSTotalBytes  int64   `json:"fsTotalBytes"`
+	Utilization   float64 `json:"utilization"`
+	DataDeviation float64 `json:"dataDeviation"`
+	NearCapacity  bool    `json:"nearCapacity"`
+}
+
+// FreeBytes returns the space left on the shard's filesystem.
+func (s ShardStorage) FreeBytes() int64 {
+	return s.FSTotalBytes - s.FSUsedBytes
+}
+
+// StorageReport is the cluster-wide storage picture from
+// ClusterStorageReport.
+type StorageReport struct {
+	Shards         []ShardStorage `json:"shards"`
+	TotalDataBytes int64          `json:"totalDataBytes"`
+	MaxDeviation   float64        `json:"maxDeviation"` // largest |DataDeviation|
+	Imbalanced     bool           `json:"imbalanced"`
+	NearCapacity   []string       `json:"nearCapacity,omitempty"`
+}
+
+// ClusterStorageReport reports every shard's data size, filesystem usage,
+// and share of the cluster's data, flagging shards whose filesystem is at
+// least 80% full and a data spread more than 20% off even. Per-collection
+// reports (sharding.GetFairnessReport) miss the shard that is filling up
+// because of many small collections, or because of data outside the app
+// database. Everything is read through mongos: listDatabases gives each
+// database's size per shard, and dbStats, which mongos fans out to every
+// shard, gives the filesystem sizes.
+func ClusterStorageReport(ctx context.Context, adminClient *mongo.Client) (*StorageReport, error) {
+	status, err := GetClusterStatus(ctx, adminClient)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Shards) == 0 {
+		return nil, fmt.Errorf("no shards registered")
+	}
+
+	byShard := make(map[string]*ShardStorage, len(status.Shards))
+	report := &StorageReport{Shards: make([]ShardStorage, len(status.Shards))}
+	for i, s := range status.Shards {
+		report.Shards[i].Shard = s.ID
+		byShard[s.ID] = &report.Shards[i]
+	}
+
+	var listing struct {
+		Databases []struct {
+			Name   string           `bson:"name"`
+			Shards map[string]int64 `bson:"shards"`
+		} `bson:"databases"`
+	}
+	if err := adminClient.Database("admin").RunCommand(ctx, bson.D{{Key: "listDatabases", Value: 1}}).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("listDatabases: %w", err)
+	}
+
+	for _, db := range listing.Databases {
+		for shard, size := range db.Shards {
+			if s, ok := byShard[shard]; ok {
+				s.DataBytes += size
+				report.TotalDataBytes += size
+			}
+		}
+		if db.Name == "admin" || db.Name == "config" {
+			continue
+		}
+		// Any database will do for the filesystem sizes; stop asking once
+		// every shard has answered
+		if err := collectFSStats(ctx, adminClient, db.Name, status.Shards, byShard); err != nil {
+			log.Printf("  [WARN] dbStats %s: %v", db.Name, err)
+		}
+		if fsStatsComplete(report.Shards) {
+			break
+		}
+	}
+
+	even := float64(report.TotalDataBytes) / float64(len(report.Shards))
+	for i := range report.Shards {
+		s := &report.Shards[i]
+		if s.FSTotalBytes > 0 {
+			s.Utilization = float64(s.FSUsedBytes) / float64(s.FSTotalBytes)
+			s.NearCapacity = s.Utilization >= storageWarnUtilization
+		}
+		if even > 0 {
+			s.DataDeviation = (float64(s.DataBytes) - even) / even
+		}
+		report.MaxDeviation = math.Max(report.MaxDeviation, math.Abs(s.DataDeviation))
+		if s.NearCapacity {
+			report.NearCapacity = append(report.NearCapacity, s.Shard)
+		}
+	}
+	report.Imbalanced = report.MaxDeviation > storageMaxDeviation
+	return report, nil
+}
+
+// collectFSStats runs dbStats on db through mongos and records the
+// filesystem sizes each shard reported in its raw section, for shards that
+// have none yet.
+func collectFSStats(ctx context.Context, client *mongo.Client, db string, shards []ShardInfo, byShard map[string]*ShardStorage) error {
+	var result struct {
+		Raw map[string]struct {
+			FSUsedSize  float64 `bson:"fsUsedSize"`
+			FSTotalSize float64 `bson:"fsTotalSize"`
+		} `bson:"raw"`
+	}
+	if err := client.Database(db).RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}}).Decode(&result); err != nil {
+		return err
+	}
+	for key, stats := range result.Raw {
+		id := shardForRawKey(key, shards)
+		s, ok := byShard[id]
+		if !ok || s.FSTotalBytes > 0 || stats.FSTotalSize == 0 {
+			continue
+		}
+		s.FSUsedBytes = int64(stats.FSUsedSize)
+		s.FSTotalBytes = int64(stats.FSTotalSize)
+	}
+	return nil
+}
+
+// shardForRawKey maps a dbStats raw key, the shard's connection string
+// ("rs/host1,host2") or its ID, to the shard ID.
+func shardForRawKey(key string, shards []ShardInfo) string {
+	rs, _, _ := strings.Cut(key, "/")
+	for _, s := range shards {
+		if key == s.ID || key == s.Host || (s.ReplicaSet != "" && rs == s.ReplicaSet) {
+			return s.ID
+		}
+	}
+	return ""
+}
+
+func fsStatsComplete(shards []ShardStorage) bool {
+	for _, s := range shards {
+		if s.FSTotalBytes == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// PrintStorageReport logs each shard's data, filesystem usage, and share of
+// the data, then flags shards near capacity and an uneven spread.
+func PrintStorageReport(r *StorageReport) {
+	const mb = 1024 * 1024
+	log.Println("  Storage by shard:")
+	log.Printf("    %-12s %10s %7s %10s %10s %6s", "shard", "data MB", "dev", "free MB", "total MB", "used")
+	for _, s := range r.Shards {
+		if s.FSTotalBytes == 0 {
+			log.Printf("    %-12s %10.1f %+6.0f%% %10s %10s %6s", s.Shard,
+				float64(s.DataBytes)/mb, s.DataDeviation*100, "n/a", "n/a", "n/a")
+			continue
+		}
+		log.Printf("    %-12s %10.1f %+6.0f%% %10.0f %10.0f %5.0f%%", s.Shard,
+			float64(s.DataBytes)/mb, s.DataDeviation*100,
+			float64(s.FreeBytes())/mb, float64(s.FSTotalBytes)/mb, s.Utilization*100)
+	}
+	log.Printf("    %-12s %10.1f", "total", float64(r.TotalDataBytes)/mb)
+
+	for _, s := range r.Shards {
+		if s.NearCapacity {
+			log.Printf("  [WARN] %s filesystem is %.0f%% full (%.0f MB free); add capacity or move chunks off it",
+				s.Shard, s.Utilization*100, float64(s.FreeBytes())/mb)
+		}
+	}
+	if r.Imbalanced {
+		log.Printf("  [WARN] Data is unevenly spread: a shard is %.0f%% off an even share (limit %.0f%%)",
+			r.MaxDeviation*100, storageMaxDeviation*100)
+	} else {
+		log.Printf("  [OK] Data is within %.0f%% of an even share on every shard", storageMaxDeviation*100)
+	}
+	if sharedFilesystem(r.Shards) {
+		log.Println("  [INFO] Every shard reports the same filesystem size: they likely share one disk, so free space is shared too")
+	}
+}
+
+// sharedFilesystem reports whether several shards report identical,
+// known filesystem totals, as containers on one host do.
+func sharedFilesystem(shards []ShardStorage) bool {
+	if len(shards) < 2 || shards[0].FSTotalBytes == 0 {
+		return false
+	}
+	for _, s := range shards[1:] {
+		if s.FSTotalBytes != shards[0].FSTotalBytes {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,17 @@
 package config
 
-import "os"
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
 
 // ClusterConfig holds all settings for the MongoDB sharded cluster.
 type ClusterConfig struct {
@@ -15,12 +26,85 @@ type ClusterConfig struct {
 	Shards           []ReplicaSet
 	MongosHosts      []string
 
+	// Shard replica set kept out of the initial cluster for the scale-out
+	// labs to add and drain (docker compose --profile scale-out).
+	SpareShard ReplicaSet
+
+	// Bytes of filler added to generated documents in the chunk and jumbo
+	// labs; larger documents fill chunks faster. 0 uses each lab's default.
+	DocPaddingBytes int
+
+	// Multiplier for every demo's and lab's document count (1 = built-in sizes)
+	DemoScale float64
+
+	// Collection throughput-lab drops and refills in AppDatabase.
+	BenchCollection string
+
+	// Driver server monitoring for the cmd tools: how long an operation
+	// waits for a suitable server, and how often each server is checked for
+	// topology changes. The HA labs lower both further (see
+	// cluster.ClientTuning.ForFailover).
+	ServerSelectionTimeout time.Duration
+	HeartbeatInterval      time.Duration
+
+	// How the HA labs stop and start nodes: "docker" (default) or
+	// "kubernetes" (kubectl pod delete in K8sNamespace).
+	HABackend    string
+	K8sNamespace string
+
+	// Prepended to hostnames to get the container or pod names the HA labs
+	// stop and start, for compose projects that prefix container names.
+	ContainerPrefix string
+
+	// Collections the setup tool shards in AppDatabase.
+	// Env format: MONGO_SHARDED_COLLECTIONS="users:user_id:hashed,orders:customer_id+order_date"
+	ShardedCollections []ShardedCollection
+
+	// Application databases the setup tool shards and creates users in.
+	// The first entry is always AppDatabase with the settings above; more
+	// come from MONGO_APP_DATABASES (see loadDatabases).
+	Databases []Database
+
 	// gRPC client-side load balancing
 	// Target formats:
 	//   Local:  "static:///localhost:50051"
 	//   K8s:    "dns:///grpc-server-headless.sharding-poc.svc.cluster.local:50051"
 	GRPCTarget   string
 	GRPCLBPolicy string // "round_robin" (default) or "pick_first"
+
+	// Connect to at most this many resolved backends (0 = all of them)
+	GRPCSubsetSize int
+
+	// How long grpc-server waits for in-flight RPCs and streams on shutdown
+	// before closing them; keep it under the pod's termination grace period
+	GRPCShutdownTimeout time.Duration
+
+	// grpc-server circuit breaker: after this many consecutive MongoDB
+	// connection failures it fails fast and reports NOT_SERVING, probing
+	// every cooldown (0 failures = disabled)
+	GRPCBreakerFailures int
+	GRPCBreakerCooldown time.Duration
+}
+
+// ShardedCollection declares a collection and its shard key.
+// Key lists the key fields in order; when Hashed is set the first field
+// is hashed and any remaining fields are ascending.
+type ShardedCollection struct {
+	Collection string
+	Key        []string
+	Hashed     bool
+}
+
+// Database is one application database with its own users and sharded
+// collections. User names may repeat across databases; MongoDB scopes each
+// user to the database it was created in.
+type Database struct {
+	Name               string
+	AppUser            string
+	AppPassword        string
+	ReadOnlyUser       string
+	ReadOnlyPassword   string
+	ShardedCollections []ShardedCollection
 }
 
 // ReplicaSet represents a named set of MongoDB members.
@@ -30,9 +114,12 @@ type ReplicaSet struct {
 }
 
 // Member represents a single mongod node.
+// Tags are applied to the replica set member config at initiation and
+// are used for read-preference tag-set routing (e.g. {"dc": "west"}).
 type Member struct {
 	Host string
 	Port string
+	Tags map[string]string
 }
 
 // Addr returns host:port for this member.
@@ -40,16 +127,127 @@ func (m Member) Addr() string {
 	return m.Host + ":" + m.Port
 }
 
+// ShardHostsWithTag returns the hostnames of shard members tagged key=value.
+func (c *ClusterConfig) ShardHostsWithTag(key, value string) []string {
+	var hosts []string
+	for _, rs := range c.Shards {
+		for _, m := range rs.Members {
+			if m.Tags[key] == value {
+				hosts = append(hosts, m.Host)
+			}
+		}
+	}
+	return hosts
+}
+
 // Load builds cluster config from environment variables with defaults.
-func Load() *ClusterConfig {
-	return &ClusterConfig{
-		AdminUser:        env("MONGO_ADMIN_USER", "clusterAdmin"),
-		AdminPassword:    env("MONGO_ADMIN_PASSWORD", "admin123"),
-		AppUser:          env("MONGO_APP_USER", "appUser"),
-		AppPassword:      env("MONGO_APP_PASSWORD", "app123"),
-		ReadOnlyUser:     env("MONGO_READONLY_USER", "readOnlyUser"),
-		ReadOnlyPassword: env("MONGO_READONLY_PASSWORD", "read123"),
-		AppDatabase:      env("MONGO_APP_DATABASE", "sharding_poc"),
+// Numbers and durations that do not parse fall back to their defaults, but
+// a malformed sharded-collection list is an error: skipping an entry would
+// leave a collection silently unsharded.
+func Load() (*ClusterConfig, error) {
+	return load(os.Getenv)
+}
+
+// LoadFromFile builds cluster config from a .env-style file of KEY=VALUE
+// lines (blank lines and # comments ignored, values optionally quoted).
+// Variables set in the process environment take precedence over the file,
+// and anything missing from both falls back to the defaults.
+func LoadFromFile(path string) (*ClusterConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config %s: %w", path, err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE, got %q", path, n, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	cfg, err := load(func(key string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return values[key]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// BuildURI returns a mongodb:// connection string for hosts. Credentials
+// are percent-escaped, so passwords containing '@', ':' or '/' are safe.
+// authSource defaults to admin when user is set.
+func BuildURI(hosts []string, user, password, authSource string) (string, error) {
+	if len(hosts) == 0 {
+		return "", fmt.Errorf("build uri: no hosts")
+	}
+	for _, h := range hosts {
+		if h == "" || strings.ContainsAny(h, "/@?, ") {
+			return "", fmt.Errorf("build uri: invalid host %q", h)
+		}
+	}
+	if user == "" && password != "" {
+		return "", fmt.Errorf("build uri: password given without user")
+	}
+
+	u := url.URL{Scheme: "mongodb", Host: strings.Join(hosts, ","), Path: "/"}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+		if authSource == "" {
+			authSource = "admin"
+		}
+	}
+	if authSource != "" {
+		u.RawQuery = url.Values{"authSource": {authSource}}.Encode()
+	}
+	return u.String(), nil
+}
+
+// load builds the config, reading variables through get.
+func load(get func(string) string) (*ClusterConfig, error) {
+	l := lookup(get)
+	shardedCollections, err := parseShardedCollections("MONGO_SHARDED_COLLECTIONS", l("MONGO_SHARDED_COLLECTIONS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ClusterConfig{
+		AdminUser:        l.env("MONGO_ADMIN_USER", "clusterAdmin"),
+		AdminPassword:    l.env("MONGO_ADMIN_PASSWORD", "admin123"),
+		AppUser:          l.env("MONGO_APP_USER", "appUser"),
+		AppPassword:      l.env("MONGO_APP_PASSWORD", "app123"),
+		ReadOnlyUser:     l.env("MONGO_READONLY_USER", "readOnlyUser"),
+		ReadOnlyPassword: l.env("MONGO_READONLY_PASSWORD", "read123"),
+		AppDatabase:      l.env("MONGO_APP_DATABASE", "sharding_poc"),
+
+		DocPaddingBytes:    l.envInt("MONGO_DOC_PADDING_BYTES", 0),
+		DemoScale:          l.envFloat("MONGO_DEMO_SCALE", 1),
+		BenchCollection:    l.env("MONGO_BENCH_COLLECTION", "throughput_bench"),
+		ContainerPrefix:    l.env("MONGO_CONTAINER_PREFIX", ""),
+		HABackend:          l.env("MONGO_HA_BACKEND", "docker"),
+		K8sNamespace:       l.env("MONGO_K8S_NAMESPACE", "sharding-poc"),
+		ShardedCollections: shardedCollections,
+
+		ServerSelectionTimeout: l.envDuration("MONGO_SERVER_SELECTION_TIMEOUT", 30*time.Second),
+		HeartbeatInterval:      l.envDuration("MONGO_HEARTBEAT_INTERVAL", 10*time.Second),
 
 		ConfigRS: ReplicaSet{
 			Name: "configrs",
@@ -60,45 +258,283 @@ func Load() *ClusterConfig {
 			},
 		},
 
+		// Shard members carry a "dc" tag: members 1-2 in "east", member 3 in "west"
 		Shards: []ReplicaSet{
 			{
 				Name: "shard1rs",
 				Members: []Member{
-					{Host: "shard1-1", Port: "27022"},
-					{Host: "shard1-2", Port: "27023"},
-					{Host: "shard1-3", Port: "27024"},
+					{Host: "shard1-1", Port: "27022", Tags: map[string]string{"dc": "east"}},
+					{Host: "shard1-2", Port: "27023", Tags: map[string]string{"dc": "east"}},
+					{Host: "shard1-3", Port: "27024", Tags: map[string]string{"dc": "west"}},
 				},
 			},
 			{
 				Name: "shard2rs",
 				Members: []Member{
-					{Host: "shard2-1", Port: "27025"},
-					{Host: "shard2-2", Port: "27026"},
-					{Host: "shard2-3", Port: "27027"},
+					{Host: "shard2-1", Port: "27025", Tags: map[string]string{"dc": "east"}},
+					{Host: "shard2-2", Port: "27026", Tags: map[string]string{"dc": "east"}},
+					{Host: "shard2-3", Port: "27027", Tags: map[string]string{"dc": "west"}},
 				},
 			},
 			{
 				Name: "shard3rs",
 				Members: []Member{
-					{Host: "shard3-1", Port: "27028"},
-					{Host: "shard3-2", Port: "27029"},
-					{Host: "shard3-3", Port: "27030"},
+					{Host: "shard3-1", Port: "27028", Tags: map[string]string{"dc": "east"}},
+					{Host: "shard3-2", Port: "27029", Tags: map[string]string{"dc": "east"}},
+					{Host: "shard3-3", Port: "27030", Tags: map[string]string{"dc": "west"}},
 				},
 			},
 		},
 
-		MongosHosts: []string{
-			"localhost:27017",
-			"localhost:27018",
+		SpareShard: ReplicaSet{
+			Name: "shard4rs",
+			Members: []Member{
+				{Host: "shard4-1", Port: "27031", Tags: map[string]string{"dc": "east"}},
+			},
 		},
 
-		GRPCTarget:   env("GRPC_LB_TARGET", "static:///localhost:50051"),
-		GRPCLBPolicy: env("GRPC_LB_POLICY", "round_robin"),
+		// Comma-separated so deployments with more (or one) routers can override
+		MongosHosts: splitHosts(l.env("MONGO_MONGOS_HOSTS", "localhost:27017,localhost:27018")),
+
+		GRPCTarget:   l.env("GRPC_LB_TARGET", "static:///localhost:50051"),
+		GRPCLBPolicy: l.env("GRPC_LB_POLICY", "round_robin"),
+
+		GRPCSubsetSize: l.envInt("GRPC_LB_SUBSET_SIZE", 0),
+
+		GRPCShutdownTimeout: l.envDuration("GRPC_SHUTDOWN_TIMEOUT", 20*time.Second),
+
+		GRPCBreakerFailures: l.envInt("GRPC_BREAKER_FAILURES", 5),
+		GRPCBreakerCooldown: l.envDuration("GRPC_BREAKER_COOLDOWN", 10*time.Second),
+	}
+	if cfg.Databases, err = loadDatabases(l, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadDatabases builds the application database list: AppDatabase first,
+// then each other name in MONGO_APP_DATABASES="orders,billing". Every
+// setting can be overridden per database with MONGO_DB_<NAME>_APP_USER,
+// _APP_PASSWORD, _READONLY_USER, _READONLY_PASSWORD and
+// _SHARDED_COLLECTIONS (NAME upper-cased, other characters as '_').
+// Users default to the global ones; sharded collections default to
+// MONGO_SHARDED_COLLECTIONS for AppDatabase and to none for the rest.
+func loadDatabases(l lookup, cfg *ClusterConfig) ([]Database, error) {
+	names := []string{cfg.AppDatabase}
+	seen := map[string]bool{cfg.AppDatabase: true}
+	for _, name := range strings.Split(l("MONGO_APP_DATABASES"), ",") {
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
+
+	dbs := make([]Database, 0, len(names))
+	var errs []error
+	prefixOwner := make(map[string]string, len(names))
+	for i, name := range names {
+		prefix := "MONGO_DB_" + envName(name) + "_"
+		if other, ok := prefixOwner[prefix]; ok {
+			errs = append(errs, fmt.Errorf("MONGO_APP_DATABASES: %q and %q both map to %s* variables", other, name, prefix))
+			continue
+		}
+		prefixOwner[prefix] = name
+		collections, err := parseShardedCollections(prefix+"SHARDED_COLLECTIONS", l(prefix+"SHARDED_COLLECTIONS"))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		db := Database{
+			Name:               name,
+			AppUser:            l.env(prefix+"APP_USER", cfg.AppUser),
+			AppPassword:        l.env(prefix+"APP_PASSWORD", cfg.AppPassword),
+			ReadOnlyUser:       l.env(prefix+"READONLY_USER", cfg.ReadOnlyUser),
+			ReadOnlyPassword:   l.env(prefix+"READONLY_PASSWORD", cfg.ReadOnlyPassword),
+			ShardedCollections: collections,
+		}
+		if i == 0 && db.ShardedCollections == nil {
+			db.ShardedCollections = cfg.ShardedCollections
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, errors.Join(errs...)
+}
+
+// envName upper-cases name and replaces anything but letters and digits
+// with '_', so "billing-v2" becomes "BILLING_V2". Distinct names can share
+// a result ("billing_v2" does too); loadDatabases rejects those.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// MongosSeedList joins all mongos hosts for a connection string, so the
+// driver spreads operations across every router instead of pinning one.
+func (c *ClusterConfig) MongosSeedList() string {
+	return strings.Join(c.MongosHosts, ",")
+}
+
+// splitHosts parses a comma-separated host list, dropping empty entries.
+func splitHosts(spec string) []string {
+	var hosts []string
+	for _, h := range strings.Split(spec, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{"localhost:27017"}
+	}
+	return hosts
+}
+
+// parseShardedCollections parses "coll:field[+field...][:hashed]" entries
+// separated by commas, read from the variable name. Empty entries are
+// ignored; every malformed one is reported.
+func parseShardedCollections(name, spec string) ([]ShardedCollection, error) {
+	var out []ShardedCollection
+	var errs []error
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		coll, key, ok := strings.Cut(entry, ":")
+		if !ok || coll == "" {
+			errs = append(errs, fmt.Errorf("%s: entry %q: want collection:field[+field...][:hashed]", name, entry))
+			continue
+		}
+		sc, err := ParseShardKey(coll, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: entry %q: %w", name, entry, err))
+			continue
+		}
+		out = append(out, sc)
+	}
+	return out, errors.Join(errs...)
+}
+
+// ParseShardKey parses a shard key in the MONGO_SHARDED_COLLECTIONS form
+// "field[+field...][:hashed]" for collection, e.g. "_id:hashed" or
+// "customer_id+order_date". Only the first field is hashed.
+func ParseShardKey(collection, spec string) (ShardedCollection, error) {
+	fields, mode, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	if fields == "" {
+		return ShardedCollection{}, fmt.Errorf("shard key %q: no fields", spec)
+	}
+	sc := ShardedCollection{Collection: collection, Key: strings.Split(fields, "+")}
+	for _, f := range sc.Key {
+		if f == "" {
+			return ShardedCollection{}, fmt.Errorf("shard key %q: empty field", spec)
+		}
+	}
+	switch mode {
+	case "":
+	case "hashed":
+		sc.Hashed = true
+	default:
+		return ShardedCollection{}, fmt.Errorf("shard key %q: unknown mode %q (want hashed)", spec, mode)
+	}
+	return sc, nil
+}
+
+// WriteConcern is a write concern as the labs and the cluster helpers take
+// it: W is a member count (int) or a mode name such as "majority", and
+// Journal asks for j:true.
+type WriteConcern struct {
+	W       interface{}
+	Journal bool
+}
+
+// Validate checks that W is a non-negative member count or a non-empty
+// mode name ("majority" or a custom write concern name).
+func (wc WriteConcern) Validate() error {
+	switch v := wc.W.(type) {
+	case int:
+		if v < 0 {
+			return fmt.Errorf("write concern w:%d must not be negative", v)
+		}
+	case string:
+		if v == "" {
+			return fmt.Errorf("write concern w must not be empty")
+		}
+	default:
+		return fmt.Errorf("write concern w must be an int or string, got %T", wc.W)
+	}
+	return nil
+}
+
+// Driver converts the concern for the driver.
+func (wc WriteConcern) Driver() *writeconcern.WriteConcern {
+	j := wc.Journal
+	return &writeconcern.WriteConcern{W: wc.W, Journal: &j}
+}
+
+// String formats the concern the way the server reports it, e.g.
+// "w:majority, j:true".
+func (wc WriteConcern) String() string {
+	if wc.Journal {
+		return fmt.Sprintf("w:%v, j:true", wc.W)
+	}
+	return fmt.Sprintf("w:%v", wc.W)
+}
+
+// ParseWriteConcern parses a write concern in the form "w[+j]", e.g. "1",
+// "1+j" or "majority". A numeric w is a member count.
+func ParseWriteConcern(spec string) (WriteConcern, error) {
+	w, j, journaled := strings.Cut(strings.TrimSpace(spec), "+")
+	if journaled && j != "j" {
+		return WriteConcern{}, fmt.Errorf("write concern %q: unknown option %q (want j)", spec, j)
+	}
+	if w == "" {
+		return WriteConcern{}, fmt.Errorf("write concern %q: no w", spec)
+	}
+	wc := WriteConcern{W: w, Journal: journaled}
+	if n, err := strconv.Atoi(w); err == nil {
+		wc.W = n
+	}
+	if err := wc.Validate(); err != nil {
+		return WriteConcern{}, fmt.Errorf("%q: %w", spec, err)
+	}
+	return wc, nil
+}
+
+// lookup reads a variable by name; empty means unset.
+type lookup func(string) string
+
+func (l lookup) env(key, fallback string) string {
+	if v := l(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (l lookup) envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(l(key)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+// envFloat parses a decimal such as "0.1"; invalid or non-positive values
+// fall back.
+func (l lookup) envFloat(key string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(l(key), 64); err == nil && v > 0 {
+		return v
+	}
+	return fallback
 }
 
-func env(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
+// envDuration parses a Go duration such as "20s"; invalid or non-positive
+// values fall back.
+func (l lookup) envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(l(key)); err == nil && v > 0 {
 		return v
 	}
 	return fallback
@@ -0,0 +1,219 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+// errCircuitOpen is returned without touching MongoDB while the circuit
+// breaker is open; mongoErrorStatus maps it to Unavailable.
+var errCircuitOpen = errors.New("mongodb unreachable: circuit breaker open")
+
+// CircuitBreakerConfig configures WithCircuitBreaker.
+type CircuitBreakerConfig struct {
+	Failures int           // consecutive unreachable-backend errors that open the circuit
+	Cooldown time.Duration // time between recovery probes while open
+	// OnChange is called when the circuit opens (true) or closes again
+	// (false), e.g. to flip the gRPC health status.
+	OnChange func(open bool)
+}
+
+// WithCircuitBreaker fast-fails every MongoDB call once cfg.Failures calls
+// in a row could not reach the cluster, instead of letting each RPC wait
+// out server selection against a dead backend. While open, a ping is tried
+// every cfg.Cooldown; the first that succeeds closes the circuit. Errors
+// the server returned (duplicate keys, validation) prove it is reachable
+// and reset the count. A Failures of 0 disables the breaker.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) ServerOption {
+	return func(o *serverOptions) {
+		o.breaker = &cfg
+	}
+}
+
+// circuitBreaker counts consecutive backend failures and, once open,
+// probes for recovery in the background until stop is called.
+type circuitBreaker struct {
+	cfg    CircuitBreakerConfig
+	probe  func(ctx context.Context) error
+	ctx    context.Context // cancelled by stop; ends the recovery probes
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+
+	notifyMu sync.Mutex // serialises OnChange calls with stop
+	stopped  bool
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig, probe func(ctx context.Context) error) *circuitBreaker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &circuitBreaker{cfg: cfg, probe: probe, ctx: ctx, cancel: cancel}
+}
+
+// stop ends the recovery probes. Once it returns, OnChange is not called
+// again, so a server that is draining keeps its NOT_SERVING status.
+func (b *circuitBreaker) stop() {
+	b.cancel()
+	b.notifyMu.Lock()
+	b.stopped = true
+	b.notifyMu.Unlock()
+}
+
+// allow returns errCircuitOpen while the circuit is open.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return errCircuitOpen
+	}
+	return nil
+}
+
+// record counts err against the backend, opening the circuit when the
+// threshold is reached.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	if !isBackendUnreachable(ctx, err) {
+		b.failures = 0
+		b.mu.Unlock()
+		return
+	}
+	b.failures++
+	if b.open || b.failures < b.cfg.Failures {
+		b.mu.Unlock()
+		return
+	}
+	b.open = true
+	b.mu.Unlock()
+
+	log.Printf("[breaker] [WARN] %d consecutive MongoDB failures (last: %v); failing fast, probing every %v",
+		b.cfg.Failures, err, b.cfg.Cooldown)
+	b.notify(true)
+	go b.recover()
+}
+
+// recover pings the backend every cooldown until a ping succeeds, then
+// closes the circuit. It gives up when the breaker is stopped.
+func (b *circuitBreaker) recover() {
+	for attempt := 1; ; attempt++ {
+		if !timeutil.Sleep(b.ctx, b.cfg.Cooldown) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(b.ctx, b.cfg.Cooldown)
+		err := b.probe(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("[breaker] probe %d failed: %v", attempt, err)
+			continue
+		}
+
+		b.mu.Lock()
+		b.open = false
+		b.failures = 0
+		b.mu.Unlock()
+		log.Printf("[breaker] [OK] MongoDB reachable again after %d probes; circuit closed", attempt)
+		b.notify(false)
+		return
+	}
+}
+
+func (b *circuitBreaker) notify(open bool) {
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+	if b.cfg.OnChange != nil && !b.stopped {
+		b.cfg.OnChange(open)
+	}
+}
+
+// isBackendUnreachable reports whether err means MongoDB could not be
+// reached: a network error, or a server selection or socket timeout that
+// was not the caller's own deadline or cancellation.
+func isBackendUnreachable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	return mongo.IsNetworkError(err) || mongo.IsTimeout(err) || errors.Is(err, mongo.ErrClientDisconnected)
+}
+
+// guard runs call through b: it fails fast while the circuit is open and
+// records the outcome otherwise.
+func guard[T any](ctx context.Context, b *circuitBreaker, call func() (T, error)) (T, error) {
+	if err := b.allow(); err != nil {
+		var zero T
+		return zero, err
+	}
+	v, err := call()
+	b.record(ctx, err)
+	return v, err
+}
+
+// breakerStore is a datastore that routes every call through a
+// circuitBreaker.
+type breakerStore struct {
+	inner   datastore
+	breaker *circuitBreaker
+}
+
+func newBreakerStore(inner datastore, cfg CircuitBreakerConfig) *breakerStore {
+	return &breakerStore{inner: inner, breaker: newCircuitBreaker(cfg, inner.Ping)}
+}
+
+func (s *breakerStore) InsertOne(ctx context.Context, db, coll string, doc interface{}) (*mongo.InsertOneResult, error) {
+	return guard(ctx, s.breaker, func() (*mongo.InsertOneResult, error) { return s.inner.InsertOne(ctx, db, coll, doc) })
+}
+
+func (s *breakerStore) Find(ctx context.Context, db, coll string, filter interface{}, opts *options.FindOptions) ([]bson.M, error) {
+	return guard(ctx, s.breaker, func() ([]bson.M, error) { return s.inner.Find(ctx, db, coll, filter, opts) })
+}
+
+func (s *breakerStore) CountDocuments(ctx context.Context, db, coll string, filter interface{}) (int64, error) {
+	return guard(ctx, s.breaker, func() (int64, error) { return s.inner.CountDocuments(ctx, db, coll, filter) })
+}
+
+func (s *breakerStore) InsertMany(ctx context.Context, db, coll string, docs []interface{}, opts *options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	return guard(ctx, s.breaker, func() (*mongo.InsertManyResult, error) { return s.inner.InsertMany(ctx, db, coll, docs, opts) })
+}
+
+func (s *breakerStore) BulkWrite(ctx context.Context, db, coll string, models []mongo.WriteModel, opts *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	return guard(ctx, s.breaker, func() (*mongo.BulkWriteResult, error) { return s.inner.BulkWrite(ctx, db, coll, models, opts) })
+}
+
+func (s *breakerStore) Watch(ctx context.Context, db, coll string, pipeline mongo.Pipeline, opts *options.ChangeStreamOptions) (changeStream, error) {
+	return guard(ctx, s.breaker, func() (changeStream, error) { return s.inner.Watch(ctx, db, coll, pipeline, opts) })
+}
+
+func (s *breakerStore) WatchOplog(ctx context.Context, db, coll string, ops []string, resumeAfter bson.Raw) (changeStream, error) {
+	return guard(ctx, s.breaker, func() (changeStream, error) { return s.inner.WatchOplog(ctx, db, coll, ops, resumeAfter) })
+}
+
+func (s *breakerStore) ExplainRouting(ctx context.Context, db, coll string, filter interface{}) (*sharding.QueryRouting, error) {
+	return guard(ctx, s.breaker, func() (*sharding.QueryRouting, error) { return s.inner.ExplainRouting(ctx, db, coll, filter) })
+}
+
+func (s *breakerStore) ShardKey(ctx context.Context, db, coll string) (bson.D, error) {
+	return guard(ctx, s.breaker, func() (bson.D, error) { return s.inner.ShardKey(ctx, db, coll) })
+}
+
+func (s *breakerStore) ReshardProgress(ctx context.Context, ns string) (*sharding.ReshardProgress, error) {
+	return guard(ctx, s.breaker, func() (*sharding.ReshardProgress, error) { return s.inner.ReshardProgress(ctx, ns) })
+}
+
+func (s *breakerStore) LocateDocument(ctx context.Context, db, coll string, doc bson.M) (*sharding.DocumentLocation, error) {
+	return guard(ctx, s.breaker, func() (*sharding.DocumentLocation, error) { return s.inner.LocateDocument(ctx, db, coll, doc) })
+}
+
+func (s *breakerStore) Ping(ctx context.Context) error {
+	_, err := guard(ctx, s.breaker, func() (struct{}, error) { return struct{}{}, s.inner.Ping(ctx) })
+	return err
+}
@@ -0,0 +1,130 @@
+package grpcserver
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "go-mongodb-sharding-poc/proto/sharding/v1"
+)
+
+var networkErr = mongo.CommandError{Message: "connection refused", Labels: []string{"NetworkError"}}
+
+// flakyStore fails Find and Ping with a network error while down, and
+// counts the Find calls that reach it.
+type flakyStore struct {
+	*fakeStore
+	down  atomic.Bool
+	finds atomic.Int32
+}
+
+func (f *flakyStore) Find(ctx context.Context, db, coll string, filter interface{}, opts *options.FindOptions) ([]bson.M, error) {
+	f.finds.Add(1)
+	if f.down.Load() {
+		return nil, networkErr
+	}
+	return nil, nil
+}
+
+func (f *flakyStore) Ping(ctx context.Context) error {
+	if f.down.Load() {
+		return networkErr
+	}
+	return nil
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	inner := &flakyStore{fakeStore: &fakeStore{}}
+	inner.down.Store(true)
+	changes := make(chan bool, 2)
+	store := newBreakerStore(inner, CircuitBreakerConfig{
+		Failures: 3,
+		Cooldown: 10 * time.Millisecond,
+		OnChange: func(open bool) { changes <- open },
+	})
+	srv := newServer(store)
+	query := &pb.QueryRequest{Database: "d", Collection: "c"}
+
+	for i := 0; i < 3; i++ {
+		if _, err := srv.QueryDocuments(context.Background(), query); status.Code(err) != codes.Unavailable {
+			t.Fatalf("query %d: code = %v, want Unavailable", i, status.Code(err))
+		}
+	}
+	if open := <-changes; !open {
+		t.Fatal("OnChange(false), want the circuit to open")
+	}
+
+	_, err := srv.QueryDocuments(context.Background(), query)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("open circuit: code = %v, want Unavailable", status.Code(err))
+	}
+	if n := inner.finds.Load(); n != 3 {
+		t.Errorf("Find reached MongoDB %d times, want 3 (open circuit must fail fast)", n)
+	}
+
+	// The backend comes back: the next probe closes the circuit
+	inner.down.Store(false)
+	select {
+	case open := <-changes:
+		if open {
+			t.Fatal("OnChange(true) again, want the circuit to close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("circuit did not close after the backend recovered")
+	}
+	if _, err := srv.QueryDocuments(context.Background(), query); err != nil {
+		t.Fatalf("closed circuit: %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresServerErrors(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{Failures: 2, Cooldown: time.Hour}, nil)
+	ctx := context.Background()
+
+	b.record(ctx, networkErr)
+	b.record(ctx, mongo.CommandError{Code: 11000, Message: "duplicate key"}) // server answered
+	b.record(ctx, networkErr)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow = %v after non-consecutive failures, want closed", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.record(cancelled, context.Canceled) // the caller gave up, not the backend
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow = %v after a cancelled call, want closed", err)
+	}
+}
+
+func TestCircuitBreakerStopSilencesOnChange(t *testing.T) {
+	inner := &flakyStore{fakeStore: &fakeStore{}}
+	inner.down.Store(true)
+	changes := make(chan bool, 2)
+	store := newBreakerStore(inner, CircuitBreakerConfig{
+		Failures: 1,
+		Cooldown: 5 * time.Millisecond,
+		OnChange: func(open bool) { changes <- open },
+	})
+	srv := newServer(store)
+
+	srv.QueryDocuments(context.Background(), &pb.QueryRequest{Database: "d", Collection: "c"})
+	if open := <-changes; !open {
+		t.Fatal("OnChange(false), want the circuit to open")
+	}
+
+	// Shutting down: the backend recovering must not flip health back
+	srv.Close()
+	inner.down.Store(false)
+	select {
+	case open := <-changes:
+		t.Fatalf("OnChange(%v) after Close, want no more calls", open)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,11 +1,16 @@
 package grpcserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "go-mongodb-sharding-poc/proto/sharding/v1"
 )
@@ -41,6 +46,9 @@ func BSONToProtoDocument(doc bson.M, collection, database string) (*pb.Document,
 // Direct unmarshal from bytes — no reflection.
 func ProtoDocumentToBSON(doc *pb.Document) (bson.M, error) {
 	if len(doc.Payload) > 0 {
+		if err := validatePayload(doc.Payload); err != nil {
+			return nil, err
+		}
 		var result bson.M
 		if err := bson.Unmarshal(doc.Payload, &result); err != nil {
 			return nil, fmt.Errorf("unmarshal bson payload: %w", err)
@@ -59,6 +67,48 @@ func ProtoDocumentToBSON(doc *pb.Document) (bson.M, error) {
 	return result, nil
 }
 
+// maxNestingDepth is MongoDB's own limit on nested documents and arrays;
+// deeper payloads are rejected before they are decoded into maps.
+const maxNestingDepth = 100
+
+// validatePayload checks an untrusted BSON payload before it is decoded:
+// it must fit MongoDB's BSON object limit, be well-formed, and nest no
+// deeper than maxNestingDepth.
+func validatePayload(raw []byte) error {
+	if len(raw) > maxBSONObjectSize {
+		return fmt.Errorf("payload is %d bytes, over the %d byte BSON limit", len(raw), maxBSONObjectSize)
+	}
+	if err := bson.Raw(raw).Validate(); err != nil {
+		return fmt.Errorf("malformed bson payload: %w", err)
+	}
+	if depthExceeds(bson.Raw(raw), maxNestingDepth) {
+		return fmt.Errorf("payload nests deeper than %d levels", maxNestingDepth)
+	}
+	return nil
+}
+
+// depthExceeds reports whether doc nests documents or arrays more than
+// limit levels deep. doc must already be valid.
+func depthExceeds(doc bson.Raw, limit int) bool {
+	if limit < 0 {
+		return true
+	}
+	elems, err := doc.Elements()
+	if err != nil {
+		return true
+	}
+	for _, e := range elems {
+		v := e.Value()
+		switch v.Type {
+		case bson.TypeEmbeddedDocument, bson.TypeArray:
+			if depthExceeds(v.Value, limit-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // RawBSONToProtoPayload wraps raw BSON bytes for protobuf transport.
 // This is the fastest path — zero conversion.
 func RawBSONToProtoPayload(raw []byte) []byte {
@@ -77,6 +127,9 @@ func BSONFilterFromBytes(data []byte) (bson.M, error) {
 	if len(data) == 0 {
 		return bson.M{}, nil
 	}
+	if err := validatePayload(data); err != nil {
+		return nil, err
+	}
 	var filter bson.M
 	if err := bson.Unmarshal(data, &filter); err != nil {
 		return nil, fmt.Errorf("unmarshal filter: %w", err)
@@ -97,3 +150,105 @@ func BSONFilterToBytes(filter bson.M) ([]byte, error) {
 func MicrosecondsSince(start time.Time) int64 {
 	return time.Since(start).Microseconds()
 }
+
+// transientErrorCodes are server errors raised while a shard elects a new
+// primary or a node shuts down. The driver reconnects to the new primary on
+// its own; the same request sent again shortly after succeeds.
+var transientErrorCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	133,   // FailedToSatisfyReadPreference
+	189,   // PrimarySteppedDown
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// invalidDocumentCodes are server errors caused by the document or request
+// itself; sending it again can never succeed.
+var invalidDocumentCodes = []int{
+	2,     // BadValue
+	61,    // ShardKeyNotFound
+	121,   // DocumentValidationFailure
+	10334, // BSONObjectTooLarge
+}
+
+// mongoErrorStatus maps a MongoDB error to a gRPC status so clients know
+// whether a retry is safe: transient failover errors become Unavailable,
+// duplicate keys AlreadyExists, rejected documents InvalidArgument, and
+// anything else Internal.
+func mongoErrorStatus(op string, err error) error {
+	code := codes.Internal
+	var se mongo.ServerError
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case mongo.IsDuplicateKeyError(err):
+		code = codes.AlreadyExists
+	case mongo.IsNetworkError(err), mongo.IsTimeout(err), errors.Is(err, mongo.ErrClientDisconnected), errors.Is(err, errCircuitOpen):
+		code = codes.Unavailable
+	case errors.As(err, &se):
+		if se.HasErrorLabel("RetryableWriteError") || hasAnyErrorCode(se, transientErrorCodes) {
+			code = codes.Unavailable
+		} else if hasAnyErrorCode(se, invalidDocumentCodes) {
+			code = codes.InvalidArgument
+		}
+	}
+	return status.Errorf(code, "%s: %v", op, err)
+}
+
+// duplicateKeyStatus builds the AlreadyExists status for an E11000 insert
+// error, naming the conflicting key the server reports in keyValue. id is
+// the _id the client sent, if any, for conflicts on another unique index.
+func duplicateKeyStatus(err error, id interface{}) error {
+	key := duplicateKeyValue(err)
+	if key == nil {
+		if id != nil {
+			return status.Errorf(codes.AlreadyExists, "insert: document _id %v: %v", id, err)
+		}
+		return status.Errorf(codes.AlreadyExists, "insert: %v", err)
+	}
+	if dupID, lookupErr := key.LookupErr("_id"); lookupErr == nil {
+		return status.Errorf(codes.AlreadyExists, "insert: document with _id %s already exists", dupID)
+	}
+	if id != nil {
+		return status.Errorf(codes.AlreadyExists, "insert: document _id %v conflicts on unique key %s", id, key)
+	}
+	return status.Errorf(codes.AlreadyExists, "insert: conflicts on unique key %s", key)
+}
+
+// duplicateKeyValue returns the keyValue of the first E11000 write error,
+// or nil when err has none.
+func duplicateKeyValue(err error) bson.Raw {
+	var we mongo.WriteException
+	if !errors.As(err, &we) {
+		return nil
+	}
+	for _, e := range we.WriteErrors {
+		if e.Code != 11000 || e.Raw == nil {
+			continue
+		}
+		if v, lookupErr := e.Raw.LookupErr("keyValue"); lookupErr == nil {
+			if doc, ok := v.DocumentOK(); ok {
+				return doc
+			}
+		}
+	}
+	return nil
+}
+
+func hasAnyErrorCode(se mongo.ServerError, list []int) bool {
+	for _, c := range list {
+		if se.HasErrorCode(c) {
+			return true
+		}
+	}
+	return false
+}
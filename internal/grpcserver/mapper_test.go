@@ -0,0 +1,186 @@
+package grpcserver
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	pb "go-mongodb-sharding-poc/proto/sharding/v1"
+)
+
+func TestBSONToProtoDocumentID(t *testing.T) {
+	oid := primitive.NewObjectID()
+
+	tests := []struct {
+		name   string
+		doc    bson.M
+		wantID string
+	}{
+		{"object id", bson.M{"_id": oid, "x": int32(1)}, oid.Hex()},
+		{"string id", bson.M{"_id": "user_000001"}, "user_000001"},
+		{"int32 id", bson.M{"_id": int32(42)}, "42"},
+		{"int64 id", bson.M{"_id": int64(9000000000)}, "9000000000"},
+		{"float id", bson.M{"_id": 1.5}, "1.5"},
+		{"missing id", bson.M{"name": "no id"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BSONToProtoDocument(tt.doc, "coll", "db")
+			if err != nil {
+				t.Fatalf("BSONToProtoDocument: %v", err)
+			}
+			if got.Id != tt.wantID {
+				t.Errorf("Id = %q, want %q", got.Id, tt.wantID)
+			}
+			if got.Collection != "coll" || got.Database != "db" {
+				t.Errorf("namespace = %s.%s, want db.coll", got.Database, got.Collection)
+			}
+		})
+	}
+}
+
+func TestProtoDocumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  bson.M
+	}{
+		{"object id", bson.M{"_id": primitive.NewObjectID(), "region": "EU"}},
+		{"string id", bson.M{"_id": "grpc_test_001", "name": "Alice", "age": int32(30)}},
+		{"numeric id", bson.M{"_id": int64(7), "score": 99.5}},
+		{"nested", bson.M{"_id": "n", "pii": bson.M{"postal_code": "10115"}, "tags": bson.A{"a", "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protoDoc, err := BSONToProtoDocument(tt.doc, "c", "d")
+			if err != nil {
+				t.Fatalf("BSONToProtoDocument: %v", err)
+			}
+			got, err := ProtoDocumentToBSON(protoDoc)
+			if err != nil {
+				t.Fatalf("ProtoDocumentToBSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.doc) {
+				t.Errorf("round trip = %v, want %v", got, tt.doc)
+			}
+		})
+	}
+}
+
+func TestProtoDocumentToBSONMetadataFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *pb.Document
+		want bson.M
+	}{
+		{
+			name: "id and metadata",
+			doc:  &pb.Document{Id: "abc", Metadata: map[string]string{"region": "US"}},
+			want: bson.M{"_id": "abc", "region": "US"},
+		},
+		{
+			name: "metadata only",
+			doc:  &pb.Document{Metadata: map[string]string{"k": "v"}},
+			want: bson.M{"k": "v"},
+		},
+		{
+			name: "empty",
+			doc:  &pb.Document{},
+			want: bson.M{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProtoDocumentToBSON(tt.doc)
+			if err != nil {
+				t.Fatalf("ProtoDocumentToBSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtoDocumentToBSONMalformed(t *testing.T) {
+	payloads := map[string][]byte{
+		"truncated":    {0x05, 0x00},
+		"bad length":   {0xff, 0x00, 0x00, 0x00, 0x00},
+		"garbage type": {0x0c, 0x00, 0x00, 0x00, 0x7f, 'a', 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+	}
+
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ProtoDocumentToBSON(&pb.Document{Payload: payload}); err == nil {
+				t.Error("expected error for malformed payload")
+			}
+		})
+	}
+}
+
+// nestedDoc returns a BSON document whose leaf sits depth documents deep.
+func nestedDoc(depth int) []byte {
+	doc := bson.D{{Key: "leaf", Value: 1}}
+	for i := 0; i < depth; i++ {
+		doc = bson.D{{Key: "n", Value: doc}}
+	}
+	raw, _ := bson.Marshal(doc)
+	return raw
+}
+
+func TestProtoDocumentToBSONNestingLimit(t *testing.T) {
+	nested := nestedDoc
+
+	if _, err := ProtoDocumentToBSON(&pb.Document{Payload: nested(maxNestingDepth)}); err != nil {
+		t.Errorf("depth %d: %v", maxNestingDepth, err)
+	}
+	if _, err := ProtoDocumentToBSON(&pb.Document{Payload: nested(maxNestingDepth + 1)}); err == nil {
+		t.Errorf("depth %d: expected error", maxNestingDepth+1)
+	}
+
+	// Arrays count as nesting too
+	arr := bson.A{1}
+	for i := 0; i < maxNestingDepth; i++ {
+		arr = bson.A{arr}
+	}
+	raw, _ := bson.Marshal(bson.D{{Key: "a", Value: arr}})
+	if _, err := BSONFilterFromBytes(raw); err == nil {
+		t.Error("nested arrays: expected error")
+	}
+}
+
+func TestProtoDocumentToBSONOversized(t *testing.T) {
+	raw, _ := bson.Marshal(bson.D{{Key: "blob", Value: make([]byte, maxBSONObjectSize)}})
+	if _, err := ProtoDocumentToBSON(&pb.Document{Payload: raw}); err == nil {
+		t.Error("expected error for payload over the BSON limit")
+	}
+}
+
+func TestBSONFilterRoundTrip(t *testing.T) {
+	filter := bson.M{"category": "cat_1", "value": bson.M{"$gt": int32(10)}}
+
+	raw, err := BSONFilterToBytes(filter)
+	if err != nil {
+		t.Fatalf("BSONFilterToBytes: %v", err)
+	}
+	got, err := BSONFilterFromBytes(raw)
+	if err != nil {
+		t.Fatalf("BSONFilterFromBytes: %v", err)
+	}
+	if !reflect.DeepEqual(got, filter) {
+		t.Errorf("round trip = %v, want %v", got, filter)
+	}
+
+	empty, err := BSONFilterFromBytes(nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("empty filter = %v, %v; want empty map", empty, err)
+	}
+
+	if raw, err := BSONFilterToBytes(nil); raw != nil || err != nil {
+		t.Errorf("nil filter = %v, %v; want nil, nil", raw, err)
+	}
+}
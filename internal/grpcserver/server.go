@@ -2,9 +2,11 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,18 +16,64 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
 	pb "go-mongodb-sharding-poc/proto/sharding/v1"
 )
 
 // Server implements the ShardingService gRPC server.
 type Server struct {
 	pb.UnimplementedShardingServiceServer
-	client *mongo.Client
+	store   datastore
+	started time.Time
+}
+
+// ServerOption configures optional Server behaviour.
+type ServerOption func(*serverOptions)
+
+// serverOptions collects the settings applied by ServerOptions.
+type serverOptions struct {
+	shardUser     string
+	shardPassword string
+	breaker       *CircuitBreakerConfig
+}
+
+// WithShardCredentials sets the user and password used to connect to each
+// shard's replica set directly, which oplog watches need; without it those
+// connections are unauthenticated.
+func WithShardCredentials(user, password string) ServerOption {
+	return func(o *serverOptions) {
+		o.shardUser = user
+		o.shardPassword = password
+	}
 }
 
 // NewServer creates a new gRPC server backed by the given MongoDB client.
-func NewServer(client *mongo.Client) *Server {
-	return &Server{client: client}
+func NewServer(client *mongo.Client, opts ...ServerOption) *Server {
+	var o serverOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var store datastore = &mongoStore{client: client, user: o.shardUser, password: o.shardPassword}
+	if o.breaker != nil && o.breaker.Failures > 0 {
+		store = newBreakerStore(store, *o.breaker)
+	}
+	return newServer(store)
+}
+
+// newServer creates a server on top of any datastore (used by tests).
+func newServer(store datastore) *Server {
+	return &Server{store: store, started: time.Now()}
+}
+
+// Close stops the server's background work, the circuit breaker's
+// recovery probes. After it returns the breaker no longer calls OnChange,
+// so call it before draining to keep the health status NOT_SERVING. The
+// MongoDB client stays connected.
+func (s *Server) Close() {
+	if b, ok := s.store.(*breakerStore); ok {
+		b.breaker.stop()
+	}
 }
 
 // InsertDocument handles single document insertion (unary RPC).
@@ -47,21 +95,46 @@ func (s *Server) InsertDocument(ctx context.Context, req *pb.InsertRequest) (*pb
 		return nil, status.Error(codes.InvalidArgument, "database and collection required")
 	}
 
-	result, err := s.client.Database(db).Collection(coll).InsertOne(ctx, doc)
+	result, err := s.store.InsertOne(ctx, db, coll, doc)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "insert: %v", err)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateKeyStatus(err, doc["_id"])
+		}
+		return nil, mongoErrorStatus("insert", err)
 	}
 
 	insertedID := fmt.Sprintf("%v", result.InsertedID)
-	log.Printf("gRPC InsertDocument: %s.%s id=%s latency=%dµs", db, coll, insertedID, MicrosecondsSince(start))
 
-	return &pb.InsertResponse{
-		InsertedId: insertedID,
-		LatencyUs:  MicrosecondsSince(start),
-	}, nil
+	resp := &pb.InsertResponse{InsertedId: insertedID}
+	if req.ReturnRouting {
+		if _, ok := doc["_id"]; !ok {
+			doc["_id"] = result.InsertedID
+		}
+		loc, err := s.store.LocateDocument(ctx, db, coll, doc)
+		if err != nil {
+			log.Printf("gRPC InsertDocument: [WARN] routing lookup: %v", err)
+		} else {
+			resp.Shard = loc.Shard
+			if loc.Sharded {
+				resp.ChunkMin = sharding.FormatKey(loc.ChunkMin)
+				resp.ChunkMax = sharding.FormatKey(loc.ChunkMax)
+			}
+		}
+	}
+
+	resp.LatencyUs = MicrosecondsSince(start)
+	log.Printf("gRPC InsertDocument: %s.%s id=%s shard=%s latency=%dµs", db, coll, insertedID, resp.Shard, resp.LatencyUs)
+	return resp, nil
 }
 
 // QueryDocuments handles document queries (unary RPC).
+//
+// BatchSize only tunes the MongoDB-to-server leg: how many documents each
+// cursor round trip through mongos returns while the server drains the
+// result. Smaller batches mean more getMores and less memory per round
+// trip; larger ones fewer round trips (MongoDB still caps a batch at
+// 16MB). The whole result is sent back as one response, so it must fit
+// the 16MB gRPC message limit whatever the batch size; use Limit to bound it.
 func (s *Server) QueryDocuments(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
 	start := time.Now()
 
@@ -81,21 +154,42 @@ func (s *Server) QueryDocuments(ctx context.Context, req *pb.QueryRequest) (*pb.
 	if req.Skip > 0 {
 		findOpts.SetSkip(int64(req.Skip))
 	}
+	switch {
+	case req.BatchSize < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "batch_size must not be negative, got %d", req.BatchSize)
+	case req.BatchSize > 0:
+		findOpts.SetBatchSize(req.BatchSize)
+	}
 
-	coll := s.client.Database(req.Database).Collection(req.Collection)
+	// Optional explain: tell the caller whether the filter is shard-targeted
+	var routing *sharding.QueryRouting
+	if req.Explain {
+		routing, err = s.store.ExplainRouting(ctx, req.Database, req.Collection, filter)
+		switch {
+		case err != nil:
+			// Routing info is optional; the query itself can still be served
+			log.Printf("gRPC QueryDocuments: [WARN] explain %s.%s: %v — returning documents without routing info",
+				req.Database, req.Collection, err)
+			routing = nil
+		case !routing.Sharded:
+			log.Printf("gRPC QueryDocuments: %s.%s is not sharded; served by primary shard %v",
+				req.Database, req.Collection, routing.Shards)
+		case routing.ScatterGather():
+			log.Printf("gRPC QueryDocuments: [WARN] scatter-gather on %s.%s (%d/%d owning shards) — filter does not include the shard key",
+				req.Database, req.Collection, len(routing.Shards), routing.OwningShards)
+		case routing.PrefixTargeted() && len(routing.Shards) > 1:
+			log.Printf("gRPC QueryDocuments: %s.%s targeted by shard key prefix %v (%d/%d owning shards)",
+				req.Database, req.Collection, routing.KeyPrefix, len(routing.Shards), routing.OwningShards)
+		}
+	}
 
-	cursor, err := coll.Find(ctx, filter, findOpts)
+	results, err := s.store.Find(ctx, req.Database, req.Collection, filter, findOpts)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "find: %v", err)
+		return nil, mongoErrorStatus("find", err)
 	}
-	defer cursor.Close(ctx)
 
-	var documents []*pb.Document
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
-			continue
-		}
+	documents := make([]*pb.Document, 0, len(results))
+	for _, doc := range results {
 		protoDoc, err := BSONToProtoDocument(doc, req.Collection, req.Database)
 		if err != nil {
 			continue
@@ -103,26 +197,42 @@ func (s *Server) QueryDocuments(ctx context.Context, req *pb.QueryRequest) (*pb.
 		documents = append(documents, protoDoc)
 	}
 
-	totalCount, _ := coll.CountDocuments(ctx, filter)
+	totalCount, _ := s.store.CountDocuments(ctx, req.Database, req.Collection, filter)
 
 	log.Printf("gRPC QueryDocuments: %s.%s returned=%d total=%d latency=%dµs",
 		req.Database, req.Collection, len(documents), totalCount, MicrosecondsSince(start))
 
-	return &pb.QueryResponse{
+	resp := &pb.QueryResponse{
 		Documents:  documents,
 		TotalCount: totalCount,
 		LatencyUs:  MicrosecondsSince(start),
-	}, nil
+	}
+	if routing != nil {
+		resp.ScatterGather = routing.ScatterGather()
+		resp.ShardsTouched = int32(len(routing.Shards))
+		if routing.Targeted() {
+			resp.TargetedShard = routing.Shards[0]
+		}
+	}
+	return resp, nil
 }
 
 // BulkInsert handles client-streaming bulk document insertion.
 // Uses bson.Raw zero-copy path: gRPC bytes → bson.Raw → InsertMany.
 // This skips deserialization to bson.M, eliminating allocation overhead.
+// UPSERT and REPLACE batches are sent as ReplaceOne models keyed by _id,
+// plus the shard key fields on a sharded collection, through BulkWrite, so
+// bulk loads can be re-run idempotently. Each batch
+// is validated as a whole before anything is written, then handed to the
+// driver in one call; the driver splits it into as many write commands as
+// MongoDB's limits require, which SubBatches reports.
 func (s *Server) BulkInsert(stream grpc.ClientStreamingServer[pb.BulkInsertRequest, pb.BulkInsertResponse]) error {
 	start := time.Now()
 	var totalInserted int64
-	var batchesReceived int32
+	var batchesReceived, subBatches int32
+	var matched, modified, upserted int64
 	perShard := make(map[string]int64)
+	shardKeys := make(map[string]bson.D) // by namespace, for UPSERT/REPLACE
 
 	for {
 		req, err := stream.Recv()
@@ -137,6 +247,52 @@ func (s *Server) BulkInsert(stream grpc.ClientStreamingServer[pb.BulkInsertReque
 			return status.Error(codes.InvalidArgument, "database and collection required")
 		}
 
+		if len(req.Documents) == 0 {
+			continue
+		}
+
+		// Reject the whole batch before writing any of it
+		for i, doc := range req.Documents {
+			if err := validatePayload(doc); err != nil {
+				return status.Errorf(codes.InvalidArgument, "batch %d: document %d: %v", req.BatchNumber, i, err)
+			}
+		}
+		batchesReceived++
+		subBatches += int32(writeBatchCount(req.Documents, maxWriteBatchSize, maxMessageSizeBytes))
+
+		if req.Mode != pb.BulkInsertRequest_INSERT {
+			ns := req.Database + "." + req.Collection
+			key, ok := shardKeys[ns]
+			if !ok {
+				if key, err = s.store.ShardKey(stream.Context(), req.Database, req.Collection); err != nil {
+					return mongoErrorStatus("shard key lookup", err)
+				}
+				shardKeys[ns] = key
+			}
+			models, err := replaceModels(req.Documents, key, req.Mode == pb.BulkInsertRequest_UPSERT)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "batch %d: %v", req.BatchNumber, err)
+			}
+
+			result, err := s.store.BulkWrite(stream.Context(), req.Database, req.Collection,
+				models, options.BulkWrite().SetOrdered(false))
+			if errors.Is(err, errCircuitOpen) {
+				return mongoErrorStatus("bulk write", err)
+			}
+			if err != nil {
+				log.Printf("gRPC BulkInsert batch %d: %v", req.BatchNumber, err)
+			}
+			if result != nil {
+				matched += result.MatchedCount
+				modified += result.ModifiedCount
+				upserted += result.UpsertedCount
+				totalInserted += result.UpsertedCount
+			}
+
+			log.Printf("gRPC BulkInsert batch %d: %d docs (%s)", req.BatchNumber, len(models), req.Mode)
+			continue
+		}
+
 		// Zero-copy: wrap raw BSON bytes directly as bson.Raw
 		// Avoids bson.Unmarshal → bson.M → InsertMany marshal cycle
 		docs := make([]interface{}, 0, len(req.Documents))
@@ -144,14 +300,13 @@ func (s *Server) BulkInsert(stream grpc.ClientStreamingServer[pb.BulkInsertReque
 			docs = append(docs, bson.Raw(raw))
 		}
 
-		if len(docs) == 0 {
-			continue
-		}
-
 		// Unordered bulk insert: allows MongoDB to process shards in parallel
 		// without waiting for the previous write to finish
-		result, err := s.client.Database(req.Database).Collection(req.Collection).InsertMany(
-			stream.Context(), docs, options.InsertMany().SetOrdered(false))
+		result, err := s.store.InsertMany(stream.Context(), req.Database, req.Collection,
+			docs, options.InsertMany().SetOrdered(false))
+		if errors.Is(err, errCircuitOpen) {
+			return mongoErrorStatus("insert many", err)
+		}
 		if err != nil {
 			log.Printf("gRPC BulkInsert batch %d: %v", req.BatchNumber, err)
 		}
@@ -160,26 +315,85 @@ func (s *Server) BulkInsert(stream grpc.ClientStreamingServer[pb.BulkInsertReque
 		if result != nil {
 			inserted = int64(len(result.InsertedIDs))
 		}
-
 		totalInserted += inserted
-		batchesReceived++
 
 		log.Printf("gRPC BulkInsert batch %d: %d docs (zero-copy)", req.BatchNumber, inserted)
 	}
 
-	log.Printf("gRPC BulkInsert complete: %d docs in %d batches, latency=%dµs",
-		totalInserted, batchesReceived, MicrosecondsSince(start))
+	log.Printf("gRPC BulkInsert complete: %d docs in %d batches (%d writes), latency=%dµs",
+		totalInserted, batchesReceived, subBatches, MicrosecondsSince(start))
 
 	return stream.SendAndClose(&pb.BulkInsertResponse{
 		TotalInserted:   totalInserted,
 		BatchesReceived: batchesReceived,
 		TotalLatencyUs:  MicrosecondsSince(start),
 		PerShardCount:   perShard,
+		MatchedCount:    matched,
+		ModifiedCount:   modified,
+		UpsertedCount:   upserted,
+		SubBatches:      subBatches,
 	})
 }
 
+// MongoDB limits the driver splits InsertMany/BulkWrite commands by.
+const (
+	maxWriteBatchSize   = 100000           // maxWriteBatchSize reported by hello
+	maxMessageSizeBytes = 48000000         // maxMessageSizeBytes reported by hello
+	maxBSONObjectSize   = 16 * 1024 * 1024 // maxBsonObjectSize reported by hello
+)
+
+// writeBatchCount returns how many write commands the driver needs for
+// documents: it starts a new command every maxCount documents or before
+// maxBytes of BSON.
+func writeBatchCount(documents [][]byte, maxCount, maxBytes int) int {
+	writes, count, size := 0, 0, 0
+	for _, doc := range documents {
+		if count == 0 || count == maxCount || size+len(doc) > maxBytes {
+			writes++
+			count, size = 0, 0
+		}
+		count++
+		size += len(doc)
+	}
+	return writes
+}
+
+// replaceModels builds ReplaceOne write models keyed by each document's
+// _id. On a sharded collection (shardKey non-nil) the filter also carries
+// the document's shard key values, which MongoDB needs to route a
+// replacement or upsert to one shard; a document missing a shard key field
+// is rejected rather than written where mongos cannot target it.
+func replaceModels(documents [][]byte, shardKey bson.D, upsert bool) ([]mongo.WriteModel, error) {
+	models := make([]mongo.WriteModel, 0, len(documents))
+	for i, raw := range documents {
+		doc := bson.Raw(raw)
+		id, err := doc.LookupErr("_id")
+		if err != nil {
+			return nil, fmt.Errorf("document %d: _id required for upsert/replace", i)
+		}
+		filter := bson.D{{Key: "_id", Value: id}}
+		for _, k := range shardKey {
+			if k.Key == "_id" {
+				continue
+			}
+			val, err := doc.LookupErr(strings.Split(k.Key, ".")...)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: shard key field %s required for upsert/replace", i, k.Key)
+			}
+			filter = append(filter, bson.E{Key: k.Key, Value: val})
+		}
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(filter).
+			SetReplacement(doc).
+			SetUpsert(upsert))
+	}
+	return models, nil
+}
+
 // WatchUpdates handles bidirectional streaming for real-time change events.
-// Client sends watch filters; server streams matching MongoDB change stream events.
+// Client sends watch filters; server streams matching MongoDB change stream events,
+// or with the OPLOG source, events read from each shard's oplog (see
+// sharding.OplogStream for what that source does not guarantee).
 func (s *Server) WatchUpdates(stream grpc.BidiStreamingServer[pb.WatchRequest, pb.WatchEvent]) error {
 	// Receive the initial watch request
 	req, err := stream.Recv()
@@ -187,11 +401,182 @@ func (s *Server) WatchUpdates(stream grpc.BidiStreamingServer[pb.WatchRequest, p
 		return status.Errorf(codes.Internal, "recv watch request: %v", err)
 	}
 
+	// The scope decides which of database/collection name the watch target
+	switch req.Scope {
+	case pb.WatchRequest_COLLECTION:
+		if req.Database == "" || req.Collection == "" {
+			return status.Error(codes.InvalidArgument, "database and collection required")
+		}
+	case pb.WatchRequest_DATABASE:
+		if req.Database == "" || req.Collection != "" {
+			return status.Error(codes.InvalidArgument, "database scope requires database and no collection")
+		}
+	case pb.WatchRequest_CLUSTER:
+		if req.Database != "" || req.Collection != "" {
+			return status.Error(codes.InvalidArgument, "cluster scope takes no database or collection")
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown watch scope %v", req.Scope)
+	}
+	switch req.Source {
+	case pb.WatchRequest_CHANGE_STREAM:
+	case pb.WatchRequest_OPLOG:
+		// Oplog updates carry no full document to match or project
+		if len(req.Filter) > 0 || len(req.Fields) > 0 {
+			return status.Error(codes.InvalidArgument, "filter and fields are not supported with the oplog source")
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown watch source %v", req.Source)
+	}
+	target := watchTarget(req.Database, req.Collection)
+
+	pipeline, err := watchPipeline(req)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	// Updates only carry fullDocument when the server looks it up
+	csOpts := options.ChangeStream()
+	if len(req.Filter) > 0 || len(req.Fields) > 0 {
+		csOpts.SetFullDocument(options.UpdateLookup)
+	}
+
+	// Resume after the client's last seen event when reconnecting
+	if len(req.ResumeAfter) > 0 {
+		if err := bson.Raw(req.ResumeAfter).Validate(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid resume token: %v", err)
+		}
+		if req.Source == pb.WatchRequest_OPLOG {
+			if _, err := sharding.ParseOplogResumeToken(req.ResumeAfter); err != nil {
+				return status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+		}
+		csOpts.SetResumeAfter(bson.Raw(req.ResumeAfter))
+	}
+
+	log.Printf("gRPC WatchUpdates: streaming %s (scope=%s source=%s filter=%s resumed=%v)",
+		target, req.Scope, req.Source, req.OperationFilter, len(req.ResumeAfter) > 0)
+
+	// Stream change events, reopening the change stream from the last resume
+	// token when it fails with a recoverable error (e.g. a shard primary
+	// election the driver could not absorb on its own)
+	ctx := stream.Context()
+	resumeToken := bson.Raw(req.ResumeAfter)
+	attempts := 0
+	for {
+		cs, err := s.openWatch(ctx, req, pipeline, csOpts, resumeToken)
+		if err != nil {
+			if ctx.Err() == nil && sharding.IsResumableChangeStreamError(err) && attempts < maxWatchResumes {
+				attempts++
+				log.Printf("gRPC WatchUpdates: reopen %s failed (attempt %d/%d): %v",
+					target, attempts, maxWatchResumes, err)
+				if !timeutil.Sleep(ctx, watchResumeBackoff*time.Duration(attempts)) {
+					return nil
+				}
+				continue
+			}
+			return status.Errorf(codes.Unavailable, "watch: %v", err)
+		}
+
+		for cs.Next(ctx) {
+			attempts = 0
+			var event bson.M
+			if err := cs.Decode(&event); err != nil {
+				continue
+			}
+
+			watchEvent := changeEventToProto(event, req.Database, req.Collection)
+			resumeToken = cs.ResumeToken()
+			watchEvent.ResumeToken = resumeToken
+			if err := stream.Send(watchEvent); err != nil {
+				cs.Close(ctx)
+				return err
+			}
+		}
+		err = cs.Err()
+		// A stream that failed before its first event still has a
+		// postBatchResumeToken; resuming from it keeps the writes made
+		// while the stream was down instead of restarting at "now"
+		if token := cs.ResumeToken(); len(token) > 0 {
+			resumeToken = token
+		}
+		cs.Close(ctx)
+
+		if ctx.Err() != nil || err == nil {
+			return nil
+		}
+		if !sharding.IsResumableChangeStreamError(err) || attempts >= maxWatchResumes {
+			return status.Errorf(codes.Unavailable, "change stream: %v", err)
+		}
+
+		attempts++
+		log.Printf("gRPC WatchUpdates: change stream on %s interrupted, resuming (attempt %d/%d): %v",
+			target, attempts, maxWatchResumes, err)
+		if len(resumeToken) > 0 {
+			csOpts.SetResumeAfter(resumeToken)
+		}
+		if !timeutil.Sleep(ctx, watchResumeBackoff*time.Duration(attempts)) {
+			return nil
+		}
+	}
+}
+
+// openWatch opens the event source req selects. Change streams resume from
+// csOpts; the oplog source takes its own resume token directly.
+func (s *Server) openWatch(ctx context.Context, req *pb.WatchRequest, pipeline mongo.Pipeline, csOpts *options.ChangeStreamOptions, resumeToken bson.Raw) (changeStream, error) {
+	if req.Source == pb.WatchRequest_OPLOG {
+		var ops []string
+		if op := operationTypeString(req.OperationFilter); op != "" {
+			ops = []string{op}
+		}
+		return s.store.WatchOplog(ctx, req.Database, req.Collection, ops, resumeToken)
+	}
+	return s.store.Watch(ctx, req.Database, req.Collection, pipeline, csOpts)
+}
+
+// GetReshardProgress reports progress of a reshardCollection operation (unary RPC).
+func (s *Server) GetReshardProgress(ctx context.Context, req *pb.ReshardProgressRequest) (*pb.ReshardProgressResponse, error) {
 	if req.Database == "" || req.Collection == "" {
-		return status.Error(codes.InvalidArgument, "database and collection required")
+		return nil, status.Error(codes.InvalidArgument, "database and collection required")
+	}
+
+	progress, err := s.store.ReshardProgress(ctx, req.Database+"."+req.Collection)
+	if err != nil {
+		return nil, mongoErrorStatus("reshard progress", err)
+	}
+
+	return &pb.ReshardProgressResponse{
+		InProgress:          progress.InProgress,
+		CoordinatorState:    progress.CoordinatorState,
+		DocumentsCopied:     progress.DocumentsCopied,
+		DocumentsToCopy:     progress.DocumentsToCopy,
+		OplogEntriesFetched: progress.OplogEntriesFetched,
+		OplogEntriesApplied: progress.OplogEntriesApplied,
+		ElapsedSecs:         progress.ElapsedSecs,
+		RemainingSecs:       progress.RemainingSecs,
+	}, nil
+}
+
+// Ping reports server uptime and the MongoDB round trip (unary RPC). It
+// pings the deployment without touching a collection, so clients and load
+// balancers can call it as an application-level liveness probe.
+func (s *Server) Ping(ctx context.Context, req *pb.PingRequest) (*pb.PingResponse, error) {
+	start := time.Now()
+	if err := s.store.Ping(ctx); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "mongodb ping: %v", err)
 	}
+	return &pb.PingResponse{
+		UptimeSecs:     int64(time.Since(s.started).Seconds()),
+		MongoLatencyUs: MicrosecondsSince(start),
+	}, nil
+}
 
-	// Build change stream pipeline
+// watchPipeline builds the change stream pipeline for a watch request:
+// an operationType match, a match on fullDocument fields from req.Filter
+// (top-level keys are prefixed with "fullDocument."), and a $project that
+// trims fullDocument to req.Fields. The event _id is always kept since it
+// is the resume token.
+func watchPipeline(req *pb.WatchRequest) (mongo.Pipeline, error) {
 	pipeline := mongo.Pipeline{}
 	if req.OperationFilter != pb.WatchRequest_ALL {
 		opType := operationTypeString(req.OperationFilter)
@@ -204,33 +589,65 @@ func (s *Server) WatchUpdates(stream grpc.BidiStreamingServer[pb.WatchRequest, p
 		}
 	}
 
-	// Open change stream
-	coll := s.client.Database(req.Database).Collection(req.Collection)
-	cs, err := coll.Watch(stream.Context(), pipeline)
-	if err != nil {
-		return status.Errorf(codes.Internal, "watch: %v", err)
+	if len(req.Filter) > 0 {
+		if err := validatePayload(req.Filter); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		var filter bson.D
+		if err := bson.Unmarshal(req.Filter, &filter); err != nil {
+			return nil, fmt.Errorf("invalid filter BSON: %w", err)
+		}
+		match := make(bson.D, 0, len(filter))
+		for _, e := range filter {
+			if strings.HasPrefix(e.Key, "$") {
+				return nil, fmt.Errorf("filter: top-level operator %s not supported; match on fields", e.Key)
+			}
+			match = append(match, bson.E{Key: "fullDocument." + e.Key, Value: e.Value})
+		}
+		if len(match) > 0 {
+			pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}})
+		}
 	}
-	defer cs.Close(stream.Context())
-
-	log.Printf("gRPC WatchUpdates: streaming %s.%s (filter=%s)",
-		req.Database, req.Collection, req.OperationFilter)
 
-	// Stream change events
-	for cs.Next(stream.Context()) {
-		var event bson.M
-		if err := cs.Decode(&event); err != nil {
-			continue
+	if len(req.Fields) > 0 {
+		project := bson.D{
+			{Key: "operationType", Value: 1},
+			{Key: "documentKey", Value: 1},
+			{Key: "ns", Value: 1},
+			{Key: "clusterTime", Value: 1},
 		}
-
-		watchEvent := changeEventToProto(event, req.Collection)
-		if err := stream.Send(watchEvent); err != nil {
-			return err
+		for _, f := range req.Fields {
+			if f == "" || strings.HasPrefix(f, "$") {
+				return nil, fmt.Errorf("invalid field name %q", f)
+			}
+			project = append(project, bson.E{Key: "fullDocument." + f, Value: 1})
 		}
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: project}})
 	}
 
-	return nil
+	return pipeline, nil
+}
+
+// watchTarget names what a watch covers, for logs.
+func watchTarget(database, collection string) string {
+	switch {
+	case database == "":
+		return "cluster"
+	case collection == "":
+		return database + ".*"
+	default:
+		return database + "." + collection
+	}
 }
 
+// maxWatchResumes bounds consecutive change stream reopen attempts; the
+// counter resets whenever an event is delivered.
+const maxWatchResumes = 5
+
+// watchResumeBackoff is the base delay between reopen attempts (a var so
+// tests can shorten it).
+var watchResumeBackoff = 500 * time.Millisecond
+
 // operationTypeString maps protobuf enum to MongoDB change stream operation type.
 func operationTypeString(op pb.WatchRequest_Operation) string {
 	switch op {
@@ -248,11 +665,23 @@ func operationTypeString(op pb.WatchRequest_Operation) string {
 }
 
 // changeEventToProto converts a MongoDB change stream event to a protobuf WatchEvent.
-func changeEventToProto(event bson.M, collection string) *pb.WatchEvent {
+// The source namespace comes from the event's ns, falling back to the
+// watched database/collection for events that carry none.
+func changeEventToProto(event bson.M, database, collection string) *pb.WatchEvent {
 	we := &pb.WatchEvent{
+		Database:   database,
 		Collection: collection,
 	}
 
+	if ns, ok := event["ns"].(bson.M); ok {
+		if db, ok := ns["db"].(string); ok {
+			we.Database = db
+		}
+		if coll, ok := ns["coll"].(string); ok {
+			we.Collection = coll
+		}
+	}
+
 	if op, ok := event["operationType"].(string); ok {
 		we.Operation = op
 	}
@@ -263,6 +692,11 @@ func changeEventToProto(event bson.M, collection string) *pb.WatchEvent {
 		}
 	}
 
+	// Set by the oplog source, which knows which shard each event came from
+	if shard, ok := event["shard"].(string); ok {
+		we.Shard = shard
+	}
+
 	if fullDoc, ok := event["fullDocument"].(bson.M); ok {
 		if raw, err := bson.Marshal(fullDoc); err == nil {
 			we.FullDocument = raw
@@ -0,0 +1,985 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+	pb "go-mongodb-sharding-poc/proto/sharding/v1"
+)
+
+// fakeStore is an in-memory datastore for handler tests.
+type fakeStore struct {
+	inserted   []interface{}
+	models     []mongo.WriteModel
+	found      []bson.M
+	count      int64
+	routing    *sharding.QueryRouting
+	explainErr error
+	shardKey   bson.D
+	location   *sharding.DocumentLocation
+	insertErr  error
+	pingErr    error
+	findErr    error
+	findOpts   *options.FindOptions
+	lastDB     string
+	lastColl   string
+
+	// streams are handed out by Watch in order; watchOpts records each call
+	streams   []*fakeChangeStream
+	watchOpts []*options.ChangeStreamOptions
+
+	// oplogCalls records the ops and resume token of each WatchOplog call
+	oplogCalls []oplogCall
+}
+
+type oplogCall struct {
+	ops         []string
+	resumeAfter bson.Raw
+}
+
+func (f *fakeStore) InsertOne(ctx context.Context, db, coll string, doc interface{}) (*mongo.InsertOneResult, error) {
+	f.lastDB, f.lastColl = db, coll
+	if f.insertErr != nil {
+		return nil, f.insertErr
+	}
+	f.inserted = append(f.inserted, doc)
+	id := interface{}(nil)
+	if m, ok := doc.(bson.M); ok {
+		id = m["_id"]
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+func (f *fakeStore) Find(ctx context.Context, db, coll string, filter interface{}, opts *options.FindOptions) ([]bson.M, error) {
+	f.lastDB, f.lastColl = db, coll
+	f.findOpts = opts
+	return f.found, f.findErr
+}
+
+func (f *fakeStore) CountDocuments(ctx context.Context, db, coll string, filter interface{}) (int64, error) {
+	return f.count, nil
+}
+
+func (f *fakeStore) InsertMany(ctx context.Context, db, coll string, docs []interface{}, opts *options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	f.inserted = append(f.inserted, docs...)
+	ids := make([]interface{}, len(docs))
+	return &mongo.InsertManyResult{InsertedIDs: ids}, nil
+}
+
+func (f *fakeStore) BulkWrite(ctx context.Context, db, coll string, models []mongo.WriteModel, opts *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	f.models = append(f.models, models...)
+	return &mongo.BulkWriteResult{MatchedCount: 1, ModifiedCount: 1, UpsertedCount: int64(len(models)) - 1}, nil
+}
+
+func (f *fakeStore) Watch(ctx context.Context, db, coll string, pipeline mongo.Pipeline, opts *options.ChangeStreamOptions) (changeStream, error) {
+	f.lastDB, f.lastColl = db, coll
+	f.watchOpts = append(f.watchOpts, opts)
+	if len(f.streams) == 0 {
+		return nil, errors.New("no more change streams")
+	}
+	cs := f.streams[0]
+	f.streams = f.streams[1:]
+	return cs, nil
+}
+
+func (f *fakeStore) WatchOplog(ctx context.Context, db, coll string, ops []string, resumeAfter bson.Raw) (changeStream, error) {
+	f.lastDB, f.lastColl = db, coll
+	f.oplogCalls = append(f.oplogCalls, oplogCall{ops: ops, resumeAfter: resumeAfter})
+	if len(f.streams) == 0 {
+		return nil, errors.New("no more oplog streams")
+	}
+	cs := f.streams[0]
+	f.streams = f.streams[1:]
+	return cs, nil
+}
+
+func (f *fakeStore) ExplainRouting(ctx context.Context, db, coll string, filter interface{}) (*sharding.QueryRouting, error) {
+	return f.routing, f.explainErr
+}
+
+func (f *fakeStore) ShardKey(ctx context.Context, db, coll string) (bson.D, error) {
+	return f.shardKey, nil
+}
+
+func (f *fakeStore) ReshardProgress(ctx context.Context, ns string) (*sharding.ReshardProgress, error) {
+	return &sharding.ReshardProgress{Namespace: ns, RemainingSecs: -1}, nil
+}
+
+func (f *fakeStore) Ping(ctx context.Context) error {
+	return f.pingErr
+}
+
+func (f *fakeStore) LocateDocument(ctx context.Context, db, coll string, doc bson.M) (*sharding.DocumentLocation, error) {
+	if f.location == nil {
+		return nil, errors.New("not sharded")
+	}
+	return f.location, nil
+}
+
+func TestInsertDocumentValidation(t *testing.T) {
+	payload, _ := bson.Marshal(bson.M{"_id": "a"})
+
+	tests := []struct {
+		name string
+		req  *pb.InsertRequest
+	}{
+		{"nil document", &pb.InsertRequest{}},
+		{"missing database", &pb.InsertRequest{Document: &pb.Document{Collection: "c", Payload: payload}}},
+		{"missing collection", &pb.InsertRequest{Document: &pb.Document{Database: "d", Payload: payload}}},
+		{"malformed payload", &pb.InsertRequest{Document: &pb.Document{Database: "d", Collection: "c", Payload: []byte{1, 2, 3}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			_, err := newServer(store).InsertDocument(context.Background(), tt.req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("code = %v, want InvalidArgument (err=%v)", status.Code(err), err)
+			}
+			if len(store.inserted) != 0 {
+				t.Fatalf("store received %d inserts, want 0", len(store.inserted))
+			}
+		})
+	}
+}
+
+func TestInsertDocument(t *testing.T) {
+	payload, _ := bson.Marshal(bson.M{"_id": "doc_1", "name": "Alice"})
+	store := &fakeStore{}
+
+	resp, err := newServer(store).InsertDocument(context.Background(), &pb.InsertRequest{
+		Document: &pb.Document{Database: "d", Collection: "c", Payload: payload},
+	})
+	if err != nil {
+		t.Fatalf("InsertDocument: %v", err)
+	}
+	if resp.InsertedId != "doc_1" {
+		t.Errorf("InsertedId = %q, want doc_1", resp.InsertedId)
+	}
+	if store.lastDB != "d" || store.lastColl != "c" {
+		t.Errorf("namespace = %s.%s, want d.c", store.lastDB, store.lastColl)
+	}
+}
+
+func TestInsertDocumentReturnRouting(t *testing.T) {
+	payload, _ := bson.Marshal(bson.M{"_id": "doc_1", "region": "EU"})
+	store := &fakeStore{location: &sharding.DocumentLocation{
+		Shard:    "shard2rs",
+		Sharded:  true,
+		ChunkMin: bson.D{{Key: "region", Value: "EU"}},
+		ChunkMax: bson.D{{Key: "region", Value: "US"}},
+	}}
+
+	resp, err := newServer(store).InsertDocument(context.Background(), &pb.InsertRequest{
+		Document:      &pb.Document{Database: "d", Collection: "c", Payload: payload},
+		ReturnRouting: true,
+	})
+	if err != nil {
+		t.Fatalf("InsertDocument: %v", err)
+	}
+	if resp.Shard != "shard2rs" {
+		t.Errorf("Shard = %q, want shard2rs", resp.Shard)
+	}
+	if resp.ChunkMin != `{ region: "EU" }` || resp.ChunkMax != `{ region: "US" }` {
+		t.Errorf("chunk = [%s, %s), want [{ region: \"EU\" }, { region: \"US\" })", resp.ChunkMin, resp.ChunkMax)
+	}
+
+	// Routing lookup failures do not fail the insert
+	resp, err = newServer(&fakeStore{}).InsertDocument(context.Background(), &pb.InsertRequest{
+		Document:      &pb.Document{Database: "d", Collection: "c", Payload: payload},
+		ReturnRouting: true,
+	})
+	if err != nil || resp.Shard != "" {
+		t.Errorf("lookup failure: resp=%v err=%v, want empty shard and no error", resp, err)
+	}
+}
+
+func TestInsertDocumentStoreError(t *testing.T) {
+	payload, _ := bson.Marshal(bson.M{"_id": "doc_1"})
+	store := &fakeStore{insertErr: errors.New("boom")}
+
+	_, err := newServer(store).InsertDocument(context.Background(), &pb.InsertRequest{
+		Document: &pb.Document{Database: "d", Collection: "c", Payload: payload},
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("code = %v, want Internal", status.Code(err))
+	}
+}
+
+func TestInsertDocumentErrorCodes(t *testing.T) {
+	payload, _ := bson.Marshal(bson.M{"_id": "doc_1"})
+	writeErr := func(code int) error {
+		return mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: code, Message: "write failed"}}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"duplicate key", writeErr(11000), codes.AlreadyExists},
+		{"document validation", writeErr(121), codes.InvalidArgument},
+		{"not writable primary", mongo.CommandError{Code: 10107, Name: "NotWritablePrimary"}, codes.Unavailable},
+		{"primary stepped down", mongo.WriteException{WriteConcernError: &mongo.WriteConcernError{Code: 189}}, codes.Unavailable},
+		{"retryable write label", mongo.CommandError{Code: 1, Labels: []string{"RetryableWriteError"}}, codes.Unavailable},
+		{"network error", mongo.CommandError{Labels: []string{"NetworkError"}}, codes.Unavailable},
+		{"client disconnected", mongo.ErrClientDisconnected, codes.Unavailable},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"unknown command error", mongo.CommandError{Code: 8000, Name: "AtlasError"}, codes.Internal},
+	}
+	for _, tt := range tests {
+		_, err := newServer(&fakeStore{insertErr: tt.err}).InsertDocument(context.Background(), &pb.InsertRequest{
+			Document: &pb.Document{Database: "d", Collection: "c", Payload: payload},
+		})
+		if status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestInsertDocumentDuplicateKey(t *testing.T) {
+	payload, _ := bson.Marshal(bson.M{"_id": "doc_1", "email": "a@example.com"})
+	dupErr := func(keyValue bson.M) error {
+		raw, _ := bson.Marshal(bson.M{"index": 0, "code": 11000, "keyValue": keyValue})
+		return mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error", Raw: raw}}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"_id conflict", dupErr(bson.M{"_id": "doc_1"}), `insert: document with _id "doc_1" already exists`},
+		{"unique index conflict", dupErr(bson.M{"email": "a@example.com"}), `insert: document _id doc_1 conflicts on unique key {"email": "a@example.com"}`},
+	}
+	for _, tt := range tests {
+		_, err := newServer(&fakeStore{insertErr: tt.err}).InsertDocument(context.Background(), &pb.InsertRequest{
+			Document: &pb.Document{Database: "d", Collection: "c", Payload: payload},
+		})
+		st := status.Convert(err)
+		if st.Code() != codes.AlreadyExists || st.Message() != tt.want {
+			t.Errorf("%s: got %v %q, want AlreadyExists %q", tt.name, st.Code(), st.Message(), tt.want)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	srv := newServer(&fakeStore{})
+	srv.started = time.Now().Add(-90 * time.Second)
+
+	resp, err := srv.Ping(context.Background(), &pb.PingRequest{})
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if resp.UptimeSecs != 90 {
+		t.Errorf("UptimeSecs = %d, want 90", resp.UptimeSecs)
+	}
+
+	_, err = newServer(&fakeStore{pingErr: errors.New("no reachable servers")}).Ping(context.Background(), &pb.PingRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("code = %v, want Unavailable", status.Code(err))
+	}
+}
+
+func TestQueryDocuments(t *testing.T) {
+	store := &fakeStore{
+		found: []bson.M{{"_id": "a"}, {"_id": "b"}},
+		count: 7,
+		routing: &sharding.QueryRouting{
+			Shards:       []string{"shard1rs", "shard2rs", "shard3rs"},
+			TotalShards:  3,
+			OwningShards: 3,
+			Sharded:      true,
+		},
+	}
+
+	resp, err := newServer(store).QueryDocuments(context.Background(), &pb.QueryRequest{
+		Database:   "d",
+		Collection: "c",
+		Explain:    true,
+	})
+	if err != nil {
+		t.Fatalf("QueryDocuments: %v", err)
+	}
+	if len(resp.Documents) != 2 {
+		t.Errorf("documents = %d, want 2", len(resp.Documents))
+	}
+	if resp.TotalCount != 7 {
+		t.Errorf("TotalCount = %d, want 7", resp.TotalCount)
+	}
+	if !resp.ScatterGather || resp.ShardsTouched != 3 || resp.TargetedShard != "" {
+		t.Errorf("routing = (scatter=%v touched=%d targeted=%q), want scatter-gather across 3",
+			resp.ScatterGather, resp.ShardsTouched, resp.TargetedShard)
+	}
+}
+
+func TestQueryDocumentsUnsharded(t *testing.T) {
+	// An unsharded collection on a one-shard cluster must not look like a broadcast
+	store := &fakeStore{routing: &sharding.QueryRouting{
+		Shards:      []string{"shard1rs"},
+		TotalShards: 1,
+	}}
+
+	resp, err := newServer(store).QueryDocuments(context.Background(), &pb.QueryRequest{
+		Database: "d", Collection: "c", Explain: true,
+	})
+	if err != nil {
+		t.Fatalf("QueryDocuments: %v", err)
+	}
+	if resp.ScatterGather || resp.TargetedShard != "shard1rs" {
+		t.Errorf("routing = (scatter=%v targeted=%q), want primary shard shard1rs", resp.ScatterGather, resp.TargetedShard)
+	}
+}
+
+func TestQueryDocumentsPrefixTargeted(t *testing.T) {
+	// { tenant_id: X } on a { tenant_id, user_id } key whose chunks span every shard
+	store := &fakeStore{
+		routing: &sharding.QueryRouting{
+			Shards:       []string{"shard1rs", "shard2rs", "shard3rs"},
+			TotalShards:  3,
+			OwningShards: 3,
+			Sharded:      true,
+			ShardKey:     bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}},
+			KeyPrefix:    []string{"tenant_id"},
+		},
+	}
+
+	resp, err := newServer(store).QueryDocuments(context.Background(), &pb.QueryRequest{
+		Database:   "d",
+		Collection: "c",
+		Explain:    true,
+	})
+	if err != nil {
+		t.Fatalf("QueryDocuments: %v", err)
+	}
+	if resp.ScatterGather || resp.ShardsTouched != 3 {
+		t.Errorf("routing = (scatter=%v touched=%d), want prefix-targeted across 3",
+			resp.ScatterGather, resp.ShardsTouched)
+	}
+}
+
+func TestQueryDocumentsScatterAcrossOwningShards(t *testing.T) {
+	// The collection's chunks live on 2 of 4 shards; reaching both is a broadcast
+	store := &fakeStore{routing: &sharding.QueryRouting{
+		Shards:       []string{"shard1rs", "shard2rs"},
+		TotalShards:  4,
+		OwningShards: 2,
+		Sharded:      true,
+	}}
+
+	resp, err := newServer(store).QueryDocuments(context.Background(), &pb.QueryRequest{
+		Database: "d", Collection: "c", Explain: true,
+	})
+	if err != nil {
+		t.Fatalf("QueryDocuments: %v", err)
+	}
+	if !resp.ScatterGather {
+		t.Error("ScatterGather = false, want true: every owning shard was queried")
+	}
+}
+
+func TestQueryDocumentsExplainFailure(t *testing.T) {
+	store := &fakeStore{
+		found:      []bson.M{{"_id": "a"}},
+		count:      1,
+		explainErr: errors.New("explain not authorized"),
+	}
+
+	resp, err := newServer(store).QueryDocuments(context.Background(), &pb.QueryRequest{
+		Database: "d", Collection: "c", Explain: true,
+	})
+	if err != nil {
+		t.Fatalf("QueryDocuments: %v, want documents without routing info", err)
+	}
+	if len(resp.Documents) != 1 || resp.ShardsTouched != 0 || resp.ScatterGather {
+		t.Errorf("resp = %d docs, touched %d, scatter %v; want 1 doc and no routing info",
+			len(resp.Documents), resp.ShardsTouched, resp.ScatterGather)
+	}
+}
+
+func TestQueryDocumentsValidation(t *testing.T) {
+	srv := newServer(&fakeStore{})
+
+	if _, err := srv.QueryDocuments(context.Background(), &pb.QueryRequest{Collection: "c"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing database: code = %v, want InvalidArgument", status.Code(err))
+	}
+	if _, err := srv.QueryDocuments(context.Background(), &pb.QueryRequest{Database: "d", Collection: "c", Filter: []byte{9}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("bad filter: code = %v, want InvalidArgument", status.Code(err))
+	}
+	if _, err := srv.QueryDocuments(context.Background(), &pb.QueryRequest{Database: "d", Collection: "c", BatchSize: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative batch size: code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestQueryDocumentsBatchSize(t *testing.T) {
+	store := &fakeStore{}
+	srv := newServer(store)
+
+	if _, err := srv.QueryDocuments(context.Background(), &pb.QueryRequest{Database: "d", Collection: "c"}); err != nil {
+		t.Fatalf("QueryDocuments: %v", err)
+	}
+	if store.findOpts.BatchSize != nil {
+		t.Errorf("BatchSize = %d, want unset for the server default", *store.findOpts.BatchSize)
+	}
+
+	if _, err := srv.QueryDocuments(context.Background(), &pb.QueryRequest{Database: "d", Collection: "c", BatchSize: 500}); err != nil {
+		t.Fatalf("QueryDocuments: %v", err)
+	}
+	if bs := store.findOpts.BatchSize; bs == nil || *bs != 500 {
+		t.Errorf("BatchSize = %v, want 500", bs)
+	}
+}
+
+// fakeBulkStream feeds canned requests to BulkInsert and captures the response.
+type fakeBulkStream struct {
+	grpc.ServerStream
+	reqs []*pb.BulkInsertRequest
+	resp *pb.BulkInsertResponse
+}
+
+func (f *fakeBulkStream) Context() context.Context { return context.Background() }
+
+func (f *fakeBulkStream) Recv() (*pb.BulkInsertRequest, error) {
+	if len(f.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := f.reqs[0]
+	f.reqs = f.reqs[1:]
+	return req, nil
+}
+
+func (f *fakeBulkStream) SendAndClose(resp *pb.BulkInsertResponse) error {
+	f.resp = resp
+	return nil
+}
+
+func TestBulkInsertUpsert(t *testing.T) {
+	docA, _ := bson.Marshal(bson.M{"_id": "a", "v": 1})
+	docB, _ := bson.Marshal(bson.M{"_id": "b", "v": 2})
+	store := &fakeStore{}
+	stream := &fakeBulkStream{reqs: []*pb.BulkInsertRequest{{
+		Database:   "d",
+		Collection: "c",
+		Documents:  [][]byte{docA, docB},
+		Mode:       pb.BulkInsertRequest_UPSERT,
+	}}}
+
+	if err := newServer(store).BulkInsert(stream); err != nil {
+		t.Fatalf("BulkInsert: %v", err)
+	}
+	if len(store.models) != 2 || len(store.inserted) != 0 {
+		t.Fatalf("models=%d inserted=%d, want 2 write models and no inserts", len(store.models), len(store.inserted))
+	}
+	if m, ok := store.models[0].(*mongo.ReplaceOneModel); !ok || m.Upsert == nil || !*m.Upsert {
+		t.Errorf("model = %#v, want upserting ReplaceOneModel", store.models[0])
+	}
+	if stream.resp.SubBatches != 1 {
+		t.Errorf("SubBatches = %d, want 1", stream.resp.SubBatches)
+	}
+	if stream.resp.MatchedCount != 1 || stream.resp.UpsertedCount != 1 || stream.resp.TotalInserted != 1 {
+		t.Errorf("counts = matched %d upserted %d inserted %d, want 1/1/1",
+			stream.resp.MatchedCount, stream.resp.UpsertedCount, stream.resp.TotalInserted)
+	}
+}
+
+func TestBulkInsertUpsertShardKeyFilter(t *testing.T) {
+	doc, _ := bson.Marshal(bson.M{"_id": "a", "tenant": "t1", "user": bson.M{"id": 7}, "v": 1})
+	store := &fakeStore{shardKey: bson.D{{Key: "tenant", Value: 1}, {Key: "user.id", Value: "hashed"}}}
+	stream := &fakeBulkStream{reqs: []*pb.BulkInsertRequest{{
+		Database:   "d",
+		Collection: "c",
+		Documents:  [][]byte{doc},
+		Mode:       pb.BulkInsertRequest_UPSERT,
+	}}}
+
+	if err := newServer(store).BulkInsert(stream); err != nil {
+		t.Fatalf("BulkInsert: %v", err)
+	}
+	filter := store.models[0].(*mongo.ReplaceOneModel).Filter.(bson.D)
+	var keys []string
+	for _, e := range filter {
+		keys = append(keys, e.Key)
+	}
+	if strings.Join(keys, ",") != "_id,tenant,user.id" {
+		t.Errorf("filter keys = %v, want _id plus the shard key fields", keys)
+	}
+
+	// A document without the shard key cannot be routed
+	noKey, _ := bson.Marshal(bson.M{"_id": "b", "v": 2})
+	stream = &fakeBulkStream{reqs: []*pb.BulkInsertRequest{{
+		Database:   "d",
+		Collection: "c",
+		Documents:  [][]byte{noKey},
+		Mode:       pb.BulkInsertRequest_REPLACE,
+	}}}
+	if err := newServer(store).BulkInsert(stream); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing shard key: code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestBulkInsertReplaceRequiresID(t *testing.T) {
+	withID, _ := bson.Marshal(bson.M{"_id": "a", "v": 1})
+	noID, _ := bson.Marshal(bson.M{"v": 1})
+	stream := &fakeBulkStream{reqs: []*pb.BulkInsertRequest{{
+		Database:   "d",
+		Collection: "c",
+		Documents:  [][]byte{withID, noID},
+		Mode:       pb.BulkInsertRequest_REPLACE,
+	}}}
+
+	store := &fakeStore{}
+	err := newServer(store).BulkInsert(stream)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("code = %v, want InvalidArgument", status.Code(err))
+	}
+	// The valid document must not be written ahead of the invalid one
+	if len(store.models) != 0 {
+		t.Errorf("store received %d models, want 0", len(store.models))
+	}
+}
+
+// fakeChangeStream yields canned events, one resume token per event, then
+// fails with err (nil means the stream ended cleanly). Before the first
+// event ResumeToken returns postBatch, like the driver's
+// postBatchResumeToken.
+type fakeChangeStream struct {
+	events    []bson.M
+	tokens    []bson.Raw
+	postBatch bson.Raw
+	err       error
+	pos       int
+}
+
+func (f *fakeChangeStream) Next(ctx context.Context) bool {
+	if f.pos >= len(f.events) {
+		return false
+	}
+	f.pos++
+	return true
+}
+
+func (f *fakeChangeStream) Decode(val interface{}) error {
+	raw, err := bson.Marshal(f.events[f.pos-1])
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, val)
+}
+
+func (f *fakeChangeStream) Err() error {
+	if f.pos < len(f.events) {
+		return nil
+	}
+	return f.err
+}
+
+func (f *fakeChangeStream) ResumeToken() bson.Raw {
+	if f.pos == 0 {
+		return f.postBatch
+	}
+	return f.tokens[f.pos-1]
+}
+
+func (f *fakeChangeStream) Close(ctx context.Context) error { return nil }
+
+// fakeWatchStream sends one WatchRequest and captures the events.
+type fakeWatchStream struct {
+	grpc.ServerStream
+	req  *pb.WatchRequest
+	sent []*pb.WatchEvent
+}
+
+func (f *fakeWatchStream) Context() context.Context { return context.Background() }
+
+func (f *fakeWatchStream) Recv() (*pb.WatchRequest, error) {
+	if f.req == nil {
+		return nil, io.EOF
+	}
+	req := f.req
+	f.req = nil
+	return req, nil
+}
+
+func (f *fakeWatchStream) Send(ev *pb.WatchEvent) error {
+	f.sent = append(f.sent, ev)
+	return nil
+}
+
+func resumeToken(t *testing.T, id string) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(bson.M{"_data": id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func insertEvent(id string) bson.M {
+	return bson.M{"operationType": "insert", "documentKey": bson.M{"_id": id}, "fullDocument": bson.M{"_id": id}}
+}
+
+// noResumeBackoff drops the delay between change stream reopens for the
+// rest of the test.
+func noResumeBackoff(t *testing.T) {
+	t.Helper()
+	saved := watchResumeBackoff
+	watchResumeBackoff = 0
+	t.Cleanup(func() { watchResumeBackoff = saved })
+}
+
+func TestWatchUpdatesResumesAfterElection(t *testing.T) {
+	noResumeBackoff(t)
+	tok1, tok2 := resumeToken(t, "t1"), resumeToken(t, "t2")
+	stepDown := mongo.CommandError{Code: 189, Message: "primary stepped down", Labels: []string{"ResumableChangeStreamError"}}
+	store := &fakeStore{streams: []*fakeChangeStream{
+		{events: []bson.M{insertEvent("a")}, tokens: []bson.Raw{tok1}, err: stepDown},
+		{events: []bson.M{insertEvent("b")}, tokens: []bson.Raw{tok2}},
+	}}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{Database: "d", Collection: "c"}}
+
+	if err := newServer(store).WatchUpdates(stream); err != nil {
+		t.Fatalf("WatchUpdates: %v", err)
+	}
+	if len(stream.sent) != 2 {
+		t.Fatalf("sent %d events, want 2 across the election", len(stream.sent))
+	}
+	if len(store.watchOpts) != 2 {
+		t.Fatalf("Watch called %d times, want 2", len(store.watchOpts))
+	}
+	if got, _ := store.watchOpts[1].ResumeAfter.(bson.Raw); !bytes.Equal(got, tok1) {
+		t.Errorf("reopened with resumeAfter %v, want last token %v", store.watchOpts[1].ResumeAfter, tok1)
+	}
+	if !bytes.Equal(stream.sent[1].ResumeToken, tok2) {
+		t.Errorf("second event token = %v, want %v", stream.sent[1].ResumeToken, tok2)
+	}
+}
+
+func TestWatchUpdatesResumesBeforeFirstEvent(t *testing.T) {
+	noResumeBackoff(t)
+	postBatch := resumeToken(t, "pb1")
+	notPrimary := mongo.CommandError{Code: 10107, Message: "not primary"}
+	store := &fakeStore{streams: []*fakeChangeStream{
+		{postBatch: postBatch, err: notPrimary},
+		{events: []bson.M{insertEvent("a")}, tokens: []bson.Raw{resumeToken(t, "t1")}},
+	}}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{Database: "d", Collection: "c"}}
+
+	if err := newServer(store).WatchUpdates(stream); err != nil {
+		t.Fatalf("WatchUpdates: %v", err)
+	}
+	if len(store.watchOpts) != 2 {
+		t.Fatalf("Watch called %d times, want 2", len(store.watchOpts))
+	}
+	if got, _ := store.watchOpts[1].ResumeAfter.(bson.Raw); !bytes.Equal(got, postBatch) {
+		t.Errorf("reopened with resumeAfter %v, want the postBatchResumeToken %v", store.watchOpts[1].ResumeAfter, postBatch)
+	}
+}
+
+func TestWatchUpdatesOplogResumesBeforeFirstEvent(t *testing.T) {
+	noResumeBackoff(t)
+	start := resumeToken(t, "start")
+	store := &fakeStore{streams: []*fakeChangeStream{
+		{postBatch: start, err: mongo.CommandError{Code: 10107, Message: "not primary"}},
+		{},
+	}}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{Database: "d", Collection: "c", Source: pb.WatchRequest_OPLOG}}
+
+	if err := newServer(store).WatchUpdates(stream); err != nil {
+		t.Fatalf("WatchUpdates: %v", err)
+	}
+	if len(store.oplogCalls) != 2 {
+		t.Fatalf("WatchOplog called %d times, want 2", len(store.oplogCalls))
+	}
+	if got := store.oplogCalls[1].resumeAfter; !bytes.Equal(got, start) {
+		t.Errorf("reopened with resumeAfter %v, want the stream's starting position %v", got, start)
+	}
+}
+
+func TestWatchUpdatesFatalError(t *testing.T) {
+	noResumeBackoff(t)
+	store := &fakeStore{streams: []*fakeChangeStream{
+		{events: []bson.M{insertEvent("a")}, tokens: []bson.Raw{resumeToken(t, "t1")},
+			err: mongo.CommandError{Code: 13, Message: "unauthorized"}},
+	}}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{Database: "d", Collection: "c"}}
+
+	err := newServer(store).WatchUpdates(stream)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("code = %v, want Unavailable (err=%v)", status.Code(err), err)
+	}
+	if len(store.watchOpts) != 1 || len(stream.sent) != 1 {
+		t.Errorf("Watch calls=%d sent=%d, want 1/1 with no reopen", len(store.watchOpts), len(stream.sent))
+	}
+}
+
+func TestWatchUpdatesGivesUpAfterMaxResumes(t *testing.T) {
+	noResumeBackoff(t)
+	store := &fakeStore{}
+	for i := 0; i <= maxWatchResumes; i++ {
+		store.streams = append(store.streams, &fakeChangeStream{
+			err: mongo.CommandError{Code: 10107, Message: "not primary"},
+		})
+	}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{Database: "d", Collection: "c"}}
+
+	err := newServer(store).WatchUpdates(stream)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("code = %v, want Unavailable", status.Code(err))
+	}
+	if len(store.watchOpts) != maxWatchResumes+1 {
+		t.Errorf("Watch called %d times, want %d", len(store.watchOpts), maxWatchResumes+1)
+	}
+}
+
+func TestWatchUpdatesScopeValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pb.WatchRequest
+	}{
+		{"collection scope without collection", &pb.WatchRequest{Database: "d"}},
+		{"database scope without database", &pb.WatchRequest{Scope: pb.WatchRequest_DATABASE}},
+		{"database scope with collection", &pb.WatchRequest{Scope: pb.WatchRequest_DATABASE, Database: "d", Collection: "c"}},
+		{"cluster scope with database", &pb.WatchRequest{Scope: pb.WatchRequest_CLUSTER, Database: "d"}},
+		{"unknown scope", &pb.WatchRequest{Scope: 9, Database: "d", Collection: "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			err := newServer(store).WatchUpdates(&fakeWatchStream{req: tt.req})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("code = %v, want InvalidArgument (err=%v)", status.Code(err), err)
+			}
+			if len(store.watchOpts) != 0 {
+				t.Errorf("Watch called %d times, want 0", len(store.watchOpts))
+			}
+		})
+	}
+}
+
+func TestWatchUpdatesDatabaseScopeNamespace(t *testing.T) {
+	event := insertEvent("a")
+	event["ns"] = bson.M{"db": "d", "coll": "orders"}
+	store := &fakeStore{streams: []*fakeChangeStream{
+		{events: []bson.M{event}, tokens: []bson.Raw{resumeToken(t, "t1")}},
+	}}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{Scope: pb.WatchRequest_DATABASE, Database: "d"}}
+
+	if err := newServer(store).WatchUpdates(stream); err != nil {
+		t.Fatalf("WatchUpdates: %v", err)
+	}
+	if store.lastDB != "d" || store.lastColl != "" {
+		t.Errorf("watched %q.%q, want the whole of database d", store.lastDB, store.lastColl)
+	}
+	if len(stream.sent) != 1 {
+		t.Fatalf("sent %d events, want 1", len(stream.sent))
+	}
+	if ev := stream.sent[0]; ev.Database != "d" || ev.Collection != "orders" {
+		t.Errorf("event ns = %s.%s, want d.orders from the change event", ev.Database, ev.Collection)
+	}
+}
+
+func TestWatchUpdatesOplogSource(t *testing.T) {
+	noResumeBackoff(t)
+	tok1, tok2 := resumeToken(t, "t1"), resumeToken(t, "t2")
+	first := insertEvent("a")
+	first["shard"] = "shard2rs"
+	store := &fakeStore{streams: []*fakeChangeStream{
+		{events: []bson.M{first}, tokens: []bson.Raw{tok1}, err: mongo.CommandError{Code: 10107, Message: "not primary"}},
+		{events: []bson.M{insertEvent("b")}, tokens: []bson.Raw{tok2}},
+	}}
+	stream := &fakeWatchStream{req: &pb.WatchRequest{
+		Database: "d", Collection: "c",
+		Source:          pb.WatchRequest_OPLOG,
+		OperationFilter: pb.WatchRequest_INSERT,
+	}}
+
+	if err := newServer(store).WatchUpdates(stream); err != nil {
+		t.Fatalf("WatchUpdates: %v", err)
+	}
+	if len(store.watchOpts) != 0 {
+		t.Errorf("Watch called %d times, want 0 with the oplog source", len(store.watchOpts))
+	}
+	if len(store.oplogCalls) != 2 {
+		t.Fatalf("WatchOplog called %d times, want 2", len(store.oplogCalls))
+	}
+	if ops := store.oplogCalls[0].ops; len(ops) != 1 || ops[0] != "insert" {
+		t.Errorf("ops = %v, want [insert]", ops)
+	}
+	if got := store.oplogCalls[1].resumeAfter; !bytes.Equal(got, tok1) {
+		t.Errorf("reopened with resumeAfter %v, want last token %v", got, tok1)
+	}
+	if len(stream.sent) != 2 || stream.sent[0].Shard != "shard2rs" {
+		t.Fatalf("sent %v, want 2 events with the first from shard2rs", stream.sent)
+	}
+}
+
+func TestWatchUpdatesOplogValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pb.WatchRequest
+	}{
+		{"filter", &pb.WatchRequest{Database: "d", Collection: "c", Source: pb.WatchRequest_OPLOG, Filter: resumeToken(t, "x")}},
+		{"fields", &pb.WatchRequest{Database: "d", Collection: "c", Source: pb.WatchRequest_OPLOG, Fields: []string{"status"}}},
+		{"change stream token", &pb.WatchRequest{Database: "d", Collection: "c", Source: pb.WatchRequest_OPLOG, ResumeAfter: resumeToken(t, "t1")}},
+		{"unknown source", &pb.WatchRequest{Database: "d", Collection: "c", Source: 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			err := newServer(store).WatchUpdates(&fakeWatchStream{req: tt.req})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("code = %v, want InvalidArgument (err=%v)", status.Code(err), err)
+			}
+			if len(store.oplogCalls) != 0 || len(store.watchOpts) != 0 {
+				t.Errorf("watch opened %d/%d times, want 0", len(store.oplogCalls), len(store.watchOpts))
+			}
+		})
+	}
+}
+
+func TestWatchPipeline(t *testing.T) {
+	filter, _ := bson.Marshal(bson.D{{Key: "status", Value: "paid"}, {Key: "total", Value: bson.D{{Key: "$gt", Value: 100}}}})
+	req := &pb.WatchRequest{
+		OperationFilter: pb.WatchRequest_INSERT,
+		Filter:          filter,
+		Fields:          []string{"status", "customer.id"},
+	}
+
+	pipeline, err := watchPipeline(req)
+	if err != nil {
+		t.Fatalf("watchPipeline: %v", err)
+	}
+	if len(pipeline) != 3 {
+		t.Fatalf("pipeline has %d stages, want 3: %v", len(pipeline), pipeline)
+	}
+
+	match := pipeline[1][0].Value.(bson.D)
+	if match[0].Key != "fullDocument.status" || match[1].Key != "fullDocument.total" {
+		t.Errorf("match keys = %s, %s, want fullDocument-prefixed", match[0].Key, match[1].Key)
+	}
+
+	project := pipeline[2][0]
+	if project.Key != "$project" {
+		t.Fatalf("last stage = %s, want $project", project.Key)
+	}
+	keys := map[string]bool{}
+	for _, e := range project.Value.(bson.D) {
+		keys[e.Key] = true
+	}
+	for _, k := range []string{"operationType", "documentKey", "fullDocument.status", "fullDocument.customer.id"} {
+		if !keys[k] {
+			t.Errorf("$project missing %s", k)
+		}
+	}
+	if keys["_id"] {
+		t.Error("$project must not touch _id (the resume token)")
+	}
+}
+
+func TestWatchPipelineValidation(t *testing.T) {
+	operator, _ := bson.Marshal(bson.D{{Key: "$where", Value: "true"}})
+	tests := []struct {
+		name string
+		req  *pb.WatchRequest
+	}{
+		{"malformed filter", &pb.WatchRequest{Filter: []byte{1, 2, 3}}},
+		{"truncated filter", &pb.WatchRequest{Filter: []byte{16, 0, 0, 0, 16, 'a', 0, 1, 0, 0, 0, 0}}},
+		{"deeply nested filter", &pb.WatchRequest{Filter: nestedDoc(maxNestingDepth + 1)}},
+		{"top-level operator", &pb.WatchRequest{Filter: operator}},
+		{"empty field", &pb.WatchRequest{Fields: []string{""}}},
+		{"operator field", &pb.WatchRequest{Fields: []string{"$secret"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := watchPipeline(tt.req); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestWriteBatchCount(t *testing.T) {
+	doc := func(n int) []byte { return make([]byte, n) }
+	docs := [][]byte{doc(10), doc(10), doc(10), doc(30), doc(5)}
+
+	tests := []struct {
+		name     string
+		maxCount int
+		maxBytes int
+		want     int
+	}{
+		{"fits", 10, 1000, 1},
+		{"by count", 2, 1000, 3},
+		{"by bytes", 10, 35, 2},
+		{"oversized document alone", 10, 20, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeBatchCount(docs, tt.maxCount, tt.maxBytes); got != tt.want {
+				t.Errorf("writes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBulkInsertRejectsOversizedDocument(t *testing.T) {
+	stream := &fakeBulkStream{reqs: []*pb.BulkInsertRequest{{
+		Database:   "d",
+		Collection: "c",
+		Documents:  [][]byte{make([]byte, maxBSONObjectSize+1)},
+	}}}
+
+	store := &fakeStore{}
+	err := newServer(store).BulkInsert(stream)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("code = %v, want InvalidArgument", status.Code(err))
+	}
+	if len(store.inserted) != 0 {
+		t.Errorf("store received %d docs, want 0", len(store.inserted))
+	}
+}
+
+func TestBulkInsertValidatesPayloads(t *testing.T) {
+	good, _ := bson.Marshal(bson.M{"_id": "a", "v": 1})
+	malformed := append([]byte(nil), good...)
+	malformed[len(malformed)-1] = 1 // missing document terminator
+
+	for _, mode := range []pb.BulkInsertRequest_WriteMode{pb.BulkInsertRequest_INSERT, pb.BulkInsertRequest_UPSERT} {
+		for name, bad := range map[string][]byte{"malformed": malformed, "deeply nested": nestedDoc(maxNestingDepth + 1)} {
+			t.Run(mode.String()+"/"+name, func(t *testing.T) {
+				stream := &fakeBulkStream{reqs: []*pb.BulkInsertRequest{{
+					Database:   "d",
+					Collection: "c",
+					Documents:  [][]byte{good, bad},
+					Mode:       mode,
+				}}}
+
+				store := &fakeStore{}
+				err := newServer(store).BulkInsert(stream)
+				if status.Code(err) != codes.InvalidArgument {
+					t.Fatalf("code = %v, want InvalidArgument", status.Code(err))
+				}
+				if len(store.inserted) != 0 || len(store.models) != 0 {
+					t.Errorf("store received %d docs and %d models, want nothing written", len(store.inserted), len(store.models))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/cluster"
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+// datastore is the MongoDB surface the gRPC handlers depend on.
+// mongoStore is the production implementation; tests substitute a fake
+// so handler validation and error mapping run without a live cluster.
+type datastore interface {
+	InsertOne(ctx context.Context, db, coll string, doc interface{}) (*mongo.InsertOneResult, error)
+	Find(ctx context.Context, db, coll string, filter interface{}, opts *options.FindOptions) ([]bson.M, error)
+	CountDocuments(ctx context.Context, db, coll string, filter interface{}) (int64, error)
+	InsertMany(ctx context.Context, db, coll string, docs []interface{}, opts *options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	BulkWrite(ctx context.Context, db, coll string, models []mongo.WriteModel, opts *options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+	Watch(ctx context.Context, db, coll string, pipeline mongo.Pipeline, opts *options.ChangeStreamOptions) (changeStream, error)
+	WatchOplog(ctx context.Context, db, coll string, ops []string, resumeAfter bson.Raw) (changeStream, error)
+	ExplainRouting(ctx context.Context, db, coll string, filter interface{}) (*sharding.QueryRouting, error)
+	ShardKey(ctx context.Context, db, coll string) (bson.D, error)
+	ReshardProgress(ctx context.Context, ns string) (*sharding.ReshardProgress, error)
+	LocateDocument(ctx context.Context, db, coll string, doc bson.M) (*sharding.DocumentLocation, error)
+	Ping(ctx context.Context) error
+}
+
+// changeStream is the subset of *mongo.ChangeStream used by WatchUpdates.
+type changeStream interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	ResumeToken() bson.Raw
+	Close(ctx context.Context) error
+}
+
+// mongoStore implements datastore on top of a *mongo.Client.
+// Oplog watches connect to each shard's replica set directly with user and
+// password; those clients are opened on first use and kept in shards.
+type mongoStore struct {
+	client   *mongo.Client
+	user     string
+	password string
+
+	mu     sync.Mutex
+	shards map[string]*mongo.Client
+}
+
+func (m *mongoStore) InsertOne(ctx context.Context, db, coll string, doc interface{}) (*mongo.InsertOneResult, error) {
+	return m.client.Database(db).Collection(coll).InsertOne(ctx, doc)
+}
+
+// Find drains the cursor into memory; documents that fail to decode are skipped.
+func (m *mongoStore) Find(ctx context.Context, db, coll string, filter interface{}, opts *options.FindOptions) ([]bson.M, error) {
+	cursor, err := m.client.Database(db).Collection(coll).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.M
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, cursor.Err()
+}
+
+func (m *mongoStore) CountDocuments(ctx context.Context, db, coll string, filter interface{}) (int64, error) {
+	return m.client.Database(db).Collection(coll).CountDocuments(ctx, filter)
+}
+
+func (m *mongoStore) InsertMany(ctx context.Context, db, coll string, docs []interface{}, opts *options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	return m.client.Database(db).Collection(coll).InsertMany(ctx, docs, opts)
+}
+
+func (m *mongoStore) BulkWrite(ctx context.Context, db, coll string, models []mongo.WriteModel, opts *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	return m.client.Database(db).Collection(coll).BulkWrite(ctx, models, opts)
+}
+
+// Watch opens a change stream on db.coll, on all of db when coll is empty,
+// or on the whole deployment when db is empty too.
+func (m *mongoStore) Watch(ctx context.Context, db, coll string, pipeline mongo.Pipeline, opts *options.ChangeStreamOptions) (changeStream, error) {
+	var cs *mongo.ChangeStream
+	var err error
+	switch {
+	case db == "":
+		cs, err = m.client.Watch(ctx, pipeline, opts)
+	case coll == "":
+		cs, err = m.client.Database(db).Watch(ctx, pipeline, opts)
+	default:
+		cs, err = m.client.Database(db).Collection(coll).Watch(ctx, pipeline, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// WatchOplog tails the oplog of every shard registered with the cluster.
+func (m *mongoStore) WatchOplog(ctx context.Context, db, coll string, ops []string, resumeAfter bson.Raw) (changeStream, error) {
+	sources, err := m.shardSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sharding.OpenOplogStream(ctx, sources, db, coll, ops, resumeAfter)
+}
+
+// shardSources returns a client per registered shard, connecting to shards
+// it has not seen before (e.g. one added since the last watch).
+func (m *mongoStore) shardSources(ctx context.Context) ([]sharding.OplogSource, error) {
+	status, err := cluster.GetClusterStatus(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shards == nil {
+		m.shards = make(map[string]*mongo.Client)
+	}
+	sources := make([]sharding.OplogSource, 0, len(status.Shards))
+	for _, shard := range status.Shards {
+		client, ok := m.shards[shard.ID]
+		if !ok {
+			uri, err := config.BuildURI(shard.Members, m.user, m.password, "admin")
+			if err != nil {
+				return nil, fmt.Errorf("shard %s: %w", shard.ID, err)
+			}
+			opts := options.Client().ApplyURI(uri)
+			if shard.ReplicaSet != "" {
+				opts.SetReplicaSet(shard.ReplicaSet)
+			}
+			if client, err = mongo.Connect(ctx, opts); err != nil {
+				return nil, fmt.Errorf("connect to shard %s: %w", shard.ID, err)
+			}
+			m.shards[shard.ID] = client
+		}
+		sources = append(sources, sharding.OplogSource{Shard: shard.ID, Client: client})
+	}
+	return sources, nil
+}
+
+func (m *mongoStore) ExplainRouting(ctx context.Context, db, coll string, filter interface{}) (*sharding.QueryRouting, error) {
+	return sharding.ExplainQueryRouting(ctx, m.client, db, coll, filter)
+}
+
+func (m *mongoStore) ShardKey(ctx context.Context, db, coll string) (bson.D, error) {
+	return sharding.CollectionShardKey(ctx, m.client, db, coll)
+}
+
+func (m *mongoStore) ReshardProgress(ctx context.Context, ns string) (*sharding.ReshardProgress, error) {
+	return sharding.GetReshardProgress(ctx, m.client, ns)
+}
+
+func (m *mongoStore) LocateDocument(ctx context.Context, db, coll string, doc bson.M) (*sharding.DocumentLocation, error) {
+	return sharding.LocateDocument(ctx, m.client, db, coll, doc)
+}
+
+func (m *mongoStore) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
+}
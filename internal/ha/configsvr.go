@@ -4,21 +4,38 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
 )
 
+// baselineDocCount is how many documents the outage test reads back while
+// the config servers are down.
+const baselineDocCount = 50
+
 // RunConfigServerOutageTest shuts down 2 of 3 config servers to demonstrate
 // that the cluster enters a degraded state where data reads still work
 // (via cached routing) but metadata writes fail.
-func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client, db string) error {
+// nodes stops and restarts the config servers.
+func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client, db string, nodes NodeController, scale sharding.Scale) error {
 	log.Println("=== Config Server Outage Test ===")
 	log.Println("Goal: Verify behavior when config server majority is lost")
 	log.Println("")
 
-	configServers := []string{"cfg-2", "cfg-3"} // Keep cfg-1 alive (minority)
+	// Keep cfg-1 alive (minority)
+	configServers := []string{"cfg-2", "cfg-3"}
+
+	log.Println("Checking config server nodes can be stopped and started...")
+	if err := nodes.Check(configServers...); err != nil {
+		return fmt.Errorf("pre-flight: %w", err)
+	}
+	log.Printf("  [OK] Nodes running: %v", configServers)
+	log.Println("")
 
 	// Verify cluster is healthy before test
 	log.Println("Verifying cluster health before outage...")
@@ -33,8 +50,8 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 	coll := mongosClient.Database(db).Collection("configsvr_test")
 	coll.Drop(ctx)
 
-	docs := make([]interface{}, 50)
-	for i := 0; i < 50; i++ {
+	docs := make([]interface{}, scale.Count(baselineDocCount))
+	for i := range docs {
 		docs[i] = bson.M{
 			"_id":   fmt.Sprintf("baseline_%04d", i),
 			"phase": "pre_outage",
@@ -43,17 +60,15 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 	if _, err := coll.InsertMany(ctx, docs); err != nil {
 		return fmt.Errorf("baseline insert: %w", err)
 	}
-	log.Println("  [OK] 50 baseline documents inserted")
+	log.Printf("  [OK] %d baseline documents inserted", len(docs))
 
 	// Stop 2 of 3 config servers
 	log.Println("")
 	log.Printf("Stopping config servers: %v...", configServers)
+	restorer := &nodeRestorer{nodes: nodes}
+	defer restorer.restore()
 	for _, cs := range configServers {
-		if err := StopContainer(cs); err != nil {
-			// Restart any we already stopped
-			for _, stopped := range configServers {
-				StartContainer(stopped)
-			}
+		if err := restorer.stop(ctx, cs); err != nil {
 			return fmt.Errorf("stop %s: %w", cs, err)
 		}
 		log.Printf("  [OK] %s stopped", cs)
@@ -62,7 +77,9 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 	// Wait for cluster to detect the outage
 	log.Println("")
 	log.Println("Waiting for cluster to detect config server outage...")
-	time.Sleep(10 * time.Second)
+	if !timeutil.Sleep(ctx, 10*time.Second) {
+		return ctx.Err()
+	}
 
 	// Test data reads (should still work via cached routing tables)
 	log.Println("")
@@ -75,7 +92,7 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 		log.Printf("  [RESULT] Data reads FAILED: %v", err)
 		log.Println("  Config server outage affected data reads (routing cache expired)")
 	} else {
-		log.Printf("  [RESULT] Data reads WORK: found %d/50 documents", count)
+		log.Printf("  [RESULT] Data reads WORK: found %d/%d documents", count, len(docs))
 		log.Println("  mongos uses cached routing tables for existing collections")
 	}
 
@@ -117,7 +134,7 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 	log.Println("")
 	log.Printf("Restoring config servers: %v...", configServers)
 	for _, cs := range configServers {
-		if err := StartContainer(cs); err != nil {
+		if err := restorer.start(ctx, cs); err != nil {
 			log.Printf("  [WARN] start %s: %v", cs, err)
 		} else {
 			log.Printf("  [OK] %s restarted", cs)
@@ -127,7 +144,9 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 	// Wait for recovery
 	log.Println("")
 	log.Println("Waiting for config server recovery...")
-	time.Sleep(15 * time.Second)
+	if !timeutil.Sleep(ctx, 15*time.Second) {
+		return ctx.Err()
+	}
 
 	// Verify full operation restored
 	log.Println("Verifying full cluster recovery...")
@@ -140,7 +159,9 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 		if pingErr == nil {
 			break
 		}
-		time.Sleep(3 * time.Second)
+		if !timeutil.Sleep(recoveryCtx, 3*time.Second) {
+			break
+		}
 	}
 
 	if pingErr != nil {
@@ -155,7 +176,7 @@ func RunConfigServerOutageTest(ctx context.Context, mongosClient *mongo.Client,
 
 	log.Println("")
 	log.Println("OUTAGE SUMMARY")
-	log.Println("  Config servers stopped:       cfg-2, cfg-3 (majority lost)")
+	log.Printf("  Config servers stopped:       %s (majority lost)", strings.Join(configServers, ", "))
 	log.Println("  Data reads during outage:     Depend on cached routing tables")
 	log.Println("  Metadata writes during outage: FAIL (no config server majority)")
 	log.Println("  After recovery:               Full operation restored")
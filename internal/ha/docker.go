@@ -0,0 +1,76 @@
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DockerController stops and starts nodes as Docker containers, matching
+// the docker-compose topology. Prefix covers compose projects that prefix
+// container names, e.g. "myproject-"; the bundled docker-compose.yml
+// needs none.
+type DockerController struct {
+	Prefix string
+}
+
+// ContainerName maps a cluster hostname (e.g. "shard1-1") to its container.
+func (d *DockerController) ContainerName(node string) string {
+	return d.Prefix + node
+}
+
+// Check verifies that the docker CLI is on PATH and that every node's
+// container exists and is running, so a missing docker or misnamed
+// container fails the lab up front instead of leaving the cluster
+// half-degraded.
+func (d *DockerController) Check(nodes ...string) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker CLI not found on PATH (HA labs stop and start containers): %w", err)
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		name := d.ContainerName(node)
+		out, err := exec.Command("docker", "inspect", "--format", "{{.State.Running}}", name).CombinedOutput()
+		state := strings.TrimSpace(string(out))
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("container %s not found (set MONGO_CONTAINER_PREFIX if compose prefixes names): %s", name, state))
+		case state != "true":
+			errs = append(errs, fmt.Errorf("container %s is not running", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stop stops the node's container.
+func (d *DockerController) Stop(ctx context.Context, node string) error {
+	return StopContainer(ctx, d.ContainerName(node))
+}
+
+// Start starts the node's container.
+func (d *DockerController) Start(ctx context.Context, node string) error {
+	return StartContainer(ctx, d.ContainerName(node))
+}
+
+// StopContainer stops a Docker container by name.
+func StopContainer(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "docker", "stop", name)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// StartContainer starts a Docker container by name.
+func StartContainer(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "docker", "start", name)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
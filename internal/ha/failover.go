@@ -4,21 +4,36 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os/exec"
-	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/cluster"
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/operations"
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
 )
 
-const failoverCollection = "failover_test"
+// DefaultFailoverCollection is the collection RunShardFailoverTest
+// recreates when the caller does not name one.
+const DefaultFailoverCollection = "failover_test"
 
 // RunShardFailoverTest kills a shard primary and verifies automatic failover.
 // Proves that mongos transparently redirects traffic to the new primary
-// with zero data loss.
-func RunShardFailoverTest(ctx context.Context, mongosClient *mongo.Client, db string) error {
+// with zero data loss, and that a change stream opened before the failure
+// keeps delivering events once it resumes on the new primary.
+// coll is dropped and recreated as a single chunk pinned to the failed
+// shard, so the zero-loss check covers exactly the data that shard holds,
+// document by document. nodes stops and restarts the primary.
+// When events is non-nil, every write attempt, what $currentOp showed in
+// flight during each failed one, and the test's milestones are captured
+// into it, and a background writer inserts through mongos from the moment
+// the primary is stopped until the post-election inserts, so the timeline
+// covers the whole outage.
+func RunShardFailoverTest(ctx context.Context, adminClient, mongosClient *mongo.Client, db, coll string, nodes NodeController, events *FailoverLog) error {
 	log.Println("=== Shard Failover Test ===")
 	log.Println("Goal: Kill primary, verify re-election, confirm zero data loss")
 	log.Println("")
@@ -26,47 +41,97 @@ func RunShardFailoverTest(ctx context.Context, mongosClient *mongo.Client, db st
 	// Target shard1rs for the failover test
 	shardRS := "shard1rs"
 	shardMembers := []string{"shard1-1:27022", "shard1-2:27023", "shard1-3:27024"}
-	containerMap := map[string]string{
+	nodeMap := map[string]string{
 		"shard1-1:27022": "shard1-1",
 		"shard1-2:27023": "shard1-2",
 		"shard1-3:27024": "shard1-3",
 	}
 
+	log.Println("Checking shard nodes can be stopped and started...")
+	var shardNodes []string
+	for _, m := range shardMembers {
+		shardNodes = append(shardNodes, nodeMap[m])
+	}
+	if err := nodes.Check(shardNodes...); err != nil {
+		return fmt.Errorf("pre-flight: %w", err)
+	}
+	log.Printf("  [OK] Nodes running: %v", shardNodes)
+	log.Println("")
+
 	// Find current primary
 	log.Printf("Identifying %s primary...", shardRS)
 	primaryAddr, err := FindPrimary(ctx, shardMembers)
 	if err != nil {
 		return fmt.Errorf("find primary: %w", err)
 	}
-	primaryContainer := containerMap[primaryAddr]
-	log.Printf("  Current PRIMARY: %s (%s)", primaryAddr, primaryContainer)
+	primaryNode := nodeMap[primaryAddr]
+	log.Printf("  Current PRIMARY: %s (%s)", primaryAddr, primaryNode)
+
+	// Keep every test document on the shard that is about to fail
+	log.Println("")
+	log.Printf("Pinning %s.%s to %s...", db, coll, shardRS)
+	if err := pinToShard(ctx, adminClient, mongosClient, db, coll, shardRS); err != nil {
+		return fmt.Errorf("pin %s to %s: %w", coll, shardRS, err)
+	}
+	log.Printf("  [OK] Sharded on { _id: 1 } as one chunk on %s, balancing off", shardRS)
 
 	// Insert pre-failover data through mongos
 	log.Println("")
 	log.Println("Inserting pre-failover test data...")
-	coll := mongosClient.Database(db).Collection(failoverCollection)
-	coll.Drop(ctx)
+	testColl, err := cluster.SetCollectionWriteConcern(ctx, mongosClient, db+"."+coll, config.WriteConcern{W: "majority", Journal: true})
+	if err != nil {
+		return fmt.Errorf("write concern: %w", err)
+	}
+	log.Println("  Writes use { w: majority, j: true }: an acknowledged write is journaled on a")
+	log.Println("  majority of members, so the election cannot roll it back")
 
+	var expected []string
 	preDocs := make([]interface{}, 100)
 	for i := 0; i < 100; i++ {
+		expected = append(expected, fmt.Sprintf("pre_%04d", i))
 		preDocs[i] = bson.M{
 			"_id":   fmt.Sprintf("pre_%04d", i),
 			"phase": "before_failover",
 			"index": i,
 		}
 	}
-	if _, err := coll.InsertMany(ctx, preDocs); err != nil {
+	ns := db + "." + coll
+	err = events.trackWrite(ctx, adminClient, ns, "insert 100 pre-failover docs", 1, func() error {
+		_, err := testColl.InsertMany(ctx, preDocs)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("pre-failover insert: %w", err)
 	}
 	log.Println("  [OK] 100 pre-failover documents inserted")
 
+	// Follow inserts through the election with a resuming change stream
+	watcher, err := startFailoverWatcher(ctx, testColl)
+	if err != nil {
+		return fmt.Errorf("open change stream: %w", err)
+	}
+	defer watcher.stop()
+	log.Println("  [OK] Change stream opened on " + coll)
+
 	// Kill the primary
 	log.Println("")
-	log.Printf("Killing primary container: %s...", primaryContainer)
-	if err := StopContainer(primaryContainer); err != nil {
-		return fmt.Errorf("stop %s: %w", primaryContainer, err)
+	log.Printf("Killing primary node: %s...", primaryNode)
+	restorer := &nodeRestorer{nodes: nodes}
+	defer restorer.restore()
+	var writer *failoverWriter
+	if events != nil {
+		writer = startFailoverWriter(ctx, adminClient, testColl, events)
+		defer func() {
+			if writer != nil {
+				writer.stop()
+			}
+		}()
+	}
+	if err := restorer.stop(ctx, primaryNode); err != nil {
+		return fmt.Errorf("stop %s: %w", primaryNode, err)
 	}
-	log.Printf("  [OK] Container %s stopped", primaryContainer)
+	log.Printf("  [OK] Node %s stopped", primaryNode)
+	events.milestone("primary %s (%s) stopped", primaryAddr, primaryNode)
 
 	// Wait for new election
 	log.Println("")
@@ -80,21 +145,23 @@ func RunShardFailoverTest(ctx context.Context, mongosClient *mongo.Client, db st
 
 	newPrimary, err := WaitForNewPrimary(ctx, remainingMembers, primaryAddr, 60*time.Second)
 	if err != nil {
-		// Restart the container before returning error
-		StartContainer(primaryContainer)
 		return fmt.Errorf("election timeout: %w", err)
 	}
 	log.Printf("  [OK] New PRIMARY elected: %s", newPrimary)
+	events.milestone("new primary %s elected", newPrimary)
 
 	// Insert post-failover data through mongos
 	log.Println("")
 	log.Println("Inserting post-failover data through mongos...")
 
 	// Give mongos a moment to discover the new topology
-	time.Sleep(3 * time.Second)
+	if !timeutil.Sleep(ctx, 3*time.Second) {
+		return ctx.Err()
+	}
 
 	postDocs := make([]interface{}, 100)
 	for i := 0; i < 100; i++ {
+		expected = append(expected, fmt.Sprintf("post_%04d", i))
 		postDocs[i] = bson.M{
 			"_id":   fmt.Sprintf("post_%04d", i),
 			"phase": "after_failover",
@@ -102,18 +169,36 @@ func RunShardFailoverTest(ctx context.Context, mongosClient *mongo.Client, db st
 		}
 	}
 
+	if writer != nil {
+		acked, unacked := writer.stop()
+		writer = nil
+		// A failed insert may still have been applied before the error
+		applied, err := existingIDs(ctx, testColl, unacked)
+		if err != nil {
+			return fmt.Errorf("check background inserts: %w", err)
+		}
+		expected = append(expected, acked...)
+		expected = append(expected, applied...)
+		log.Printf("  Background writer: %d inserts acknowledged, %d failed (%d of those applied anyway)",
+			len(acked), len(unacked), len(applied))
+	}
+
 	// Retry insert with backoff (mongos may need time)
 	var insertErr error
 	for attempt := 0; attempt < 5; attempt++ {
-		_, insertErr = coll.InsertMany(ctx, postDocs)
+		insertErr = events.trackWrite(ctx, adminClient, ns, "insert 100 post-failover docs", attempt+1, func() error {
+			_, err := testColl.InsertMany(ctx, postDocs)
+			return err
+		})
 		if insertErr == nil {
 			break
 		}
 		log.Printf("  Attempt %d: %v (retrying...)", attempt+1, insertErr)
-		time.Sleep(3 * time.Second)
+		if !timeutil.Sleep(ctx, 3*time.Second) {
+			return ctx.Err()
+		}
 	}
 	if insertErr != nil {
-		StartContainer(primaryContainer)
 		return fmt.Errorf("post-failover insert failed: %w", insertErr)
 	}
 	log.Println("  [OK] 100 post-failover documents inserted")
@@ -121,31 +206,48 @@ func RunShardFailoverTest(ctx context.Context, mongosClient *mongo.Client, db st
 	// Verify data integrity
 	log.Println("")
 	log.Println("Verifying data integrity...")
-	preCount, _ := coll.CountDocuments(ctx, bson.M{"phase": "before_failover"})
-	postCount, _ := coll.CountDocuments(ctx, bson.M{"phase": "after_failover"})
-	totalCount, _ := coll.CountDocuments(ctx, bson.M{})
+	preCount, _ := testColl.CountDocuments(ctx, bson.M{"phase": "before_failover"})
+	postCount, _ := testColl.CountDocuments(ctx, bson.M{"phase": "after_failover"})
+	totalCount, _ := testColl.CountDocuments(ctx, bson.M{})
 
 	log.Printf("  Pre-failover docs:  %d/100", preCount)
 	log.Printf("  Post-failover docs: %d/100", postCount)
-	log.Printf("  Total docs:         %d/200", totalCount)
+	log.Printf("  Total docs:         %d/%d", totalCount, len(expected))
 
-	if totalCount == 200 {
-		log.Println("  [OK] ZERO DATA LOSS confirmed")
-	} else {
-		log.Printf("  [WARN] Expected 200 docs, found %d", totalCount)
+	if err := verifyShardData(ctx, adminClient, testColl, shardRS, expected); err != nil {
+		return fmt.Errorf("data on %s after failover: %w", shardRS, err)
+	}
+	log.Printf("  [OK] ZERO DATA LOSS confirmed on %s: all %d majority-acknowledged documents", shardRS, len(expected))
+	log.Println("       are present by _id and still stored on the failed shard")
+
+	// The change stream must have carried on across the election
+	watcher.waitFor(ctx, "after_failover", 100, 15*time.Second)
+	seen := watcher.count("after_failover")
+	resumes, watchErr := watcher.stop()
+	log.Printf("  [VERIFY] Change stream saw %d/100 post-failover inserts (resumed %d times)", seen, resumes)
+	switch {
+	case watchErr != nil:
+		log.Printf("  [WARN] Change stream ended with a non-resumable error: %v", watchErr)
+	case seen == 100:
+		log.Println("  [OK] Change stream survived the election")
+	default:
+		log.Printf("  [WARN] Change stream missed %d post-failover events", 100-seen)
 	}
 
 	// Restart the killed node
 	log.Println("")
-	log.Printf("Restarting %s...", primaryContainer)
-	if err := StartContainer(primaryContainer); err != nil {
-		log.Printf("  [WARN] restart %s: %v", primaryContainer, err)
+	log.Printf("Restarting %s...", primaryNode)
+	if err := restorer.start(ctx, primaryNode); err != nil {
+		log.Printf("  [WARN] restart %s: %v", primaryNode, err)
 	} else {
-		log.Printf("  [OK] %s restarted (will rejoin as SECONDARY)", primaryContainer)
+		log.Printf("  [OK] %s restarted (will rejoin as SECONDARY)", primaryNode)
+		events.milestone("%s restarted", primaryNode)
 	}
 
 	// Wait and show final RS status
-	time.Sleep(5 * time.Second)
+	if !timeutil.Sleep(ctx, 5*time.Second) {
+		return ctx.Err()
+	}
 	log.Println("")
 	log.Println("Final replica set status:")
 	PrintRSStatus(ctx, shardMembers)
@@ -156,6 +258,92 @@ func RunShardFailoverTest(ctx context.Context, mongosClient *mongo.Client, db st
 	return nil
 }
 
+// pinToShard recreates db.coll sharded on { _id: 1 } and moves its single
+// chunk to shard. Balancing is disabled for the collection so the chunk
+// stays there for the whole test.
+func pinToShard(ctx context.Context, adminClient, appClient *mongo.Client, db, coll, shard string) error {
+	if err := sharding.DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return err
+	}
+	if err := sharding.ShardCollection(ctx, adminClient, db, coll, bson.D{{Key: "_id", Value: 1}}); err != nil {
+		return err
+	}
+	ns := db + "." + coll
+	if err := operations.SetCollectionBalancing(ctx, adminClient, ns, false); err != nil {
+		return err
+	}
+
+	// A fresh collection is one chunk on the database's primary shard
+	loc, err := sharding.LocateDocument(ctx, adminClient, db, coll, bson.M{"_id": ""})
+	if err != nil {
+		return err
+	}
+	if loc.Shard == shard {
+		return nil
+	}
+	return operations.MoveChunk(ctx, adminClient, ns, bson.D{{Key: "_id", Value: ""}}, shard)
+}
+
+// verifyShardData checks that every _id in expected is readable through
+// mongos and that shard stores all of the collection's documents.
+func verifyShardData(ctx context.Context, adminClient *mongo.Client, coll *mongo.Collection, shard string, expected []string) error {
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	found := make(map[string]bool, len(docs))
+	for _, d := range docs {
+		found[d.ID] = true
+	}
+	var missing []string
+	for _, id := range expected {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d of %d documents missing, e.g. %v", len(missing), len(expected), missing[:min(len(missing), 5)])
+	}
+
+	dist, err := sharding.GetShardDistribution(ctx, adminClient, coll.Database().Name(), coll.Name())
+	if err != nil {
+		return err
+	}
+	if dist.Shards[shard] != int64(len(expected)) || dist.Total != int64(len(expected)) {
+		return fmt.Errorf("expected all %d documents on %s, distribution is %v", len(expected), shard, dist.Shards)
+	}
+	log.Printf("  [VERIFY] %d/%d documents by _id, %d stored on %s", len(docs), len(expected), dist.Shards[shard], shard)
+	return nil
+}
+
+// existingIDs returns which of ids are stored in coll.
+func existingIDs(ctx context.Context, coll *mongo.Collection, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	found := make([]string, len(docs))
+	for i, d := range docs {
+		found[i] = d.ID
+	}
+	return found, nil
+}
+
 // FindPrimary connects to each member and returns the address of the PRIMARY.
 func FindPrimary(ctx context.Context, members []string) (string, error) {
 	for _, addr := range members {
@@ -218,10 +406,8 @@ func WaitForNewPrimary(ctx context.Context, members []string, oldPrimary string,
 			}
 		}
 
-		select {
-		case <-ctx.Done():
+		if !timeutil.Sleep(ctx, 2*time.Second) {
 			return "", ctx.Err()
-		case <-time.After(2 * time.Second):
 		}
 	}
 	return "", fmt.Errorf("no new primary elected within %v", timeout)
@@ -257,23 +443,3 @@ func PrintRSStatus(ctx context.Context, members []string) {
 		}
 	}
 }
-
-// StopContainer stops a Docker container by name.
-func StopContainer(name string) error {
-	cmd := exec.Command("docker", "stop", name)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
-	}
-	return nil
-}
-
-// StartContainer starts a Docker container by name.
-func StartContainer(name string) error {
-	cmd := exec.Command("docker", "start", name)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
-	}
-	return nil
-}
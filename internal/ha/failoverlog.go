@@ -0,0 +1,288 @@
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+// Failover event kinds.
+const (
+	EventMilestone = "milestone" // a step of the test: primary stopped, new primary elected, ...
+	EventWrite     = "write"     // one write attempt, successful or not
+	EventCurrentOp = "currentOp" // in-flight operations on the namespace while a failed write ran
+)
+
+// currentOpInterval is how often trackWrite samples $currentOp while a
+// write is in flight.
+const currentOpInterval = 250 * time.Millisecond
+
+// FailoverEvent is one entry of a FailoverLog. Codes and Labels are the
+// server error codes and labels of a failed write (RetryableWriteError,
+// NetworkError, ...); Network marks errors where no server answered.
+type FailoverEvent struct {
+	Time     time.Time     `json:"time"`
+	Kind     string        `json:"kind"`
+	Detail   string        `json:"detail"`
+	Attempt  int           `json:"attempt,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Codes    []int         `json:"codes,omitempty"`
+	Labels   []string      `json:"labels,omitempty"`
+	Network  bool          `json:"network,omitempty"`
+	Err      error         `json:"-"`
+}
+
+// FailoverLog captures a timeline of RunShardFailoverTest for post-mortem
+// analysis: each write attempt with its outcome and error details, what
+// $currentOp showed in flight while every failed write ran, and the test's
+// milestones.
+// It is safe for concurrent use; the zero value is ready to use, and a nil
+// *FailoverLog captures nothing.
+type FailoverLog struct {
+	mu     sync.Mutex
+	events []FailoverEvent
+}
+
+// Events returns a copy of the captured events in order.
+func (l *FailoverLog) Events() []FailoverEvent {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]FailoverEvent(nil), l.events...)
+}
+
+// PrintTimeline logs every captured event with its offset from the first.
+func (l *FailoverLog) PrintTimeline() {
+	events := l.Events()
+	log.Printf("  Failover timeline (%d events):", len(events))
+	if len(events) == 0 {
+		return
+	}
+	start := events[0].Time
+	for _, e := range events {
+		line := fmt.Sprintf("    +%-9v %-9s %s", e.Time.Sub(start).Round(time.Millisecond), e.Kind, e.Detail)
+		if e.Kind == EventWrite {
+			result := "ok"
+			if e.Err != nil {
+				result = "error: " + e.Err.Error()
+			}
+			line += fmt.Sprintf(" attempt %d in %v [%s]", e.Attempt, e.Duration.Round(time.Millisecond), result)
+			if len(e.Codes) > 0 || len(e.Labels) > 0 || e.Network {
+				line += fmt.Sprintf(" codes=%v labels=%v network=%v", e.Codes, e.Labels, e.Network)
+			}
+		}
+		log.Print(line)
+	}
+}
+
+func (l *FailoverLog) add(e FailoverEvent) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	l.mu.Unlock()
+}
+
+// milestone records a step of the test.
+func (l *FailoverLog) milestone(format string, args ...interface{}) {
+	l.add(FailoverEvent{Kind: EventMilestone, Detail: fmt.Sprintf(format, args...)})
+}
+
+// trackWrite runs write, one write attempt on ns, and records it with its
+// outcome. While the write is in flight, $currentOp is sampled through
+// adminClient every currentOpInterval; if the write fails, the sample that
+// last saw operations on ns is recorded too. Sampling afterwards would be
+// too late: by the time an error returns, the operation is gone. A nil
+// *FailoverLog just runs write.
+func (l *FailoverLog) trackWrite(ctx context.Context, adminClient *mongo.Client, ns, detail string, attempt int, write func() error) error {
+	if l == nil {
+		return write()
+	}
+
+	sampleCtx, stopSampling := context.WithCancel(ctx)
+	sampled := make(chan *FailoverEvent, 1)
+	go func() {
+		var last *FailoverEvent
+		for timeutil.Sleep(sampleCtx, currentOpInterval) {
+			at := time.Now()
+			summary, ops := currentOpSummary(sampleCtx, adminClient, ns)
+			if sampleCtx.Err() != nil {
+				break // cut short by the write finishing
+			}
+			if ops > 0 || last == nil {
+				last = &FailoverEvent{Time: at, Kind: EventCurrentOp, Detail: summary}
+			}
+		}
+		sampled <- last
+	}()
+
+	start := time.Now()
+	err := write()
+	e := FailoverEvent{Time: start, Kind: EventWrite, Detail: detail, Attempt: attempt, Duration: time.Since(start), Err: err}
+	stopSampling()
+	snapshot := <-sampled
+
+	if err != nil {
+		e.Codes, e.Labels = writeErrorDetails(err)
+		e.Network = mongo.IsNetworkError(err)
+	}
+	l.add(e)
+	if err != nil {
+		if snapshot == nil {
+			snapshot = &FailoverEvent{Kind: EventCurrentOp,
+				Detail: fmt.Sprintf("write failed within %v, before $currentOp was sampled", currentOpInterval)}
+		}
+		l.add(*snapshot)
+	}
+	return err
+}
+
+// writeErrorDetails extracts the server error codes and labels from a
+// write error, including write concern and per-document write errors.
+func writeErrorDetails(err error) ([]int, []string) {
+	var codes []int
+	var labels []string
+
+	var cmdErr mongo.CommandError
+	var writeErr mongo.WriteException
+	var bulkErr mongo.BulkWriteException
+	switch {
+	case errors.As(err, &cmdErr):
+		codes = append(codes, int(cmdErr.Code))
+		labels = cmdErr.Labels
+	case errors.As(err, &writeErr):
+		for _, we := range writeErr.WriteErrors {
+			codes = appendCode(codes, we.Code)
+		}
+		if wce := writeErr.WriteConcernError; wce != nil {
+			codes = appendCode(codes, wce.Code)
+		}
+		labels = writeErr.Labels
+	case errors.As(err, &bulkErr):
+		for _, we := range bulkErr.WriteErrors {
+			codes = appendCode(codes, we.Code)
+		}
+		if wce := bulkErr.WriteConcernError; wce != nil {
+			codes = appendCode(codes, wce.Code)
+		}
+		labels = bulkErr.Labels
+	}
+	return codes, labels
+}
+
+// appendCode adds code to codes once; a failed InsertMany repeats the same
+// code for every rejected document.
+func appendCode(codes []int, code int) []int {
+	for _, c := range codes {
+		if c == code {
+			return codes
+		}
+	}
+	return append(codes, code)
+}
+
+// currentOpSummary describes the operations on ns that $currentOp reports
+// in flight, one "op(shard, secs)" per operation, or why it could not tell,
+// and returns how many it found.
+func currentOpSummary(ctx context.Context, client *mongo.Client, ns string) (string, int) {
+	opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$currentOp", Value: bson.D{{Key: "allUsers", Value: true}}}},
+		{{Key: "$match", Value: bson.D{{Key: "ns", Value: ns}}}},
+	}
+	cursor, err := client.Database("admin").Aggregate(opCtx, pipeline)
+	if err != nil {
+		return fmt.Sprintf("$currentOp on %s unavailable: %v", ns, err), 0
+	}
+	defer cursor.Close(opCtx)
+
+	var ops []string
+	for cursor.Next(opCtx) {
+		var op struct {
+			Op          string `bson:"op"`
+			Shard       string `bson:"shard"`
+			SecsRunning int64  `bson:"secs_running"`
+		}
+		if err := cursor.Decode(&op); err != nil {
+			continue
+		}
+		ops = append(ops, fmt.Sprintf("%s(%s, %ds)", op.Op, op.Shard, op.SecsRunning))
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Sprintf("$currentOp on %s failed: %v", ns, err), 0
+	}
+	if len(ops) == 0 {
+		return fmt.Sprintf("no operations in flight on %s", ns), 0
+	}
+	return fmt.Sprintf("%d operation(s) in flight on %s: %s", len(ops), ns, strings.Join(ops, ", ")), len(ops)
+}
+
+// failoverWriteInterval is the pause between the background writer's inserts.
+const failoverWriteInterval = 200 * time.Millisecond
+
+// failoverWriter inserts one document at a time into coll until stopped,
+// tracking every attempt in a FailoverLog, so the timeline shows when
+// writes stalled, failed, and recovered around the election.
+type failoverWriter struct {
+	quit chan struct{}
+	done chan struct{}
+
+	acked   []string // _ids of acknowledged inserts
+	unacked []string // _ids of failed inserts, which may still have been applied
+}
+
+// startFailoverWriter starts inserting "during_NNNN" documents into coll
+// through mongos, one every failoverWriteInterval.
+func startFailoverWriter(ctx context.Context, adminClient *mongo.Client, coll *mongo.Collection, events *FailoverLog) *failoverWriter {
+	w := &failoverWriter{quit: make(chan struct{}), done: make(chan struct{})}
+	ns := coll.Database().Name() + "." + coll.Name()
+	go func() {
+		defer close(w.done)
+		for i := 0; ; i++ {
+			id := fmt.Sprintf("during_%04d", i)
+			err := events.trackWrite(ctx, adminClient, ns, "background insert "+id, 1, func() error {
+				_, err := coll.InsertOne(ctx, bson.M{"_id": id, "phase": "during_failover", "index": i})
+				return err
+			})
+			if err == nil {
+				w.acked = append(w.acked, id)
+			} else {
+				w.unacked = append(w.unacked, id)
+			}
+
+			select {
+			case <-w.quit:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(failoverWriteInterval):
+			}
+		}
+	}()
+	return w
+}
+
+// stop waits for the insert in flight, then ends the writer and returns
+// the _ids of its acknowledged and failed inserts.
+func (w *failoverWriter) stop() (acked, unacked []string) {
+	close(w.quit)
+	<-w.done
+	return w.acked, w.unacked
+}
@@ -1,21 +1,34 @@
 package ha
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/sharding"
 )
 
 const jumboCollection = "jumbo_analysis"
 const jumboDocCount = 30000
+const defaultJumboPadding = 100
 
 // RunJumboChunkAnalysis demonstrates how low-cardinality shard keys create
 // unmovable "jumbo" chunks and provides diagnostic analysis.
-func RunJumboChunkAnalysis(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+// padBytes sets the filler per document (<= 0 uses 100).
+func RunJumboChunkAnalysis(ctx context.Context, adminClient, appClient *mongo.Client, db string, padBytes int, scale sharding.Scale) error {
+	docCount := scale.Count(jumboDocCount)
+
+	if padBytes <= 0 {
+		padBytes = defaultJumboPadding
+	}
+
 	log.Println("=== Jumbo Chunk Analysis ===")
 	log.Println("Goal: Identify unmovable chunks caused by low-cardinality shard keys")
 	log.Println("")
@@ -41,30 +54,24 @@ func RunJumboChunkAnalysis(ctx context.Context, adminClient, appClient *mongo.Cl
 
 	// Insert data with only 3 status values
 	log.Println("")
-	log.Printf("Inserting %d documents with only 3 status values...", jumboDocCount)
+	log.Printf("Inserting %d documents with only 3 status values...", docCount)
 	statuses := []string{"active", "inactive", "pending"}
 	coll := appClient.Database(db).Collection(jumboCollection)
-	batchSize := 1000
-
-	for i := 0; i < jumboDocCount; i += batchSize {
-		end := i + batchSize
-		if end > jumboDocCount {
-			end = jumboDocCount
-		}
-		docs := make([]interface{}, 0, end-i)
-		for j := i; j < end; j++ {
-			docs = append(docs, bson.M{
-				"status":  statuses[j%3],
-				"user_id": fmt.Sprintf("user_%08d", j),
-				"email":   fmt.Sprintf("user%d@example.com", j),
-				"data":    fmt.Sprintf("payload-%d-%s", j, strings.Repeat("x", 100)),
-			})
-		}
-		if _, err := coll.InsertMany(ctx, docs); err != nil {
-			return fmt.Errorf("insert at %d: %w", i, err)
+	docs := make([]interface{}, docCount)
+	for j := range docs {
+		docs[j] = bson.M{
+			"status":  statuses[j%3],
+			"user_id": fmt.Sprintf("user_%08d", j),
+			"email":   fmt.Sprintf("user%d@example.com", j),
+			"data":    fmt.Sprintf("payload-%d-%s", j, sharding.Padding(padBytes)),
 		}
 	}
-	log.Printf("  [OK] %d documents inserted", jumboDocCount)
+	loadOpts := sharding.ParallelInsertOptions()
+	loadOpts.Progress = sharding.LogProgress
+	if err := sharding.BatchInsert(ctx, appClient, db, jumboCollection, docs, loadOpts); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	log.Printf("  [OK] %d documents inserted", docCount)
 
 	// Per-status distribution
 	log.Println("")
@@ -84,7 +91,7 @@ func RunJumboChunkAnalysis(ctx context.Context, adminClient, appClient *mongo.Cl
 		log.Printf("  Total chunks: %d", len(chunks))
 		for i, chunk := range chunks {
 			log.Printf("    Chunk %d: shard=%s min=%v max=%v",
-				i+1, chunk.Shard, formatBound(chunk.Min), formatBound(chunk.Max))
+				i+1, chunk.Shard, sharding.FormatKey(chunk.Min), sharding.FormatKey(chunk.Max))
 		}
 	}
 
@@ -115,14 +122,23 @@ func RunJumboChunkAnalysis(ctx context.Context, adminClient, appClient *mongo.Cl
 	log.Println("JUMBO CHUNK DIAGNOSTIC REPORT")
 	log.Println("")
 	log.Println("  Problem: Low-cardinality shard key { status: 1 }")
-	log.Printf("  Cardinality: %d unique values for %d documents", len(statuses), jumboDocCount)
-	log.Printf("  Ratio: %.0f docs per unique key value", float64(jumboDocCount)/float64(len(statuses)))
+	log.Printf("  Cardinality: %d unique values for %d documents", len(statuses), docCount)
+	log.Printf("  Ratio: %.0f docs per unique key value", float64(docCount)/float64(len(statuses)))
 	log.Println("")
 	log.Println("  Why this is bad:")
 	log.Println("    - MongoDB cannot split a chunk below the shard key granularity")
 	log.Println("    - With only 3 values, maximum 3 chunks can exist")
 	log.Println("    - Each chunk contains ~10,000 docs (far above normal)")
 	log.Println("    - These chunks become 'jumbo' and cannot be migrated")
+	log.Println("")
+	log.Println("  Remediation attempt:")
+	results, err := RemediateJumboChunk(ctx, adminClient, ns)
+	if err != nil {
+		log.Printf("    [WARN] remediation: %v", err)
+	} else {
+		PrintJumboRemediation(results)
+	}
+
 	log.Println("")
 	log.Println("  Recommendations:")
 	log.Println("    1. Use high-cardinality shard keys (e.g., user_id, _id)")
@@ -141,33 +157,15 @@ type chunkDoc struct {
 	Shard string
 	Min   bson.D
 	Max   bson.D
+	Jumbo bool
 }
 
 // getChunksForNamespace queries config.chunks for a namespace.
 func getChunksForNamespace(ctx context.Context, client *mongo.Client, ns string) ([]chunkDoc, error) {
-	// Try by namespace first
-	chunks, err := queryChunks(ctx, client, bson.M{"ns": ns})
-	if err == nil && len(chunks) > 0 {
-		return chunks, nil
-	}
-
-	// Fallback: lookup by UUID (MongoDB 7.0+)
-	var collDoc bson.M
-	err = client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": ns}).Decode(&collDoc)
+	filter, err := sharding.NamespaceChunksFilter(ctx, client, ns)
 	if err != nil {
-		return nil, fmt.Errorf("lookup collection: %w", err)
-	}
-
-	uuid, ok := collDoc["uuid"]
-	if !ok {
-		return nil, fmt.Errorf("no uuid for %s", ns)
+		return nil, err
 	}
-
-	return queryChunks(ctx, client, bson.M{"uuid": uuid})
-}
-
-// queryChunks runs a find on config.chunks with the given filter.
-func queryChunks(ctx context.Context, client *mongo.Client, filter bson.M) ([]chunkDoc, error) {
 	cursor, err := client.Database("config").Collection("chunks").Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -191,23 +189,14 @@ func queryChunks(ctx context.Context, client *mongo.Client, filter bson.M) ([]ch
 		if m, ok := doc["max"].(bson.D); ok {
 			chunk.Max = m
 		}
+		if j, ok := doc["jumbo"].(bool); ok {
+			chunk.Jumbo = j
+		}
 		chunks = append(chunks, chunk)
 	}
 	return chunks, nil
 }
 
-// formatBound formats a chunk boundary for display.
-func formatBound(bound bson.D) string {
-	if len(bound) == 0 {
-		return "{}"
-	}
-	parts := make([]string, 0, len(bound))
-	for _, elem := range bound {
-		parts = append(parts, fmt.Sprintf("%s: %v", elem.Key, elem.Value))
-	}
-	return "{ " + strings.Join(parts, ", ") + " }"
-}
-
 // findDifferentShard returns a shard name that differs from the given one.
 func findDifferentShard(ctx context.Context, client *mongo.Client, excludeShard string) string {
 	var result bson.M
@@ -243,3 +232,209 @@ func attemptMoveChunk(ctx context.Context, client *mongo.Client, ns string, min
 	}
 	return nil
 }
+
+// defaultChunkSizeMB is the balancer chunk size when config.settings has no override.
+const defaultChunkSizeMB = 128
+
+// JumboRemediation records what RemediateJumboChunk did for one chunk.
+type JumboRemediation struct {
+	Shard          string
+	Min            bson.D
+	Max            bson.D
+	WasJumbo       bool
+	SizeBytes      int64
+	Split          bool
+	FlagCleared    bool // clearJumboFlag removed the flag from a resulting chunk
+	StillOversized bool // Split but a resulting chunk exceeds the chunk size
+	NeedsReshard   bool // Every document in the range shares one shard key value
+	Err            error
+}
+
+// RemediateJumboChunk tries to fix jumbo chunks in ns. Candidates are chunks
+// flagged jumbo, or above the configured chunk size when none are flagged.
+// Each candidate is split at its median; on success the jumbo flag is cleared
+// from the resulting chunks (clearJumboFlag, MongoDB 4.4+) and their sizes are
+// re-checked. A chunk whose range holds a single shard key value cannot be
+// split, and resharding is the only fix.
+func RemediateJumboChunk(ctx context.Context, adminClient *mongo.Client, ns string) ([]JumboRemediation, error) {
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid namespace %q", ns)
+	}
+
+	var collMeta struct {
+		Key bson.D `bson:"key"`
+	}
+	if err := adminClient.Database("config").Collection("collections").
+		FindOne(ctx, bson.M{"_id": ns}).Decode(&collMeta); err != nil {
+		return nil, fmt.Errorf("lookup shard key for %s: %w", ns, err)
+	}
+
+	maxBytes := chunkSizeBytes(ctx, adminClient)
+
+	chunks, err := getChunksForNamespace(ctx, adminClient, ns)
+	if err != nil {
+		return nil, fmt.Errorf("chunks: %w", err)
+	}
+
+	var candidates []JumboRemediation
+	for _, c := range chunks {
+		if c.Jumbo {
+			candidates = append(candidates, JumboRemediation{Shard: c.Shard, Min: c.Min, Max: c.Max, WasJumbo: true})
+		}
+	}
+	if len(candidates) == 0 {
+		for _, c := range chunks {
+			size, err := chunkDataSize(ctx, adminClient, ns, collMeta.Key, c.Min, c.Max)
+			if err == nil && size > maxBytes {
+				candidates = append(candidates, JumboRemediation{Shard: c.Shard, Min: c.Min, Max: c.Max, SizeBytes: size})
+			}
+		}
+	}
+
+	for i := range candidates {
+		r := &candidates[i]
+		if r.SizeBytes == 0 {
+			r.SizeBytes, _ = chunkDataSize(ctx, adminClient, ns, collMeta.Key, r.Min, r.Max)
+		}
+
+		if err := splitChunkAtMedian(ctx, adminClient, ns, r.Min, r.Max); err != nil {
+			single, checkErr := singleKeyValue(ctx, adminClient.Database(db).Collection(coll), collMeta.Key, r.Min, r.Max)
+			if checkErr == nil && single {
+				r.NeedsReshard = true
+			} else {
+				r.Err = fmt.Errorf("split: %w", err)
+			}
+			continue
+		}
+		r.Split = true
+
+		// The split produced [min, mid) and [mid, max); inspect both halves
+		after, err := getChunksForNamespace(ctx, adminClient, ns)
+		if err != nil {
+			r.Err = fmt.Errorf("chunks after split: %w", err)
+			continue
+		}
+		for _, c := range after {
+			if !reflect.DeepEqual(c.Min, r.Min) && !reflect.DeepEqual(c.Max, r.Max) {
+				continue
+			}
+			if c.Jumbo {
+				if err := clearJumboFlag(ctx, adminClient, ns, c.Min, c.Max); err != nil {
+					r.Err = fmt.Errorf("clearJumboFlag: %w", err)
+				} else {
+					r.FlagCleared = true
+				}
+			}
+			if size, err := chunkDataSize(ctx, adminClient, ns, collMeta.Key, c.Min, c.Max); err == nil && size > maxBytes {
+				r.StillOversized = true
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// PrintJumboRemediation logs the outcome of a remediation attempt.
+func PrintJumboRemediation(results []JumboRemediation) {
+	if len(results) == 0 {
+		log.Println("    [OK] No jumbo or oversized chunks found")
+		return
+	}
+	for _, r := range results {
+		log.Printf("    Chunk shard=%s min=%s max=%s size=%.1fMB jumbo=%v",
+			r.Shard, sharding.FormatKey(r.Min), sharding.FormatKey(r.Max), float64(r.SizeBytes)/(1024*1024), r.WasJumbo)
+		switch {
+		case r.NeedsReshard:
+			log.Println("      [WARN] Range holds a single shard key value — cannot split")
+			log.Println("      Resharding to a higher-cardinality key is the only fix")
+		case r.Err != nil:
+			log.Printf("      [WARN] %v", r.Err)
+		case r.StillOversized:
+			log.Println("      [WARN] Split succeeded, but a half is still oversized")
+			log.Println("      The balancer will re-flag it; run remediation again or reshard")
+		case r.FlagCleared:
+			log.Println("      [OK] Split at median and jumbo flag cleared")
+		case r.Split:
+			log.Println("      [OK] Split at median")
+		}
+	}
+}
+
+// chunkSizeBytes reads the balancer chunk size from config.settings.
+func chunkSizeBytes(ctx context.Context, client *mongo.Client) int64 {
+	var doc bson.M
+	err := client.Database("config").Collection("settings").FindOne(ctx, bson.M{"_id": "chunksize"}).Decode(&doc)
+	if mb := sharding.ToInt64(doc["value"]); err == nil && mb > 0 {
+		return mb * 1024 * 1024
+	}
+	return defaultChunkSizeMB * 1024 * 1024
+}
+
+// chunkDataSize estimates the bytes stored in a chunk range via dataSize.
+func chunkDataSize(ctx context.Context, client *mongo.Client, ns string, keyPattern, min, max bson.D) (int64, error) {
+	db, _, _ := strings.Cut(ns, ".")
+	var result bson.M
+	err := client.Database(db).RunCommand(ctx, bson.D{
+		{Key: "dataSize", Value: ns},
+		{Key: "keyPattern", Value: keyPattern},
+		{Key: "min", Value: min},
+		{Key: "max", Value: max},
+		{Key: "estimate", Value: true},
+	}).Decode(&result)
+	if err != nil {
+		return 0, err
+	}
+	return sharding.ToInt64(result["size"]), nil
+}
+
+// splitChunkAtMedian asks the server to split the chunk with the given bounds.
+func splitChunkAtMedian(ctx context.Context, client *mongo.Client, ns string, min, max bson.D) error {
+	var result bson.M
+	return client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "split", Value: ns},
+		{Key: "bounds", Value: bson.A{min, max}},
+	}).Decode(&result)
+}
+
+// clearJumboFlag removes the jumbo flag from the chunk with the given bounds.
+func clearJumboFlag(ctx context.Context, client *mongo.Client, ns string, min, max bson.D) error {
+	var result bson.M
+	return client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "clearJumboFlag", Value: ns},
+		{Key: "bounds", Value: bson.A{min, max}},
+	}).Decode(&result)
+}
+
+// singleKeyValue reports whether every document in [min, max) has the same
+// shard key value, by comparing the first and last keys in index order.
+func singleKeyValue(ctx context.Context, coll *mongo.Collection, keyPattern, min, max bson.D) (bool, error) {
+	projection := bson.D{{Key: "_id", Value: 0}}
+	for _, k := range keyPattern {
+		projection = append(projection, bson.E{Key: k.Key, Value: 1})
+	}
+
+	edge := func(direction int) (bson.Raw, error) {
+		sort := bson.D{}
+		for _, k := range keyPattern {
+			sort = append(sort, bson.E{Key: k.Key, Value: direction})
+		}
+		opts := options.FindOne().
+			SetHint(keyPattern).
+			SetMin(min).
+			SetMax(max).
+			SetSort(sort).
+			SetProjection(projection)
+		return coll.FindOne(ctx, bson.D{}, opts).Raw()
+	}
+
+	first, err := edge(1)
+	if err != nil {
+		return false, err
+	}
+	last, err := edge(-1)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(first, last), nil
+}
@@ -0,0 +1,92 @@
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+// podReadyTimeout bounds how long Start waits for a replacement pod.
+const podReadyTimeout = 3 * time.Minute
+
+// KubernetesController stops nodes by deleting their pods with kubectl.
+// The owning StatefulSet recreates a deleted pod straight away, so a
+// "stopped" node is down only until its replacement starts: long enough
+// to force an election, but outages held across several seconds (as in
+// the config server lab) may end before the lab's checks run. Start waits
+// for the replacement pod to become Ready.
+type KubernetesController struct {
+	Namespace string
+	Prefix    string
+}
+
+// PodName maps a cluster hostname (e.g. "shard1-1") to its pod.
+func (k *KubernetesController) PodName(node string) string {
+	return k.Prefix + node
+}
+
+// Check verifies that kubectl is on PATH and every node's pod is Running.
+func (k *KubernetesController) Check(nodes ...string) error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return fmt.Errorf("kubectl not found on PATH (HA labs delete and await pods): %w", err)
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		pod := k.PodName(node)
+		phase, err := k.kubectl(context.Background(), "get", "pod", pod, "-o", "jsonpath={.status.phase}")
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("pod %s not found in namespace %s: %w", pod, k.Namespace, err))
+		case phase != "Running":
+			errs = append(errs, fmt.Errorf("pod %s is %s, not Running", pod, phase))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stop deletes the node's pod and waits for it to terminate.
+func (k *KubernetesController) Stop(ctx context.Context, node string) error {
+	_, err := k.kubectl(ctx, "delete", "pod", k.PodName(node), "--wait=true")
+	return err
+}
+
+// Start waits for the StatefulSet's replacement pod to become Ready. The
+// pod may not exist yet right after Stop, so lookups are retried until the
+// pod is Ready, podReadyTimeout passes, or ctx is done.
+func (k *KubernetesController) Start(ctx context.Context, node string) error {
+	pod := k.PodName(node)
+	deadline := time.Now().Add(podReadyTimeout)
+	var lastErr error
+	for {
+		remaining := time.Until(deadline).Round(time.Second)
+		if remaining <= 0 {
+			return fmt.Errorf("pod %s not Ready within %v: %w", pod, podReadyTimeout, lastErr)
+		}
+		if _, lastErr = k.kubectl(ctx, "wait", "--for=condition=Ready", "pod/"+pod, "--timeout="+remaining.String()); lastErr == nil {
+			return nil
+		}
+		if !timeutil.Sleep(ctx, 2*time.Second) {
+			return fmt.Errorf("waiting for pod %s: %w", pod, ctx.Err())
+		}
+	}
+}
+
+// kubectl runs a kubectl command in the controller's namespace, killing it
+// if ctx ends, and returns its trimmed output.
+func (k *KubernetesController) kubectl(ctx context.Context, args ...string) (string, error) {
+	if k.Namespace != "" {
+		args = append([]string{"--namespace", k.Namespace}, args...)
+	}
+	output, err := exec.CommandContext(ctx, "kubectl", args...).CombinedOutput()
+	out := strings.TrimSpace(string(output))
+	if err != nil {
+		return out, fmt.Errorf("kubectl %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package ha
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// NodeController stops and starts cluster nodes for the HA labs. Nodes are
+// named by cluster hostname (e.g. "shard1-1"); each backend maps that to
+// its own container or pod name.
+type NodeController interface {
+	// Check verifies the backend is usable and every node is running. Labs
+	// call it before stopping anything.
+	Check(nodes ...string) error
+	Stop(ctx context.Context, node string) error
+	Start(ctx context.Context, node string) error
+}
+
+// Node controller backends accepted by NewNodeController.
+const (
+	BackendDocker     = "docker"
+	BackendKubernetes = "kubernetes"
+)
+
+// NewNodeController returns the controller for backend. prefix is
+// prepended to hostnames to form container or pod names; namespace is
+// only used by the Kubernetes backend.
+func NewNodeController(backend, prefix, namespace string) (NodeController, error) {
+	switch backend {
+	case BackendDocker, "":
+		return &DockerController{Prefix: prefix}, nil
+	case BackendKubernetes, "k8s":
+		return &KubernetesController{Namespace: namespace, Prefix: prefix}, nil
+	default:
+		return nil, fmt.Errorf("unknown HA backend %q (want %s or %s)", backend, BackendDocker, BackendKubernetes)
+	}
+}
+
+// nodeRestorer tracks the nodes a lab has stopped so a deferred restore
+// brings them all back, even when the lab returns early, its context is
+// cancelled, or it panics. restore does not use the lab's context, so
+// restoring still works after cancellation.
+type nodeRestorer struct {
+	nodes   NodeController
+	stopped []string
+}
+
+// stop stops node and remembers it for restore.
+func (r *nodeRestorer) stop(ctx context.Context, node string) error {
+	if err := r.nodes.Stop(ctx, node); err != nil {
+		return err
+	}
+	r.stopped = append(r.stopped, node)
+	return nil
+}
+
+// start starts node and forgets it; on failure it stays on the restore list.
+func (r *nodeRestorer) start(ctx context.Context, node string) error {
+	if err := r.nodes.Start(ctx, node); err != nil {
+		return err
+	}
+	for i, n := range r.stopped {
+		if n == node {
+			r.stopped = append(r.stopped[:i], r.stopped[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// restore starts every node still stopped. Meant to be deferred.
+func (r *nodeRestorer) restore() {
+	for len(r.stopped) > 0 {
+		node := r.stopped[0]
+		log.Printf("  [RESTORE] Starting %s left stopped by an early exit...", node)
+		if err := r.start(context.Background(), node); err != nil {
+			log.Printf("  [WARN] restore %s: %v (start it manually)", node, err)
+			r.stopped = r.stopped[1:]
+		}
+	}
+}
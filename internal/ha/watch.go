@@ -0,0 +1,121 @@
+package ha
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+// failoverWatcher follows insert events on a collection through mongos and
+// reopens the change stream from its resume token whenever an election
+// breaks it, counting what it saw per "phase" field.
+type failoverWatcher struct {
+	mu      sync.Mutex
+	phases  map[string]int
+	resumes int
+	lastErr error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startFailoverWatcher opens the change stream and returns once it is
+// established, so no insert made after it returns can be missed.
+func startFailoverWatcher(ctx context.Context, coll *mongo.Collection) (*failoverWatcher, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}}
+	cs, err := coll.Watch(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &failoverWatcher{phases: make(map[string]int), cancel: cancel, done: make(chan struct{})}
+	go w.run(ctx, coll, pipeline, cs)
+	return w, nil
+}
+
+func (w *failoverWatcher) run(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline, cs *mongo.ChangeStream) {
+	defer close(w.done)
+	for {
+		for cs.Next(ctx) {
+			var event struct {
+				FullDocument struct {
+					Phase string `bson:"phase"`
+				} `bson:"fullDocument"`
+			}
+			if err := cs.Decode(&event); err != nil {
+				continue
+			}
+			w.mu.Lock()
+			w.phases[event.FullDocument.Phase]++
+			w.mu.Unlock()
+		}
+		err := cs.Err()
+		token := cs.ResumeToken()
+		cs.Close(context.Background())
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Reopen from the last token until the new primary is reachable
+		for {
+			if !sharding.IsResumableChangeStreamError(err) {
+				w.mu.Lock()
+				w.lastErr = err
+				w.mu.Unlock()
+				return
+			}
+			w.mu.Lock()
+			w.resumes++
+			w.mu.Unlock()
+			log.Printf("  [INFO] Change stream interrupted (%v); resuming from last token", err)
+
+			if !timeutil.Sleep(ctx, time.Second) {
+				return
+			}
+
+			opts := options.ChangeStream()
+			if token != nil {
+				opts.SetResumeAfter(token)
+			}
+			if cs, err = coll.Watch(ctx, pipeline, opts); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// waitFor blocks until n events with the given phase were seen, timeout
+// passes, or ctx is done.
+func (w *failoverWatcher) waitFor(ctx context.Context, phase string, n int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if w.count(phase) >= n || !timeutil.Sleep(ctx, 200*time.Millisecond) {
+			return
+		}
+	}
+}
+
+func (w *failoverWatcher) count(phase string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.phases[phase]
+}
+
+// stop closes the change stream and returns how often it was reopened and
+// the error that ended it early, if any.
+func (w *failoverWatcher) stop() (resumes int, err error) {
+	w.cancel()
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.resumes, w.lastErr
+}
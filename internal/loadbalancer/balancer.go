@@ -1,6 +1,7 @@
 package loadbalancer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -17,8 +18,9 @@ import (
 // How it works:
 //   - loadBalancingConfig: tells the gRPC client to distribute RPCs across
 //     all resolved endpoints using round-robin (not pin to one connection)
-//   - healthCheckingConfig: the client probes each endpoint via the standard
-//     grpc.health.v1.Health service and stops routing RPCs to unhealthy ones
+//   - healthCheckingConfig: the client opens a grpc.health.v1.Health/Watch
+//     stream to each endpoint and stops routing RPCs to it as soon as the
+//     server pushes NOT_SERVING (see Drain) — there is no polling interval
 func DefaultServiceConfig(serviceName string) string {
 	config := map[string]interface{}{
 		"loadBalancingConfig": []map[string]interface{}{
@@ -37,6 +39,11 @@ func DefaultServiceConfig(serviceName string) string {
 	return string(raw)
 }
 
+// MaxMessageSize is the largest gRPC message clients and the server
+// exchange (16MB, for bulk payloads). The server must set the same
+// receive limit, since grpc-go otherwise accepts only 4MB.
+const MaxMessageSize = 16 * 1024 * 1024
+
 // DialOptions returns gRPC dial options configured for client-side load balancing.
 // These should be used instead of manual connection pools.
 func DialOptions(serviceName string) []grpc.DialOption {
@@ -46,10 +53,10 @@ func DialOptions(serviceName string) []grpc.DialOption {
 		// Service config: round-robin LB + health checking
 		grpc.WithDefaultServiceConfig(DefaultServiceConfig(serviceName)),
 
-		// Message size limits (16MB for bulk payloads)
+		// Message size limits, matching the server's
 		grpc.WithDefaultCallOptions(
-			grpc.MaxCallRecvMsgSize(16*1024*1024),
-			grpc.MaxCallSendMsgSize(16*1024*1024),
+			grpc.MaxCallRecvMsgSize(MaxMessageSize),
+			grpc.MaxCallSendMsgSize(MaxMessageSize),
 		),
 
 		// Keepalive: detect dead connections early
@@ -61,6 +68,56 @@ func DialOptions(serviceName string) []grpc.DialOption {
 	}
 }
 
+// ClientOption customizes the connection built by NewClientConn.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	callTimeout   time.Duration
+	subsetSize    int
+	noHealthWatch bool
+}
+
+// WithDefaultCallTimeout bounds every unary RPC on the connection to d,
+// so a hung backend cannot hold a call open until the caller's own context
+// expires. A shorter deadline already on the caller's context still wins.
+// Streaming RPCs are long-lived by design and are not affected.
+func WithDefaultCallTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.callTimeout = d
+	}
+}
+
+// WithHealthWatch toggles health-check-driven eviction (enabled by default).
+// When enabled, each subchannel keeps a Health/Watch stream open and is
+// removed from the round-robin set the moment its status leaves SERVING.
+// Disabling it falls back to connection state only, so a draining pod keeps
+// receiving RPCs until its connection closes.
+func WithHealthWatch(enabled bool) ClientOption {
+	return func(o *clientOptions) {
+		o.noHealthWatch = !enabled
+	}
+}
+
+// WithSubsetSize limits the connection to a random subset of k resolved
+// endpoints, re-chosen on each re-resolution, to bound connection count in
+// large fleets. See subsetResolverBuilder for the health-check tradeoff.
+// k <= 0 connects to every endpoint.
+func WithSubsetSize(k int) ClientOption {
+	return func(o *clientOptions) {
+		o.subsetSize = k
+	}
+}
+
+// timeoutInterceptor applies d to unary calls via context.WithTimeout.
+func timeoutInterceptor(d time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
 // NewClientConn creates a gRPC client connection with client-side load balancing.
 //
 // Target formats:
@@ -69,15 +126,35 @@ func DialOptions(serviceName string) []grpc.DialOption {
 //
 // The connection uses round-robin to distribute RPCs across all resolved endpoints.
 // Combined with gRPC health checking, unhealthy endpoints are automatically excluded.
-func NewClientConn(target string) (*grpc.ClientConn, error) {
+func NewClientConn(target string, options ...ClientOption) (*grpc.ClientConn, error) {
 	RegisterResolvers()
 
-	opts := DialOptions("sharding.v1.ShardingService")
+	var co clientOptions
+	for _, o := range options {
+		o(&co)
+	}
+
+	opts := DialOptions(ShardingServiceName)
+	if co.noHealthWatch {
+		opts = append(opts, grpc.WithDisableHealthCheck())
+	}
+	if co.callTimeout > 0 {
+		opts = append(opts, grpc.WithUnaryInterceptor(timeoutInterceptor(co.callTimeout)))
+	}
+	if co.subsetSize > 0 {
+		builder, err := subsetResolver(target, co.subsetSize)
+		if err != nil {
+			return nil, fmt.Errorf("subset resolver: %v", err)
+		}
+		opts = append(opts, grpc.WithResolvers(builder))
+	}
+
 	conn, err := grpc.NewClient(target, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("grpc dial %s: %v", target, err)
 	}
 
-	log.Printf("[loadbalancer] connected: target=%s policy=round_robin health=enabled", target)
+	log.Printf("[loadbalancer] connected: target=%s policy=round_robin health_watch=%v call_timeout=%v subset=%d",
+		target, !co.noHealthWatch, co.callTimeout, co.subsetSize)
 	return conn, nil
 }
@@ -2,12 +2,17 @@ package loadbalancer
 
 import (
 	"log"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// ShardingServiceName is the health-checked service name; it matches the
+// serviceName in the client's healthCheckConfig.
+const ShardingServiceName = "sharding.v1.ShardingService"
+
 // RegisterHealthServer registers the gRPC health checking service on the server.
 // This enables clients using health-check-aware LB policies to detect and
 // route around unhealthy pods automatically.
@@ -16,18 +21,32 @@ import (
 // and the specific ShardingService — clients can probe either.
 func RegisterHealthServer(server *grpc.Server) *health.Server {
 	healthServer := health.NewServer()
-
-	// Overall server health
-	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
-
-	// Per-service health — matches the serviceName in client's healthCheckConfig
-	healthServer.SetServingStatus(
-		"sharding.v1.ShardingService",
-		healthpb.HealthCheckResponse_SERVING,
-	)
+	SetServing(healthServer, true)
 
 	healthpb.RegisterHealthServer(server, healthServer)
 
 	log.Println("[health] gRPC health service registered (status=SERVING)")
 	return healthServer
 }
+
+// SetServing flips both the overall and ShardingService status.
+// SetServingStatus pushes the change to every open Health/Watch stream, so
+// clients evict (or re-admit) this pod immediately rather than on a poll.
+func SetServing(healthServer *health.Server, serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	healthServer.SetServingStatus("", status)
+	healthServer.SetServingStatus(ShardingServiceName, status)
+	log.Printf("[health] status=%s", status)
+}
+
+// Drain marks the server NOT_SERVING and waits grace so watching clients
+// stop picking this pod before GracefulStop closes its connections.
+// Without this, RPCs keep arriving until the transport is torn down.
+func Drain(healthServer *health.Server, grace time.Duration) {
+	SetServing(healthServer, false)
+	log.Printf("[health] draining for %v", grace)
+	time.Sleep(grace)
+}
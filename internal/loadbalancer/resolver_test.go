@@ -0,0 +1,126 @@
+package loadbalancer
+
+import (
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeClientConn captures the state pushed by a resolver.
+type fakeClientConn struct {
+	resolver.ClientConn
+	states []resolver.State
+}
+
+func (f *fakeClientConn) UpdateState(s resolver.State) error {
+	f.states = append(f.states, s)
+	return nil
+}
+
+// fakeResolver counts calls from PeriodicDNSResolver to its inner resolver.
+type fakeResolver struct {
+	resolveNow atomic.Int32
+	closed     atomic.Int32
+}
+
+func (f *fakeResolver) ResolveNow(resolver.ResolveNowOptions) { f.resolveNow.Add(1) }
+func (f *fakeResolver) Close()                                { f.closed.Add(1) }
+
+func staticTarget(t *testing.T, raw string) resolver.Target {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	return resolver.Target{URL: *u}
+}
+
+func TestStaticResolverBuild(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"single address", "static:///localhost:50051", []string{"localhost:50051"}},
+		{"comma separated", "static:///a:50051,b:50052,c:50053", []string{"a:50051", "b:50052", "c:50053"}},
+		{"whitespace trimmed", "static:///a:50051,%20b:50052%20", []string{"a:50051", "b:50052"}},
+		{"empty entries skipped", "static:///a:50051,,b:50052,", []string{"a:50051", "b:50052"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := &fakeClientConn{}
+			r, err := (&staticResolverBuilder{}).Build(staticTarget(t, tt.target), cc, resolver.BuildOptions{})
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			defer r.Close()
+
+			if len(cc.states) != 1 {
+				t.Fatalf("UpdateState called %d times, want 1", len(cc.states))
+			}
+			var got []string
+			for _, a := range cc.states[0].Addresses {
+				got = append(got, a.Addr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addresses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticResolverBuildEmpty(t *testing.T) {
+	for _, target := range []string{"static:///", "static:///,,%20,"} {
+		cc := &fakeClientConn{}
+		if _, err := (&staticResolverBuilder{}).Build(staticTarget(t, target), cc, resolver.BuildOptions{}); err == nil {
+			t.Errorf("Build(%q): expected error", target)
+		}
+		if len(cc.states) != 0 {
+			t.Errorf("Build(%q): UpdateState called %d times, want 0", target, len(cc.states))
+		}
+	}
+}
+
+func TestPeriodicDNSResolverCloseIdempotent(t *testing.T) {
+	inner := &fakeResolver{}
+	r := &PeriodicDNSResolver{inner: inner, interval: time.Hour, done: make(chan struct{})}
+
+	r.Close()
+	r.Close()
+
+	if n := inner.closed.Load(); n != 1 {
+		t.Errorf("inner Close called %d times, want 1", n)
+	}
+}
+
+func TestPeriodicDNSResolverRefreshLoopStops(t *testing.T) {
+	inner := &fakeResolver{}
+	r := &PeriodicDNSResolver{inner: inner, interval: 5 * time.Millisecond, done: make(chan struct{})}
+
+	stopped := make(chan struct{})
+	go func() {
+		r.refreshLoop()
+		close(stopped)
+	}()
+
+	deadline := time.After(time.Second)
+	for inner.resolveNow.Load() < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("refreshLoop did not re-resolve")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	r.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("refreshLoop still running after Close")
+	}
+}
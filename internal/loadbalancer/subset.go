@@ -0,0 +1,81 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// subsetResolverBuilder wraps another resolver and limits each client to a
+// random subset of the resolved endpoints.
+//
+// With N backend pods and plain round-robin, every client holds N HTTP/2
+// connections and N health-check streams. Subsetting caps that at K per
+// client; across many clients the random choice still spreads load over the
+// whole fleet. A fresh subset is drawn on every re-resolution (every 30s for
+// DNS), so pods added by a scale-up are eventually picked up.
+//
+// Tradeoff: health checking only covers the endpoints in the subset. If all
+// K chosen pods become unhealthy, RPCs fail even though healthy pods exist
+// outside the subset, until the next re-resolution draws a new one. Choose K
+// large enough that losing all of them at once is unlikely (e.g. >= 3), and
+// keep in mind that with few clients the load per pod is less even.
+type subsetResolverBuilder struct {
+	inner resolver.Builder
+	size  int
+}
+
+func (b *subsetResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	return b.inner.Build(target, &subsetClientConn{ClientConn: cc, size: b.size}, opts)
+}
+
+func (b *subsetResolverBuilder) Scheme() string { return b.inner.Scheme() }
+
+// subsetClientConn intercepts resolver updates and forwards a random subset.
+type subsetClientConn struct {
+	resolver.ClientConn
+	size int
+}
+
+func (c *subsetClientConn) UpdateState(state resolver.State) error {
+	if len(state.Endpoints) > c.size {
+		total := len(state.Endpoints)
+		state.Endpoints = pickSubset(state.Endpoints, c.size)
+		state.Addresses = nil
+		for _, ep := range state.Endpoints {
+			state.Addresses = append(state.Addresses, ep.Addresses...)
+		}
+		log.Printf("[loadbalancer] subset: using %d of %d endpoints", len(state.Endpoints), total)
+	} else if len(state.Endpoints) == 0 && len(state.Addresses) > c.size {
+		total := len(state.Addresses)
+		state.Addresses = pickSubset(state.Addresses, c.size)
+		log.Printf("[loadbalancer] subset: using %d of %d endpoints", len(state.Addresses), total)
+	}
+	return c.ClientConn.UpdateState(state)
+}
+
+// pickSubset returns k randomly chosen elements of items without modifying it.
+func pickSubset[T any](items []T, k int) []T {
+	out := make([]T, 0, k)
+	for _, i := range rand.Perm(len(items))[:k] {
+		out = append(out, items[i])
+	}
+	return out
+}
+
+// subsetResolver wraps the resolver registered for target's scheme
+// (dns when no scheme is given, matching grpc.NewClient).
+func subsetResolver(target string, size int) (resolver.Builder, error) {
+	scheme := "dns"
+	if u, err := url.Parse(target); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	inner := resolver.Get(scheme)
+	if inner == nil {
+		return nil, fmt.Errorf("no resolver registered for scheme %q", scheme)
+	}
+	return &subsetResolverBuilder{inner: inner, size: size}, nil
+}
@@ -0,0 +1,81 @@
+package operations
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ChunkOperation is one audited step of a lab and its effect on chunks.
+type ChunkOperation struct {
+	Time         time.Time
+	Duration     time.Duration
+	Kind         string // "insert", "split", "move", ...
+	Namespace    string
+	Detail       string
+	ChunksBefore int64
+	ChunksAfter  int64
+	Err          error
+}
+
+// OperationsLog accumulates chunk operations as a timeline. It is safe for
+// concurrent use; the zero value is ready to use.
+type OperationsLog struct {
+	mu      sync.Mutex
+	entries []ChunkOperation
+}
+
+// Record runs fn, capturing the chunk count of ns before and after, and
+// appends the result to the log. fn's error is returned unchanged.
+func (l *OperationsLog) Record(ctx context.Context, client *mongo.Client, kind, ns, detail string, fn func() error) error {
+	op := ChunkOperation{
+		Time:         time.Now(),
+		Kind:         kind,
+		Namespace:    ns,
+		Detail:       detail,
+		ChunksBefore: chunkCount(ctx, client, ns),
+	}
+	op.Err = fn()
+	op.Duration = time.Since(op.Time)
+	op.ChunksAfter = chunkCount(ctx, client, ns)
+
+	l.mu.Lock()
+	l.entries = append(l.entries, op)
+	l.mu.Unlock()
+	return op.Err
+}
+
+// Entries returns a copy of the recorded operations in order.
+func (l *OperationsLog) Entries() []ChunkOperation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]ChunkOperation(nil), l.entries...)
+}
+
+// PrintTimeline logs every recorded operation with its chunk-count delta.
+func (l *OperationsLog) PrintTimeline() {
+	entries := l.Entries()
+	log.Printf("  Operations timeline (%d entries):", len(entries))
+	for _, op := range entries {
+		result := "ok"
+		if op.Err != nil {
+			result = "error: " + op.Err.Error()
+		}
+		log.Printf("    %s %-6s %-28s chunks %d -> %d (%+d) in %v  %s [%s]",
+			op.Time.Format("15:04:05.000"), op.Kind, op.Namespace,
+			op.ChunksBefore, op.ChunksAfter, op.ChunksAfter-op.ChunksBefore,
+			op.Duration.Round(time.Millisecond), op.Detail, result)
+	}
+}
+
+// chunkCount returns the total chunks for ns, or -1 if unavailable.
+func chunkCount(ctx context.Context, client *mongo.Client, ns string) int64 {
+	info, err := GetChunkInfo(ctx, client, ns)
+	if err != nil {
+		return -1
+	}
+	return info.TotalCount
+}
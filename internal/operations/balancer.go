@@ -2,12 +2,16 @@ package operations
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/sharding"
 )
 
 // BalancerState holds the current balancer status.
@@ -16,8 +20,10 @@ type BalancerState struct {
 	InProgress bool
 }
 
-// RunBalancerLab demonstrates manual balancer control and maintenance windows.
-func RunBalancerLab(ctx context.Context, client *mongo.Client) error {
+// RunBalancerLab demonstrates manual balancer control and maintenance
+// windows, then measures how fast the balancer spreads an imbalanced
+// collection in db once it is running again.
+func RunBalancerLab(ctx context.Context, client *mongo.Client, db string, scale sharding.Scale) error {
 	log.Println("=== Balancer Lab ===")
 	log.Println("Goal: Manual balancer control and maintenance windows")
 	log.Println("")
@@ -42,21 +48,41 @@ func RunBalancerLab(ctx context.Context, client *mongo.Client) error {
 	}
 	log.Printf("  After stop: mode=%s", state.Mode)
 
+	// Load a collection while nothing can migrate, so all of it starts on one shard
+	log.Println("")
+	log.Printf("Loading %d docs into %s while the balancer is stopped...", scale.Count(migrationDocCount), migrationCollection)
+	ns, err := setupImbalancedCollection(ctx, client, db, scale)
+	defer client.Database(db).Collection(migrationCollection).Drop(ctx)
+	if err != nil {
+		log.Printf("  [WARN] imbalanced collection: %v (skipping migration rate)", err)
+		ns = ""
+	} else if info, err := GetChunkInfo(ctx, client, ns); err == nil {
+		PrintChunkReport(info)
+	}
+
 	// Set maintenance window (2:00 AM - 5:00 AM)
 	log.Println("")
-	log.Println("Configuring balancer window: 02:00 - 05:00 UTC...")
+	log.Println("Configuring balancer window: 02:00 - 05:00 (server-local time)...")
 	if err := SetBalancerWindow(ctx, client, 2, 0, 5, 0); err != nil {
 		return fmt.Errorf("set window: %w", err)
 	}
-	log.Println("  Window set: migrations only allowed between 02:00-05:00 UTC")
+	log.Println("  Window set: migrations only allowed between 02:00-05:00")
 	log.Println("  This prevents performance degradation during peak hours")
 
-	// Verify the window was set
-	window, err := GetBalancerWindow(ctx, client)
+	// Invalid windows are rejected before they reach config.settings
+	if err := SetBalancerWindow(ctx, client, 3, 0, 3, 0); err != nil {
+		log.Printf("  [OK] Rejected empty window: %v", err)
+	}
+
+	// Verify the window was set and show what the times mean
+	window, err := GetBalancerWindowWithTZ(ctx, client)
 	if err != nil {
 		log.Printf("  [WARN] Could not read window: %v", err)
 	} else {
 		log.Printf("  Active window: start=%s, stop=%s", window.Start, window.Stop)
+		log.Printf("  Server %s clock: %s (UTC offset %s)",
+			window.ServerHost, window.ServerTime.Format("15:04"), window.UTCOffset)
+		log.Println("  Window times are evaluated in the config server primary's local time")
 	}
 
 	// Start the balancer back
@@ -80,8 +106,18 @@ func RunBalancerLab(ctx context.Context, client *mongo.Client) error {
 	}
 	log.Println("  Balancer restored to full-time operation")
 
+	if ns != "" {
+		log.Println("")
+		log.Printf("Measuring chunk migration rate on %s (up to %v)...", ns, migrationTimeout)
+		report, err := MeasureMigrationRate(ctx, client, ns, 5*time.Second, migrationTimeout)
+		if err != nil {
+			log.Printf("  [WARN] migration rate: %v", err)
+		}
+		PrintMigrationReport(report)
+	}
+
 	log.Println("")
-	log.Println("Result: Balancer manually controlled with maintenance window")
+	log.Println("Result: Balancer manually controlled with maintenance window, migration rate measured")
 	log.Println("")
 	return nil
 }
@@ -132,13 +168,31 @@ func StopBalancer(ctx context.Context, client *mongo.Client) error {
 }
 
 // BalancerWindow represents the active balancer time window.
+// Start and Stop are "HH:MM" in the config server primary's local time.
+// The server fields are only filled by GetBalancerWindowWithTZ.
 type BalancerWindow struct {
 	Start string
 	Stop  string
+
+	ServerHost string
+	ServerTime time.Time
+	UTCOffset  string // e.g. "+00:00"; empty when the server log is unavailable
 }
 
-// SetBalancerWindow restricts the balancer to run only during the specified UTC window.
+// SetBalancerWindow restricts the balancer to run only during the given window.
+// MongoDB evaluates activeWindow in the config server primary's local time,
+// not UTC. Windows may wrap midnight (e.g. 23:00-02:00) but must not be empty.
 func SetBalancerWindow(ctx context.Context, client *mongo.Client, startHour, startMin, stopHour, stopMin int) error {
+	if err := validateWindowTime(startHour, startMin); err != nil {
+		return fmt.Errorf("window start: %w", err)
+	}
+	if err := validateWindowTime(stopHour, stopMin); err != nil {
+		return fmt.Errorf("window stop: %w", err)
+	}
+	if startHour == stopHour && startMin == stopMin {
+		return fmt.Errorf("window start and stop are both %02d:%02d; migrations would never run", startHour, startMin)
+	}
+
 	settings := client.Database("config").Collection("settings")
 
 	filter := bson.M{"_id": "balancer"}
@@ -178,6 +232,77 @@ func GetBalancerWindow(ctx context.Context, client *mongo.Client) (*BalancerWind
 	return window, nil
 }
 
+// GetBalancerWindowWithTZ reads the balancer window along with the clock and
+// UTC offset of the server the client is connected to, so operators can
+// tell what "02:00" means. hostInfo provides the host and current time;
+// the offset comes from the timestamp of the latest global log line, which
+// mongod writes in local time. Through mongos this reports the router's
+// zone — connect to the config server primary for the authoritative one.
+func GetBalancerWindowWithTZ(ctx context.Context, client *mongo.Client) (*BalancerWindow, error) {
+	window, err := GetBalancerWindow(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		System struct {
+			Hostname    string    `bson:"hostname"`
+			CurrentTime time.Time `bson:"currentTime"`
+		} `bson:"system"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "hostInfo", Value: 1},
+	}).Decode(&info); err != nil {
+		return nil, fmt.Errorf("hostInfo: %w", err)
+	}
+	window.ServerHost = info.System.Hostname
+	window.ServerTime = info.System.CurrentTime
+
+	var logs struct {
+		Log []string `bson:"log"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "getLog", Value: "global"},
+	}).Decode(&logs); err == nil && len(logs.Log) > 0 {
+		if offset, ok := logLineUTCOffset(logs.Log[len(logs.Log)-1]); ok {
+			window.UTCOffset = offset
+			if t, err := time.Parse("-07:00", offset); err == nil {
+				window.ServerTime = window.ServerTime.In(t.Location())
+			}
+		}
+	}
+	return window, nil
+}
+
+// validateWindowTime checks an HH:MM pair is a valid time of day.
+func validateWindowTime(hour, min int) error {
+	if hour < 0 || hour > 23 {
+		return fmt.Errorf("hour %d out of range 0-23", hour)
+	}
+	if min < 0 || min > 59 {
+		return fmt.Errorf("minute %d out of range 0-59", min)
+	}
+	return nil
+}
+
+// logLineUTCOffset extracts the UTC offset from a structured log line's
+// {"t":{"$date":"2024-01-02T03:04:05.678+00:00"}} timestamp.
+func logLineUTCOffset(line string) (string, bool) {
+	var entry struct {
+		T struct {
+			Date string `json:"$date"`
+		} `json:"t"`
+	}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return "", false
+	}
+	t, err := time.Parse("2006-01-02T15:04:05.000-07:00", entry.T.Date)
+	if err != nil {
+		return "", false
+	}
+	return t.Format("-07:00"), true
+}
+
 // ClearBalancerWindow removes the active window restriction.
 func ClearBalancerWindow(ctx context.Context, client *mongo.Client) error {
 	settings := client.Database("config").Collection("settings")
@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ChunkCountAlert is raised when a namespace's chunk count goes above the
+// threshold given to MonitorChunkCount.
+type ChunkCountAlert struct {
+	Namespace string
+	Chunks    int64
+	Threshold int
+	PerShard  map[string]int64
+	At        time.Time
+}
+
+// DefaultChunkMonitorInterval is how often MonitorChunkCount checks when
+// given no interval.
+const DefaultChunkMonitorInterval = 10 * time.Second
+
+// MonitorChunkCount checks the chunk count of ns every interval
+// (DefaultChunkMonitorInterval when zero or negative) in the background
+// until ctx is cancelled. Each time the count rises above
+// threshold it logs a warning and sends an alert on the returned channel;
+// it logs again once the count falls back (after merges or
+// defragmentation), so a collection that stays bloated alerts once, not on
+// every check. Thousands of chunks per collection mean excessive splitting
+// and make every mongos and shard routing-table refresh slower.
+//
+// The channel is buffered and closed when monitoring stops. Alerts are
+// dropped rather than blocking the monitor if the caller does not read
+// them.
+func MonitorChunkCount(ctx context.Context, adminClient *mongo.Client, ns string, threshold int, interval time.Duration) <-chan ChunkCountAlert {
+	if interval <= 0 {
+		interval = DefaultChunkMonitorInterval
+	}
+	alerts := make(chan ChunkCountAlert, 8)
+
+	go func() {
+		defer close(alerts)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		above := false
+		for {
+			info, err := GetChunkInfo(ctx, adminClient, ns)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				log.Printf("  [WARN] chunk count monitor %s: %v", ns, err)
+			case info.TotalCount > int64(threshold) && !above:
+				above = true
+				log.Printf("  [WARN] %s has %d chunks, above the alert threshold of %d", ns, info.TotalCount, threshold)
+				select {
+				case alerts <- ChunkCountAlert{Namespace: ns, Chunks: info.TotalCount, Threshold: threshold, PerShard: info.PerShard, At: time.Now()}:
+				default:
+				}
+			case info.TotalCount <= int64(threshold) && above:
+				above = false
+				log.Printf("  [OK] %s is back to %d chunks (threshold %d)", ns, info.TotalCount, threshold)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return alerts
+}
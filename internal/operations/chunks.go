@@ -4,15 +4,23 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/sharding"
 )
 
 const chunkLabCollection = "chunk_lab"
 const jumboDocCount = 50000
 
+// distributedDocCount is how many documents the chunk lab spreads over
+// ten categories to contrast with the hotspot.
+const distributedDocCount = 5000
+const defaultChunkLabPadding = 200
+const chunkLabTargetChunks = 4
+
 // ChunkInfo holds chunk details for a collection.
 type ChunkInfo struct {
 	Namespace  string
@@ -20,10 +28,25 @@ type ChunkInfo struct {
 	PerShard   map[string]int64
 }
 
-// RunChunkLab demonstrates chunk monitoring, jumbo chunk simulation, and manual split.
-func RunChunkLab(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+// RunChunkLab demonstrates chunk monitoring, jumbo chunk simulation, a
+// planned set of manual splits (shown by PlanSplits before any is applied),
+// and a chunk move, with MonitorChunkCount watching for new chunks.
+// padBytes sets the filler per document (<= 0 uses 200); raise it to fill
+// chunks faster on clusters with a larger chunk size.
+// Every insert, split, and move is recorded in opLog (a fresh log if nil)
+// and printed as a timeline at the end.
+func RunChunkLab(ctx context.Context, adminClient, appClient *mongo.Client, db string, padBytes int, scale sharding.Scale, opLog *OperationsLog) error {
+	docCount := scale.Count(jumboDocCount)
+
+	if padBytes <= 0 {
+		padBytes = defaultChunkLabPadding
+	}
+	if opLog == nil {
+		opLog = &OperationsLog{}
+	}
+
 	log.Println("=== Chunk Management Lab ===")
-	log.Println("Goal: Monitor chunks, simulate jumbo chunk, manual split")
+	log.Println("Goal: Monitor chunks, simulate jumbo chunk, plan and apply manual splits")
 	log.Println("")
 
 	// Drop and recreate collection with ranged sharding on category
@@ -55,49 +78,53 @@ func RunChunkLab(ctx context.Context, adminClient, appClient *mongo.Client, db s
 		PrintChunkReport(info)
 	}
 
+	// Alert on any chunk beyond the initial ones so the splits below trip it
+	threshold := 1
+	if info != nil && info.TotalCount > 0 {
+		threshold = int(info.TotalCount)
+	}
+	monitorCtx, stopMonitor := context.WithCancel(ctx)
+	defer stopMonitor()
+	alerts := MonitorChunkCount(monitorCtx, adminClient, ns, threshold, time.Second)
+	log.Printf("Monitoring chunk count (alert above %d)", threshold)
+
 	// Simulate jumbo chunk: insert 50K docs with identical category to create hotspot
 	log.Println("")
-	log.Printf("Simulating jumbo chunk: inserting %d docs with category='hotspot'...", jumboDocCount)
-	coll := appClient.Database(db).Collection(chunkLabCollection)
-	batchSize := 1000
-
-	for i := 0; i < jumboDocCount; i += batchSize {
-		end := i + batchSize
-		if end > jumboDocCount {
-			end = jumboDocCount
-		}
-		docs := make([]interface{}, 0, end-i)
-		for j := i; j < end; j++ {
-			docs = append(docs, bson.M{
+	log.Printf("Simulating jumbo chunk: inserting %d docs with category='hotspot'...", docCount)
+	loadOpts := sharding.ParallelInsertOptions()
+	loadOpts.Progress = sharding.LogProgress
+	err = opLog.Record(ctx, adminClient, "insert", ns, fmt.Sprintf("%d hotspot docs", docCount), func() error {
+		docs := make([]interface{}, docCount)
+		for j := range docs {
+			docs[j] = bson.M{
 				"category": "hotspot",
 				"item_id":  fmt.Sprintf("ITEM-%08d", j),
-				"data":     fmt.Sprintf("payload-%d-padding-to-increase-document-size-%s", j, strings.Repeat("x", 200)),
-			})
-		}
-		if _, err := coll.InsertMany(ctx, docs); err != nil {
-			return fmt.Errorf("bulk insert at %d: %w", i, err)
+				"data":     fmt.Sprintf("payload-%d-padding-to-increase-document-size-%s", j, sharding.Padding(padBytes)),
+			}
 		}
+		return sharding.BatchInsert(ctx, appClient, db, chunkLabCollection, docs, loadOpts)
+	})
+	if err != nil {
+		return err
 	}
-	log.Printf("  [OK] Inserted %d documents into category='hotspot'", jumboDocCount)
+	log.Printf("  [OK] Inserted %d documents into category='hotspot'", docCount)
 
 	// Also insert some distributed data for contrast
-	log.Println("Inserting 5,000 distributed docs across 10 categories...")
-	for i := 0; i < 5000; i += batchSize {
-		end := i + batchSize
-		if end > 5000 {
-			end = 5000
-		}
-		docs := make([]interface{}, 0, end-i)
-		for j := i; j < end; j++ {
-			docs = append(docs, bson.M{
+	distCount := scale.Count(distributedDocCount)
+	log.Printf("Inserting %d distributed docs across 10 categories...", distCount)
+	err = opLog.Record(ctx, adminClient, "insert", ns, fmt.Sprintf("%d distributed docs", distCount), func() error {
+		docs := make([]interface{}, distCount)
+		for j := range docs {
+			docs[j] = bson.M{
 				"category": fmt.Sprintf("cat_%02d", j%10),
 				"item_id":  fmt.Sprintf("DIST-%08d", j),
 				"data":     fmt.Sprintf("distributed-payload-%d", j),
-			})
-		}
-		if _, err := coll.InsertMany(ctx, docs); err != nil {
-			return fmt.Errorf("distributed insert at %d: %w", i, err)
+			}
 		}
+		return sharding.BatchInsert(ctx, appClient, db, chunkLabCollection, docs, loadOpts)
+	})
+	if err != nil {
+		return err
 	}
 	log.Println("  [OK] Distributed documents inserted")
 
@@ -111,112 +138,114 @@ func RunChunkLab(ctx context.Context, adminClient, appClient *mongo.Client, db s
 		PrintChunkReport(info)
 	}
 
-	// Attempt manual split on the hotspot chunk
+	// Plan the splits from the data before applying any of them
 	log.Println("")
-	log.Println("Attempting manual split on hotspot chunk...")
-	splitPoint := bson.D{
-		{Key: "category", Value: "hotspot"},
-		{Key: "item_id", Value: fmt.Sprintf("ITEM-%08d", jumboDocCount/2)},
+	log.Printf("Planning splits to reach %d chunks...", chunkLabTargetChunks)
+	current := int64(1)
+	if info != nil && info.TotalCount > 0 {
+		current = info.TotalCount
 	}
-	if err := ManualSplitChunk(ctx, adminClient, ns, splitPoint); err != nil {
-		log.Printf("  [WARN] Manual split: %v", err)
-		log.Println("  This can happen if the chunk was already auto-split by MongoDB")
+	plan, err := PlanSplits(ctx, adminClient, ns, chunkLabTargetChunks)
+	if err != nil {
+		log.Printf("  [WARN] Split plan: %v", err)
 	} else {
-		log.Println("  [OK] Manual split succeeded")
+		PrintSplitPlan(ns, current, plan)
+	}
+	if len(plan) == 0 {
+		log.Println("  Falling back to a single split in the middle of the hotspot")
+		plan = []bson.D{{
+			{Key: "category", Value: "hotspot"},
+			{Key: "item_id", Value: fmt.Sprintf("ITEM-%08d", docCount/2)},
+		}}
 	}
 
-	// Show final chunk state
 	log.Println("")
-	log.Println("Chunk state after manual split:")
-	info, err = GetChunkInfo(ctx, adminClient, ns)
-	if err != nil {
-		log.Printf("  [WARN] chunk info: %v", err)
+	log.Printf("Applying %d manual split(s)...", len(plan))
+	applied := 0
+	for _, point := range plan {
+		err = opLog.Record(ctx, adminClient, "split", ns, "at "+sharding.FormatKey(point), func() error {
+			return ManualSplitChunk(ctx, adminClient, ns, point)
+		})
+		if err != nil {
+			log.Printf("  [WARN] Split at %s: %v", sharding.FormatKey(point), err)
+			continue
+		}
+		applied++
+	}
+	if applied == len(plan) {
+		log.Printf("  [OK] All %d planned splits succeeded", applied)
 	} else {
-		PrintChunkReport(info)
+		log.Printf("  [WARN] %d of %d splits succeeded", applied, len(plan))
+		log.Println("  This can happen if a chunk was already split at that point by MongoDB")
 	}
 
+	// Move the chunk starting at the middle split point to another shard
+	splitPoint := plan[len(plan)/2]
 	log.Println("")
-	log.Println("Result: Demonstrated chunk monitoring, jumbo simulation, and manual split")
-	log.Println("")
-	return nil
-}
-
-// GetChunkInfo queries config.chunks to get chunk distribution for a namespace.
-func GetChunkInfo(ctx context.Context, client *mongo.Client, ns string) (*ChunkInfo, error) {
-	info := &ChunkInfo{
-		Namespace: ns,
-		PerShard:  make(map[string]int64),
+	log.Printf("Moving the chunk at %s to another shard...", sharding.FormatKey(splitPoint))
+	owner, err := chunkOwner(ctx, adminClient, db, splitPoint)
+	target := ""
+	if err == nil {
+		target, err = otherShard(ctx, adminClient, owner)
 	}
-
-	// Aggregate chunks per shard
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{{Key: "ns", Value: ns}}}},
-		{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: "$shard"},
-			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
-		}}},
+	if err != nil {
+		log.Printf("  [SKIP] Move: %v", err)
+	} else {
+		err = opLog.Record(ctx, adminClient, "move", ns, sharding.FormatKey(splitPoint)+" -> "+target, func() error {
+			return MoveChunk(ctx, adminClient, ns, splitPoint, target)
+		})
+		if err != nil {
+			log.Printf("  [WARN] Move: %v", err)
+		} else {
+			log.Printf("  [OK] Chunk moved to %s", target)
+		}
 	}
 
-	cursor, err := client.Database("config").Collection("chunks").Aggregate(ctx, pipeline)
+	// Show final chunk state
+	log.Println("")
+	log.Println("Chunk state after manual splits and move:")
+	info, err = GetChunkInfo(ctx, adminClient, ns)
 	if err != nil {
-		// Try uuid-based namespace lookup (MongoDB 7.0+)
-		return getChunkInfoByUUID(ctx, client, ns)
+		log.Printf("  [WARN] chunk info: %v", err)
+	} else {
+		PrintChunkReport(info)
 	}
-	defer cursor.Close(ctx)
 
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
-			continue
-		}
-		shard := ""
-		if v, ok := doc["_id"].(string); ok {
-			shard = v
-		}
-		count := int64(0)
-		switch v := doc["count"].(type) {
-		case int32:
-			count = int64(v)
-		case int64:
-			count = v
-		case float64:
-			count = int64(v)
-		}
-		if shard != "" {
-			info.PerShard[shard] = count
-			info.TotalCount += count
-		}
+	stopMonitor()
+	fired := 0
+	for range alerts {
+		fired++
 	}
-
-	if info.TotalCount == 0 {
-		return getChunkInfoByUUID(ctx, client, ns)
+	if fired > 0 {
+		log.Printf("  [VERIFY] Chunk count monitor raised %d alert(s) above %d chunks", fired, threshold)
+	} else {
+		log.Printf("  [INFO] Chunk count stayed at or below %d; no alert raised", threshold)
 	}
 
-	return info, nil
+	log.Println("")
+	opLog.PrintTimeline()
+
+	log.Println("")
+	log.Println("Result: Demonstrated chunk monitoring, jumbo simulation, planned manual splits, and move")
+	log.Println("")
+	return nil
 }
 
-// getChunkInfoByUUID handles MongoDB 7.0+ where chunks use uuid instead of ns.
-func getChunkInfoByUUID(ctx context.Context, client *mongo.Client, ns string) (*ChunkInfo, error) {
+// GetChunkInfo queries config.chunks to get chunk distribution for a namespace.
+func GetChunkInfo(ctx context.Context, client *mongo.Client, ns string) (*ChunkInfo, error) {
 	info := &ChunkInfo{
 		Namespace: ns,
 		PerShard:  make(map[string]int64),
 	}
 
-	// Look up the collection UUID from config.collections
-	var collDoc bson.M
-	err := client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": ns}).Decode(&collDoc)
+	filter, err := sharding.NamespaceChunksFilter(ctx, client, ns)
 	if err != nil {
-		return info, fmt.Errorf("lookup collection uuid: %w", err)
-	}
-
-	uuid, ok := collDoc["uuid"]
-	if !ok {
-		return info, fmt.Errorf("no uuid for %s", ns)
+		return info, err
 	}
 
-	// Query chunks by uuid
+	// Aggregate chunks per shard
 	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{{Key: "uuid", Value: uuid}}}},
+		{{Key: "$match", Value: filter}},
 		{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: "$shard"},
 			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
@@ -225,7 +254,7 @@ func getChunkInfoByUUID(ctx context.Context, client *mongo.Client, ns string) (*
 
 	cursor, err := client.Database("config").Collection("chunks").Aggregate(ctx, pipeline)
 	if err != nil {
-		return info, fmt.Errorf("aggregate chunks by uuid: %w", err)
+		return info, fmt.Errorf("aggregate chunks for %s: %w", ns, err)
 	}
 	defer cursor.Close(ctx)
 
@@ -282,3 +311,31 @@ func ManualSplitChunk(ctx context.Context, client *mongo.Client, ns string, spli
 	}
 	return nil
 }
+
+// MoveChunk moves the chunk containing find to toShard.
+func MoveChunk(ctx context.Context, client *mongo.Client, ns string, find bson.D, toShard string) error {
+	cmd := bson.D{
+		{Key: "moveChunk", Value: ns},
+		{Key: "find", Value: find},
+		{Key: "to", Value: toShard},
+	}
+
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("moveChunk %s to %s: %w", ns, toShard, err)
+	}
+	return nil
+}
+
+// chunkOwner returns the shard that owns the chunk containing key.
+func chunkOwner(ctx context.Context, client *mongo.Client, db string, key bson.D) (string, error) {
+	doc := bson.M{}
+	for _, e := range key {
+		doc[e.Key] = e.Value
+	}
+	loc, err := sharding.LocateDocument(ctx, client, db, chunkLabCollection, doc)
+	if err != nil {
+		return "", fmt.Errorf("locate chunk: %w", err)
+	}
+	return loc.Shard, nil
+}
@@ -0,0 +1,107 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const frozenCollection = "frozen_lab"
+
+// RunCollectionBalancingLab freezes balancing on a single collection while
+// the cluster-wide balancer keeps running, then unfreezes it.
+func RunCollectionBalancingLab(ctx context.Context, adminClient *mongo.Client, db string) error {
+	log.Println("=== Per-Collection Balancing Lab ===")
+	log.Println("Goal: Freeze migrations on one hot collection without stopping the balancer")
+	log.Println("")
+
+	adminClient.Database(db).Collection(frozenCollection).Drop(ctx)
+
+	ns := db + "." + frozenCollection
+	var shardResult bson.M
+	if err := adminClient.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "shardCollection", Value: ns},
+		{Key: "key", Value: bson.D{{Key: "_id", Value: "hashed"}}},
+	}).Decode(&shardResult); err != nil {
+		return fmt.Errorf("shard collection: %w", err)
+	}
+	log.Printf("Sharded collection: %s { _id: 'hashed' }", ns)
+
+	log.Println("")
+	log.Println("Disabling balancing for this collection only...")
+	if err := SetCollectionBalancing(ctx, adminClient, ns, false); err != nil {
+		return fmt.Errorf("disable: %w", err)
+	}
+	reportCollectionBalancing(ctx, adminClient, ns)
+
+	state, err := GetBalancerStatus(ctx, adminClient)
+	if err == nil {
+		log.Printf("  Cluster balancer mode is still: %s", state.Mode)
+	}
+	log.Println("  Other collections keep migrating; this one is frozen")
+
+	log.Println("")
+	log.Println("Re-enabling balancing for this collection...")
+	if err := SetCollectionBalancing(ctx, adminClient, ns, true); err != nil {
+		return fmt.Errorf("enable: %w", err)
+	}
+	reportCollectionBalancing(ctx, adminClient, ns)
+
+	adminClient.Database(db).Collection(frozenCollection).Drop(ctx)
+
+	log.Println("")
+	log.Println("Result: Balancing toggled per collection while the cluster balancer stayed on")
+	log.Println("")
+	return nil
+}
+
+// SetCollectionBalancing enables or disables balancer migrations for one
+// sharded collection by setting noBalance in config.collections, the same
+// flag sh.disableBalancing() and sh.enableBalancing() write.
+func SetCollectionBalancing(ctx context.Context, client *mongo.Client, ns string, enabled bool) error {
+	result, err := client.Database("config").Collection("collections").UpdateOne(ctx,
+		bson.M{"_id": ns},
+		bson.M{"$set": bson.M{"noBalance": !enabled}},
+	)
+	if err != nil {
+		return fmt.Errorf("set noBalance on %s: %w", ns, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s is not a sharded collection", ns)
+	}
+	return nil
+}
+
+// GetCollectionBalancing reports whether the balancer may migrate chunks of ns.
+func GetCollectionBalancing(ctx context.Context, client *mongo.Client, ns string) (bool, error) {
+	var doc struct {
+		NoBalance bool `bson:"noBalance"`
+	}
+	err := client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": ns}).Decode(&doc)
+	if err != nil {
+		return false, fmt.Errorf("read %s from config.collections: %w", ns, err)
+	}
+	return !doc.NoBalance, nil
+}
+
+// reportCollectionBalancing logs the noBalance flag and balancerCollectionStatus.
+func reportCollectionBalancing(ctx context.Context, client *mongo.Client, ns string) {
+	enabled, err := GetCollectionBalancing(ctx, client, ns)
+	if err != nil {
+		log.Printf("  [WARN] %v", err)
+		return
+	}
+	log.Printf("  [VERIFY] Balancing enabled for %s: %v", ns, enabled)
+
+	var status bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "balancerCollectionStatus", Value: ns},
+	}).Decode(&status); err != nil {
+		log.Printf("  [INFO] balancerCollectionStatus unavailable: %v", err)
+		return
+	}
+	log.Printf("  balancerCompliant=%v", status["balancerCompliant"])
+}
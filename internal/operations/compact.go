@@ -0,0 +1,177 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/config"
+)
+
+// compactTimeout bounds compact on one member; it rewrites the whole collection.
+const compactTimeout = 10 * time.Minute
+
+// CompactResult is the outcome of compact on one shard member.
+type CompactResult struct {
+	Shard       string
+	Host        string
+	Primary     bool
+	Skipped     bool
+	StorageSize int64 // before compact
+	StorageNow  int64 // after compact
+	Err         error
+}
+
+// Freed is the reduction in on-disk storage size.
+func (r CompactResult) Freed() int64 {
+	return r.StorageSize - r.StorageNow
+}
+
+// RunStorageReclaimLab deletes the chunk lab's hotspot documents and
+// compacts the collection so the freed space is returned to the OS.
+func RunStorageReclaimLab(ctx context.Context, adminClient, appClient *mongo.Client, db, user, password string) error {
+	log.Println("=== Storage Reclaim Lab ===")
+	log.Println("Goal: Return disk space from deleted documents with compact")
+	log.Println("")
+
+	coll := appClient.Database(db).Collection(chunkLabCollection)
+	deleted, err := coll.DeleteMany(ctx, bson.M{"category": "hotspot"})
+	if err != nil {
+		return fmt.Errorf("delete hotspot docs: %w", err)
+	}
+	log.Printf("  [OK] Deleted %d hotspot documents from %s", deleted.DeletedCount, chunkLabCollection)
+	log.Println("  WiredTiger keeps the freed pages for reuse; the files do not shrink on their own")
+
+	log.Println("")
+	log.Println("Compacting on each shard's secondaries...")
+	results, err := CompactCollection(ctx, adminClient, db, chunkLabCollection, user, password)
+	if err != nil {
+		return fmt.Errorf("compact: %w", err)
+	}
+	PrintCompactResults(results)
+
+	log.Println("")
+	log.Println("Result: Storage reclaimed on secondaries; step down and repeat to compact the old primary")
+	log.Println("")
+	return nil
+}
+
+// CompactCollection runs compact on db.coll on every secondary of every
+// shard, connecting directly to each member because mongos does not route
+// compact. Primaries are skipped so foreground traffic is not slowed; step
+// a primary down and re-run to compact it. Storage size is read from
+// $collStats on the member before and after.
+//
+// mongosClient is used only to discover shard members via listShards;
+// user and password authenticate the direct member connections.
+func CompactCollection(ctx context.Context, mongosClient *mongo.Client, db, coll, user, password string) ([]CompactResult, error) {
+	var shards struct {
+		Shards []struct {
+			ID   string `bson:"_id"`
+			Host string `bson:"host"`
+		} `bson:"shards"`
+	}
+	if err := mongosClient.Database("admin").RunCommand(ctx, bson.D{{Key: "listShards", Value: 1}}).Decode(&shards); err != nil {
+		return nil, fmt.Errorf("listShards: %w", err)
+	}
+
+	var results []CompactResult
+	for _, shard := range shards.Shards {
+		_, members, _ := strings.Cut(shard.Host, "/")
+		for _, host := range strings.Split(members, ",") {
+			result := CompactResult{Shard: shard.ID, Host: host}
+			compactMember(ctx, &result, db, coll, user, password)
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// compactMember fills in result for one member.
+func compactMember(ctx context.Context, result *CompactResult, db, coll, user, password string) {
+	uri, err := config.BuildURI([]string{result.Host}, user, password, "admin")
+	if err != nil {
+		result.Err = err
+		return
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetDirect(true).SetTimeout(compactTimeout))
+	if err != nil {
+		result.Err = err
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	var hello struct {
+		IsWritablePrimary bool `bson:"isWritablePrimary"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		result.Err = fmt.Errorf("hello: %w", err)
+		return
+	}
+	if hello.IsWritablePrimary {
+		result.Primary = true
+		result.Skipped = true
+		return
+	}
+
+	if result.StorageSize, err = memberStorageSize(ctx, client, db, coll); err != nil {
+		result.Err = err
+		return
+	}
+
+	var out bson.M
+	if err := client.Database(db).RunCommand(ctx, bson.D{{Key: "compact", Value: coll}}).Decode(&out); err != nil {
+		result.Err = fmt.Errorf("compact: %w", err)
+		return
+	}
+
+	result.StorageNow, result.Err = memberStorageSize(ctx, client, db, coll)
+}
+
+// memberStorageSize returns the collection's on-disk size on one mongod.
+func memberStorageSize(ctx context.Context, client *mongo.Client, db, coll string) (int64, error) {
+	cursor, err := client.Database(db).Collection(coll).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("collStats: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var stats struct {
+		StorageStats struct {
+			StorageSize int64 `bson:"storageSize"`
+		} `bson:"storageStats"`
+	}
+	if !cursor.Next(ctx) {
+		return 0, fmt.Errorf("collStats: no result for %s.%s", db, coll)
+	}
+	if err := cursor.Decode(&stats); err != nil {
+		return 0, fmt.Errorf("decode collStats: %w", err)
+	}
+	return stats.StorageStats.StorageSize, nil
+}
+
+// PrintCompactResults logs per-member compact outcomes and the total freed.
+func PrintCompactResults(results []CompactResult) {
+	var total int64
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			log.Printf("  [WARN] %-10s %-18s %v", r.Shard, r.Host, r.Err)
+		case r.Skipped:
+			log.Printf("  [SKIP] %-10s %-18s primary", r.Shard, r.Host)
+		default:
+			total += r.Freed()
+			log.Printf("  [OK]   %-10s %-18s %.2f MB -> %.2f MB (freed %.2f MB)", r.Shard, r.Host,
+				float64(r.StorageSize)/1024/1024, float64(r.StorageNow)/1024/1024, float64(r.Freed())/1024/1024)
+		}
+	}
+	log.Printf("  [VERIFY] Total freed on secondaries: %.2f MB", float64(total)/1024/1024)
+}
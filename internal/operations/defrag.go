@@ -0,0 +1,209 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+const defragCollection = "defrag_lab"
+const defragDocCount = 2000
+const defragSplitEvery = 20
+const defragTimeout = 3 * time.Minute
+
+// CollectionBalancingOptions are the settings accepted by
+// configureCollectionBalancing. Zero values leave a setting unchanged.
+type CollectionBalancingOptions struct {
+	ChunkSizeMB          int
+	DefragmentCollection bool
+	EnableAutoMerger     *bool // MongoDB 7.0+
+}
+
+// DefragProgress is a snapshot of a collection defragmentation.
+type DefragProgress struct {
+	Namespace       string
+	InProgress      bool
+	Phase           string
+	RemainingChunks int64
+	TotalChunks     int64
+}
+
+// RunDefragmentationLab presplits a collection into many small chunks and
+// then defragments it, reporting the chunk count as merges complete.
+func RunDefragmentationLab(ctx context.Context, adminClient, appClient *mongo.Client, db string, scale sharding.Scale) error {
+	docCount := scale.Count(defragDocCount)
+
+	log.Println("=== Defragmentation Lab ===")
+	log.Println("Goal: Merge many small chunks back together with defragmentCollection")
+	log.Println("")
+
+	major, _, err := sharding.ServerVersion(ctx, adminClient)
+	if err != nil {
+		return fmt.Errorf("server version: %w", err)
+	}
+	if major < 6 {
+		log.Printf("  [SKIP] defragmentCollection requires MongoDB 6.0+ (server is %d.x)", major)
+		log.Println("")
+		return nil
+	}
+
+	appClient.Database(db).Collection(defragCollection).Drop(ctx)
+
+	ns := db + "." + defragCollection
+	key := bson.D{{Key: "seq", Value: 1}}
+	if err := sharding.ShardCollection(ctx, adminClient, db, defragCollection, key); err != nil {
+		return fmt.Errorf("shard collection: %w", err)
+	}
+	log.Printf("Sharded collection: %s { seq: 1 }", ns)
+
+	docs := make([]interface{}, docCount)
+	for i := range docs {
+		docs[i] = bson.M{"seq": i, "payload": fmt.Sprintf("defrag-%d", i)}
+	}
+	if _, err := appClient.Database(db).Collection(defragCollection).InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	log.Printf("  [OK] %d documents inserted", docCount)
+
+	// Presplit into many tiny chunks to simulate chunk-count bloat
+	log.Println("")
+	log.Printf("Presplitting every ~%d documents...", defragSplitEvery)
+	if chunks := docCount / defragSplitEvery; chunks > 1 {
+		points, err := sharding.SuggestSplitPoints(ctx, adminClient, db, defragCollection, key, chunks-1)
+		if err == nil {
+			err = sharding.PreSplitChunks(ctx, adminClient, db, defragCollection, points)
+		}
+		if err != nil {
+			log.Printf("  [WARN] presplit: %v", err)
+		}
+	}
+
+	before, err := GetChunkInfo(ctx, adminClient, ns)
+	if err != nil {
+		return fmt.Errorf("chunk info: %w", err)
+	}
+	log.Println("Before defragmentation:")
+	PrintChunkReport(before)
+
+	log.Println("")
+	log.Println("Starting defragmentation...")
+	if err := ConfigureCollectionBalancing(ctx, adminClient, ns, CollectionBalancingOptions{DefragmentCollection: true}); err != nil {
+		return fmt.Errorf("defragment: %w", err)
+	}
+
+	watchCtx, cancel := context.WithTimeout(ctx, defragTimeout)
+	WatchDefragmentation(watchCtx, adminClient, ns, 3*time.Second, PrintDefragProgress)
+	timedOut := watchCtx.Err() == context.DeadlineExceeded
+	cancel()
+	if timedOut {
+		log.Printf("  [WARN] Defragmentation still running after %v; it continues in the background", defragTimeout)
+	}
+
+	after, err := GetChunkInfo(ctx, adminClient, ns)
+	if err != nil {
+		return fmt.Errorf("chunk info: %w", err)
+	}
+	log.Println("")
+	log.Println("After defragmentation:")
+	PrintChunkReport(after)
+	log.Printf("  [VERIFY] Chunk count: %d -> %d", before.TotalCount, after.TotalCount)
+
+	appClient.Database(db).Collection(defragCollection).Drop(ctx)
+
+	log.Println("")
+	log.Println("Result: Small chunks merged by the balancer's defragmentation")
+	log.Println("")
+	return nil
+}
+
+// ConfigureCollectionBalancing runs configureCollectionBalancing on ns
+// (MongoDB 6.0+). Setting DefragmentCollection starts a background
+// defragmentation driven by the balancer; poll it with GetDefragProgress.
+func ConfigureCollectionBalancing(ctx context.Context, client *mongo.Client, ns string, opts CollectionBalancingOptions) error {
+	cmd := bson.D{{Key: "configureCollectionBalancing", Value: ns}}
+	if opts.ChunkSizeMB > 0 {
+		cmd = append(cmd, bson.E{Key: "chunkSize", Value: opts.ChunkSizeMB})
+	}
+	if opts.DefragmentCollection {
+		cmd = append(cmd, bson.E{Key: "defragmentCollection", Value: true})
+	}
+	if opts.EnableAutoMerger != nil {
+		cmd = append(cmd, bson.E{Key: "enableAutoMerger", Value: *opts.EnableAutoMerger})
+	}
+
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("configureCollectionBalancing %s: %w", ns, err)
+	}
+	return nil
+}
+
+// GetDefragProgress reads defragmentation state from balancerCollectionStatus.
+// InProgress is false once the collection is no longer being defragmented.
+func GetDefragProgress(ctx context.Context, client *mongo.Client, ns string) (*DefragProgress, error) {
+	var status struct {
+		Violation string `bson:"firstComplianceViolation"`
+		Details   struct {
+			CurrentPhase string `bson:"currentPhase"`
+			Progress     struct {
+				Remaining int64 `bson:"remainingChunksToProcess"`
+			} `bson:"progress"`
+		} `bson:"details"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "balancerCollectionStatus", Value: ns},
+	}).Decode(&status); err != nil {
+		return nil, fmt.Errorf("balancerCollectionStatus %s: %w", ns, err)
+	}
+
+	progress := &DefragProgress{
+		Namespace:       ns,
+		InProgress:      status.Violation == "defragmentingChunks",
+		Phase:           status.Details.CurrentPhase,
+		RemainingChunks: status.Details.Progress.Remaining,
+	}
+	if info, err := GetChunkInfo(ctx, client, ns); err == nil {
+		progress.TotalChunks = info.TotalCount
+	}
+	return progress, nil
+}
+
+// WatchDefragmentation polls GetDefragProgress every interval and passes
+// each snapshot to report until defragmentation ends or ctx is cancelled.
+func WatchDefragmentation(ctx context.Context, client *mongo.Client, ns string, interval time.Duration, report func(*DefragProgress)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		progress, err := GetDefragProgress(ctx, client, ns)
+		if err != nil {
+			log.Printf("  [WARN] defrag progress: %v", err)
+			continue
+		}
+		report(progress)
+		if !progress.InProgress {
+			return
+		}
+	}
+}
+
+// PrintDefragProgress logs a one-line defragmentation snapshot.
+func PrintDefragProgress(p *DefragProgress) {
+	if !p.InProgress {
+		log.Printf("  [OK] Defragmentation finished: %d chunks", p.TotalChunks)
+		return
+	}
+	log.Printf("  [defrag] phase=%s remaining=%d chunks=%d", p.Phase, p.RemainingChunks, p.TotalChunks)
+}
@@ -4,26 +4,35 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/stats"
 )
 
 const hedgedCollection = "hedged_reads_test"
 const hedgedQueryCount = 20
+const hedgedDocCount = 1000
 
 // RunHedgedReadsLab demonstrates hedged reads for latency reduction.
 // Compares query latencies with standard reads vs hedged reads.
-func RunHedgedReadsLab(ctx context.Context, host, user, password, db string) error {
+func RunHedgedReadsLab(ctx context.Context, host, user, password, db string, scale sharding.Scale) error {
 	log.Println("=== Hedged Reads Lab ===")
 	log.Println("Goal: Reduce read latency by querying multiple replicas")
 	log.Println("")
 
 	// Standard client (no hedging)
-	standardURI := fmt.Sprintf("mongodb://%s:%s@%s/?authSource=admin", user, password, host)
+	standardURI, err := config.BuildURI(strings.Split(host, ","), user, password, "admin")
+	if err != nil {
+		return err
+	}
 	standardClient, err := mongo.Connect(ctx, options.Client().
 		ApplyURI(standardURI).
 		SetTimeout(30*time.Second).
@@ -53,34 +62,34 @@ func RunHedgedReadsLab(ctx context.Context, host, user, password, db string) err
 	coll := standardClient.Database(db).Collection(hedgedCollection)
 	coll.Drop(ctx)
 
-	docs := make([]interface{}, 1000)
-	for i := 0; i < 1000; i++ {
+	docs := make([]interface{}, scale.Count(hedgedDocCount))
+	for i := range docs {
 		docs[i] = bson.M{
 			"_id":       fmt.Sprintf("doc_%04d", i),
 			"value":     i,
 			"category":  fmt.Sprintf("cat_%d", i%10),
-			"timestamp": time.Now().UTC(),
+			"timestamp": sharding.DataEpoch.Add(time.Duration(i) * time.Second),
 			"payload":   fmt.Sprintf("test-data-%d", i),
 		}
 	}
 	if _, err := coll.InsertMany(ctx, docs); err != nil {
 		return fmt.Errorf("seed data: %w", err)
 	}
-	log.Println("  [OK] 1,000 test documents inserted")
+	log.Printf("  [OK] %d test documents inserted", len(docs))
 
 	// Benchmark standard reads
 	log.Println("")
 	log.Println("Running standard reads (nearest, no hedging)...")
 	standardLatencies := benchmarkReads(ctx, standardClient, db, hedgedCollection)
-	standardAvg := avgDuration(standardLatencies)
-	log.Printf("  %d queries, avg latency: %v", len(standardLatencies), standardAvg)
+	standardAvg := stats.Mean(standardLatencies)
+	log.Printf("  %d queries, avg latency: %v, p99: %v", len(standardLatencies), standardAvg, stats.Percentile(standardLatencies, 0.99))
 
 	// Benchmark hedged reads
 	log.Println("")
 	log.Println("Running hedged reads (nearest, hedging enabled)...")
 	hedgedLatencies := benchmarkReads(ctx, hedgedClient, db, hedgedCollection)
-	hedgedAvg := avgDuration(hedgedLatencies)
-	log.Printf("  %d queries, avg latency: %v", len(hedgedLatencies), hedgedAvg)
+	hedgedAvg := stats.Mean(hedgedLatencies)
+	log.Printf("  %d queries, avg latency: %v, p99: %v", len(hedgedLatencies), hedgedAvg, stats.Percentile(hedgedLatencies, 0.99))
 
 	// Report
 	log.Println("")
@@ -137,15 +146,3 @@ func benchmarkReads(ctx context.Context, client *mongo.Client, db, collection st
 
 	return latencies
 }
-
-// avgDuration computes the average of a duration slice.
-func avgDuration(durations []time.Duration) time.Duration {
-	if len(durations) == 0 {
-		return 0
-	}
-	total := time.Duration(0)
-	for _, d := range durations {
-		total += d
-	}
-	return total / time.Duration(len(durations))
-}
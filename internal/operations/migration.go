@@ -0,0 +1,186 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+const migrationCollection = "migration_rate_lab"
+const migrationDocCount = 20000
+const migrationDocBytes = 1024
+const migrationChunkDocs = 1000 // ~1MB chunks at migrationDocBytes
+const migrationTimeout = 3 * time.Minute
+
+// MigrationSample is one observation of where a collection's chunks live.
+// Moved is the running total of chunks that have arrived on a new shard.
+type MigrationSample struct {
+	Time     time.Time
+	PerShard map[string]int64
+	Moved    int64
+}
+
+// MigrationReport summarizes how fast the balancer spread a collection.
+type MigrationReport struct {
+	Namespace   string
+	Samples     []MigrationSample
+	ChunksMoved int64
+	Elapsed     time.Duration
+	Balanced    bool // the balancer reported the collection compliant
+}
+
+// ChunksPerMinute is the average migration rate over the measured run.
+func (r *MigrationReport) ChunksPerMinute() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.ChunksMoved) / r.Elapsed.Minutes()
+}
+
+// MeasureMigrationRate samples the per-shard chunk counts of ns from
+// config.chunks every interval until the balancer reports ns compliant or
+// timeout elapses, and derives chunks moved from chunks arriving on each
+// shard between samples. Sampling only sees net placement, so a chunk that
+// moves twice within one interval counts once; keep the interval short
+// relative to a migration.
+func MeasureMigrationRate(ctx context.Context, client *mongo.Client, ns string, interval, timeout time.Duration) (*MigrationReport, error) {
+	report := &MigrationReport{Namespace: ns}
+	start := time.Now()
+	deadline := start.Add(timeout)
+
+	var prev map[string]int64
+	for {
+		info, err := GetChunkInfo(ctx, client, ns)
+		if err != nil {
+			return report, fmt.Errorf("sample chunks: %w", err)
+		}
+		if prev != nil {
+			report.ChunksMoved += chunksArrived(prev, info.PerShard)
+		}
+		prev = info.PerShard
+		report.Samples = append(report.Samples, MigrationSample{Time: time.Now(), PerShard: info.PerShard, Moved: report.ChunksMoved})
+		report.Elapsed = time.Since(start)
+
+		compliant, err := balancerCompliant(ctx, client, ns)
+		if err != nil {
+			return report, err
+		}
+		if compliant && report.ChunksMoved > 0 {
+			report.Balanced = true
+			return report, nil
+		}
+		if time.Now().After(deadline) {
+			return report, nil
+		}
+
+		if !timeutil.Sleep(ctx, interval) {
+			return report, ctx.Err()
+		}
+	}
+}
+
+// printMigrationSample logs one sample as per-shard chunk counts.
+func printMigrationSample(start time.Time, s MigrationSample) {
+	shards := make([]string, 0, len(s.PerShard))
+	for shard := range s.PerShard {
+		shards = append(shards, shard)
+	}
+	sort.Strings(shards)
+
+	line := ""
+	for _, shard := range shards {
+		line += fmt.Sprintf("  %s=%d", shard, s.PerShard[shard])
+	}
+	log.Printf("    [%6v] moved=%-4d%s", s.Time.Sub(start).Round(time.Second), s.Moved, line)
+}
+
+// PrintMigrationReport logs the timeline and rate of a migration run.
+func PrintMigrationReport(r *MigrationReport) {
+	if len(r.Samples) == 0 {
+		return
+	}
+	start := r.Samples[0].Time
+	for _, s := range r.Samples {
+		printMigrationSample(start, s)
+	}
+	log.Printf("  Chunks moved:  %d in %v", r.ChunksMoved, r.Elapsed.Round(time.Second))
+	log.Printf("  Rate:          %.1f chunks/min", r.ChunksPerMinute())
+	if r.Balanced {
+		log.Printf("  [OK] Balanced after %v", r.Elapsed.Round(time.Second))
+	} else {
+		log.Printf("  [INFO] Not yet balanced after %v; the balancer keeps going in the background", r.Elapsed.Round(time.Second))
+	}
+}
+
+// chunksArrived counts chunks gained per shard between two samples. Losses
+// are ignored, so each migration counts once, at its destination.
+func chunksArrived(prev, cur map[string]int64) int64 {
+	var arrived int64
+	for shard, n := range cur {
+		if d := n - prev[shard]; d > 0 {
+			arrived += d
+		}
+	}
+	return arrived
+}
+
+// balancerCompliant reports whether the balancer has nothing left to do
+// for ns.
+func balancerCompliant(ctx context.Context, client *mongo.Client, ns string) (bool, error) {
+	var status struct {
+		BalancerCompliant bool `bson:"balancerCompliant"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "balancerCollectionStatus", Value: ns},
+	}).Decode(&status); err != nil {
+		return false, fmt.Errorf("balancerCollectionStatus %s: %w", ns, err)
+	}
+	return status.BalancerCompliant, nil
+}
+
+// setupImbalancedCollection loads db.migrationCollection with ~20MB split
+// into ~1MB chunks while the balancer is stopped, leaving every chunk on
+// the primary shard for the balancer to spread once it starts. The
+// collection's chunk size is lowered to 1MB so this little data is enough
+// to exceed the data-size imbalance threshold of MongoDB 6.1+.
+func setupImbalancedCollection(ctx context.Context, client *mongo.Client, db string, scale sharding.Scale) (string, error) {
+	docCount := scale.Count(migrationDocCount)
+
+	ns := db + "." + migrationCollection
+	client.Database(db).Collection(migrationCollection).Drop(ctx)
+
+	key := bson.D{{Key: "seq", Value: 1}}
+	if err := sharding.ShardCollection(ctx, client, db, migrationCollection, key); err != nil {
+		return ns, fmt.Errorf("shard collection: %w", err)
+	}
+	if err := ConfigureCollectionBalancing(ctx, client, ns, CollectionBalancingOptions{ChunkSizeMB: 1}); err != nil {
+		log.Printf("  [WARN] chunk size: %v (migrations may not start on this little data)", err)
+	}
+
+	docs := make([]interface{}, docCount)
+	for i := range docs {
+		docs[i] = bson.M{"seq": i, "data": sharding.Padding(migrationDocBytes)}
+	}
+	if err := sharding.BatchInsert(ctx, client, db, migrationCollection, docs, sharding.DefaultInsertOptions()); err != nil {
+		return ns, fmt.Errorf("insert: %w", err)
+	}
+
+	if chunks := docCount / migrationChunkDocs; chunks > 1 {
+		points, err := sharding.SuggestSplitPoints(ctx, client, db, migrationCollection, key, chunks-1)
+		if err == nil {
+			err = sharding.PreSplitChunks(ctx, client, db, migrationCollection, points)
+		}
+		if err != nil {
+			log.Printf("  [WARN] presplit: %v", err)
+		}
+	}
+	return ns, nil
+}
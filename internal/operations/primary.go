@@ -0,0 +1,117 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+const unshardedCollection = "unsharded_lab"
+
+// unshardedDocCount is how many documents the moved collection holds.
+const unshardedDocCount = 100
+
+// RunMovePrimaryLab demonstrates relocating a database's primary shard.
+// Unsharded collections live entirely on the primary shard, so moving it
+// shifts their load off an overloaded shard.
+func RunMovePrimaryLab(ctx context.Context, adminClient *mongo.Client, db string, scale sharding.Scale) error {
+	log.Println("=== Move Primary Lab ===")
+	log.Println("Goal: Relocate unsharded collections to a different shard")
+	log.Println("")
+
+	// Make sure the database has at least one unsharded collection to move
+	coll := adminClient.Database(db).Collection(unshardedCollection)
+	coll.Drop(ctx)
+	docs := make([]interface{}, scale.Count(unshardedDocCount))
+	for i := range docs {
+		docs[i] = bson.M{"_id": fmt.Sprintf("unsharded_%04d", i), "value": i}
+	}
+	if _, err := coll.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("seed unsharded collection: %w", err)
+	}
+	log.Printf("  [OK] Seeded unsharded collection %s.%s", db, unshardedCollection)
+
+	current, err := GetPrimaryShard(ctx, adminClient, db)
+	if err != nil {
+		return fmt.Errorf("primary shard: %w", err)
+	}
+	log.Printf("  Current primary shard for '%s': %s", db, current)
+
+	target, err := otherShard(ctx, adminClient, current)
+	if err != nil {
+		return fmt.Errorf("pick target shard: %w", err)
+	}
+
+	log.Println("")
+	log.Println("  WARNING: movePrimary blocks writes to unsharded collections while it copies them.")
+	log.Println("           Quiesce the database before running it in production.")
+	log.Printf("Moving primary of '%s': %s → %s...", db, current, target)
+	if err := MovePrimary(ctx, adminClient, db, target); err != nil {
+		return fmt.Errorf("move primary: %w", err)
+	}
+
+	after, err := GetPrimaryShard(ctx, adminClient, db)
+	if err != nil {
+		return fmt.Errorf("primary shard after move: %w", err)
+	}
+	log.Printf("  Primary shard is now: %s", after)
+
+	count, _ := coll.CountDocuments(ctx, bson.M{})
+	log.Printf("  Unsharded collection still has %d/%d documents", count, len(docs))
+
+	coll.Drop(ctx)
+
+	log.Println("")
+	log.Println("Result: Database primary relocated with unsharded data intact")
+	log.Println("")
+	return nil
+}
+
+// GetPrimaryShard reads the primary shard for a database from config.databases.
+func GetPrimaryShard(ctx context.Context, client *mongo.Client, db string) (string, error) {
+	var doc bson.M
+	err := client.Database("config").Collection("databases").FindOne(ctx, bson.M{"_id": db}).Decode(&doc)
+	if err != nil {
+		return "", fmt.Errorf("read config.databases for %s: %w", db, err)
+	}
+	primary, ok := doc["primary"].(string)
+	if !ok || primary == "" {
+		return "", fmt.Errorf("no primary shard recorded for %s", db)
+	}
+	return primary, nil
+}
+
+// MovePrimary moves a database's primary shard (and its unsharded collections) to toShard.
+// This is a blocking operation: writes to unsharded collections stall until it completes.
+func MovePrimary(ctx context.Context, client *mongo.Client, db, toShard string) error {
+	cmd := bson.D{
+		{Key: "movePrimary", Value: db},
+		{Key: "to", Value: toShard},
+	}
+
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("movePrimary %s→%s: %w", db, toShard, err)
+	}
+	log.Printf("  [OK] Primary for '%s' moved to %s", db, toShard)
+	return nil
+}
+
+// otherShard returns the first registered shard that differs from exclude.
+func otherShard(ctx context.Context, client *mongo.Client, exclude string) (string, error) {
+	shards, err := sharding.ShardNames(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range shards {
+		if id != exclude {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no shard other than %s", exclude)
+}
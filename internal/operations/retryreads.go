@@ -0,0 +1,141 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/cluster"
+	"go-mongodb-sharding-poc/internal/config"
+)
+
+// retryReadsFaults are the transient failures injected on one find each:
+// a dropped connection and a retryable server error (ShutdownInProgress).
+var retryReadsFaults = []struct {
+	name string
+	data bson.M
+}{
+	{"closed connection", bson.M{"closeConnection": true}},
+	{"ShutdownInProgress (91)", bson.M{"errorCode": 91}},
+}
+
+// retryReadsOutcome is what one read did under an injected fault.
+type retryReadsOutcome struct {
+	attempts int32
+	latency  time.Duration
+	err      error
+}
+
+// RunRetryableReadsLab verifies the driver's retryable reads. It connects
+// to a shard replica set directly, because mongos retries shard errors on
+// its own and the driver behind it would never see them. For each
+// fault, a failCommand fail point fails the next find from one client, once;
+// the retryReads=true client must succeed on a second attempt and the
+// retryReads=false client must surface the error.
+func RunRetryableReadsLab(ctx context.Context, shard config.ReplicaSet, user, password string) error {
+	log.Println("=== Retryable Reads Lab ===")
+	log.Println("Goal: Transient read errors are retried transparently by the driver")
+	log.Println("")
+
+	hosts := make([]string, len(shard.Members))
+	for i, m := range shard.Members {
+		hosts[i] = m.Addr()
+	}
+	uri, err := config.BuildURI(hosts, user, password, "admin")
+	if err != nil {
+		return fmt.Errorf("shard %s: %w", shard.Name, err)
+	}
+
+	var failed []string
+	for _, retry := range []bool{true, false} {
+		appName := fmt.Sprintf("retry-reads-lab-%v", retry)
+		var attempts atomic.Int32
+		client, err := mongo.Connect(ctx, options.Client().
+			ApplyURI(uri).
+			SetReplicaSet(shard.Name).
+			SetAppName(appName).
+			SetRetryReads(retry).
+			SetTimeout(30*time.Second).
+			SetMonitor(&event.CommandMonitor{
+				Started: func(_ context.Context, e *event.CommandStartedEvent) {
+					if e.CommandName == "find" {
+						attempts.Add(1)
+					}
+				},
+			}))
+		if err != nil {
+			return fmt.Errorf("connect to %s: %w", shard.Name, err)
+		}
+
+		log.Printf("retryReads=%v (%s):", retry, shard.Name)
+		for _, fault := range retryReadsFaults {
+			out, err := readUnderFault(ctx, client, appName, fault.data, &attempts)
+			if errors.Is(err, cluster.ErrTestCommandsDisabled) {
+				client.Disconnect(ctx)
+				log.Printf("  [SKIP] %s: %v", shard.Name, err)
+				return nil
+			}
+			if err != nil {
+				client.Disconnect(ctx)
+				return err
+			}
+
+			switch {
+			case retry && out.err == nil && out.attempts >= 2:
+				log.Printf("  [VERIFY] %-24s succeeded after %d attempts in %v", fault.name, out.attempts, out.latency.Round(time.Millisecond))
+			case !retry && out.err != nil:
+				log.Printf("  [VERIFY] %-24s failed after %d attempt(s): %v", fault.name, out.attempts, out.err)
+			default:
+				log.Printf("  [FAIL] %-24s attempts=%d err=%v", fault.name, out.attempts, out.err)
+				failed = append(failed, fmt.Sprintf("retryReads=%v %s", retry, fault.name))
+			}
+		}
+		client.Disconnect(ctx)
+	}
+
+	log.Println("")
+	log.Println("How retryable reads work:")
+	log.Println("  1. A read fails with a network error or a retryable code (e.g. a primary stepping down)")
+	log.Println("  2. The driver selects a server again and re-sends the read exactly once")
+	log.Println("  3. Cursor getMore calls are not retried; only the initial read command is")
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unexpected retry behavior: %s", strings.Join(failed, ", "))
+	}
+	log.Println("")
+	log.Println("Result: Retryable reads hide a single transient failure; without them the error reaches the caller")
+	log.Println("")
+	return nil
+}
+
+// readUnderFault arms failCommand to fail the next find from appName once,
+// runs one read and reports how it went. The returned error is a setup
+// failure; the read's own error is in the outcome.
+func readUnderFault(ctx context.Context, client *mongo.Client, appName string, data bson.M, attempts *atomic.Int32) (*retryReadsOutcome, error) {
+	fpData := bson.M{"failCommands": bson.A{"find"}, "appName": appName}
+	for k, v := range data {
+		fpData[k] = v
+	}
+	if err := cluster.SetFailPoint(ctx, client, "failCommand", bson.M{"times": 1}, fpData); err != nil {
+		return nil, err
+	}
+	defer cluster.ClearFailPoint(ctx, client, "failCommand")
+
+	attempts.Store(0)
+	start := time.Now()
+	err := client.Database("admin").Collection("system.version").FindOne(ctx, bson.M{}).Err()
+	out := &retryReadsOutcome{attempts: attempts.Load(), latency: time.Since(start)}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		out.err = err
+	}
+	return out, nil
+}
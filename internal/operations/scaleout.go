@@ -0,0 +1,287 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/cluster"
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/sharding"
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+const scaleOutCollection = "scaleout_lab"
+const scaleOutDocCount = 30000
+const scaleOutDocBytes = 1024
+const scaleOutTimeout = 5 * time.Minute
+const drainCollection = "drain_lab"
+const drainDocCount = 20000
+const drainTimeout = 5 * time.Minute
+
+// RunAddShardLab demonstrates elastic scale-out: it loads a collection
+// that is balanced across the existing shards, adds the empty spare shard,
+// and follows the balancer as it migrates chunks onto it, reporting
+// per-shard chunk counts over time and the time to balance. The spare
+// shard is left registered so the shard-removal lab can drain it again.
+// user and password create the spare shard's local admin, as setup does
+// for the others.
+func RunAddShardLab(ctx context.Context, adminClient *mongo.Client, db string, spare config.ReplicaSet, user, password string, scale sharding.Scale) error {
+	docCount := scale.Count(scaleOutDocCount)
+
+	log.Println("=== Add Shard & Rebalance Lab ===")
+	log.Printf("Goal: Add empty shard %s to a loaded cluster and watch data spread onto it", spare.Name)
+	log.Println("")
+
+	shards, err := sharding.ShardNames(ctx, adminClient)
+	if err != nil {
+		return err
+	}
+	for _, id := range shards {
+		if id == spare.Name {
+			log.Printf("  [SKIP] %s is already a shard; run the shard-removal lab to drain it first", spare.Name)
+			log.Println("")
+			return nil
+		}
+	}
+	if err := cluster.WaitForHost(ctx, spare.Members[0].Addr(), 5*time.Second); err != nil {
+		log.Printf("  [SKIP] Spare shard %s unreachable: %v", spare.Members[0].Addr(), err)
+		log.Println("         Start it with: docker compose --profile scale-out up -d")
+		log.Println("")
+		return nil
+	}
+
+	// Bring up the spare replica set before it joins
+	log.Printf("Initializing spare replica set %s...", spare.Name)
+	if err := cluster.InitReplicaSet(ctx, spare.Name, spare.Members, false); err != nil {
+		return fmt.Errorf("init %s: %w", spare.Name, err)
+	}
+	if err := cluster.WaitForPrimary(ctx, spare.Members[0].Addr(), 60*time.Second); err != nil {
+		return fmt.Errorf("primary %s: %w", spare.Name, err)
+	}
+	if err := cluster.CreateAdminUser(ctx, spare.Members[0].Addr(), user, password); err != nil {
+		return fmt.Errorf("admin on %s: %w", spare.Name, err)
+	}
+
+	// Load balanced data on the current shards
+	log.Println("")
+	log.Printf("Loading %d docs (~%dMB) across %d shards...", docCount, docCount*scaleOutDocBytes>>20, len(shards))
+	ns := db + "." + scaleOutCollection
+	adminClient.Database(db).Collection(scaleOutCollection).Drop(ctx)
+	if err := sharding.ShardCollection(ctx, adminClient, db, scaleOutCollection, bson.D{{Key: "_id", Value: "hashed"}}); err != nil {
+		return fmt.Errorf("shard collection: %w", err)
+	}
+	// 1MB chunks so this little data is enough for the balancer to act on
+	if err := ConfigureCollectionBalancing(ctx, adminClient, ns, CollectionBalancingOptions{ChunkSizeMB: 1}); err != nil {
+		log.Printf("  [WARN] chunk size: %v (the new shard may stay empty on this little data)", err)
+	}
+	docs := make([]interface{}, docCount)
+	for i := range docs {
+		docs[i] = bson.M{"_id": fmt.Sprintf("scale_%06d", i), "data": sharding.Padding(scaleOutDocBytes)}
+	}
+	loadOpts := sharding.ParallelInsertOptions()
+	loadOpts.Progress = sharding.LogProgress
+	if err := sharding.BatchInsert(ctx, adminClient, db, scaleOutCollection, docs, loadOpts); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	if balanced, err := sharding.WaitForBalanced(ctx, adminClient, ns, time.Minute); err != nil || !balanced {
+		log.Printf("  [INFO] Not fully balanced before the add (err=%v); measuring from here anyway", err)
+	}
+
+	log.Println("")
+	log.Printf("Before adding %s:", spare.Name)
+	printShardState(ctx, adminClient, db, scaleOutCollection)
+
+	// Scale out
+	log.Println("")
+	log.Printf("Adding shard %s...", spare.Name)
+	added := time.Now()
+	if err := cluster.AddShard(ctx, adminClient, spare.Name, spare.Members); err != nil {
+		return fmt.Errorf("add shard: %w", err)
+	}
+
+	log.Println("")
+	log.Printf("Watching the balancer fill %s (up to %v)...", spare.Name, scaleOutTimeout)
+	report, err := MeasureMigrationRate(ctx, adminClient, ns, 5*time.Second, scaleOutTimeout)
+	if err != nil {
+		log.Printf("  [WARN] migration tracking: %v", err)
+	}
+	PrintMigrationReport(report)
+
+	log.Println("")
+	log.Printf("After adding %s:", spare.Name)
+	printShardState(ctx, adminClient, db, scaleOutCollection)
+	if dist, err := sharding.GetShardDistribution(ctx, adminClient, db, scaleOutCollection); err == nil {
+		if dist.Shards[spare.Name] > 0 {
+			log.Printf("  [OK] %s now holds %d docs, %v after joining", spare.Name, dist.Shards[spare.Name], time.Since(added).Round(time.Second))
+		} else {
+			log.Printf("  [WARN] %s holds no documents yet", spare.Name)
+		}
+	}
+
+	adminClient.Database(db).Collection(scaleOutCollection).Drop(ctx)
+
+	log.Println("")
+	log.Printf("Result: Cluster scaled out to %d shards; %s stays registered for the removal lab", len(shards)+1, spare.Name)
+	log.Println("")
+	return nil
+}
+
+// RunRemoveShardLab decommissions shardName (normally the spare shard the
+// add-shard lab brought in): it loads balanced data so the shard holds
+// chunks, makes it the primary of a scratch database, then drains it with
+// removeShard. Progress, jumbo chunks blocking the drain, and the moves of
+// databases it is primary for are reported until the removal completes,
+// after which document counts are checked against the pre-drain totals.
+func RunRemoveShardLab(ctx context.Context, adminClient *mongo.Client, db, shardName string, scale sharding.Scale) error {
+	docCount := scale.Count(drainDocCount)
+
+	log.Println("=== Remove Shard & Drain Lab ===")
+	log.Printf("Goal: Drain %s's chunks and databases onto the other shards, then remove it", shardName)
+	log.Println("")
+
+	shards, err := sharding.ShardNames(ctx, adminClient)
+	if err != nil {
+		return err
+	}
+	registered := false
+	for _, id := range shards {
+		registered = registered || id == shardName
+	}
+	if !registered {
+		log.Printf("  [SKIP] %s is not a shard; run the add-shard lab first", shardName)
+		log.Println("")
+		return nil
+	}
+	if len(shards) < 2 {
+		log.Printf("  [SKIP] %s is the only shard; nowhere to drain to", shardName)
+		log.Println("")
+		return nil
+	}
+	target, err := otherShard(ctx, adminClient, shardName)
+	if err != nil {
+		return err
+	}
+
+	// Data spread over every shard, including the one leaving
+	log.Printf("Loading %d docs across %d shards...", docCount, len(shards))
+	coll := adminClient.Database(db).Collection(drainCollection)
+	coll.Drop(ctx)
+	defer coll.Drop(ctx)
+	if err := sharding.ShardCollection(ctx, adminClient, db, drainCollection, bson.D{{Key: "_id", Value: "hashed"}}); err != nil {
+		return fmt.Errorf("shard collection: %w", err)
+	}
+	docs := make([]interface{}, docCount)
+	for i := range docs {
+		docs[i] = bson.M{"_id": fmt.Sprintf("drain_%06d", i), "data": sharding.Padding(scaleOutDocBytes)}
+	}
+	if err := sharding.BatchInsert(ctx, adminClient, db, drainCollection, docs, sharding.DefaultInsertOptions()); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+
+	// A database whose primary is the leaving shard must be moved off it
+	scratchDB := db + "_drain"
+	scratch := adminClient.Database(scratchDB).Collection("unsharded")
+	defer adminClient.Database(scratchDB).Drop(ctx)
+	if _, err := scratch.InsertOne(ctx, bson.M{"note": "primary shard data"}); err != nil {
+		return fmt.Errorf("create %s: %w", scratchDB, err)
+	}
+	if err := MovePrimary(ctx, adminClient, scratchDB, shardName); err != nil {
+		log.Printf("  [WARN] %v (the drain will not need to move a database)", err)
+	}
+
+	before, err := coll.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("count before drain: %w", err)
+	}
+	log.Println("")
+	log.Println("Before draining:")
+	printShardState(ctx, adminClient, db, drainCollection)
+
+	// Drain
+	log.Println("")
+	log.Printf("Draining %s (up to %v)...", shardName, drainTimeout)
+	start := time.Now()
+	deadline := start.Add(drainTimeout)
+	moved := map[string]bool{}
+	var status *cluster.RemoveShardStatus
+	for {
+		status, err = cluster.RemoveShard(ctx, adminClient, shardName)
+		if err != nil {
+			return err
+		}
+		log.Printf("    [%6v] state=%-9s chunks=%d dbs=%d jumbo=%d",
+			time.Since(start).Round(time.Second), status.State,
+			status.Remaining.Chunks, status.Remaining.DBs, status.Remaining.JumboChunks)
+		if status.State == "completed" {
+			break
+		}
+		if status.Remaining.JumboChunks > 0 {
+			log.Printf("  [WARN] %d jumbo chunk(s) cannot migrate and block the drain; split or clear them", status.Remaining.JumboChunks)
+		}
+		for _, name := range status.DBsToMove {
+			if !moved[name] {
+				moved[name] = true
+				if err := MovePrimary(ctx, adminClient, name, target); err != nil {
+					log.Printf("  [WARN] %v", err)
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			log.Printf("  [WARN] %s still draining after %v; removeShard keeps going, re-run it to finish", shardName, drainTimeout)
+			break
+		}
+
+		if !timeutil.Sleep(ctx, 5*time.Second) {
+			return ctx.Err()
+		}
+	}
+	elapsed := time.Since(start)
+
+	// Verify
+	log.Println("")
+	log.Println("After draining:")
+	printShardState(ctx, adminClient, db, drainCollection)
+	after, err := coll.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("count after drain: %w", err)
+	}
+	log.Printf("  Documents: %d before, %d after", before, after)
+	if after != before {
+		return fmt.Errorf("document count changed during drain: %d -> %d", before, after)
+	}
+	log.Println("  [OK] Every document preserved on the remaining shards")
+	if n, err := scratch.CountDocuments(ctx, bson.M{}); err == nil && n == 1 {
+		log.Printf("  [OK] %s's unsharded data moved with its primary", scratchDB)
+	}
+
+	if status.State != "completed" {
+		log.Println("")
+		log.Printf("Result: %s partially drained in %v", shardName, elapsed.Round(time.Second))
+		log.Println("")
+		return nil
+	}
+	log.Printf("  Drain duration: %v", elapsed.Round(time.Second))
+	log.Println("")
+	log.Printf("Result: %s drained and removed; cluster back to %d shards", shardName, len(shards)-1)
+	log.Println("")
+	return nil
+}
+
+// printShardState logs per-shard chunk and document counts for db.coll.
+func printShardState(ctx context.Context, client *mongo.Client, db, coll string) {
+	if info, err := GetChunkInfo(ctx, client, db+"."+coll); err != nil {
+		log.Printf("  [WARN] chunk info: %v", err)
+	} else {
+		PrintChunkReport(info)
+	}
+	if dist, err := sharding.GetShardDistribution(ctx, client, db, coll); err != nil {
+		log.Printf("  [WARN] distribution: %v", err)
+	} else {
+		sharding.PrintDistribution(dist)
+	}
+}
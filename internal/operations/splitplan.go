@@ -0,0 +1,111 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+// PlanSplits works out where to split ns so it ends up with targetChunks
+// chunks, without splitting anything. Candidate points are the shard key
+// values that cut a sample of the data into targetChunks ranges of equal
+// document count (sharding.SuggestSplitPoints); points that already are
+// chunk boundaries are dropped, and of the rest an evenly spread
+// targetChunks-current are kept. It returns nil when ns already has
+// targetChunks or more chunks. Hashed shard keys are not supported.
+func PlanSplits(ctx context.Context, client *mongo.Client, ns string, targetChunks int) ([]bson.D, error) {
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid namespace %q", ns)
+	}
+	if targetChunks < 2 {
+		return nil, fmt.Errorf("plan splits: targetChunks must be at least 2")
+	}
+
+	var meta struct {
+		Key  bson.D      `bson:"key"`
+		UUID interface{} `bson:"uuid"`
+	}
+	if err := client.Database("config").Collection("collections").
+		FindOne(ctx, bson.M{"_id": ns}).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("lookup shard key for %s: %w", ns, err)
+	}
+
+	bounds, err := chunkLowerBounds(ctx, client, ns, meta.UUID)
+	if err != nil {
+		return nil, err
+	}
+	needed := targetChunks - len(bounds)
+	if needed <= 0 {
+		return nil, nil
+	}
+
+	candidates, err := sharding.SuggestSplitPoints(ctx, client, db, coll, meta.Key, targetChunks-1)
+	if err != nil {
+		return nil, err
+	}
+	var fresh []bson.D
+	for _, p := range candidates {
+		if !containsBound(bounds, p) {
+			fresh = append(fresh, p)
+		}
+	}
+	if len(fresh) <= needed {
+		return fresh, nil
+	}
+
+	// Keep needed points spread across the candidates, centred in each stride
+	plan := make([]bson.D, 0, needed)
+	for i := 0; i < needed; i++ {
+		plan = append(plan, fresh[(2*i+1)*len(fresh)/(2*needed)])
+	}
+	return plan, nil
+}
+
+// PrintSplitPlan logs the split points PlanSplits proposed for ns.
+func PrintSplitPlan(ns string, currentChunks int64, plan []bson.D) {
+	if len(plan) == 0 {
+		log.Printf("  [INFO] No splits planned for %s (%d chunks)", ns, currentChunks)
+		return
+	}
+	log.Printf("  Split plan for %s (dry run): %d chunks -> %d", ns, currentChunks, currentChunks+int64(len(plan)))
+	for i, p := range plan {
+		log.Printf("    %d. split at %s", i+1, sharding.FormatKey(p))
+	}
+}
+
+// chunkLowerBounds returns the min bound of every chunk of ns.
+func chunkLowerBounds(ctx context.Context, client *mongo.Client, ns string, uuid interface{}) ([]bson.D, error) {
+	cursor, err := client.Database("config").Collection("chunks").Find(ctx, sharding.ChunksFilter(ns, uuid))
+	if err != nil {
+		return nil, fmt.Errorf("list chunks for %s: %w", ns, err)
+	}
+	var chunks []struct {
+		Min bson.D `bson:"min"`
+	}
+	if err := cursor.All(ctx, &chunks); err != nil {
+		return nil, fmt.Errorf("decode chunks for %s: %w", ns, err)
+	}
+
+	bounds := make([]bson.D, len(chunks))
+	for i, c := range chunks {
+		bounds[i] = c.Min
+	}
+	return bounds, nil
+}
+
+func containsBound(bounds []bson.D, point bson.D) bool {
+	for _, b := range bounds {
+		if reflect.DeepEqual(b, point) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+const taggedCollection = "tagged_reads_test"
+const taggedQueryCount = 10
+const taggedDocCount = 500
+
+// ConnectWithTags connects through mongos with a read preference restricted
+// to members matching the given tag sets. Sets are tried in order; an empty
+// set matches any eligible member. Primary mode does not accept tags.
+func ConnectWithTags(ctx context.Context, host, user, password string, mode readpref.Mode, tagSets ...tag.Set) (*mongo.Client, error) {
+	pref, err := readpref.New(mode, readpref.WithTagSets(tagSets...))
+	if err != nil {
+		return nil, fmt.Errorf("create readpref: %w", err)
+	}
+
+	uri, err := config.BuildURI(strings.Split(host, ","), user, password, "admin")
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(uri).
+		SetTimeout(30*time.Second).
+		SetReadPreference(pref))
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+	return client, nil
+}
+
+// RunTaggedReadsLab pins reads to secondaries carrying the given tags and
+// verifies via explain that each read was served by one of expectedHosts.
+func RunTaggedReadsLab(ctx context.Context, host, user, password, db string, tags tag.Set, expectedHosts []string, scale sharding.Scale) error {
+	log.Println("=== Tag-Set Read Routing Lab ===")
+	log.Printf("Goal: Route reads to secondaries tagged %s", formatTags(tags))
+	log.Println("")
+
+	client, err := ConnectWithTags(ctx, host, user, password, readpref.SecondaryMode, tags)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	// Majority write so tagged secondaries have the data before reading
+	coll := client.Database(db).Collection(taggedCollection,
+		options.Collection().SetWriteConcern(writeconcern.Majority()))
+	coll.Drop(ctx)
+
+	docs := make([]interface{}, scale.Count(taggedDocCount))
+	for i := range docs {
+		docs[i] = bson.M{
+			"_id":      fmt.Sprintf("tag_%04d", i),
+			"category": fmt.Sprintf("cat_%d", i%10),
+			"value":    i,
+		}
+	}
+	if _, err := coll.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("seed data: %w", err)
+	}
+	log.Printf("  [OK] %d test documents inserted (w: majority)", len(docs))
+
+	expected := make(map[string]bool, len(expectedHosts))
+	for _, h := range expectedHosts {
+		expected[h] = true
+	}
+
+	log.Println("")
+	log.Printf("Running %d tagged reads with explain...", taggedQueryCount)
+	hits := make(map[string]int)
+	mismatches := 0
+	for i := 0; i < taggedQueryCount; i++ {
+		filter := bson.M{"category": fmt.Sprintf("cat_%d", i%10)}
+
+		hosts, err := explainServingHosts(ctx, client, db, taggedCollection, filter)
+		if err != nil {
+			if strings.Contains(err.Error(), "Could not find host matching read preference") {
+				log.Printf("  [SKIP] No member matches %s", formatTags(tags))
+				log.Println("  Member tags are applied at replica set initiation; re-run setup on a fresh cluster")
+				coll.Drop(ctx)
+				log.Println("")
+				return nil
+			}
+			return fmt.Errorf("explain: %w", err)
+		}
+		for _, h := range hosts {
+			hits[h]++
+			if !expected[h] {
+				mismatches++
+			}
+		}
+	}
+
+	log.Println("")
+	log.Println("Reads served by:")
+	for h, n := range hits {
+		marker := "[OK]  "
+		if !expected[h] {
+			marker = "[WARN]"
+		}
+		log.Printf("  %s %-12s %d reads", marker, h, n)
+	}
+
+	if mismatches == 0 {
+		log.Printf("  [VERIFY] All reads were served by members tagged %s", formatTags(tags))
+	} else {
+		log.Printf("  [WARN] %d reads were served by untagged members", mismatches)
+	}
+
+	log.Println("")
+	log.Println("In a multi-region cluster, tagging members by region and reading")
+	log.Println("with the local region's tag keeps read latency local.")
+
+	coll.Drop(ctx)
+
+	log.Println("")
+	log.Println("Result: Reads pinned to tagged secondaries via read-preference tag sets")
+	log.Println("")
+	return nil
+}
+
+// explainServingHosts runs an explain of find with the client's read
+// preference and returns the host of every shard member that planned it.
+func explainServingHosts(ctx context.Context, client *mongo.Client, db, collection string, filter interface{}) ([]string, error) {
+	cmd := bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: collection},
+			{Key: "filter", Value: filter},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}
+
+	var result bson.M
+	err := client.Database(db).RunCommand(ctx, cmd,
+		options.RunCmd().SetReadPreference(client.Database(db).ReadPreference())).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	planner, _ := result["queryPlanner"].(bson.M)
+	winning, _ := planner["winningPlan"].(bson.M)
+	shards, _ := winning["shards"].(bson.A)
+	for _, s := range shards {
+		shard, ok := s.(bson.M)
+		if !ok {
+			continue
+		}
+		if info, ok := shard["serverInfo"].(bson.M); ok {
+			if h, ok := info["host"].(string); ok {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// formatTags renders a tag set as { dc: west }.
+func formatTags(tags tag.Set) string {
+	parts := make([]string, 0, len(tags))
+	for _, t := range tags {
+		parts = append(parts, t.Name+": "+t.Value)
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
@@ -0,0 +1,164 @@
+// Package router groups documents by the shard that owns them before they
+// are written, using a client-side copy of the collection's chunk map read
+// from config.chunks. Sending each shard its own batch keeps mongos from
+// fanning a mixed batch out to every shard, and lets a caller send each
+// batch through the mongos closest to that shard.
+//
+// The chunk map is a snapshot. After a split, a migration or a reshard it
+// can name the wrong shard for some documents; mongos still routes every
+// write by its own routing table, so a stale map costs locality, never
+// correctness. Router reloads the map once it is older than its max age and
+// the collection's chunk version has moved on.
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+// Chunk is one [Min, Max) shard key range and the shard that owns it.
+type Chunk struct {
+	Min   bson.Raw
+	Max   bson.Raw
+	Shard string
+}
+
+// ChunkMap is a snapshot of a collection's chunks, sorted by Min.
+type ChunkMap struct {
+	Namespace string
+	Key       bson.D
+	Chunks    []Chunk
+	UUID      primitive.Binary    // collection UUID; changes when it is dropped or resharded
+	Version   primitive.Timestamp // highest chunk lastmod when loaded
+	LoadedAt  time.Time
+}
+
+// LoadChunkMap reads the shard key and every chunk of ns from the config
+// database. Hashed shard keys are rejected: their chunk bounds are hash
+// values that only the server can compute for a document.
+func LoadChunkMap(ctx context.Context, client *mongo.Client, ns string) (*ChunkMap, error) {
+	var coll struct {
+		Key  bson.D           `bson:"key"`
+		UUID primitive.Binary `bson:"uuid"`
+	}
+	err := client.Database("config").Collection("collections").FindOne(ctx, bson.D{
+		{Key: "_id", Value: ns},
+		{Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}},
+	}).Decode(&coll)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%s is not sharded", ns)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookup shard key for %s: %w", ns, err)
+	}
+	for _, k := range coll.Key {
+		if k.Value == "hashed" {
+			return nil, fmt.Errorf("%s: hashed shard key field %q cannot be routed client-side", ns, k.Key)
+		}
+	}
+
+	cursor, err := client.Database("config").Collection("chunks").Find(ctx,
+		sharding.ChunksFilter(ns, coll.UUID), options.Find().SetSort(bson.D{{Key: "min", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("read chunks for %s: %w", ns, err)
+	}
+	defer cursor.Close(ctx)
+
+	m := &ChunkMap{Namespace: ns, Key: coll.Key, UUID: coll.UUID, LoadedAt: time.Now()}
+	for cursor.Next(ctx) {
+		var c struct {
+			Min     bson.Raw            `bson:"min"`
+			Max     bson.Raw            `bson:"max"`
+			Shard   string              `bson:"shard"`
+			Lastmod primitive.Timestamp `bson:"lastmod"`
+		}
+		if err := cursor.Decode(&c); err != nil {
+			return nil, fmt.Errorf("decode chunk: %w", err)
+		}
+		m.Chunks = append(m.Chunks, Chunk{Min: c.Min, Max: c.Max, Shard: c.Shard})
+		if m.Version.Before(c.Lastmod) {
+			m.Version = c.Lastmod
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("read chunks for %s: %w", ns, err)
+	}
+	if len(m.Chunks) == 0 {
+		return nil, fmt.Errorf("no chunks found for %s", ns)
+	}
+	return m, nil
+}
+
+// Changed reports whether the collection was recreated or any of its
+// chunks was split or moved since m was loaded.
+func (m *ChunkMap) Changed(ctx context.Context, client *mongo.Client) (bool, error) {
+	var coll struct {
+		UUID primitive.Binary `bson:"uuid"`
+	}
+	err := client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": m.Namespace}).Decode(&coll)
+	if err == mongo.ErrNoDocuments {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("lookup %s: %w", m.Namespace, err)
+	}
+	if !coll.UUID.Equal(m.UUID) {
+		return true, nil
+	}
+
+	var latest struct {
+		Lastmod primitive.Timestamp `bson:"lastmod"`
+	}
+	err = client.Database("config").Collection("chunks").FindOne(ctx, sharding.ChunksFilter(m.Namespace, m.UUID),
+		options.FindOne().SetSort(bson.D{{Key: "lastmod", Value: -1}})).Decode(&latest)
+	if err != nil {
+		return false, fmt.Errorf("read chunk version for %s: %w", m.Namespace, err)
+	}
+	return !latest.Lastmod.Equal(m.Version), nil
+}
+
+// ShardFor returns the shard whose chunk contains doc's shard key. A
+// missing key field counts as null, as it does on the server.
+func (m *ChunkMap) ShardFor(doc bson.Raw) (string, error) {
+	key := make([]bson.RawValue, len(m.Key))
+	for i, k := range m.Key {
+		v, err := doc.LookupErr(strings.Split(k.Key, ".")...)
+		if err != nil {
+			v = bson.RawValue{Type: bson.TypeNull}
+		}
+		key[i] = v
+	}
+
+	// The owning chunk is the last one whose Min is <= key
+	i := sort.Search(len(m.Chunks), func(i int) bool {
+		return compareKey(key, m.Chunks[i].Min) < 0
+	}) - 1
+	if i < 0 {
+		return "", fmt.Errorf("%s: shard key below the first chunk", m.Namespace)
+	}
+	return m.Chunks[i].Shard, nil
+}
+
+// Group splits docs into one batch per owning shard, keeping their order
+// within each batch.
+func (m *ChunkMap) Group(docs []bson.Raw) (map[string][]interface{}, error) {
+	groups := make(map[string][]interface{})
+	for _, doc := range docs {
+		shard, err := m.ShardFor(doc)
+		if err != nil {
+			return nil, err
+		}
+		groups[shard] = append(groups[shard], doc)
+	}
+	return groups, nil
+}
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func bound(t *testing.T, d bson.D) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal %v: %v", d, err)
+	}
+	return raw
+}
+
+func testChunkMap(t *testing.T) *ChunkMap {
+	b := func(region interface{}, id interface{}) bson.Raw {
+		return bound(t, bson.D{{Key: "region", Value: region}, {Key: "customer_id", Value: id}})
+	}
+	return &ChunkMap{
+		Namespace: "d.c",
+		Key:       bson.D{{Key: "region", Value: 1}, {Key: "customer_id", Value: 1}},
+		Chunks: []Chunk{
+			{Min: b(primitive.MinKey{}, primitive.MinKey{}), Max: b("EU", primitive.MinKey{}), Shard: "shard3rs"},
+			{Min: b("EU", primitive.MinKey{}), Max: b("EU", int64(5000)), Shard: "shard1rs"},
+			{Min: b("EU", int64(5000)), Max: b("US", primitive.MinKey{}), Shard: "shard2rs"},
+			{Min: b("US", primitive.MinKey{}), Max: b(primitive.MaxKey{}, primitive.MaxKey{}), Shard: "shard3rs"},
+		},
+	}
+}
+
+func TestShardFor(t *testing.T) {
+	m := testChunkMap(t)
+	tests := []struct {
+		doc  bson.D
+		want string
+	}{
+		{bson.D{{Key: "region", Value: "APAC"}, {Key: "customer_id", Value: 1}}, "shard3rs"},
+		{bson.D{{Key: "region", Value: "EU"}, {Key: "customer_id", Value: int32(1)}}, "shard1rs"},
+		{bson.D{{Key: "region", Value: "EU"}, {Key: "customer_id", Value: 4999.5}}, "shard1rs"},
+		{bson.D{{Key: "region", Value: "EU"}, {Key: "customer_id", Value: int64(5000)}}, "shard2rs"},
+		{bson.D{{Key: "region", Value: "EU"}, {Key: "customer_id", Value: "x"}}, "shard2rs"}, // strings sort after numbers
+		{bson.D{{Key: "region", Value: "US"}, {Key: "customer_id", Value: 7}}, "shard3rs"},
+		{bson.D{{Key: "customer_id", Value: 7}}, "shard3rs"}, // missing region is null, below every string
+	}
+	for _, tt := range tests {
+		got, err := m.ShardFor(bound(t, tt.doc))
+		if err != nil {
+			t.Errorf("ShardFor(%v): %v", tt.doc, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ShardFor(%v) = %s, want %s", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	m := testChunkMap(t)
+	docs := []bson.Raw{
+		bound(t, bson.D{{Key: "region", Value: "EU"}, {Key: "customer_id", Value: 1}}),
+		bound(t, bson.D{{Key: "region", Value: "US"}, {Key: "customer_id", Value: 1}}),
+		bound(t, bson.D{{Key: "region", Value: "EU"}, {Key: "customer_id", Value: 2}}),
+	}
+	groups, err := m.Group(docs)
+	if err != nil {
+		t.Fatalf("Group: %v", err)
+	}
+	if len(groups) != 2 || len(groups["shard1rs"]) != 2 || len(groups["shard3rs"]) != 1 {
+		t.Fatalf("groups = %v, want 2 docs on shard1rs and 1 on shard3rs", groups)
+	}
+	if first := groups["shard1rs"][0].(bson.Raw); !bytesEqual(first, docs[0]) {
+		t.Error("group order not preserved")
+	}
+}
+
+func bytesEqual(a, b []byte) bool {
+	return string(a) == string(b)
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// compareKey compares a shard key tuple with a chunk bound field by field.
+func compareKey(key []bson.RawValue, bound bson.Raw) int {
+	values, err := bound.Values()
+	if err != nil {
+		return 0
+	}
+	for i, v := range key {
+		if i >= len(values) {
+			return 1
+		}
+		if c := compareValues(v, values[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// typeRank is MongoDB's canonical BSON sort order across types: MinKey,
+// null, numbers, strings, objects, arrays, binary, ObjectId, booleans,
+// dates, timestamps, regular expressions, MaxKey.
+func typeRank(t bsontype.Type) int {
+	switch t {
+	case bson.TypeMinKey:
+		return 0
+	case bson.TypeNull, bson.TypeUndefined:
+		return 1
+	case bson.TypeInt32, bson.TypeInt64, bson.TypeDouble, bson.TypeDecimal128:
+		return 2
+	case bson.TypeString, bson.TypeSymbol:
+		return 3
+	case bson.TypeEmbeddedDocument:
+		return 4
+	case bson.TypeArray:
+		return 5
+	case bson.TypeBinary:
+		return 6
+	case bson.TypeObjectID:
+		return 7
+	case bson.TypeBoolean:
+		return 8
+	case bson.TypeDateTime:
+		return 9
+	case bson.TypeTimestamp:
+		return 10
+	case bson.TypeRegex:
+		return 11
+	case bson.TypeMaxKey:
+		return 13
+	default:
+		return 12
+	}
+}
+
+// compareValues orders two BSON values the way the server orders shard key
+// values under the simple collation. Types without a natural order here
+// (documents, arrays, regexes, ...) fall back to comparing their encoded
+// bytes, which is enough to keep them in a stable position.
+func compareValues(a, b bson.RawValue) int {
+	ra, rb := typeRank(a.Type), typeRank(b.Type)
+	if ra != rb {
+		return compareInts(int64(ra), int64(rb))
+	}
+
+	switch ra {
+	case 0, 1, 13:
+		return 0
+	case 2:
+		return compareNumbers(a, b)
+	case 3:
+		return bytes.Compare([]byte(stringValue(a)), []byte(stringValue(b)))
+	case 7:
+		oa, ob := a.ObjectID(), b.ObjectID()
+		return bytes.Compare(oa[:], ob[:])
+	case 8:
+		return compareInts(boolInt(a.Boolean()), boolInt(b.Boolean()))
+	case 9:
+		return compareInts(a.DateTime(), b.DateTime())
+	case 10:
+		ta, ia := a.Timestamp()
+		tb, ib := b.Timestamp()
+		if ta != tb {
+			return compareInts(int64(ta), int64(tb))
+		}
+		return compareInts(int64(ia), int64(ib))
+	}
+	return bytes.Compare(a.Value, b.Value)
+}
+
+// compareNumbers compares numeric values across int32, int64 and double,
+// exactly when both are integers.
+func compareNumbers(a, b bson.RawValue) int {
+	ia, aInt := a.AsInt64OK()
+	ib, bInt := b.AsInt64OK()
+	if aInt && bInt && a.Type != bson.TypeDouble && b.Type != bson.TypeDouble {
+		return compareInts(ia, ib)
+	}
+	fa, fb := numberFloat(a), numberFloat(b)
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	case math.IsNaN(fa) && !math.IsNaN(fb):
+		return -1 // NaN sorts below every other number
+	case !math.IsNaN(fa) && math.IsNaN(fb):
+		return 1
+	}
+	return 0
+}
+
+func numberFloat(v bson.RawValue) float64 {
+	switch v.Type {
+	case bson.TypeDouble:
+		return v.Double()
+	case bson.TypeInt32:
+		return float64(v.Int32())
+	case bson.TypeInt64:
+		return float64(v.Int64())
+	case bson.TypeDecimal128:
+		if f, err := strconv.ParseFloat(v.Decimal128().String(), 64); err == nil {
+			return f
+		}
+	}
+	return math.NaN()
+}
+
+func stringValue(v bson.RawValue) string {
+	if v.Type == bson.TypeSymbol {
+		return v.Symbol()
+	}
+	return v.StringValue()
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,143 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultMaxAge is how long a chunk map is used before Router checks the
+// config database for splits and migrations.
+const DefaultMaxAge = 30 * time.Second
+
+// Router inserts documents in per-shard batches using a cached chunk map.
+// It is safe for concurrent use.
+type Router struct {
+	client *mongo.Client // reads config.collections and config.chunks
+	ns     string
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	chunks *ChunkMap
+	colls  map[string]*mongo.Collection // per-shard write path, e.g. via a nearby mongos
+}
+
+// New loads the chunk map of ns through client and returns a Router that
+// refreshes it after maxAge (DefaultMaxAge when zero).
+func New(ctx context.Context, client *mongo.Client, ns string, maxAge time.Duration) (*Router, error) {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	chunks, err := LoadChunkMap(ctx, client, ns)
+	if err != nil {
+		return nil, err
+	}
+	return &Router{
+		client: client,
+		ns:     ns,
+		maxAge: maxAge,
+		chunks: chunks,
+		colls:  make(map[string]*mongo.Collection),
+	}, nil
+}
+
+// UseCollection sends batches for shard through coll, typically a handle
+// on a client connected to the mongos closest to that shard. Shards without
+// one use the collection passed to InsertMany.
+func (r *Router) UseCollection(shard string, coll *mongo.Collection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.colls[shard] = coll
+}
+
+// ChunkMap returns the chunk map currently in use.
+func (r *Router) ChunkMap() *ChunkMap {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.chunks
+}
+
+// InsertMany groups docs by owning shard and writes each group as one
+// unordered InsertMany, all groups in parallel. A failing group does not
+// cancel the others; InsertMany returns how many documents were inserted
+// and every group's error, joined.
+func (r *Router) InsertMany(ctx context.Context, coll *mongo.Collection, docs []bson.Raw) (int64, error) {
+	chunks := r.refresh(ctx)
+	groups, err := chunks.Group(docs)
+	if err != nil {
+		return 0, err
+	}
+
+	var mu sync.Mutex
+	var inserted int64
+	var errs []error
+	var wg sync.WaitGroup
+	for shard, batch := range groups {
+		target := r.collectionFor(shard, coll)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := target.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+			mu.Lock()
+			defer mu.Unlock()
+			if res != nil {
+				inserted += int64(len(res.InsertedIDs))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("insert %d docs for %s: %w", len(batch), shard, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return inserted, errors.Join(errs...)
+}
+
+// refresh reloads the chunk map when it is older than maxAge and the
+// collection's chunks have changed. A failed check keeps the old map: it
+// only affects grouping, and mongos corrects any misrouted document.
+func (r *Router) refresh(ctx context.Context) *ChunkMap {
+	r.mu.Lock()
+	chunks := r.chunks
+	r.mu.Unlock()
+	if time.Since(chunks.LoadedAt) < r.maxAge {
+		return chunks
+	}
+
+	changed, err := chunks.Changed(ctx, r.client)
+	if err == nil && changed {
+		var fresh *ChunkMap
+		if fresh, err = LoadChunkMap(ctx, r.client, r.ns); err == nil {
+			chunks = fresh
+		}
+	}
+	if err != nil || !changed {
+		if err != nil {
+			log.Printf("[WARN] router %s: keeping cached chunk map: %v", r.ns, err)
+		}
+		// Still current (or unknown): check again after another maxAge
+		renewed := *chunks
+		renewed.LoadedAt = time.Now()
+		chunks = &renewed
+	}
+
+	r.mu.Lock()
+	r.chunks = chunks
+	r.mu.Unlock()
+	return chunks
+}
+
+func (r *Router) collectionFor(shard string, fallback *mongo.Collection) *mongo.Collection {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.colls[shard]; ok {
+		return c
+	}
+	return fallback
+}
@@ -0,0 +1,41 @@
+// Package runner runs the steps of the lab and demo commands and turns
+// their failures into the command's exit code.
+package runner
+
+import (
+	"log"
+	"strings"
+)
+
+// Results records which steps failed so a command can keep going after a
+// failure and still exit non-zero. Kind names a step in the log ("lab",
+// "demo"); the zero value says "step".
+type Results struct {
+	Kind   string
+	failed []string
+}
+
+// Run calls fn and records name as failed if it returns an error.
+func (r *Results) Run(name string, fn func() error) {
+	if err := fn(); err != nil {
+		log.Printf("[ERROR] %s %s failed: %v", name, r.kind(), err)
+		r.failed = append(r.failed, name)
+	}
+}
+
+// Summarize logs the failed steps, if any, and returns the exit code.
+func (r *Results) Summarize() int {
+	if len(r.failed) == 0 {
+		log.Printf("[OK] Every %s passed", r.kind())
+		return 0
+	}
+	log.Printf("[FAIL] %d %s(s) failed: %s", len(r.failed), r.kind(), strings.Join(r.failed, ", "))
+	return 1
+}
+
+func (r *Results) kind() string {
+	if r.Kind == "" {
+		return "step"
+	}
+	return r.Kind
+}
@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResultsSummarize(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		want int
+	}{
+		{"none run", nil, 0},
+		{"all pass", []error{nil, nil}, 0},
+		{"one fails", []error{nil, errors.New("boom"), nil}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Results{Kind: "demo"}
+			ran := 0
+			for _, err := range tt.errs {
+				r.Run("step", func() error { ran++; return err })
+			}
+			if ran != len(tt.errs) {
+				t.Errorf("ran %d steps, want %d: a failure must not stop later steps", ran, len(tt.errs))
+			}
+			if got := r.Summarize(); got != tt.want {
+				t.Errorf("Summarize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
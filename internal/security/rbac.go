@@ -9,6 +9,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/config"
 )
 
 // CreateAppUser creates a readWrite user on the given database.
@@ -84,7 +86,10 @@ func createUser(ctx context.Context, client *mongo.Client, db, user, pwd, role s
 
 // connectAs creates a client authenticated as the given user.
 func connectAs(ctx context.Context, host, authDB, user, pwd string) (*mongo.Client, error) {
-	uri := fmt.Sprintf("mongodb://%s:%s@%s/?authSource=%s", user, pwd, host, authDB)
+	uri, err := config.BuildURI(strings.Split(host, ","), user, pwd, authDB)
+	if err != nil {
+		return nil, err
+	}
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		return nil, fmt.Errorf("connect as '%s': %w", user, err)
@@ -4,24 +4,41 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/timeutil"
 )
 
 // ShardDistribution holds document counts per shard for a collection.
 type ShardDistribution struct {
-	Collection string
-	Shards     map[string]int64
-	Total      int64
+	Collection string           `json:"collection"`
+	Sharded    bool             `json:"sharded"` // false: every document is on the database's primary shard
+	Shards     map[string]int64 `json:"shards"`
+	Sizes      map[string]int64 `json:"sizes,omitempty"` // data size in bytes per shard
+	Total      int64            `json:"total"`
 }
 
 // GetShardDistribution returns how documents are distributed across shards.
+// An unsharded collection is reported with Sharded false and its single
+// primary shard, rather than as a maximally skewed sharded collection.
 func GetShardDistribution(ctx context.Context, client *mongo.Client, db, collection string) (*ShardDistribution, error) {
 	dist := &ShardDistribution{
 		Collection: collection,
 		Shards:     make(map[string]int64),
+		Sizes:      make(map[string]int64),
+	}
+
+	key, err := CollectionShardKey(ctx, client, db, collection)
+	if err != nil {
+		return nil, err
 	}
+	dist.Sharded = key != nil
 
 	// Use $collStats aggregation to get per-shard doc counts
 	pipeline := mongo.Pipeline{
@@ -41,13 +58,15 @@ func GetShardDistribution(ctx context.Context, client *mongo.Client, db, collect
 		}
 
 		shard := stringVal(doc, "shard")
-		count := int64(0)
+		count, size := int64(0), int64(0)
 		if stats, ok := doc["storageStats"].(bson.M); ok {
 			count = intVal(stats, "count")
+			size = intVal(stats, "size")
 		}
 
 		if shard != "" {
 			dist.Shards[shard] = count
+			dist.Sizes[shard] = size
 			dist.Total += count
 		}
 	}
@@ -58,6 +77,9 @@ func GetShardDistribution(ctx context.Context, client *mongo.Client, db, collect
 // PrintDistribution logs a formatted distribution report.
 func PrintDistribution(dist *ShardDistribution) {
 	log.Printf("  Collection: %s (total: %d)", dist.Collection, dist.Total)
+	if !dist.Sharded {
+		log.Println("  [INFO] Collection is not sharded: all documents live on the database's primary shard")
+	}
 	for shard, count := range dist.Shards {
 		pct := float64(0)
 		if dist.Total > 0 {
@@ -67,7 +89,99 @@ func PrintDistribution(dist *ShardDistribution) {
 	}
 }
 
+// DefaultMaxDeviation is how far (as a fraction of the even share) a shard
+// may stray before a distribution is reported as uneven. It leaves room for
+// hashing noise and a balancer that has not finished its rounds.
+const DefaultMaxDeviation = 0.25
+
+// CheckEvenDistribution verifies that each of shardCount shards holds
+// within maxDeviation of an even Total/shardCount share. Shards missing
+// from dist count as holding nothing. The error names the worst shard.
+func CheckEvenDistribution(dist *ShardDistribution, shardCount int, maxDeviation float64) error {
+	if shardCount <= 0 {
+		return fmt.Errorf("no shards to compare against")
+	}
+	if dist.Total == 0 {
+		return fmt.Errorf("%s is empty", dist.Collection)
+	}
+	if len(dist.Shards) > shardCount {
+		return fmt.Errorf("%s has documents on %d shards, expected at most %d", dist.Collection, len(dist.Shards), shardCount)
+	}
+
+	expected := float64(dist.Total) / float64(shardCount)
+	worstShard, worst := maxDeviationFromEven(dist, shardCount)
+	if worst > maxDeviation {
+		return fmt.Errorf("%s is uneven: %s is %.0f%% off the expected %.0f docs per shard (limit %.0f%%)",
+			dist.Collection, worstShard, worst*100, expected, maxDeviation*100)
+	}
+	return nil
+}
+
+// WaitForBalanced polls balancerCollectionStatus until the balancer reports
+// ns as compliant (no migrations pending) or timeout elapses. It reports
+// whether the collection settled.
+func WaitForBalanced(ctx context.Context, client *mongo.Client, ns string, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var status struct {
+			BalancerCompliant bool `bson:"balancerCompliant"`
+		}
+		if err := client.Database("admin").RunCommand(ctx, bson.D{
+			{Key: "balancerCollectionStatus", Value: ns},
+		}).Decode(&status); err != nil {
+			return false, fmt.Errorf("balancerCollectionStatus %s: %w", ns, err)
+		}
+		if status.BalancerCompliant {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+
+		if !timeutil.Sleep(ctx, 2*time.Second) {
+			return false, ctx.Err()
+		}
+	}
+}
+
+// BalanceScore rates how evenly dist is spread over shardCount shards:
+// 1 is perfectly even, 0 means some shard is off by a full even share
+// (e.g. holds nothing). Scores are clamped to [0, 1].
+func BalanceScore(dist *ShardDistribution, shardCount int) float64 {
+	if dist == nil || dist.Total == 0 || shardCount <= 0 {
+		return 0
+	}
+	_, worst := maxDeviationFromEven(dist, shardCount)
+	return math.Max(0, 1-worst)
+}
+
+// maxDeviationFromEven returns the shard furthest from an even share and
+// its deviation as a fraction of that share. Shards missing from dist count
+// as empty. dist.Total and shardCount must be positive.
+func maxDeviationFromEven(dist *ShardDistribution, shardCount int) (string, float64) {
+	expected := float64(dist.Total) / float64(shardCount)
+	worstShard, worst := "", 0.0
+	for shard, count := range dist.Shards {
+		if dev := math.Abs(float64(count)-expected) / expected; dev > worst {
+			worstShard, worst = shard, dev
+		}
+	}
+	if len(dist.Shards) < shardCount && worst < 1 {
+		// An empty shard is a full 100% below its share
+		worstShard, worst = "(empty shard)", 1
+	}
+	return worstShard, worst
+}
+
+// CountShards returns the number of shards registered with the cluster.
+func CountShards(ctx context.Context, client *mongo.Client) (int, error) {
+	names, err := ShardNames(ctx, client)
+	return len(names), err
+}
+
 // ExplainQuery runs explain on a find query and returns targeted shard names.
+// For an unsharded collection that is the database's primary shard, even
+// when explain itself lists none.
 func ExplainQuery(ctx context.Context, client *mongo.Client, db, collection string, filter bson.D) ([]string, error) {
 	cmd := bson.D{
 		{Key: "explain", Value: bson.D{
@@ -82,11 +196,113 @@ func ExplainQuery(ctx context.Context, client *mongo.Client, db, collection stri
 		return nil, fmt.Errorf("explain: %w", err)
 	}
 
-	return extractTargetedShards(result), nil
+	shards := extractTargetedShards(result)
+	if len(shards) == 0 {
+		key, err := CollectionShardKey(ctx, client, db, collection)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			primary, err := primaryShard(ctx, client, db)
+			if err != nil {
+				return nil, err
+			}
+			shards = []string{primary}
+		}
+	}
+	return shards, nil
+}
+
+// CollectionShardKey returns the shard key of db.collection from
+// config.collections, or nil when the collection is not sharded.
+func CollectionShardKey(ctx context.Context, client *mongo.Client, db, collection string) (bson.D, error) {
+	meta, err := shardedCollection(ctx, client, db+"."+collection)
+	if meta == nil || err != nil {
+		return nil, err
+	}
+	return meta.Key, nil
+}
+
+// collectionMeta is the part of a config.collections entry the package reads.
+type collectionMeta struct {
+	Key  bson.D      `bson:"key"`
+	UUID interface{} `bson:"uuid"` // absent before MongoDB 5.0
+}
+
+// shardedCollection returns the config.collections entry of ns, or nil
+// when ns is not sharded.
+func shardedCollection(ctx context.Context, client *mongo.Client, ns string) (*collectionMeta, error) {
+	var meta collectionMeta
+	err := client.Database("config").Collection("collections").FindOne(ctx, bson.D{
+		{Key: "_id", Value: ns},
+		{Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}},
+	}).Decode(&meta)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookup shard key for %s: %w", ns, err)
+	}
+	return &meta, nil
+}
+
+// ChunksFilter matches the config.chunks entries of ns, which are keyed by
+// the collection uuid from MongoDB 5.0 and by ns before. uuid may be nil
+// when it is unknown.
+func ChunksFilter(ns string, uuid interface{}) bson.D {
+	if uuid == nil {
+		return bson.D{{Key: "ns", Value: ns}}
+	}
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "uuid", Value: uuid}},
+		bson.D{{Key: "ns", Value: ns}},
+	}}}
+}
+
+// NamespaceChunksFilter is ChunksFilter for ns with the uuid looked up in
+// config.collections; an unsharded ns gets the ns-only filter.
+func NamespaceChunksFilter(ctx context.Context, client *mongo.Client, ns string) (bson.D, error) {
+	meta, err := shardedCollection(ctx, client, ns)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return ChunksFilter(ns, nil), nil
+	}
+	return ChunksFilter(ns, meta.UUID), nil
+}
+
+// primaryShard returns the primary shard of db, which holds all of its
+// unsharded collections.
+func primaryShard(ctx context.Context, client *mongo.Client, db string) (string, error) {
+	var meta struct {
+		Primary string `bson:"primary"`
+	}
+	if err := client.Database("config").Collection("databases").FindOne(ctx, bson.M{"_id": db}).Decode(&meta); err != nil {
+		return "", fmt.Errorf("lookup primary shard for %s: %w", db, err)
+	}
+	return meta.Primary, nil
 }
 
 // ShardCollection creates a shard key on a collection via the admin command.
-func ShardCollection(ctx context.Context, client *mongo.Client, db, collection string, key bson.D) error {
+// The supporting index is created and verified first so a missing index
+// surfaces as a clear error rather than a cryptic shardCollection failure.
+// WithKeyValidation adds a check of the existing data's insert order first.
+func ShardCollection(ctx context.Context, client *mongo.Client, db, collection string, key bson.D, opts ...ShardCollectionOption) error {
+	var o shardCollectionOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.keySamples > 0 {
+		if err := checkShardKey(ctx, client, db, collection, key, o.keySamples); err != nil {
+			return fmt.Errorf("shardCollection %s.%s: %w", db, collection, err)
+		}
+	}
+
+	if err := EnsureShardKeyIndex(ctx, client, db, collection, key); err != nil {
+		return err
+	}
+
 	ns := db + "." + collection
 	cmd := bson.D{
 		{Key: "shardCollection", Value: ns},
@@ -115,6 +331,38 @@ func ShardCollectionHashed(ctx context.Context, client *mongo.Client, db, collec
 	return nil
 }
 
+// ShardCollectionUnique shards a collection on a ranged key with unique:true,
+// so MongoDB enforces uniqueness of the full shard key across every shard.
+// The supporting unique index is created first; hashed keys cannot be unique.
+func ShardCollectionUnique(ctx context.Context, client *mongo.Client, db, collection string, key bson.D) error {
+	for _, k := range key {
+		if k.Value == "hashed" {
+			return fmt.Errorf("shardCollection (unique) %s.%s: hashed key field %q cannot be unique", db, collection, k.Key)
+		}
+	}
+
+	_, err := client.Database(db).Collection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    key,
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("create unique shard key index %s on %s.%s: %w", FormatKey(key), db, collection, err)
+	}
+
+	ns := db + "." + collection
+	cmd := bson.D{
+		{Key: "shardCollection", Value: ns},
+		{Key: "key", Value: key},
+		{Key: "unique", Value: true},
+	}
+
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("shardCollection (unique) %s: %w", ns, err)
+	}
+	return nil
+}
+
 // RefineShardKey adds a suffix field to an existing shard key.
 func RefineShardKey(ctx context.Context, client *mongo.Client, db, collection string, newKey bson.D) error {
 	ns := db + "." + collection
@@ -135,6 +383,79 @@ func DropCollection(ctx context.Context, client *mongo.Client, db, collection st
 	client.Database(db).Collection(collection).Drop(ctx)
 }
 
+// DropShardedCollection drops db.collection through appClient, then checks
+// the config metadata with adminClient so the namespace can be sharded again
+// with a different key. Zone ranges always survive a drop and are removed.
+// If an older server left config.collections or config.chunks entries, the
+// namespace is dropped again through adminClient and the routers' cached
+// routing table flushed; metadata that survives that is an error, since
+// config documents are never edited directly. The same applies to the
+// system.buckets namespace behind a time-series collection.
+func DropShardedCollection(ctx context.Context, adminClient, appClient *mongo.Client, db, collection string) error {
+	if err := appClient.Database(db).Collection(collection).Drop(ctx); err != nil {
+		return fmt.Errorf("drop %s.%s: %w", db, collection, err)
+	}
+
+	for _, ns := range []string{db + "." + collection, db + ".system.buckets." + collection} {
+		if err := removeZoneRanges(ctx, adminClient, ns); err != nil {
+			return err
+		}
+		if err := clearShardingMetadata(ctx, adminClient, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearShardingMetadata makes sure no config.collections or config.chunks
+// entries still describe ns after its drop. Leftovers are cleaned up the
+// supported way, with another drop and a flushRouterConfig, and an error
+// is returned if they survive.
+func clearShardingMetadata(ctx context.Context, client *mongo.Client, ns string) error {
+	chunks, found, err := shardingMetadata(ctx, client, ns)
+	if err != nil || !found {
+		return err
+	}
+
+	log.Printf("  [WARN] %s still has sharding metadata after drop (%d chunks); dropping it again", ns, chunks)
+	db, coll, _ := strings.Cut(ns, ".")
+	if err := client.Database(db).Collection(coll).Drop(ctx); err != nil {
+		return fmt.Errorf("drop %s again: %w", ns, err)
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "flushRouterConfig", Value: ns}}).Err(); err != nil {
+		return fmt.Errorf("flushRouterConfig %s: %w", ns, err)
+	}
+
+	chunks, found, err = shardingMetadata(ctx, client, ns)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%s still has sharding metadata after a second drop (%d chunks); it cannot be resharded until the config servers clear it", ns, chunks)
+	}
+	return nil
+}
+
+// shardingMetadata counts the config.chunks entries of ns and reports
+// whether any metadata, chunks or a live config.collections entry, is
+// left. Chunks are matched by the collection UUID (5.0+) and by ns
+// (earlier versions).
+func shardingMetadata(ctx context.Context, client *mongo.Client, ns string) (int64, bool, error) {
+	meta, err := shardedCollection(ctx, client, ns)
+	if err != nil {
+		return 0, false, err
+	}
+	var uuid interface{}
+	if meta != nil {
+		uuid = meta.UUID
+	}
+	chunks, err := client.Database("config").Collection("chunks").CountDocuments(ctx, ChunksFilter(ns, uuid))
+	if err != nil {
+		return 0, false, fmt.Errorf("check config.chunks for %s: %w", ns, err)
+	}
+	return chunks, meta != nil || chunks > 0, nil
+}
+
 // extractTargetedShards pulls shard names from an explain result.
 func extractTargetedShards(result bson.M) []string {
 	var shards []string
@@ -165,7 +486,12 @@ func stringVal(m bson.M, key string) string {
 }
 
 func intVal(m bson.M, key string) int64 {
-	switch v := m[key].(type) {
+	return ToInt64(m[key])
+}
+
+// ToInt64 converts any BSON numeric type to int64, and anything else to 0.
+func ToInt64(val interface{}) int64 {
+	switch v := val.(type) {
 	case int64:
 		return v
 	case int32:
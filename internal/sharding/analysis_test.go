@@ -0,0 +1,72 @@
+package sharding
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func dist(shards map[string]int64) *ShardDistribution {
+	d := &ShardDistribution{Collection: "orders", Sharded: true, Shards: shards}
+	for _, n := range shards {
+		d.Total += n
+	}
+	return d
+}
+
+func TestCheckEvenDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		dist    *ShardDistribution
+		shards  int
+		maxDev  float64
+		wantErr string // substring; empty means no error
+	}{
+		{"even", dist(map[string]int64{"shard1": 100, "shard2": 100, "shard3": 100}), 3, 0.25, ""},
+		{"within limit", dist(map[string]int64{"shard1": 120, "shard2": 90, "shard3": 90}), 3, 0.25, ""},
+		{"at limit", dist(map[string]int64{"shard1": 125, "shard2": 75}), 2, 0.25, ""},
+		{"over limit", dist(map[string]int64{"shard1": 150, "shard2": 50}), 2, 0.25, "shard1 is 50% off"},
+		{"empty shard", dist(map[string]int64{"shard1": 100, "shard2": 100}), 3, 0.25, "(empty shard) is 100% off"},
+		{"too many shards", dist(map[string]int64{"shard1": 1, "shard2": 1, "shard3": 1}), 2, 0.25, "on 3 shards, expected at most 2"},
+		{"empty collection", dist(map[string]int64{}), 2, 0.25, "orders is empty"},
+		{"no shards", dist(map[string]int64{"shard1": 10}), 0, 0.25, "no shards"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckEvenDistribution(tt.dist, tt.shards, tt.maxDev)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("CheckEvenDistribution: unexpected error %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("CheckEvenDistribution: got nil, want error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("CheckEvenDistribution: error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBalanceScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		dist   *ShardDistribution
+		shards int
+		want   float64
+	}{
+		{"even", dist(map[string]int64{"shard1": 100, "shard2": 100}), 2, 1},
+		{"quarter off", dist(map[string]int64{"shard1": 125, "shard2": 75}), 2, 0.75},
+		{"all on one of two", dist(map[string]int64{"shard1": 200}), 2, 0},
+		{"empty shard", dist(map[string]int64{"shard1": 150, "shard2": 150}), 3, 0},
+		{"clamped", dist(map[string]int64{"shard1": 300, "shard2": 0, "shard3": 0}), 3, 0},
+		{"nil", nil, 2, 0},
+		{"empty collection", dist(map[string]int64{}), 2, 0},
+		{"no shards", dist(map[string]int64{"shard1": 10}), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BalanceScore(tt.dist, tt.shards); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("BalanceScore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const changeStreamCollection = "orders_changestream"
+const changeStreamWrites = 60
+
+// changeStreamReadTimeout bounds the wait for every expected event.
+const changeStreamReadTimeout = 30 * time.Second
+
+// resumableChangeStreamCodes are server errors raised while a shard elects a
+// new primary or a node shuts down; a change stream reopened from its resume
+// token on the new primary picks up where it left off.
+var resumableChangeStreamCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	133,   // FailedToSatisfyReadPreference
+	189,   // PrimarySteppedDown
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// IsResumableChangeStreamError reports whether a change stream that failed
+// with err is worth reopening from its last resume token. The driver retries
+// some of these once internally; this covers what is left over when an
+// election takes longer than that single retry.
+func IsResumableChangeStreamError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var se mongo.ServerError
+	if errors.As(err, &se) {
+		if se.HasErrorLabel("ResumableChangeStreamError") {
+			return true
+		}
+		for _, code := range resumableChangeStreamCodes {
+			if se.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RunChangeStreamDemo demonstrates that a change stream on a sharded
+// collection is one ordered feed. Sequential writes land on different shards
+// under { _id: "hashed" }; mongos merges each shard's oplog by cluster time,
+// so the events must arrive in write order with non-decreasing clusterTime.
+// The write count is not scaled: the demo checks ordering, not volume.
+func RunChangeStreamDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, _ Scale) (*DemoResult, error) {
+	log.Println("=== Change Stream Ordering Demo ===")
+	log.Println("Goal: One globally ordered event feed across shards")
+	result := startDemo("changestream", db, coll)
+	defer result.finish()
+
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
+
+	if err := ShardCollectionHashed(ctx, adminClient, db, coll, "_id"); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
+	}
+	log.Println("Shard key: { _id: 'hashed' }")
+
+	orders := appClient.Database(db).Collection(coll)
+	stream, err := orders.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		return result, fmt.Errorf("open change stream: %w", err)
+	}
+	defer stream.Close(ctx)
+
+	// Each write is acknowledged before the next, so write order is causal order
+	log.Printf("Writing %d orders one at a time...", changeStreamWrites)
+	shardOf := make(map[int]string, changeStreamWrites)
+	for i := 0; i < changeStreamWrites; i++ {
+		doc := bson.M{"_id": i, "seq": i, "amount": float64(10 + i)}
+		if _, err := orders.InsertOne(ctx, doc); err != nil {
+			return result, fmt.Errorf("insert %d: %w", i, err)
+		}
+		loc, err := LocateDocument(ctx, adminClient, db, coll, doc)
+		if err != nil {
+			log.Printf("  [WARN] locate %d: %v", i, err)
+			continue
+		}
+		shardOf[i] = loc.Shard
+	}
+
+	log.Println("Reading change events...")
+	readCtx, cancel := context.WithTimeout(ctx, changeStreamReadTimeout)
+	defer cancel()
+
+	var seqs []int
+	var lastTime primitive.Timestamp
+	outOfOrder := 0
+	for len(seqs) < changeStreamWrites && stream.Next(readCtx) {
+		var event struct {
+			OperationType string              `bson:"operationType"`
+			ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+			DocumentKey   struct {
+				ID int `bson:"_id"`
+			} `bson:"documentKey"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			return result, fmt.Errorf("decode event: %w", err)
+		}
+		if event.OperationType != "insert" {
+			continue
+		}
+		if event.ClusterTime.Before(lastTime) {
+			log.Printf("  [WARN] event for %d has clusterTime %v before %v", event.DocumentKey.ID, event.ClusterTime, lastTime)
+			outOfOrder++
+		}
+		lastTime = event.ClusterTime
+		seqs = append(seqs, event.DocumentKey.ID)
+	}
+	if err := stream.Err(); err != nil && readCtx.Err() == nil {
+		return result, fmt.Errorf("change stream: %w", err)
+	}
+	if len(seqs) < changeStreamWrites {
+		return result, fmt.Errorf("received %d of %d change events within %v", len(seqs), changeStreamWrites, changeStreamReadTimeout)
+	}
+
+	// Count how often consecutive events came from different shards
+	switches := 0
+	for i := 1; i < len(seqs); i++ {
+		if a, b := shardOf[seqs[i-1]], shardOf[seqs[i]]; a != "" && b != "" && a != b {
+			switches++
+		}
+	}
+	shards := make(map[string]bool)
+	for _, shard := range shardOf {
+		shards[shard] = true
+	}
+	for shard := range shards {
+		result.TargetedShards = append(result.TargetedShards, shard)
+	}
+	sort.Strings(result.TargetedShards)
+	log.Printf("  Events: %d from shards %v, %d shard switches between consecutive events",
+		len(seqs), result.TargetedShards, switches)
+
+	for i, seq := range seqs {
+		if seq != i {
+			log.Printf("  [WARN] event %d is for write %d", i, seq)
+			outOfOrder++
+		}
+	}
+
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
+	if err != nil {
+		return result, fmt.Errorf("distribution: %w", err)
+	}
+	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
+
+	if outOfOrder > 0 {
+		return result, fmt.Errorf("%d change events arrived out of write order", outOfOrder)
+	}
+	log.Println("  [VERIFY] Events arrived in write order with non-decreasing clusterTime")
+	if len(shards) < 2 {
+		log.Println("  [INFO] All writes landed on one shard; ordering was not exercised across shards")
+	}
+
+	log.Println("Result: mongos merges per-shard change streams into one cluster-time ordered feed")
+	log.Println("")
+	return result, nil
+}
@@ -16,31 +16,32 @@ const tenantCount = 5
 // RunCompoundDemo demonstrates compound shard keys for multi-tenant workloads.
 // Uses { tenant_id: 1, user_id: 1 } to ensure tenant data spreads across
 // shards and no single chunk becomes a "jumbo chunk."
-func RunCompoundDemo(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+func RunCompoundDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(compoundDocCount)
+
 	log.Println("=== Compound Shard Key Demo ===")
 	log.Println("Goal: Multi-tenant isolation without jumbo chunks")
+	result := startDemo("compound", db, coll)
+	defer result.finish()
 
-	DropCollection(ctx, appClient, db, compoundCollection)
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
 
 	// Create compound shard key
 	key := bson.D{
 		{Key: "tenant_id", Value: 1},
 		{Key: "user_id", Value: 1},
 	}
-	if err := ShardCollection(ctx, adminClient, db, compoundCollection, key); err != nil {
-		return fmt.Errorf("shard collection: %w", err)
+	if err := ShardCollection(ctx, adminClient, db, coll, key); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
 	}
 	log.Println("Shard key: { tenant_id: 1, user_id: 1 }")
 
-	// Create supporting index
-	appClient.Database(db).Collection(compoundCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: key,
-	})
-
 	// Insert orders across 5 tenants with varying user counts
-	log.Printf("Inserting %d orders across %d tenants...", compoundDocCount, tenantCount)
-	docs := make([]interface{}, compoundDocCount)
-	for i := 0; i < compoundDocCount; i++ {
+	log.Printf("Inserting %d orders across %d tenants...", docCount, tenantCount)
+	docs := make([]interface{}, docCount)
+	for i := 0; i < docCount; i++ {
 		tenantID := fmt.Sprintf("tenant_%d", (i%tenantCount)+1)
 		userID := fmt.Sprintf("user_%06d", i)
 		docs[i] = bson.M{
@@ -52,26 +53,46 @@ func RunCompoundDemo(ctx context.Context, adminClient, appClient *mongo.Client,
 		}
 	}
 
-	if err := batchInsert(ctx, appClient, db, compoundCollection, docs); err != nil {
-		return fmt.Errorf("insert: %w", err)
+	loadOpts := ParallelInsertOptions()
+	loadOpts.Progress = LogProgress
+	if err := BatchInsert(ctx, appClient, db, coll, docs, loadOpts); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
 	}
 
 	// Analyze overall distribution
-	dist, err := GetShardDistribution(ctx, adminClient, db, compoundCollection)
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
 	if err != nil {
-		return fmt.Errorf("distribution: %w", err)
+		return result, fmt.Errorf("distribution: %w", err)
 	}
 	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
 
 	// Show per-tenant counts
 	log.Println("Per-tenant document counts:")
-	coll := appClient.Database(db).Collection(compoundCollection)
+	orders := appClient.Database(db).Collection(coll)
 	for t := 1; t <= tenantCount; t++ {
 		tenantID := fmt.Sprintf("tenant_%d", t)
-		count, _ := coll.CountDocuments(ctx, bson.M{"tenant_id": tenantID})
+		count, _ := orders.CountDocuments(ctx, bson.M{"tenant_id": tenantID})
 		log.Printf("    %-12s %d docs", tenantID, count)
 	}
 
+	// Show how a per-tenant aggregation is split between shards and merger
+	log.Println("Explaining per-tenant revenue aggregation...")
+	aggPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "tenant_id", Value: "tenant_1"}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$product"},
+			{Key: "revenue", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "revenue", Value: -1}}}},
+	}
+	if plan, err := ExplainAggregate(ctx, adminClient, db, coll, aggPipeline); err != nil {
+		log.Printf("  Explain: %v", err)
+	} else {
+		PrintAggPlan(plan)
+		result.TargetedShards = plan.TargetedShards
+	}
+
 	// Check for jumbo chunk risk
 	maxPct := float64(0)
 	for _, count := range dist.Shards {
@@ -88,5 +109,5 @@ func RunCompoundDemo(ctx context.Context, adminClient, appClient *mongo.Client,
 
 	log.Println("Result: Compound key distributes multi-tenant data evenly")
 	log.Println("")
-	return nil
+	return result, nil
 }
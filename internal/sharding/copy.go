@@ -0,0 +1,64 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const copyBatchSize = 1000
+
+// CopyCollection streams every document from srcDB.srcColl into
+// dstDB.dstColl using unordered batch inserts. transform, if non-nil, is
+// applied to each document before insert; returning nil skips it.
+// This supports the "create a collection with a better shard key, copy,
+// swap" migration: shard the destination first, then copy into it.
+func CopyCollection(ctx context.Context, client *mongo.Client, srcDB, srcColl, dstDB, dstColl string, transform func(bson.M) bson.M) error {
+	src := client.Database(srcDB).Collection(srcColl)
+	dst := client.Database(dstDB).Collection(dstColl)
+
+	cursor, err := src.Find(ctx, bson.D{}, options.Find().SetBatchSize(copyBatchSize))
+	if err != nil {
+		return fmt.Errorf("find %s.%s: %w", srcDB, srcColl, err)
+	}
+	defer cursor.Close(ctx)
+
+	insertOpts := options.InsertMany().SetOrdered(false)
+	batch := make([]interface{}, 0, copyBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := dst.InsertMany(ctx, batch, insertOpts); err != nil {
+			return fmt.Errorf("insert into %s.%s: %w", dstDB, dstColl, err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		if transform != nil {
+			doc = transform(doc)
+			if doc == nil {
+				continue
+			}
+		}
+		batch = append(batch, doc)
+		if len(batch) == copyBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor %s.%s: %w", srcDB, srcColl, err)
+	}
+	return flush()
+}
@@ -0,0 +1,106 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const copySwapCollection = "orders_copyswap"
+const copySwapDocCount = 5000
+const copySwapLiveWrites = 100
+
+// RunCopySwapDemo changes a shard key the way clusters before MongoDB 5.0
+// had to, without reshardCollection: shard a new collection with the
+// better key, send live writes to both through a DualWriter, backfill the
+// old data with CopyCollection, verify, then cut reads over and drop the
+// old collection. coll is the new collection; the old one is coll+"_old".
+func RunCopySwapDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(copySwapDocCount)
+	oldColl := coll + "_old"
+
+	log.Println("=== Copy-and-Swap Demo ===")
+	log.Println("Goal: Move to a new shard key by copying into a new collection")
+	result := startDemo("copyswap", db, coll)
+	defer result.finish()
+
+	for _, c := range []string{oldColl, coll} {
+		if err := DropShardedCollection(ctx, adminClient, appClient, db, c); err != nil {
+			return result, err
+		}
+	}
+
+	if err := ShardCollection(ctx, adminClient, db, oldColl, bson.D{{Key: "customer_id", Value: 1}}); err != nil {
+		return result, fmt.Errorf("shard %s: %w", oldColl, err)
+	}
+	log.Printf("Old collection %s: { customer_id: 1 }", oldColl)
+
+	docs := make([]interface{}, docCount)
+	for i := range docs {
+		docs[i] = bson.M{
+			"_id":         fmt.Sprintf("ORD-%08d", i),
+			"customer_id": fmt.Sprintf("cust_%04d", i%50),
+			"amount":      float64(10 + (i % 300)),
+		}
+	}
+	if err := batchInsert(ctx, appClient, db, oldColl, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
+	}
+
+	if err := ShardCollection(ctx, adminClient, db, coll, bson.D{{Key: "_id", Value: "hashed"}}); err != nil {
+		return result, fmt.Errorf("shard %s: %w", coll, err)
+	}
+	log.Printf("New collection %s: { _id: 'hashed' }", coll)
+
+	// From here on every write goes to both collections
+	writer := NewDualWriter(appClient, db+"."+oldColl, db+"."+coll)
+	for i := 0; i < copySwapLiveWrites; i++ {
+		doc := bson.M{
+			"_id":         fmt.Sprintf("ORD-LIVE-%04d", i),
+			"customer_id": fmt.Sprintf("cust_%04d", i%50),
+			"amount":      float64(10 + (i % 300)),
+			"dual_write":  true,
+		}
+		if err := writer.Write(ctx, doc); err != nil {
+			return result, fmt.Errorf("dual write: %w", err)
+		}
+	}
+	log.Printf("  [OK] %d live writes sent to both collections", copySwapLiveWrites)
+
+	// Backfill everything else; dual-written documents are already there
+	log.Printf("Backfilling %s into %s...", oldColl, coll)
+	skipDualWritten := func(doc bson.M) bson.M {
+		if doc["dual_write"] == true {
+			return nil
+		}
+		return doc
+	}
+	if err := CopyCollection(ctx, appClient, db, oldColl, db, coll, skipDualWritten); err != nil {
+		return result, fmt.Errorf("backfill: %w", err)
+	}
+
+	report, err := writer.Cutover(ctx)
+	if err != nil {
+		return result, err
+	}
+	log.Printf("  [VERIFY] %s and %s both hold %d documents; reads now use %s",
+		oldColl, coll, report.NewCount, writer.ReadCollection().Name())
+
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, oldColl); err != nil {
+		log.Printf("  [WARN] drop %s: %v", oldColl, err)
+	}
+
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
+	if err != nil {
+		return result, fmt.Errorf("distribution: %w", err)
+	}
+	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
+
+	log.Println("Result: Shard key changed by copy, dual writes, and cutover")
+	log.Println("")
+	return result, nil
+}
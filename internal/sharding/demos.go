@@ -0,0 +1,125 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Demo is one sharding strategy demo and the collection it manages.
+type Demo struct {
+	Name       string // selector used by the -demos flag
+	Title      string // label used in logs
+	Collection string // default collection, dropped and recreated on each run
+	Run        func(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error)
+}
+
+// demos is the single list of strategy demos, in run order.
+var demos = []Demo{
+	{"hashed", "Hashed", hashedCollection, RunHashedDemo},
+	{"ranged", "Ranged", rangedCollection, RunRangedDemo},
+	{"compound", "Compound", compoundCollection, RunCompoundDemo},
+	{"refinable", "Refinable", refinableCollection, RunRefinableDemo},
+	{"zones", "Zone-Based", zoneCollection, RunZoneDemo},
+	{"reshard", "Reshard", reshardCollection, RunReshardDemo},
+	{"copyswap", "Copy-and-Swap", copySwapCollection, RunCopySwapDemo},
+	{"timeseries", "Time-Series", timeSeriesCollection, RunTimeSeriesDemo},
+	{"unique", "Unique Index", uniqueCollection, RunUniqueDemo},
+	{"changestream", "Change Stream", changeStreamCollection, RunChangeStreamDemo},
+}
+
+// Demos returns every strategy demo in run order.
+func Demos() []Demo {
+	return append([]Demo(nil), demos...)
+}
+
+// SelectDemos parses a comma-separated list of demo names ("" or "all"
+// selects every demo) and returns them in run order. Unknown names are an
+// error listing the valid ones.
+func SelectDemos(names string) ([]Demo, error) {
+	names = strings.TrimSpace(names)
+	if names == "" || names == "all" {
+		return Demos(), nil
+	}
+
+	byName := make(map[string]bool)
+	for _, n := range strings.Split(names, ",") {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" {
+			continue
+		}
+		if !isDemo(n) {
+			return nil, fmt.Errorf("unknown demo %q (valid: %s)", n, strings.Join(demoNames(), ", "))
+		}
+		byName[n] = true
+	}
+	if len(byName) == 0 {
+		return nil, fmt.Errorf("no demos selected (valid: %s)", strings.Join(demoNames(), ", "))
+	}
+
+	var selected []Demo
+	for _, d := range demos {
+		if byName[d.Name] {
+			selected = append(selected, d)
+		}
+	}
+	return selected, nil
+}
+
+// CleanupDemo drops db.coll and removes any sharding metadata the demo
+// left behind, so a rerun starts from a clean catalog. See
+// DropShardedCollection.
+func CleanupDemo(ctx context.Context, adminClient *mongo.Client, db, coll string) error {
+	return DropShardedCollection(ctx, adminClient, adminClient, db, coll)
+}
+
+// removeZoneRanges clears every zone key range on ns. A range is removed by
+// re-issuing updateZoneKeyRange for it with a null zone.
+func removeZoneRanges(ctx context.Context, client *mongo.Client, ns string) error {
+	cursor, err := client.Database("config").Collection("tags").Find(ctx, bson.M{"ns": ns})
+	if err != nil {
+		return fmt.Errorf("read config.tags for %s: %w", ns, err)
+	}
+	var ranges []struct {
+		Min bson.Raw `bson:"min"`
+		Max bson.Raw `bson:"max"`
+	}
+	if err := cursor.All(ctx, &ranges); err != nil {
+		return fmt.Errorf("decode config.tags for %s: %w", ns, err)
+	}
+
+	for _, r := range ranges {
+		cmd := bson.D{
+			{Key: "updateZoneKeyRange", Value: ns},
+			{Key: "min", Value: r.Min},
+			{Key: "max", Value: r.Max},
+			{Key: "zone", Value: nil},
+		}
+		if err := client.Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+			return fmt.Errorf("remove zone range on %s: %w", ns, err)
+		}
+	}
+	return nil
+}
+
+func isDemo(name string) bool {
+	for _, d := range demos {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func demoNames() []string {
+	names := make([]string, 0, len(demos))
+	for _, d := range demos {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+	return names
+}
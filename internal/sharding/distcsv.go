@@ -0,0 +1,90 @@
+package sharding
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// distributionCSVHeader is the column layout written by SampleDistribution.
+var distributionCSVHeader = []string{"timestamp", "namespace", "shard", "count", "size_bytes"}
+
+// finalSampleTimeout bounds the sample SampleDistribution takes after its
+// context ends.
+const finalSampleTimeout = 10 * time.Second
+
+// SampleDistribution records the per-shard distribution of db.collection to
+// w as CSV every interval until ctx is done, so balancer convergence can be
+// charted after a load. Each sample writes one row per shard, all sharing
+// the same RFC 3339 timestamp. A final sample is taken when ctx ends.
+// Failed samples are logged and skipped; only write errors are returned.
+func SampleDistribution(ctx context.Context, client *mongo.Client, db, collection string, interval time.Duration, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(distributionCSVHeader); err != nil {
+		return fmt.Errorf("write CSV header: %w", err)
+	}
+
+	sample := func(ctx context.Context) error {
+		dist, err := GetShardDistribution(ctx, client, db, collection)
+		if err != nil {
+			log.Printf("  [WARN] distribution sample: %v", err)
+			return nil
+		}
+		return writeDistributionRows(cw, time.Now(), db, dist)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sample(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			// ctx is spent, so the final sample gets a short fresh deadline
+			finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalSampleTimeout)
+			defer cancel()
+			return sample(finalCtx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeDistributionRows writes one CSV row per shard in dist, sorted by
+// shard name, and flushes so partial runs still leave usable output.
+func writeDistributionRows(cw *csv.Writer, at time.Time, db string, dist *ShardDistribution) error {
+	shards := make([]string, 0, len(dist.Shards))
+	for shard := range dist.Shards {
+		shards = append(shards, shard)
+	}
+	sort.Strings(shards)
+
+	ts := at.UTC().Format(time.RFC3339)
+	ns := db + "." + dist.Collection
+	for _, shard := range shards {
+		row := []string{
+			ts,
+			ns,
+			shard,
+			strconv.FormatInt(dist.Shards[shard], 10),
+			strconv.FormatInt(dist.Sizes[shard], 10),
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write CSV row: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("flush CSV: %w", err)
+	}
+	return nil
+}
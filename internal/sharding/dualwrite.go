@@ -0,0 +1,237 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxReportedMissing caps the _id values kept in a DualWriteReport.
+const maxReportedMissing = 20
+
+// DualWriteReport compares the old and new collections of a migration.
+type DualWriteReport struct {
+	OldCount int64
+	NewCount int64
+	Missing  []interface{} // _ids in old but not in new (first maxReportedMissing)
+	Match    bool
+}
+
+// DualWriter migrates to a new shard key without resharding (pre-5.0):
+// shard the new collection with the better key, write every change to both
+// collections, backfill with CopyCollection, verify, then cut reads over.
+// RunCopySwapDemo walks through it.
+type DualWriter struct {
+	client  *mongo.Client
+	oldNS   string
+	newNS   string
+	cutover atomic.Bool
+}
+
+// NewDualWriter returns a DualWriter that reads from oldNS until Cutover.
+func NewDualWriter(client *mongo.Client, oldNS, newNS string) *DualWriter {
+	return &DualWriter{client: client, oldNS: oldNS, newNS: newNS}
+}
+
+// Write inserts doc into both collections; see DualWrite.
+func (w *DualWriter) Write(ctx context.Context, doc interface{}) error {
+	return DualWrite(ctx, w.client, w.oldNS, w.newNS, doc)
+}
+
+// Verify compares the two collections; see VerifyDualWrite.
+func (w *DualWriter) Verify(ctx context.Context) (*DualWriteReport, error) {
+	return VerifyDualWrite(ctx, w.client, w.oldNS, w.newNS)
+}
+
+// Cutover switches reads to the new collection once verification passes.
+// It refuses to switch while the collections differ.
+func (w *DualWriter) Cutover(ctx context.Context) (*DualWriteReport, error) {
+	report, err := w.Verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Match {
+		return report, fmt.Errorf("cutover refused: %s has %d docs, %s has %d (%d missing)",
+			w.oldNS, report.OldCount, w.newNS, report.NewCount, len(report.Missing))
+	}
+	w.cutover.Store(true)
+	return report, nil
+}
+
+// ReadCollection is the collection reads should use: old before Cutover,
+// new after.
+func (w *DualWriter) ReadCollection() *mongo.Collection {
+	ns := w.oldNS
+	if w.cutover.Load() {
+		ns = w.newNS
+	}
+	db, coll, _ := strings.Cut(ns, ".")
+	return w.client.Database(db).Collection(coll)
+}
+
+// DualWrite inserts doc into both oldNS and newNS inside a transaction so
+// the collections never diverge. Deployments without transaction support
+// fall back to best-effort sequential writes, which VerifyDualWrite checks.
+func DualWrite(ctx context.Context, client *mongo.Client, oldNS, newNS string, doc interface{}) error {
+	oldColl, err := nsCollection(client, oldNS)
+	if err != nil {
+		return err
+	}
+	newColl, err := nsCollection(client, newNS)
+	if err != nil {
+		return err
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := oldColl.InsertOne(sc, doc); err != nil {
+			return nil, fmt.Errorf("insert into %s: %w", oldNS, err)
+		}
+		if _, err := newColl.InsertOne(sc, doc); err != nil {
+			return nil, fmt.Errorf("insert into %s: %w", newNS, err)
+		}
+		return nil, nil
+	})
+	if err == nil || !transactionsUnsupported(err) {
+		return err
+	}
+
+	// Best effort: old first, since it is still the source of truth
+	if _, err := oldColl.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("insert into %s: %w", oldNS, err)
+	}
+	if _, err := newColl.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("insert into %s (old write succeeded): %w", newNS, err)
+	}
+	return nil
+}
+
+// VerifyDualWrite compares document counts and checks that every _id in
+// oldNS exists in newNS. Match is true only when both agree.
+func VerifyDualWrite(ctx context.Context, client *mongo.Client, oldNS, newNS string) (*DualWriteReport, error) {
+	oldColl, err := nsCollection(client, oldNS)
+	if err != nil {
+		return nil, err
+	}
+	newColl, err := nsCollection(client, newNS)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DualWriteReport{}
+	if report.OldCount, err = oldColl.CountDocuments(ctx, bson.D{}); err != nil {
+		return nil, fmt.Errorf("count %s: %w", oldNS, err)
+	}
+	if report.NewCount, err = newColl.CountDocuments(ctx, bson.D{}); err != nil {
+		return nil, fmt.Errorf("count %s: %w", newNS, err)
+	}
+
+	cursor, err := oldColl.Find(ctx, bson.D{},
+		options.Find().SetProjection(bson.M{"_id": 1}).SetBatchSize(copyBatchSize))
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", oldNS, err)
+	}
+	defer cursor.Close(ctx)
+
+	ids := make([]interface{}, 0, copyBatchSize)
+	check := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		missing, err := missingIDs(ctx, newColl, ids)
+		if err != nil {
+			return fmt.Errorf("check %s: %w", newNS, err)
+		}
+		for _, id := range missing {
+			if len(report.Missing) < maxReportedMissing {
+				report.Missing = append(report.Missing, id)
+			}
+		}
+		ids = ids[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		ids = append(ids, doc.ID)
+		if len(ids) == copyBatchSize {
+			if err := check(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor %s: %w", oldNS, err)
+	}
+	if err := check(); err != nil {
+		return nil, err
+	}
+
+	report.Match = report.OldCount == report.NewCount && len(report.Missing) == 0
+	return report, nil
+}
+
+// missingIDs returns the ids not present in coll.
+func missingIDs(ctx context.Context, coll *mongo.Collection, ids []interface{}) ([]interface{}, error) {
+	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	found := make(map[string]bool, len(ids))
+	for cursor.Next(ctx) {
+		found[cursor.Current.Lookup("_id").String()] = true
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []interface{}
+	for _, id := range ids {
+		t, raw, err := bson.MarshalValue(id)
+		if err != nil {
+			return nil, err
+		}
+		if !found[bson.RawValue{Type: t, Value: raw}.String()] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// nsCollection resolves a "db.collection" namespace.
+func nsCollection(client *mongo.Client, ns string) (*mongo.Collection, error) {
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok || db == "" || coll == "" {
+		return nil, fmt.Errorf("invalid namespace %q", ns)
+	}
+	return client.Database(db).Collection(coll), nil
+}
+
+// transactionsUnsupported reports whether err means the deployment cannot
+// run multi-document transactions (standalone, or pre-4.2 sharded cluster).
+func transactionsUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 { // IllegalOperation
+		return true
+	}
+	return strings.Contains(err.Error(), "Transaction numbers are only allowed")
+}
@@ -0,0 +1,274 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AggPlan describes how mongos routes an aggregation on a sharded collection.
+type AggPlan struct {
+	TargetedShards []string // Shards that run the shard part of the pipeline
+	MergeType      string   // Where results are merged: mongos, primaryShard, anyShard, specificShard
+	ShardsPart     []string // Stage names executed on each targeted shard
+	MergerPart     []string // Stage names executed on the merging node
+	HasOutputStage bool     // Pipeline ends in $merge or $out
+	UsesPrimary    bool     // Explain reports the merge on the database's primary shard
+}
+
+// ExplainAggregate runs explain on an aggregate command and reports its shard routing.
+func ExplainAggregate(ctx context.Context, client *mongo.Client, db, collection string, pipeline mongo.Pipeline) (*AggPlan, error) {
+	cmd := bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "aggregate", Value: collection},
+			{Key: "pipeline", Value: pipeline},
+			{Key: "cursor", Value: bson.D{}},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}
+
+	var result bson.M
+	if err := client.Database(db).RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return nil, fmt.Errorf("explain aggregate: %w", err)
+	}
+
+	plan := &AggPlan{
+		MergeType: stringVal(result, "mergeType"),
+	}
+
+	// shards: { <shardName>: { ... } } lists every shard the pipeline reached
+	if shards, ok := result["shards"].(bson.M); ok {
+		for name := range shards {
+			plan.TargetedShards = append(plan.TargetedShards, name)
+		}
+		sort.Strings(plan.TargetedShards)
+	}
+
+	// splitPipeline is present when part of the pipeline must run after merging
+	if split, ok := result["splitPipeline"].(bson.M); ok {
+		plan.ShardsPart = stageNames(split["shardsPart"])
+		plan.MergerPart = stageNames(split["mergerPart"])
+	}
+
+	for _, stage := range pipeline {
+		if len(stage) == 0 {
+			continue
+		}
+		switch stage[0].Key {
+		case "$merge", "$out":
+			plan.HasOutputStage = true
+		}
+	}
+	if plan.MergeType == "primaryShard" {
+		plan.UsesPrimary = true
+	}
+
+	return plan, nil
+}
+
+// PrintAggPlan logs a formatted aggregation routing report.
+func PrintAggPlan(plan *AggPlan) {
+	log.Printf("  Targeted shards: %v", plan.TargetedShards)
+	log.Printf("  Merge type:      %s", plan.MergeType)
+	if len(plan.ShardsPart) > 0 || len(plan.MergerPart) > 0 {
+		log.Printf("  Shards part:     %v", plan.ShardsPart)
+		log.Printf("  Merger part:     %v", plan.MergerPart)
+	}
+	if plan.HasOutputStage {
+		log.Println("  Output stage:    $merge/$out present (writes routed via the merging node)")
+	}
+	if plan.UsesPrimary {
+		log.Println("  Primary shard:   results are merged on the database's primary shard")
+	}
+}
+
+// stageNames extracts the operator name of each stage in an explain pipeline part.
+func stageNames(part interface{}) []string {
+	stages, ok := part.(bson.A)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(stages))
+	for _, s := range stages {
+		if m, ok := s.(bson.M); ok {
+			for k := range m {
+				names = append(names, k)
+			}
+		}
+	}
+	return names
+}
+
+// QueryRouting describes how mongos routed a find query.
+type QueryRouting struct {
+	Shards       []string // Shards the query was sent to
+	TotalShards  int      // Shards registered in the cluster
+	OwningShards int      // Shards holding chunks of the collection; 0 when unsharded
+	Stage        string   // Top-level winning plan stage (SINGLE_SHARD, SHARD_MERGE, ...)
+	Sharded      bool     // False when the collection lives unsharded on its primary shard
+	ShardKey     bson.D   // Collection's shard key; nil when the collection is unsharded
+	KeyPrefix    []string // Leading shard key fields the filter constrains, in key order
+}
+
+// Targeted reports whether the query was routed to exactly one shard.
+func (r *QueryRouting) Targeted() bool {
+	return len(r.Shards) == 1
+}
+
+// PrefixTargeted reports whether the filter constrains a prefix of the
+// shard key, so mongos routes it by chunk ranges. Such a query may still
+// reach every shard when the matching ranges happen to span them all, as
+// with { tenant_id: X } on a { tenant_id: 1, user_id: 1 } key and a large
+// tenant, but it is not a broadcast.
+func (r *QueryRouting) PrefixTargeted() bool {
+	return len(r.KeyPrefix) > 0
+}
+
+// ScatterGather reports whether the query was broadcast to every shard
+// that owns chunks of the collection because its filter does not constrain
+// the shard key. Shards without chunks of the collection never receive
+// its queries, so they do not count.
+func (r *QueryRouting) ScatterGather() bool {
+	return r.Sharded && r.OwningShards > 1 && len(r.Shards) >= r.OwningShards && !r.PrefixTargeted()
+}
+
+// ExplainQueryRouting explains a find and reports which shards it touches
+// relative to the whole cluster and to the shards owning the collection's
+// chunks, to detect scatter-gather queries. It also reads the collection's
+// shard key so queries on a key prefix are told apart from broadcasts.
+func ExplainQueryRouting(ctx context.Context, client *mongo.Client, db, collection string, filter interface{}) (*QueryRouting, error) {
+	cmd := bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: collection},
+			{Key: "filter", Value: filter},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}
+
+	var result bson.M
+	if err := client.Database(db).RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return nil, fmt.Errorf("explain: %w", err)
+	}
+
+	routing := &QueryRouting{
+		Shards: extractTargetedShards(result),
+	}
+	if qp, ok := result["queryPlanner"].(bson.M); ok {
+		if wp, ok := qp["winningPlan"].(bson.M); ok {
+			routing.Stage = stringVal(wp, "stage")
+		}
+	}
+
+	totalShards, err := CountShards(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	routing.TotalShards = totalShards
+
+	meta, err := shardedCollection(ctx, client, db+"."+collection)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		// Unsharded: mongos sends the query to the primary shard, which
+		// explain does not always name
+		if len(routing.Shards) == 0 {
+			primary, err := primaryShard(ctx, client, db)
+			if err != nil {
+				return nil, err
+			}
+			routing.Shards = []string{primary}
+		}
+		return routing, nil
+	}
+
+	routing.Sharded = true
+	routing.ShardKey = meta.Key
+	routing.KeyPrefix, err = shardKeyPrefix(meta.Key, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := client.Database("config").Collection("chunks").
+		Distinct(ctx, "shard", ChunksFilter(db+"."+collection, meta.UUID))
+	if err != nil {
+		return nil, fmt.Errorf("list shards owning %s.%s: %w", db, collection, err)
+	}
+	routing.OwningShards = len(owners)
+	return routing, nil
+}
+
+// shardKeyPrefix returns the leading fields of key that filter constrains
+// at its top level. Equality and $in constrain any field; range operators
+// only constrain ranged fields and end the prefix, since later fields no
+// longer narrow the chunk ranges.
+func shardKeyPrefix(key bson.D, filter interface{}) ([]string, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	raw, err := bson.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("marshal filter: %w", err)
+	}
+
+	var prefix []string
+	for _, k := range key {
+		val, err := bson.Raw(raw).LookupErr(k.Key)
+		if err != nil {
+			break
+		}
+		hashed := k.Value == "hashed"
+		switch fieldConstraint(val) {
+		case constraintEquality:
+			prefix = append(prefix, k.Key)
+			continue
+		case constraintRange:
+			if !hashed {
+				prefix = append(prefix, k.Key)
+			}
+		}
+		break
+	}
+	return prefix, nil
+}
+
+type constraint int
+
+const (
+	constraintNone constraint = iota
+	constraintEquality
+	constraintRange
+)
+
+// fieldConstraint classifies one top-level filter value: a literal or
+// operator-free document is an equality match, $eq/$in narrow to exact
+// values, and $gt/$gte/$lt/$lte narrow to a range.
+func fieldConstraint(val bson.RawValue) constraint {
+	doc, ok := val.DocumentOK()
+	if !ok {
+		if val.Type == bson.TypeRegex {
+			return constraintNone
+		}
+		return constraintEquality
+	}
+	elems, err := doc.Elements()
+	if err != nil || len(elems) == 0 || !strings.HasPrefix(elems[0].Key(), "$") {
+		return constraintEquality
+	}
+
+	result := constraintNone
+	for _, e := range elems {
+		switch e.Key() {
+		case "$eq", "$in":
+			return constraintEquality
+		case "$gt", "$gte", "$lt", "$lte":
+			result = constraintRange
+		}
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Fairness verdicts: which of the two metrics is off its even share.
+const (
+	FairnessBalanced    = "balanced"     // counts and bytes both even
+	FairnessSizeSkewed  = "size-skewed"  // counts even, bytes not
+	FairnessCountSkewed = "count-skewed" // bytes even, counts not
+	FairnessSkewed      = "skewed"       // neither even
+)
+
+// ShardFairness is one shard's share of a collection's documents and
+// bytes, each as a signed deviation from an even share: +0.5 holds 50% more
+// than its share, -1 holds nothing.
+type ShardFairness struct {
+	Shard         string  `json:"shard"`
+	Docs          int64   `json:"docs"`
+	Bytes         int64   `json:"bytes"`
+	DocDeviation  float64 `json:"docDeviation"`
+	SizeDeviation float64 `json:"sizeDeviation"`
+}
+
+// FairnessReport compares document-count balance with data-size balance
+// for one collection.
+type FairnessReport struct {
+	Collection   string          `json:"collection"`
+	MaxDeviation float64         `json:"maxDeviation"`
+	Shards       []ShardFairness `json:"shards"`
+	CountEven    bool            `json:"countEven"`
+	SizeEven     bool            `json:"sizeEven"`
+	Verdict      string          `json:"verdict"`
+}
+
+// CompareCountAndSize checks dist's document counts and data sizes against
+// an even share across shards, each within maxDeviation. Older balancers
+// equalised chunk counts rather than bytes, so a collection with uneven
+// document sizes (or uneven chunk contents) can look balanced by count and
+// still leave one shard holding most of the data, or the reverse. Shards in
+// shards that hold none of the collection count as empty on both metrics.
+func CompareCountAndSize(dist *ShardDistribution, shards []string, maxDeviation float64) *FairnessReport {
+	report := &FairnessReport{Collection: dist.Collection, MaxDeviation: maxDeviation, CountEven: true, SizeEven: true}
+
+	names := append([]string(nil), shards...)
+	for shard := range dist.Shards {
+		if !containsString(names, shard) {
+			names = append(names, shard)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		report.Verdict = FairnessBalanced
+		return report
+	}
+
+	var totalBytes int64
+	for _, size := range dist.Sizes {
+		totalBytes += size
+	}
+	evenDocs := float64(dist.Total) / float64(len(names))
+	evenBytes := float64(totalBytes) / float64(len(names))
+
+	for _, shard := range names {
+		f := ShardFairness{Shard: shard, Docs: dist.Shards[shard], Bytes: dist.Sizes[shard]}
+		if evenDocs > 0 {
+			f.DocDeviation = (float64(f.Docs) - evenDocs) / evenDocs
+		}
+		if evenBytes > 0 {
+			f.SizeDeviation = (float64(f.Bytes) - evenBytes) / evenBytes
+		}
+		if math.Abs(f.DocDeviation) > maxDeviation {
+			report.CountEven = false
+		}
+		if math.Abs(f.SizeDeviation) > maxDeviation {
+			report.SizeEven = false
+		}
+		report.Shards = append(report.Shards, f)
+	}
+
+	switch {
+	case report.CountEven && report.SizeEven:
+		report.Verdict = FairnessBalanced
+	case report.CountEven:
+		report.Verdict = FairnessSizeSkewed
+	case report.SizeEven:
+		report.Verdict = FairnessCountSkewed
+	default:
+		report.Verdict = FairnessSkewed
+	}
+	return report
+}
+
+// GetFairnessReport reads the distribution of db.collection and compares it
+// across every registered shard with CompareCountAndSize.
+func GetFairnessReport(ctx context.Context, client *mongo.Client, db, collection string, maxDeviation float64) (*FairnessReport, error) {
+	dist, err := GetShardDistribution(ctx, client, db, collection)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := ShardNames(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return CompareCountAndSize(dist, shards, maxDeviation), nil
+}
+
+// ShardNames returns the IDs of the shards registered with the cluster.
+func ShardNames(ctx context.Context, client *mongo.Client) ([]string, error) {
+	var result struct {
+		Shards []struct {
+			ID string `bson:"_id"`
+		} `bson:"shards"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "listShards", Value: 1}}).Decode(&result); err != nil {
+		return nil, fmt.Errorf("listShards: %w", err)
+	}
+	names := make([]string, 0, len(result.Shards))
+	for _, s := range result.Shards {
+		names = append(names, s.ID)
+	}
+	return names, nil
+}
+
+// PrintFairness logs each shard's count and size deviation, marking which
+// shards are over or under on each metric, and flags a count/size mismatch.
+func PrintFairness(r *FairnessReport) {
+	log.Printf("  Collection: %s (even = within %.0f%% of an equal share)", r.Collection, r.MaxDeviation*100)
+	log.Printf("    %-12s %10s %7s %-6s %10s %7s %-6s", "shard", "docs", "dev", "", "MB", "dev", "")
+	for _, f := range r.Shards {
+		log.Printf("    %-12s %10d %+6.0f%% %-6s %10.1f %+6.0f%% %-6s", f.Shard,
+			f.Docs, f.DocDeviation*100, fairnessMark(f.DocDeviation, r.MaxDeviation),
+			float64(f.Bytes)/(1024*1024), f.SizeDeviation*100, fairnessMark(f.SizeDeviation, r.MaxDeviation))
+	}
+
+	switch r.Verdict {
+	case FairnessBalanced:
+		log.Println("  [OK] Documents and bytes are both evenly spread")
+	case FairnessSizeSkewed:
+		log.Println("  [WARN] Counts look balanced but bytes are skewed: document sizes differ by shard key range")
+	case FairnessCountSkewed:
+		log.Println("  [WARN] Bytes are balanced but counts are skewed: small documents cluster on some shards")
+	default:
+		log.Println("  [WARN] Neither documents nor bytes are evenly spread")
+	}
+}
+
+// fairnessMark labels a deviation as over or under its share, or blank
+// when within maxDeviation.
+func fairnessMark(dev, maxDeviation float64) string {
+	switch {
+	case dev > maxDeviation:
+		return "over"
+	case dev < -maxDeviation:
+		return "under"
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
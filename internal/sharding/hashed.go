@@ -15,59 +15,71 @@ const hashedDocCount = 10000
 // RunHashedDemo demonstrates hashed sharding for even write distribution.
 // Uses sequential _id values to show that hashing eliminates hotspots
 // on monotonically increasing keys.
-func RunHashedDemo(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+func RunHashedDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(hashedDocCount)
+
 	log.Println("=== Hashed Sharding Demo ===")
 	log.Println("Goal: Even write distribution despite monotonic _id")
+	result := startDemo("hashed", db, coll)
+	defer result.finish()
 
-	DropCollection(ctx, appClient, db, hashedCollection)
-
-	// Create hashed shard key on _id
-	if err := ShardCollectionHashed(ctx, adminClient, db, hashedCollection, "_id"); err != nil {
-		return fmt.Errorf("shard collection: %w", err)
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
 	}
-	log.Println("Shard key: { _id: 'hashed' }")
 
-	// Insert documents with sequential IDs
-	log.Printf("Inserting %d documents with sequential IDs...", hashedDocCount)
-	docs := make([]interface{}, hashedDocCount)
-	for i := 0; i < hashedDocCount; i++ {
+	// Sequential IDs: the insert pattern a ranged _id key cannot spread
+	docs := make([]interface{}, docCount)
+	ids := make([]interface{}, 0, 100)
+	for i := 0; i < docCount; i++ {
+		id := fmt.Sprintf("user_%06d", i)
 		docs[i] = bson.M{
-			"_id":      fmt.Sprintf("user_%06d", i),
+			"_id":      id,
 			"username": fmt.Sprintf("user%d", i),
 			"email":    fmt.Sprintf("user%d@example.com", i),
 			"age":      20 + (i % 50),
 		}
+		if len(ids) < cap(ids) {
+			ids = append(ids, id)
+		}
 	}
 
-	if err := batchInsert(ctx, appClient, db, hashedCollection, docs); err != nil {
-		return fmt.Errorf("insert: %w", err)
+	// Show why a ranged key is the wrong choice for these IDs
+	for _, w := range ValidateShardKey(bson.D{{Key: "_id", Value: 1}}, ids) {
+		log.Printf("  [INFO] Ranged { _id: 1 } rejected: %s", w)
+	}
+	hashedKey := bson.D{{Key: "_id", Value: "hashed"}}
+	if warnings := ValidateShardKey(hashedKey, ids); len(warnings) == 0 {
+		log.Println("  [VERIFY] Hashed { _id: 'hashed' } passes shard key validation")
+	}
+
+	// Create hashed shard key on _id
+	if err := ShardCollectionHashed(ctx, adminClient, db, coll, "_id"); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
+	}
+	log.Println("Shard key: { _id: 'hashed' }")
+
+	// Insert documents with sequential IDs
+	log.Printf("Inserting %d documents with sequential IDs...", docCount)
+
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
 	}
 
 	// Analyze distribution
-	dist, err := GetShardDistribution(ctx, adminClient, db, hashedCollection)
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
 	if err != nil {
-		return fmt.Errorf("distribution: %w", err)
+		return result, fmt.Errorf("distribution: %w", err)
 	}
 
 	PrintDistribution(dist)
-	log.Println("Result: Documents are evenly spread despite sequential keys")
-	log.Println("")
-	return nil
-}
+	result.setDistribution(ctx, adminClient, dist)
 
-// batchInsert inserts documents in batches of 1000.
-func batchInsert(ctx context.Context, client *mongo.Client, db, coll string, docs []interface{}) error {
-	collection := client.Database(db).Collection(coll)
-	batchSize := 1000
-
-	for i := 0; i < len(docs); i += batchSize {
-		end := i + batchSize
-		if end > len(docs) {
-			end = len(docs)
-		}
-		if _, err := collection.InsertMany(ctx, docs[i:end]); err != nil {
-			return err
-		}
+	if err := CheckEvenDistribution(dist, result.TotalShards, DefaultMaxDeviation); err != nil {
+		log.Printf("  [WARN] %v", err)
+	} else {
+		log.Printf("  [VERIFY] Every shard within %.0f%% of an even share", DefaultMaxDeviation*100)
 	}
-	return nil
+	log.Println("Result: Documents are evenly spread despite sequential keys")
+	log.Println("")
+	return result, nil
 }
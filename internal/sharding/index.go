@@ -0,0 +1,239 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// indexProgressInterval is how often CreateIndexWithProgress polls $currentOp.
+const indexProgressInterval = 2 * time.Second
+
+// indexBuildMsg matches the progress message of an index build operation,
+// e.g. "Index Build: scanning collection Index Build: scanning collection: 41200/100000 41%".
+var indexBuildMsg = regexp.MustCompile(`(\d+)/(\d+)\s+\d+%`)
+
+// indexBuildOp is one in-flight index build reported by $currentOp.
+type indexBuildOp struct {
+	Shard string
+	Msg   string
+	Done  int64
+	Total int64
+}
+
+// EnsureShardKeyIndex makes sure an index supporting the shard key exists,
+// creating it if needed and confirming it via listIndexes before returning.
+// A supporting index has the shard key as a prefix and is neither sparse
+// nor partial.
+func EnsureShardKeyIndex(ctx context.Context, client *mongo.Client, db, collection string, key bson.D) error {
+	ok, err := hasSupportingIndex(ctx, client, db, collection, key)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	_, err = CreateIndexWithProgress(ctx, client, db, collection, mongo.IndexModel{Keys: key})
+	if err != nil {
+		return fmt.Errorf("create shard key index %s on %s.%s: %w", FormatKey(key), db, collection, err)
+	}
+
+	ok, err = hasSupportingIndex(ctx, client, db, collection, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("shard key index %s on %s.%s not found after creation", FormatKey(key), db, collection)
+	}
+	return nil
+}
+
+// CreateIndexWithProgress builds an index and, while the build runs, polls
+// $currentOp every indexProgressInterval and logs how far each shard has
+// got. Background and two-phase builds report progress on the build
+// thread's operation, foreground builds on the createIndexes command
+// itself; both are matched by namespace. A fast build logs nothing.
+func CreateIndexWithProgress(ctx context.Context, client *mongo.Client, db, coll string, model mongo.IndexModel) (string, error) {
+	type buildResult struct {
+		name string
+		err  error
+	}
+	done := make(chan buildResult, 1)
+	go func() {
+		name, err := client.Database(db).Collection(coll).Indexes().CreateOne(ctx, model)
+		done <- buildResult{name, err}
+	}()
+
+	ns := db + "." + coll
+	label := fmt.Sprintf("%v", model.Keys)
+	if key, ok := model.Keys.(bson.D); ok {
+		label = FormatKey(key)
+	}
+	ticker := time.NewTicker(indexProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-done:
+			return r.name, r.err
+		case <-ticker.C:
+			ops, err := indexBuildProgress(ctx, client, ns)
+			if err != nil {
+				log.Printf("  [WARN] index build progress on %s: %v", ns, err)
+				continue
+			}
+			for _, op := range ops {
+				if op.Total > 0 {
+					log.Printf("  Index build %s on %s: %.0f%% (%d/%d)",
+						label, op.Shard, float64(op.Done)/float64(op.Total)*100, op.Done, op.Total)
+				} else {
+					log.Printf("  Index build %s on %s: %s", label, op.Shard, op.Msg)
+				}
+			}
+		}
+	}
+}
+
+// indexBuildProgress lists the index builds running on ns, one entry per
+// shard (or "local" when not behind mongos), preferring operations that
+// report a done/total count.
+func indexBuildProgress(ctx context.Context, client *mongo.Client, ns string) ([]indexBuildOp, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$currentOp", Value: bson.D{{Key: "allUsers", Value: true}}}},
+		{{Key: "$match", Value: bson.D{
+			{Key: "ns", Value: ns},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "command.createIndexes", Value: bson.D{{Key: "$exists", Value: true}}}},
+				bson.D{{Key: "msg", Value: bson.D{{Key: "$regex", Value: "^Index Build"}}}},
+			}},
+		}}},
+	}
+	cursor, err := client.Database("admin").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("$currentOp: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	byShard := make(map[string]indexBuildOp)
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		op := indexBuildOp{Shard: stringVal(doc, "shard"), Msg: stringVal(doc, "msg")}
+		if op.Shard == "" {
+			op.Shard = "local"
+		}
+		if progress, ok := doc["progress"].(bson.M); ok {
+			op.Done, op.Total = intVal(progress, "done"), intVal(progress, "total")
+		} else if m := indexBuildMsg.FindStringSubmatch(op.Msg); m != nil {
+			op.Done, _ = strconv.ParseInt(m[1], 10, 64)
+			op.Total, _ = strconv.ParseInt(m[2], 10, 64)
+		}
+		if op.Msg == "" {
+			op.Msg = "waiting for the build to start"
+		}
+		if prev, ok := byShard[op.Shard]; !ok || (prev.Total == 0 && op.Total > 0) {
+			byShard[op.Shard] = op
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	ops := make([]indexBuildOp, 0, len(byShard))
+	for _, op := range byShard {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Shard < ops[j].Shard })
+	return ops, nil
+}
+
+// hasSupportingIndex checks listIndexes for an index usable by the shard key.
+func hasSupportingIndex(ctx context.Context, client *mongo.Client, db, collection string, key bson.D) (bool, error) {
+	cursor, err := client.Database(db).Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		// Collection does not exist yet — there is nothing to support the key
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Code == 26 {
+			return false, nil
+		}
+		return false, fmt.Errorf("listIndexes %s.%s: %w", db, collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var idx struct {
+			Key     bson.D      `bson:"key"`
+			Sparse  bool        `bson:"sparse"`
+			Partial interface{} `bson:"partialFilterExpression"`
+		}
+		if err := cursor.Decode(&idx); err != nil {
+			continue
+		}
+		if idx.Sparse || idx.Partial != nil {
+			continue
+		}
+		if keyIsPrefix(key, idx.Key) {
+			return true, nil
+		}
+	}
+	return false, cursor.Err()
+}
+
+// keyIsPrefix reports whether key matches the leading fields of index.
+func keyIsPrefix(key, index bson.D) bool {
+	if len(index) < len(key) {
+		return false
+	}
+	for i, k := range key {
+		if index[i].Key != k.Key || !sameDirection(index[i].Value, k.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDirection compares index key values across BSON numeric types and "hashed".
+func sameDirection(a, b interface{}) bool {
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	if aStr || bStr {
+		return aStr && bStr && as == bs
+	}
+	return ToInt64(a) == ToInt64(b)
+}
+
+// FormatKey renders a shard key pattern, chunk bound or split point for
+// messages, e.g. { region: "EU", _id: MinKey } or { a: 1, b: "hashed" }.
+func FormatKey(key bson.D) string {
+	if len(key) == 0 {
+		return "{}"
+	}
+	parts := make([]string, 0, len(key))
+	for _, e := range key {
+		var v string
+		switch val := e.Value.(type) {
+		case primitive.MinKey:
+			v = "MinKey"
+		case primitive.MaxKey:
+			v = "MaxKey"
+		case string:
+			v = fmt.Sprintf("%q", val)
+		default:
+			v = fmt.Sprintf("%v", val)
+		}
+		parts = append(parts, e.Key+": "+v)
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
@@ -0,0 +1,218 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnsafeShardKey is returned by ShardCollection with WithKeyValidation
+// when ValidateShardKey warns about the key.
+var ErrUnsafeShardKey = errors.New("unsafe shard key")
+
+// monotonicRatio is the share of consecutive sample pairs that must move in
+// the same direction for a field to count as monotonic.
+const monotonicRatio = 0.9
+
+// minKeySamples is the fewest sample values ValidateShardKey judges; fewer
+// say nothing reliable about insert order.
+const minKeySamples = 10
+
+// Warning is one problem ValidateShardKey found with a shard key, and what
+// to do about it.
+type Warning struct {
+	Field      string `json:"field"`
+	Problem    string `json:"problem"`
+	Suggestion string `json:"suggestion"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s; %s", w.Field, w.Problem, w.Suggestion)
+}
+
+// ValidateShardKey warns when key's leading field is ranged and
+// sampleValues, that field's values in insertion order, keep increasing
+// (or decreasing), as timestamps, counters and ObjectIds do. Every new
+// document then sorts past the last chunk's lower bound, so one shard takes
+// all inserts until the balancer moves chunks away: the hotspot
+// RunHashedDemo avoids by hashing. Only the leading field is checked; a
+// high-cardinality prefix spreads inserts even when later fields increase.
+// A hashed leading field, or fewer than minKeySamples comparable values,
+// yields no warnings.
+func ValidateShardKey(key bson.D, sampleValues []interface{}) []Warning {
+	if len(key) == 0 || key[0].Value == "hashed" || len(sampleValues) < minKeySamples {
+		return nil
+	}
+	field := key[0].Key
+
+	var up, down, compared int
+	for i := 1; i < len(sampleValues); i++ {
+		c, ok := compareSample(sampleValues[i-1], sampleValues[i])
+		if !ok {
+			continue
+		}
+		compared++
+		switch {
+		case c < 0:
+			up++
+		case c > 0:
+			down++
+		}
+	}
+	if compared < minKeySamples-1 {
+		return nil
+	}
+
+	direction := ""
+	switch {
+	case float64(up) >= monotonicRatio*float64(compared):
+		direction = "increasing"
+	case float64(down) >= monotonicRatio*float64(compared):
+		direction = "decreasing"
+	default:
+		return nil
+	}
+
+	problem := fmt.Sprintf("ranged values are monotonically %s in %d/%d consecutive samples%s, so every insert lands on one shard",
+		direction, max(up, down), compared, monotonicKind(sampleValues))
+	suggestion := fmt.Sprintf("shard on { %s: \"hashed\" } to spread inserts, or put a high-cardinality field (e.g. customer_id) before it", field)
+	if len(key) > 1 {
+		suggestion = fmt.Sprintf("move a high-cardinality field ahead of %s in the key, or hash %s", field, field)
+	}
+	return []Warning{{Field: field, Problem: problem, Suggestion: suggestion}}
+}
+
+// ShardCollectionOption configures ShardCollection.
+type ShardCollectionOption func(*shardCollectionOptions)
+
+type shardCollectionOptions struct {
+	keySamples int
+}
+
+// WithKeyValidation makes ShardCollection read the leading key field of up
+// to samples existing documents in natural (roughly insertion) order and
+// refuse with ErrUnsafeShardKey when ValidateShardKey warns. An empty or
+// small collection passes: there is no insert order to judge yet.
+func WithKeyValidation(samples int) ShardCollectionOption {
+	return func(o *shardCollectionOptions) {
+		o.keySamples = samples
+	}
+}
+
+// checkShardKey samples db.collection and runs ValidateShardKey on key.
+func checkShardKey(ctx context.Context, client *mongo.Client, db, collection string, key bson.D, samples int) error {
+	if len(key) == 0 || key[0].Value == "hashed" {
+		return nil
+	}
+	field := key[0].Key
+
+	cursor, err := client.Database(db).Collection(collection).Find(ctx, bson.D{}, options.Find().
+		SetSort(bson.D{{Key: "$natural", Value: 1}}).
+		SetProjection(bson.D{{Key: field, Value: 1}}).
+		SetLimit(int64(samples)))
+	if err != nil {
+		return fmt.Errorf("sample shard key values: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var values []interface{}
+	for cursor.Next(ctx) {
+		v, err := cursor.Current.LookupErr(strings.Split(field, ".")...)
+		if err != nil {
+			continue
+		}
+		var decoded interface{}
+		if err := v.Unmarshal(&decoded); err == nil {
+			values = append(values, decoded)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("sample shard key values: %w", err)
+	}
+
+	warnings := ValidateShardKey(key, values)
+	if len(warnings) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(warnings))
+	for i, w := range warnings {
+		msgs[i] = w.String()
+	}
+	return fmt.Errorf("%w %s: %s", ErrUnsafeShardKey, FormatKey(key), strings.Join(msgs, "; "))
+}
+
+// compareSample orders two shard key values of the same kind. Values of
+// different kinds, or kinds it does not know, are not comparable.
+func compareSample(a, b interface{}) (int, bool) {
+	if x, ok := sampleNumber(a); ok {
+		if y, ok := sampleNumber(b); ok {
+			return cmpOrdered(x, y), true
+		}
+		return 0, false
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return cmpOrdered(x, y), true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return strings.Compare(x.Hex(), y.Hex()), true
+		}
+	case primitive.Timestamp:
+		if y, ok := b.(primitive.Timestamp); ok {
+			return primitive.CompareTimestamp(x, y), true
+		}
+	}
+	return 0, false
+}
+
+func sampleNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64 | primitive.DateTime](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// monotonicKind explains why the values increase when their type says so.
+func monotonicKind(values []interface{}) string {
+	switch values[0].(type) {
+	case primitive.ObjectID:
+		return " (ObjectIds start with their creation time)"
+	case primitive.DateTime, time.Time, primitive.Timestamp:
+		return " (timestamps only grow)"
+	}
+	return ""
+}
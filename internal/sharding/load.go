@@ -0,0 +1,225 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/errgroup"
+)
+
+// InsertOptions control how BatchInsert loads documents.
+type InsertOptions struct {
+	BatchSize int  // documents per InsertMany call
+	Ordered   bool // stop a batch at its first failed document
+	Workers   int  // batches in flight at once; 1 loads serially
+
+	// MaxFailureRate is the fraction of documents that may be rejected
+	// (duplicate keys, validation, ...) before BatchInsert returns an error.
+	// Rejected documents never stop the load; 0 tolerates none.
+	MaxFailureRate float64
+
+	// Progress, when set, is called as batches complete, at most once per
+	// ProgressEvery (default 2s), and once more when the load finishes.
+	Progress      func(LoadProgress)
+	ProgressEvery time.Duration
+}
+
+// LoadProgress is a snapshot of a running BatchInsert.
+type LoadProgress struct {
+	Inserted int
+	Failed   int // documents the server rejected
+	Total    int
+	Elapsed  time.Duration
+}
+
+// Rate is the average insert rate so far in documents per second.
+func (p LoadProgress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Inserted) / p.Elapsed.Seconds()
+}
+
+// LogProgress is a Progress callback that logs inserted count and rate.
+func LogProgress(p LoadProgress) {
+	pct := 0.0
+	if p.Total > 0 {
+		pct = float64(p.Inserted) / float64(p.Total) * 100
+	}
+	failed := ""
+	if p.Failed > 0 {
+		failed = fmt.Sprintf(", %d failed", p.Failed)
+	}
+	log.Printf("  ... %d/%d docs (%.0f%%)%s in %v, %.0f docs/s",
+		p.Inserted, p.Total, pct, failed, p.Elapsed.Round(time.Millisecond), p.Rate())
+}
+
+// LoadError is returned by BatchInsert when more than MaxFailureRate of
+// the documents were rejected.
+type LoadError struct {
+	Inserted int
+	Failed   int
+	Total    int
+	First    error // first rejected batch's error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%d of %d documents failed to insert (%.1f%%), %d inserted: %v",
+		e.Failed, e.Total, float64(e.Failed)/float64(e.Total)*100, e.Inserted, e.First)
+}
+
+func (e *LoadError) Unwrap() error { return e.First }
+
+// Padding returns a filler string of the given size in bytes, for data
+// generators that need documents of a known size.
+func Padding(bytes int) string {
+	return strings.Repeat("x", bytes)
+}
+
+// DataEpoch is the start of the timestamps the data generators write. It
+// is a fixed instant rather than the clock, so the counter-based demo data
+// is the same on every run; throughput-lab's random values also need its
+// -seed to repeat.
+var DataEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultInsertOptions loads 1000-document ordered batches one at a time,
+// so documents arrive in slice order. Up to 1% of documents may be
+// rejected before the load counts as failed.
+func DefaultInsertOptions() InsertOptions {
+	return InsertOptions{BatchSize: 1000, Ordered: true, Workers: 1, MaxFailureRate: 0.01}
+}
+
+// ParallelInsertOptions is DefaultInsertOptions with unordered batches and
+// four workers, which keeps every shard busy without flooding a small
+// cluster. Use it for large loads whose insert order does not matter.
+func ParallelInsertOptions() InsertOptions {
+	opts := DefaultInsertOptions()
+	opts.Ordered = false
+	opts.Workers = 4
+	return opts
+}
+
+// BatchInsert inserts docs into db.coll in batches of opts.BatchSize,
+// running up to opts.Workers batches concurrently. Batches may complete out
+// of order when Workers > 1.
+//
+// Documents the server rejects (a duplicate key, a failed validator) are
+// counted and logged while the load carries on; an unordered batch still
+// inserts its other documents, an ordered one stops at the rejected
+// document and the rest of that batch counts as failed. Once every batch
+// has run, a *LoadError is returned if more than opts.MaxFailureRate of
+// the documents failed. Any other error (network, timeout, write concern)
+// cancels the remaining batches and is returned as is.
+func BatchInsert(ctx context.Context, client *mongo.Client, db, coll string, docs []interface{}, opts InsertOptions) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultInsertOptions().BatchSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	collection := client.Database(db).Collection(coll)
+	insertOpts := options.InsertMany().SetOrdered(opts.Ordered)
+	progress := newProgressReporter(len(docs), opts)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.Workers)
+	for i := 0; i < len(docs); i += opts.BatchSize {
+		start, end := i, min(i+opts.BatchSize, len(docs))
+		g.Go(func() error {
+			_, err := collection.InsertMany(gctx, docs[start:end], insertOpts)
+			var bwe mongo.BulkWriteException
+			switch {
+			case err == nil:
+				progress.add(end-start, 0, nil)
+			case errors.As(err, &bwe) && bwe.WriteConcernError == nil && len(bwe.WriteErrors) > 0:
+				failed := len(bwe.WriteErrors)
+				if opts.Ordered {
+					failed = end - start - bwe.WriteErrors[0].Index
+				}
+				log.Printf("  [WARN] batch at %d: %d of %d documents rejected, first: %s",
+					start, failed, end-start, bwe.WriteErrors[0].Message)
+				progress.add(end-start-failed, failed, fmt.Errorf("insert batch at %d: %w", start, err))
+			default:
+				return fmt.Errorf("insert batch at %d: %w", start, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	progress.finish()
+
+	summary := progress.snapshot()
+	if summary.Failed == 0 {
+		return nil
+	}
+	log.Printf("  [WARN] %d documents inserted, %d rejected", summary.Inserted, summary.Failed)
+	if float64(summary.Failed) > opts.MaxFailureRate*float64(len(docs)) {
+		return &LoadError{Inserted: summary.Inserted, Failed: summary.Failed, Total: len(docs), First: progress.firstErr}
+	}
+	return nil
+}
+
+// progressReporter throttles LoadProgress callbacks from concurrent batches.
+type progressReporter struct {
+	mu       sync.Mutex
+	fn       func(LoadProgress)
+	every    time.Duration
+	start    time.Time
+	last     time.Time
+	inserted int
+	failed   int
+	firstErr error
+	total    int
+}
+
+func newProgressReporter(total int, opts InsertOptions) *progressReporter {
+	every := opts.ProgressEvery
+	if every <= 0 {
+		every = 2 * time.Second
+	}
+	now := time.Now()
+	return &progressReporter{fn: opts.Progress, every: every, start: now, last: now, total: total}
+}
+
+// add records a finished batch: inserted documents, failed ones, and the
+// batch's error when any failed.
+func (r *progressReporter) add(inserted, failed int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inserted += inserted
+	r.failed += failed
+	if err != nil && r.firstErr == nil {
+		r.firstErr = err
+	}
+	if r.fn != nil && time.Since(r.last) >= r.every && r.inserted+r.failed < r.total {
+		r.last = time.Now()
+		r.fn(r.snapshot())
+	}
+}
+
+func (r *progressReporter) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fn != nil {
+		r.fn(r.snapshot())
+	}
+}
+
+func (r *progressReporter) snapshot() LoadProgress {
+	return LoadProgress{Inserted: r.inserted, Failed: r.failed, Total: r.total, Elapsed: time.Since(r.start)}
+}
+
+// batchInsert loads demo data with the default options.
+func batchInsert(ctx context.Context, client *mongo.Client, db, coll string, docs []interface{}) error {
+	return BatchInsert(ctx, client, db, coll, docs, DefaultInsertOptions())
+}
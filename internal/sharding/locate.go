@@ -0,0 +1,97 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DocumentLocation is where a document lives in the cluster.
+// For unsharded collections Shard is the database primary and the chunk
+// bounds are empty.
+type DocumentLocation struct {
+	Shard    string
+	Sharded  bool
+	ChunkMin bson.D
+	ChunkMax bson.D
+}
+
+// LocateDocument finds the shard and chunk that own doc. It extracts the
+// shard key values from doc (hashing hashed fields server-side with
+// convertShardKeyToHashed) and looks up the chunk whose [min, max) range
+// contains them in config.chunks.
+func LocateDocument(ctx context.Context, client *mongo.Client, db, collection string, doc bson.M) (*DocumentLocation, error) {
+	ns := db + "." + collection
+
+	collMeta, err := shardedCollection(ctx, client, ns)
+	if err != nil {
+		return nil, err
+	}
+	if collMeta == nil {
+		primary, err := primaryShard(ctx, client, db)
+		if err != nil {
+			return nil, err
+		}
+		return &DocumentLocation{Shard: primary}, nil
+	}
+
+	keyValues := bson.D{}
+	for _, k := range collMeta.Key {
+		val := lookupPath(doc, k.Key)
+		if v, ok := k.Value.(string); ok && v == "hashed" {
+			var hashed struct {
+				Outputs int64 `bson:"outputs"`
+			}
+			if err := client.Database("admin").RunCommand(ctx, bson.D{
+				{Key: "convertShardKeyToHashed", Value: val},
+			}).Decode(&hashed); err != nil {
+				return nil, fmt.Errorf("hash %s: %w", k.Key, err)
+			}
+			val = hashed.Outputs
+		}
+		keyValues = append(keyValues, bson.E{Key: k.Key, Value: val})
+	}
+
+	// Chunk bounds are documents with the shard key's field order, so BSON
+	// comparison on min/max matches the chunk ordering mongos uses
+	filter := append(ChunksFilter(ns, collMeta.UUID),
+		bson.E{Key: "min", Value: bson.D{{Key: "$lte", Value: keyValues}}},
+		bson.E{Key: "max", Value: bson.D{{Key: "$gt", Value: keyValues}}},
+	)
+
+	var chunk struct {
+		Shard string `bson:"shard"`
+		Min   bson.D `bson:"min"`
+		Max   bson.D `bson:"max"`
+	}
+	if err := client.Database("config").Collection("chunks").FindOne(ctx, filter).Decode(&chunk); err != nil {
+		return nil, fmt.Errorf("find chunk for %s in %s: %w", FormatKey(keyValues), ns, err)
+	}
+
+	return &DocumentLocation{
+		Shard:    chunk.Shard,
+		Sharded:  true,
+		ChunkMin: chunk.Min,
+		ChunkMax: chunk.Max,
+	}, nil
+}
+
+// lookupPath resolves a dotted field path in doc. Missing fields are nil,
+// which is how mongos routes documents lacking a shard key field.
+func lookupPath(doc bson.M, path string) interface{} {
+	var cur interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch m := cur.(type) {
+		case bson.M:
+			cur = m[part]
+		case bson.D:
+			cur = m.Map()[part]
+		default:
+			return nil
+		}
+	}
+	return cur
+}
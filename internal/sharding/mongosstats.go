@@ -0,0 +1,150 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// scatterGatherWarnRatio is the share of scatter-gather reads above which
+// PrintMongosStats warns that the workload is poorly targeted.
+const scatterGatherWarnRatio = 0.5
+
+// MongosStats is the query router section of one mongos's serverStatus.
+// Counters are cumulative since that mongos started.
+type MongosStats struct {
+	Host      string                  `json:"host"`
+	Version   string                  `json:"version"`
+	Uptime    time.Duration           `json:"uptimeNs"`
+	Targeting map[string]TargetCounts `json:"targeting"` // per operation: find, insert, update, delete, aggregate
+	Catalog   CatalogCacheStats       `json:"catalogCache"`
+}
+
+// TargetCounts is shardingStatistics.numHostsTargeted for one operation
+// type: how many operations mongos sent to every shard, to several, to
+// exactly one, or to the primary shard of an unsharded collection.
+type TargetCounts struct {
+	AllShards  int64 `json:"allShards" bson:"allShards"`
+	ManyShards int64 `json:"manyShards" bson:"manyShards"`
+	OneShard   int64 `json:"oneShard" bson:"oneShard"`
+	Unsharded  int64 `json:"unsharded" bson:"unsharded"`
+}
+
+// ScatterGather is the number of operations that reached more than one shard.
+func (t TargetCounts) ScatterGather() int64 { return t.AllShards + t.ManyShards }
+
+// Targeted is the number of operations that reached a single shard.
+func (t TargetCounts) Targeted() int64 { return t.OneShard + t.Unsharded }
+
+// Total is the number of operations counted.
+func (t TargetCounts) Total() int64 { return t.ScatterGather() + t.Targeted() }
+
+// CatalogCacheStats is shardingStatistics.catalogCache: how often mongos
+// found its routing table stale and how long operations waited for it to
+// refresh.
+type CatalogCacheStats struct {
+	DatabaseEntries      int64 `json:"databaseEntries" bson:"numDatabaseEntries"`
+	CollectionEntries    int64 `json:"collectionEntries" bson:"numCollectionEntries"`
+	StaleConfigErrors    int64 `json:"staleConfigErrors" bson:"countStaleConfigErrors"`
+	RefreshWaitMicros    int64 `json:"refreshWaitMicros" bson:"totalRefreshWaitTimeMicros"`
+	FullRefreshes        int64 `json:"fullRefreshes" bson:"countFullRefreshesStarted"`
+	IncrementalRefreshes int64 `json:"incrementalRefreshes" bson:"countIncrementalRefreshesStarted"`
+	FailedRefreshes      int64 `json:"failedRefreshes" bson:"countFailedRefreshes"`
+}
+
+// GetMongosStats runs serverStatus through client and extracts the
+// sharding router statistics. Unlike explain, which shows how one query is
+// routed, these counters cover every operation the mongos has routed, so
+// they show whether the workload as a whole is targeted or scatter-gather.
+// With several mongos in the seed list the driver picks one; Host says
+// which.
+func GetMongosStats(ctx context.Context, client *mongo.Client) (*MongosStats, error) {
+	cmd := bson.D{
+		{Key: "serverStatus", Value: 1},
+		// Skip the sections that are expensive or irrelevant here
+		{Key: "repl", Value: 0},
+		{Key: "metrics", Value: 0},
+		{Key: "locks", Value: 0},
+	}
+	var status struct {
+		Host               string  `bson:"host"`
+		Version            string  `bson:"version"`
+		Process            string  `bson:"process"`
+		Uptime             float64 `bson:"uptime"`
+		ShardingStatistics struct {
+			NumHostsTargeted map[string]TargetCounts `bson:"numHostsTargeted"`
+			CatalogCache     CatalogCacheStats       `bson:"catalogCache"`
+		} `bson:"shardingStatistics"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&status); err != nil {
+		return nil, fmt.Errorf("serverStatus: %w", err)
+	}
+	if status.Process != "mongos" {
+		return nil, fmt.Errorf("%s is a %s, not a mongos", status.Host, status.Process)
+	}
+
+	return &MongosStats{
+		Host:      status.Host,
+		Version:   status.Version,
+		Uptime:    time.Duration(status.Uptime * float64(time.Second)),
+		Targeting: status.ShardingStatistics.NumHostsTargeted,
+		Catalog:   status.ShardingStatistics.CatalogCache,
+	}, nil
+}
+
+// ReadTargeting sums the find and aggregate counters, the operations whose
+// targeting depends on the query filter.
+func (s *MongosStats) ReadTargeting() TargetCounts {
+	var sum TargetCounts
+	for _, op := range []string{"find", "aggregate"} {
+		t := s.Targeting[op]
+		sum.AllShards += t.AllShards
+		sum.ManyShards += t.ManyShards
+		sum.OneShard += t.OneShard
+		sum.Unsharded += t.Unsharded
+	}
+	return sum
+}
+
+// PrintMongosStats logs a per-operation targeting table and the catalog
+// cache counters, and warns when most reads were scatter-gather.
+func PrintMongosStats(s *MongosStats) {
+	log.Printf("  Mongos: %s (v%s, up %v)", s.Host, s.Version, s.Uptime.Round(time.Second))
+	log.Printf("  %-10s %10s %10s %10s %10s %9s", "op", "allShards", "manyShards", "oneShard", "unsharded", "targeted")
+	for _, op := range []string{"find", "aggregate", "insert", "update", "delete"} {
+		t, ok := s.Targeting[op]
+		if !ok {
+			continue
+		}
+		log.Printf("  %-10s %10d %10d %10d %10d %9s", op, t.AllShards, t.ManyShards, t.OneShard, t.Unsharded, targetedPct(t))
+	}
+
+	c := s.Catalog
+	log.Printf("  Catalog cache: %d databases, %d collections, %d stale config errors, %d full / %d incremental refreshes (%d failed), %v waiting",
+		c.DatabaseEntries, c.CollectionEntries, c.StaleConfigErrors, c.FullRefreshes, c.IncrementalRefreshes, c.FailedRefreshes,
+		(time.Duration(c.RefreshWaitMicros) * time.Microsecond).Round(time.Millisecond))
+
+	reads := s.ReadTargeting()
+	if reads.Total() == 0 {
+		log.Println("  [INFO] No reads routed yet")
+		return
+	}
+	ratio := float64(reads.ScatterGather()) / float64(reads.Total())
+	if ratio > scatterGatherWarnRatio {
+		log.Printf("  [WARN] %.0f%% of reads were scatter-gather; add shard key fields to the common query filters", ratio*100)
+	} else {
+		log.Printf("  [OK] %.0f%% of reads were targeted to a single shard", (1-ratio)*100)
+	}
+}
+
+// targetedPct formats the share of t that reached a single shard.
+func targetedPct(t TargetCounts) string {
+	if t.Total() == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(t.Targeted())/float64(t.Total())*100)
+}
@@ -0,0 +1,443 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"go-mongodb-sharding-poc/internal/timeutil"
+)
+
+// oplogReopenDelay is how long a shard's tailer waits before reopening a
+// tailable cursor the server has closed.
+const oplogReopenDelay = 500 * time.Millisecond
+
+// OplogSource is one shard's replica set, reached directly rather than
+// through mongos (local.oplog.rs is not readable through a router).
+type OplogSource struct {
+	Shard  string
+	Client *mongo.Client
+}
+
+// OplogPosition is how far one shard's oplog has been delivered: the entry
+// timestamp and, for a transaction's applyOps entry, the index of the last
+// operation delivered from it.
+type OplogPosition struct {
+	TS primitive.Timestamp `bson:"ts"`
+	Op int32               `bson:"op"`
+}
+
+// OplogStream merges the oplogs of every shard into a feed of change-event
+// shaped documents (operationType, ns, documentKey, fullDocument,
+// clusterTime) plus the shard each came from. It satisfies the same
+// Next/Decode/Err/ResumeToken/Close surface as *mongo.ChangeStream, so
+// callers can switch between the two.
+//
+// Unlike a change stream it does not need majority read concern, but it
+// also gives up what mongos adds on top of the oplog:
+//   - Events are delivered in arrival order. Each shard's events are in
+//     order, but events from different shards are not sorted by cluster time.
+//   - Updates carry no fullDocument; only inserts and replacements do.
+//   - Writes are reported once applied on the shard primary, not once
+//     majority-committed, so a rollback after a failover can retract one.
+//   - DDL (drop, rename, create) is not reported.
+//
+// Chunk migration traffic (fromMigrate entries) is skipped, as a change
+// stream would skip it.
+type OplogStream struct {
+	events chan oplogEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	err       error
+	positions map[string]OplogPosition
+	current   bson.Raw
+	token     bson.Raw
+}
+
+// oplogEvent is one decoded operation waiting to be delivered.
+type oplogEvent struct {
+	shard string
+	pos   OplogPosition
+	event bson.D // change event fields, without _id
+}
+
+// oplogEntry is the part of a local.oplog.rs entry the stream reads. Entries
+// nested in an applyOps command have no ts of their own.
+type oplogEntry struct {
+	TS          primitive.Timestamp `bson:"ts"`
+	Op          string              `bson:"op"`
+	NS          string              `bson:"ns"`
+	O           bson.Raw            `bson:"o"`
+	O2          bson.Raw            `bson:"o2"`
+	FromMigrate bool                `bson:"fromMigrate"`
+}
+
+// OpenOplogStream starts tailing local.oplog.rs on every source, reporting
+// writes to db.coll, to all of db when coll is empty, or to every user
+// database when db is empty too. ops limits the operation types
+// ("insert", "update", "replace", "delete"); empty means all.
+//
+// Without resumeAfter the stream starts at each shard's newest entry. With
+// a token from ResumeToken it continues after the last event delivered, and
+// fails if a shard's oplog has already rolled past that point. The stream
+// runs until ctx is done, Close is called, or a shard's cursor fails.
+func OpenOplogStream(ctx context.Context, sources []OplogSource, db, coll string, ops []string, resumeAfter bson.Raw) (*OplogStream, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("oplog stream: no shards to tail")
+	}
+	var resume map[string]OplogPosition
+	if len(resumeAfter) > 0 {
+		var err error
+		if resume, err = ParseOplogResumeToken(resumeAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fix every shard's starting point before tailing, so the first token
+	// covers all of them and a bad shard fails the open
+	positions := make(map[string]OplogPosition, len(sources))
+	for _, src := range sources {
+		oplog := src.Client.Database("local").Collection("oplog.rs")
+		if pos, ok := resume[src.Shard]; ok {
+			var oldest oplogEntry
+			if err := oplog.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "$natural", Value: 1}})).Decode(&oldest); err != nil {
+				return nil, fmt.Errorf("oplog stream: read oldest entry on %s: %w", src.Shard, err)
+			}
+			if pos.TS.Before(oldest.TS) {
+				return nil, fmt.Errorf("oplog stream: resume point on %s is no longer in its oplog (oldest entry %v)", src.Shard, oldest.TS)
+			}
+			positions[src.Shard] = pos
+			continue
+		}
+		var newest oplogEntry
+		if err := oplog.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "$natural", Value: -1}})).Decode(&newest); err != nil {
+			return nil, fmt.Errorf("oplog stream: read newest entry on %s: %w", src.Shard, err)
+		}
+		positions[src.Shard] = OplogPosition{TS: newest.TS, Op: math.MaxInt32}
+	}
+
+	// Until the first event the token is the starting point, so a stream
+	// that fails early still resumes where it began, not at the newest entry
+	token, err := bson.Marshal(bson.D{{Key: "oplog", Value: positions}})
+	if err != nil {
+		return nil, fmt.Errorf("oplog stream: encode resume token: %w", err)
+	}
+
+	tailCtx, cancel := context.WithCancel(ctx)
+	s := &OplogStream{
+		events:    make(chan oplogEvent, 256),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		positions: positions,
+		token:     token,
+	}
+	filter := oplogFilter(db, coll)
+	match := oplogMatcher(db, coll, ops)
+
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src OplogSource, start OplogPosition) {
+			defer wg.Done()
+			if err := s.tail(tailCtx, src, start, filter, match); err != nil && tailCtx.Err() == nil {
+				s.fail(fmt.Errorf("oplog stream on %s: %w", src.Shard, err))
+			}
+		}(src, positions[src.Shard])
+	}
+	go func() {
+		wg.Wait()
+		close(s.events)
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// tail follows one shard's oplog from start, reopening the tailable cursor
+// whenever the server closes it, until ctx is done or the cursor fails.
+func (s *OplogStream) tail(ctx context.Context, src OplogSource, start OplogPosition, filter bson.D, match func(oplogEntry) (string, bool)) error {
+	oplog := src.Client.Database("local").Collection("oplog.rs")
+	pos := start
+	for {
+		// $gte re-reads the entry at pos so the rest of a half-delivered
+		// applyOps is not lost; operations up to pos.Op are skipped below
+		query := append(bson.D{{Key: "ts", Value: bson.D{{Key: "$gte", Value: pos.TS}}}}, filter...)
+		cursor, err := oplog.Find(ctx, query, options.Find().
+			SetCursorType(options.TailableAwait).
+			SetMaxAwaitTime(time.Second).
+			SetNoCursorTimeout(true))
+		if err != nil {
+			return err
+		}
+
+		for cursor.Next(ctx) {
+			var entry oplogEntry
+			if err := cursor.Decode(&entry); err != nil {
+				cursor.Close(ctx)
+				return fmt.Errorf("decode oplog entry: %w", err)
+			}
+			for i, op := range expandApplyOps(entry) {
+				if entry.TS.Equal(pos.TS) && int32(i) <= pos.Op {
+					continue
+				}
+				opType, ok := match(op)
+				if !ok {
+					continue
+				}
+				ev := oplogEvent{
+					shard: src.Shard,
+					pos:   OplogPosition{TS: entry.TS, Op: int32(i)},
+					event: oplogChangeEvent(op, opType, entry.TS, src.Shard),
+				}
+				select {
+				case s.events <- ev:
+				case <-ctx.Done():
+					cursor.Close(context.Background())
+					return nil
+				}
+			}
+			pos = OplogPosition{TS: entry.TS, Op: math.MaxInt32}
+		}
+		err = cursor.Err()
+		cursor.Close(context.Background())
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// A tailable cursor dies when it has nothing to wait on (e.g. it
+		// was opened before the first matching entry); open a new one
+		if !timeutil.Sleep(ctx, oplogReopenDelay) {
+			return nil
+		}
+	}
+}
+
+// fail records the first tailer error and stops the other tailers.
+func (s *OplogStream) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// Next waits for the next event, returning false when ctx is done or the
+// stream has stopped; check Err to tell a failure from a clean stop.
+func (s *OplogStream) Next(ctx context.Context) bool {
+	var ev oplogEvent
+	var ok bool
+	select {
+	case ev, ok = <-s.events:
+		if !ok {
+			return false
+		}
+	case <-ctx.Done():
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions[ev.shard] = ev.pos
+	token, err := bson.Marshal(bson.D{{Key: "oplog", Value: s.positions}})
+	if err != nil {
+		s.err = fmt.Errorf("oplog stream: encode resume token: %w", err)
+		s.cancel()
+		return false
+	}
+	current, err := bson.Marshal(append(bson.D{{Key: "_id", Value: bson.Raw(token)}}, ev.event...))
+	if err != nil {
+		s.err = fmt.Errorf("oplog stream: encode event: %w", err)
+		s.cancel()
+		return false
+	}
+	s.token, s.current = token, current
+	return true
+}
+
+// Decode unmarshals the current event into val.
+func (s *OplogStream) Decode(val interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return errors.New("oplog stream: no current event")
+	}
+	return bson.Unmarshal(s.current, val)
+}
+
+// Err returns the error that stopped the stream, if any.
+func (s *OplogStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// ResumeToken returns the position after the current event on every shard
+// (the starting positions before the first event), for OpenOplogStream's
+// resumeAfter. It is not a change stream resume token.
+func (s *OplogStream) ResumeToken() bson.Raw {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// Close stops every tailer and waits for them to exit. The shard clients
+// belong to the caller and stay connected.
+func (s *OplogStream) Close(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseOplogResumeToken decodes a token from OplogStream.ResumeToken into
+// its per-shard positions.
+func ParseOplogResumeToken(token bson.Raw) (map[string]OplogPosition, error) {
+	var parsed struct {
+		Oplog map[string]OplogPosition `bson:"oplog"`
+	}
+	if err := bson.Unmarshal(token, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid oplog resume token: %w", err)
+	}
+	if len(parsed.Oplog) == 0 {
+		return nil, errors.New("invalid oplog resume token: no shard positions")
+	}
+	return parsed.Oplog, nil
+}
+
+// oplogFilter narrows the server-side scan to CRUD entries and transaction
+// commits, and to the watched namespace where it can be expressed as a
+// query. oplogMatcher makes the exact decision per operation.
+func oplogFilter(db, coll string) bson.D {
+	filter := bson.D{
+		{Key: "op", Value: bson.D{{Key: "$in", Value: bson.A{"i", "u", "d", "c"}}}},
+		{Key: "fromMigrate", Value: bson.D{{Key: "$ne", Value: true}}},
+	}
+	switch {
+	case db == "":
+	case coll == "":
+		filter = append(filter, bson.E{Key: "ns", Value: primitive.Regex{
+			Pattern: "^(" + regexp.QuoteMeta(db+".") + `|admin\.\$cmd$)`,
+		}})
+	default:
+		filter = append(filter, bson.E{Key: "ns", Value: bson.D{{Key: "$in", Value: bson.A{db + "." + coll, "admin.$cmd"}}}})
+	}
+	return filter
+}
+
+// oplogMatcher returns a function reporting whether an operation belongs
+// to the watched namespace and operation types, and which change stream
+// operationType it maps to.
+func oplogMatcher(db, coll string, ops []string) func(oplogEntry) (string, bool) {
+	return func(e oplogEntry) (string, bool) {
+		if e.FromMigrate {
+			return "", false
+		}
+		d, c, ok := strings.Cut(e.NS, ".")
+		if !ok || strings.HasPrefix(c, "system.") {
+			return "", false
+		}
+		switch {
+		case db == "":
+			if d == "admin" || d == "config" || d == "local" {
+				return "", false
+			}
+		case d != db:
+			return "", false
+		case coll != "" && c != coll:
+			return "", false
+		}
+
+		var opType string
+		switch e.Op {
+		case "i":
+			opType = "insert"
+		case "d":
+			opType = "delete"
+		case "u":
+			opType = "update"
+			if isReplacement(e.O) {
+				opType = "replace"
+			}
+		default:
+			return "", false
+		}
+		if len(ops) > 0 && !containsString(ops, opType) {
+			return "", false
+		}
+		return opType, true
+	}
+}
+
+// expandApplyOps returns the operations in entry: the operations of a
+// transaction's applyOps command, or the entry itself.
+func expandApplyOps(entry oplogEntry) []oplogEntry {
+	if entry.Op != "c" {
+		return []oplogEntry{entry}
+	}
+	var cmd struct {
+		ApplyOps []oplogEntry `bson:"applyOps"`
+	}
+	if err := bson.Unmarshal(entry.O, &cmd); err != nil {
+		return nil
+	}
+	return cmd.ApplyOps
+}
+
+// isReplacement reports whether an update entry's o is a whole replacement
+// document rather than update operators ($set, or a $v:2 diff).
+func isReplacement(o bson.Raw) bool {
+	elems, err := o.Elements()
+	if err != nil {
+		return false
+	}
+	for _, e := range elems {
+		if strings.HasPrefix(e.Key(), "$") || e.Key() == "diff" {
+			return false
+		}
+	}
+	return true
+}
+
+// oplogChangeEvent shapes an operation like a change stream event. For
+// updates the document key is o2 (_id plus shard key fields); for deletes
+// it is o.
+func oplogChangeEvent(e oplogEntry, opType string, ts primitive.Timestamp, shard string) bson.D {
+	db, coll, _ := strings.Cut(e.NS, ".")
+	event := bson.D{
+		{Key: "operationType", Value: opType},
+		{Key: "clusterTime", Value: ts},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: db}, {Key: "coll", Value: coll}}},
+	}
+
+	switch opType {
+	case "insert":
+		event = append(event, bson.E{Key: "documentKey", Value: bson.D{{Key: "_id", Value: e.O.Lookup("_id")}}})
+		event = append(event, bson.E{Key: "fullDocument", Value: e.O})
+	case "replace":
+		event = append(event, bson.E{Key: "documentKey", Value: e.O2})
+		event = append(event, bson.E{Key: "fullDocument", Value: e.O})
+	case "update":
+		event = append(event, bson.E{Key: "documentKey", Value: e.O2})
+	case "delete":
+		event = append(event, bson.E{Key: "documentKey", Value: e.O})
+	}
+	return append(event, bson.E{Key: "shard", Value: shard})
+}
@@ -0,0 +1,114 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// splitSampleSize caps how many documents SuggestSplitPoints inspects.
+const splitSampleSize = 10000
+
+// SuggestSplitPoints samples a collection and returns n shard-key values that
+// divide the data into n+1 roughly equal ranges, usable as presplit points.
+// Only ranged keys are supported — hashed keys split on hash values, not data.
+func SuggestSplitPoints(ctx context.Context, client *mongo.Client, db, collection string, key bson.D, n int) ([]bson.D, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("split points: n must be positive")
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("split points: empty shard key")
+	}
+
+	// groupBy the full key so compound keys bucket on the whole tuple
+	groupBy := bson.D{}
+	for _, k := range key {
+		if k.Value == "hashed" {
+			return nil, fmt.Errorf("split points: hashed key field %q not supported", k.Key)
+		}
+		groupBy = append(groupBy, bson.E{Key: k.Key, Value: "$" + k.Key})
+	}
+
+	var groupExpr interface{} = groupBy
+	if len(key) == 1 {
+		groupExpr = "$" + key[0].Key
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: splitSampleSize}}}},
+		{{Key: "$bucketAuto", Value: bson.D{
+			{Key: "groupBy", Value: groupExpr},
+			{Key: "buckets", Value: n + 1},
+		}}},
+	}
+
+	cursor, err := client.Database(db).Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("bucketAuto %s.%s: %w", db, collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	var points []bson.D
+	first := true
+	for cursor.Next(ctx) {
+		var bucket struct {
+			ID struct {
+				Min bson.RawValue `bson:"min"`
+			} `bson:"_id"`
+		}
+		if err := cursor.Decode(&bucket); err != nil {
+			return nil, fmt.Errorf("decode bucket: %w", err)
+		}
+		// The first bucket's min is the lowest value — not a split point
+		if first {
+			first = false
+			continue
+		}
+		point, err := splitPointFromBound(key, bucket.ID.Min)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("bucketAuto cursor: %w", err)
+	}
+
+	return points, nil
+}
+
+// PreSplitChunks splits the chunk containing each point, creating len(points)+1 ranges.
+// Points must be full shard-key values in key order.
+func PreSplitChunks(ctx context.Context, client *mongo.Client, db, collection string, points []bson.D) error {
+	ns := db + "." + collection
+	for _, p := range points {
+		cmd := bson.D{
+			{Key: "split", Value: ns},
+			{Key: "middle", Value: p},
+		}
+		var result bson.M
+		if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+			return fmt.Errorf("split %s at %v: %w", ns, p, err)
+		}
+	}
+	return nil
+}
+
+// splitPointFromBound converts a $bucketAuto boundary into a shard-key document.
+func splitPointFromBound(key bson.D, bound bson.RawValue) (bson.D, error) {
+	if len(key) == 1 {
+		var v interface{}
+		if err := bound.Unmarshal(&v); err != nil {
+			return nil, fmt.Errorf("decode bound: %w", err)
+		}
+		return bson.D{{Key: key[0].Key, Value: v}}, nil
+	}
+
+	var doc bson.D
+	if err := bound.Unmarshal(&doc); err != nil {
+		return nil, fmt.Errorf("decode compound bound: %w", err)
+	}
+	return doc, nil
+}
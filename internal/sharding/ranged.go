@@ -13,31 +13,35 @@ import (
 const rangedCollection = "events_ranged"
 const rangedDocCount = 10000
 
+// rangedSettleTimeout bounds the wait for the balancer before explaining.
+const rangedSettleTimeout = 30 * time.Second
+
 // RunRangedDemo demonstrates ranged sharding for query locality.
 // Uses last_login_date as the shard key so date-range queries
 // target only the relevant shard instead of scatter-gathering.
-func RunRangedDemo(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+func RunRangedDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(rangedDocCount)
+
 	log.Println("=== Ranged Sharding Demo ===")
 	log.Println("Goal: Date-range queries hit only the relevant shard")
+	result := startDemo("ranged", db, coll)
+	defer result.finish()
 
-	DropCollection(ctx, appClient, db, rangedCollection)
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
 
 	// Create ranged shard key on last_login_date
-	if err := ShardCollection(ctx, adminClient, db, rangedCollection, bson.D{{Key: "last_login_date", Value: 1}}); err != nil {
-		return fmt.Errorf("shard collection: %w", err)
+	if err := ShardCollection(ctx, adminClient, db, coll, bson.D{{Key: "last_login_date", Value: 1}}); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
 	}
 	log.Println("Shard key: { last_login_date: 1 }")
 
-	// Create index for the shard key
-	appClient.Database(db).Collection(rangedCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.D{{Key: "last_login_date", Value: 1}},
-	})
-
 	// Insert documents spread over 12 months
-	log.Printf("Inserting %d events across 12 months...", rangedDocCount)
-	baseDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
-	docs := make([]interface{}, rangedDocCount)
-	for i := 0; i < rangedDocCount; i++ {
+	log.Printf("Inserting %d events across 12 months...", docCount)
+	baseDate := DataEpoch
+	docs := make([]interface{}, docCount)
+	for i := 0; i < docCount; i++ {
 		dayOffset := i % 365
 		docs[i] = bson.M{
 			"last_login_date": baseDate.AddDate(0, 0, dayOffset),
@@ -47,34 +51,52 @@ func RunRangedDemo(ctx context.Context, adminClient, appClient *mongo.Client, db
 		}
 	}
 
-	if err := batchInsert(ctx, appClient, db, rangedCollection, docs); err != nil {
-		return fmt.Errorf("insert: %w", err)
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
 	}
 
 	// Analyze distribution
-	dist, err := GetShardDistribution(ctx, adminClient, db, rangedCollection)
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
 	if err != nil {
-		return fmt.Errorf("distribution: %w", err)
+		return result, fmt.Errorf("distribution: %w", err)
 	}
 	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
 
 	// Run a targeted date-range query
-	log.Println("Running date-range query (Jan 2025 only)...")
+	log.Printf("Running date-range query (%s only)...", baseDate.Format("Jan 2006"))
 	filter := bson.D{
 		{Key: "last_login_date", Value: bson.D{
-			{Key: "$gte", Value: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
-			{Key: "$lt", Value: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+			{Key: "$gte", Value: baseDate},
+			{Key: "$lt", Value: baseDate.AddDate(0, 1, 0)},
 		}},
 	}
 
-	shards, err := ExplainQuery(ctx, adminClient, db, rangedCollection, filter)
+	// Let in-flight migrations finish so the explain reflects settled chunks
+	settled, err := WaitForBalanced(ctx, adminClient, db+"."+coll, rangedSettleTimeout)
 	if err != nil {
-		log.Printf("  Explain: %v", err)
-	} else {
-		log.Printf("  Targeted shards: %v (fewer = better locality)", shards)
+		log.Printf("  [WARN] %v", err)
+	} else if !settled {
+		log.Printf("  [WARN] Balancer still migrating %s after %v; checking anyway", coll, rangedSettleTimeout)
+	}
+
+	routing, err := ExplainQueryRouting(ctx, adminClient, db, coll, filter)
+	if err != nil {
+		return result, fmt.Errorf("explain: %w", err)
+	}
+	log.Printf("  Targeted shards: %v of %d (fewer = better locality)", routing.Shards, routing.TotalShards)
+	result.TargetedShards = routing.Shards
+
+	switch {
+	case routing.TotalShards > 1 && len(routing.Shards) >= routing.TotalShards:
+		return result, fmt.Errorf("date-range query scatter-gathered to all %d shards: %v", routing.TotalShards, routing.Shards)
+	case len(routing.Shards) == 1:
+		log.Println("  [VERIFY] Query targeted a single shard")
+	default:
+		log.Printf("  [INFO] Query spans a chunk boundary across %d shards (still targeted)", len(routing.Shards))
 	}
 
 	log.Println("Result: Range queries avoid scatter-gather")
 	log.Println("")
-	return nil
+	return result, nil
 }
@@ -16,16 +16,22 @@ const categoryCount = 10
 // RunRefinableDemo demonstrates refining an existing shard key.
 // Starts with { category: 1 }, inserts data, then refines to
 // { category: 1, sku: 1 } to further subdivide chunks without resharding.
-func RunRefinableDemo(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+func RunRefinableDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(refinableDocCount)
+
 	log.Println("=== Refinable Shard Key Demo ===")
 	log.Println("Goal: Add suffix to shard key without full reshard")
+	result := startDemo("refinable", db, coll)
+	defer result.finish()
 
-	DropCollection(ctx, appClient, db, refinableCollection)
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
 
 	// Start with a simple shard key
 	initialKey := bson.D{{Key: "category", Value: 1}}
-	if err := ShardCollection(ctx, adminClient, db, refinableCollection, initialKey); err != nil {
-		return fmt.Errorf("shard collection: %w", err)
+	if err := ShardCollection(ctx, adminClient, db, coll, initialKey); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
 	}
 	log.Println("Initial shard key: { category: 1 }")
 
@@ -34,19 +40,19 @@ func RunRefinableDemo(ctx context.Context, adminClient, appClient *mongo.Client,
 		{Key: "category", Value: 1},
 		{Key: "sku", Value: 1},
 	}
-	appClient.Database(db).Collection(refinableCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: refinedKey,
-	})
+	if err := EnsureShardKeyIndex(ctx, appClient, db, coll, refinedKey); err != nil {
+		return result, fmt.Errorf("refined key index: %w", err)
+	}
 
 	// Insert products across categories
-	log.Printf("Inserting %d products across %d categories...", refinableDocCount, categoryCount)
+	log.Printf("Inserting %d products across %d categories...", docCount, categoryCount)
 	categories := []string{
 		"electronics", "clothing", "books", "home", "sports",
 		"toys", "food", "automotive", "health", "garden",
 	}
 
-	docs := make([]interface{}, refinableDocCount)
-	for i := 0; i < refinableDocCount; i++ {
+	docs := make([]interface{}, docCount)
+	for i := 0; i < docCount; i++ {
 		docs[i] = bson.M{
 			"category": categories[i%categoryCount],
 			"sku":      fmt.Sprintf("SKU-%06d", i),
@@ -55,34 +61,35 @@ func RunRefinableDemo(ctx context.Context, adminClient, appClient *mongo.Client,
 		}
 	}
 
-	if err := batchInsert(ctx, appClient, db, refinableCollection, docs); err != nil {
-		return fmt.Errorf("insert: %w", err)
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
 	}
 
 	// Show distribution before refinement
 	log.Println("Distribution BEFORE refinement:")
-	distBefore, err := GetShardDistribution(ctx, adminClient, db, refinableCollection)
+	distBefore, err := GetShardDistribution(ctx, adminClient, db, coll)
 	if err != nil {
-		return fmt.Errorf("distribution before: %w", err)
+		return result, fmt.Errorf("distribution before: %w", err)
 	}
 	PrintDistribution(distBefore)
 
 	// Refine the shard key
 	log.Println("Refining shard key to { category: 1, sku: 1 }...")
-	if err := RefineShardKey(ctx, adminClient, db, refinableCollection, refinedKey); err != nil {
-		return fmt.Errorf("refine key: %w", err)
+	if err := RefineShardKey(ctx, adminClient, db, coll, refinedKey); err != nil {
+		return result, fmt.Errorf("refine key: %w", err)
 	}
 	log.Println("Shard key refined successfully")
 
 	// Show distribution after refinement
 	log.Println("Distribution AFTER refinement:")
-	distAfter, err := GetShardDistribution(ctx, adminClient, db, refinableCollection)
+	distAfter, err := GetShardDistribution(ctx, adminClient, db, coll)
 	if err != nil {
-		return fmt.Errorf("distribution after: %w", err)
+		return result, fmt.Errorf("distribution after: %w", err)
 	}
 	PrintDistribution(distAfter)
+	result.setDistribution(ctx, adminClient, distAfter)
 
 	log.Println("Result: Key refined without full reshard operation")
 	log.Println("")
-	return nil
+	return result, nil
 }
@@ -0,0 +1,221 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const reshardCollection = "orders_reshard"
+const reshardDocCount = 5000
+
+// ReshardProgress summarizes an in-flight reshardCollection operation,
+// aggregated from the coordinator, donor, and recipient $currentOp entries.
+type ReshardProgress struct {
+	Namespace           string
+	InProgress          bool
+	CoordinatorState    string
+	DocumentsCopied     int64
+	DocumentsToCopy     int64
+	OplogEntriesFetched int64
+	OplogEntriesApplied int64
+	ElapsedSecs         int64
+	RemainingSecs       int64 // Server estimate; -1 when not yet known
+}
+
+// PercentCopied returns the clone-phase completion percentage.
+func (p *ReshardProgress) PercentCopied() float64 {
+	if p.DocumentsToCopy == 0 {
+		return 0
+	}
+	return float64(p.DocumentsCopied) / float64(p.DocumentsToCopy) * 100
+}
+
+// RunReshardDemo reshards a collection to a new key while polling progress.
+// Starts on { customer_id: 1 } and reshards to { order_id: "hashed" }.
+func RunReshardDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(reshardDocCount)
+
+	log.Println("=== Resharding Demo ===")
+	log.Println("Goal: Change a shard key online and watch progress")
+	result := startDemo("reshard", db, coll)
+	defer result.finish()
+
+	major, _, err := ServerVersion(ctx, adminClient)
+	if err != nil {
+		return result, fmt.Errorf("server version: %w", err)
+	}
+	if major < 5 {
+		log.Printf("  [SKIP] reshardCollection requires MongoDB 5.0+ (server is %d.x)", major)
+		log.Println("")
+		result.skip(fmt.Sprintf("requires MongoDB 5.0+ (server is %d.x)", major))
+		return result, nil
+	}
+
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
+
+	initialKey := bson.D{{Key: "customer_id", Value: 1}}
+	if err := ShardCollection(ctx, adminClient, db, coll, initialKey); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
+	}
+	log.Println("Initial shard key: { customer_id: 1 }")
+
+	docs := make([]interface{}, docCount)
+	for i := 0; i < docCount; i++ {
+		docs[i] = bson.M{
+			"customer_id": fmt.Sprintf("cust_%04d", i%50),
+			"order_id":    fmt.Sprintf("ORD-%08d", i),
+			"amount":      float64(10 + (i % 300)),
+		}
+	}
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
+	}
+
+	ns := db + "." + coll
+	newKey := bson.D{{Key: "order_id", Value: "hashed"}}
+
+	// reshardCollection blocks until done, so run it alongside the poller
+	log.Println("Resharding to { order_id: 'hashed' }...")
+	done := make(chan error, 1)
+	go func() {
+		done <- ReshardCollection(ctx, adminClient, db, coll, newKey)
+	}()
+
+	pollCtx, pollCancel := context.WithCancel(ctx)
+	go WatchReshardProgress(pollCtx, adminClient, ns, 2*time.Second, PrintReshardProgress)
+
+	err = <-done
+	pollCancel()
+	if err != nil {
+		return result, fmt.Errorf("reshard: %w", err)
+	}
+	log.Println("  [OK] Resharding complete")
+
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
+	if err != nil {
+		return result, fmt.Errorf("distribution: %w", err)
+	}
+	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
+
+	log.Println("Result: Shard key changed online with progress visibility")
+	log.Println("")
+	return result, nil
+}
+
+// ReshardCollection changes a collection's shard key. The command blocks
+// until resharding finishes; use GetReshardProgress from another goroutine
+// to observe it.
+func ReshardCollection(ctx context.Context, client *mongo.Client, db, collection string, key bson.D) error {
+	ns := db + "." + collection
+	cmd := bson.D{
+		{Key: "reshardCollection", Value: ns},
+		{Key: "key", Value: key},
+	}
+
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("reshardCollection %s: %w", ns, err)
+	}
+	return nil
+}
+
+// GetReshardProgress reports resharding progress for ns from $currentOp.
+// InProgress is false when no resharding operation is running.
+func GetReshardProgress(ctx context.Context, client *mongo.Client, ns string) (*ReshardProgress, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$currentOp", Value: bson.D{
+			{Key: "allUsers", Value: true},
+			{Key: "localOps", Value: false},
+		}}},
+		{{Key: "$match", Value: bson.D{
+			{Key: "type", Value: "op"},
+			{Key: "originatingCommand.reshardCollection", Value: ns},
+		}}},
+	}
+
+	cursor, err := client.Database("admin").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("currentOp: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	progress := &ReshardProgress{Namespace: ns, RemainingSecs: -1}
+	for cursor.Next(ctx) {
+		var op bson.M
+		if err := cursor.Decode(&op); err != nil {
+			continue
+		}
+		progress.InProgress = true
+
+		if state := stringVal(op, "coordinatorState"); state != "" {
+			progress.CoordinatorState = state
+		}
+		if elapsed := intVal(op, "totalOperationTimeElapsedSecs"); elapsed > progress.ElapsedSecs {
+			progress.ElapsedSecs = elapsed
+		}
+		if remaining, ok := op["remainingOperationTimeEstimatedSecs"]; ok {
+			if r := ToInt64(remaining); r > progress.RemainingSecs {
+				progress.RemainingSecs = r
+			}
+		}
+
+		// Copy and oplog counters are reported per recipient shard
+		if _, ok := op["recipientState"]; ok {
+			progress.DocumentsCopied += intVal(op, "documentsCopied")
+			progress.DocumentsToCopy += intVal(op, "approxDocumentsToCopy")
+			progress.OplogEntriesFetched += intVal(op, "oplogEntriesFetched")
+			progress.OplogEntriesApplied += intVal(op, "oplogEntriesApplied")
+		}
+	}
+
+	return progress, nil
+}
+
+// WatchReshardProgress polls GetReshardProgress every interval and passes each
+// snapshot to report until the operation finishes or ctx is cancelled.
+func WatchReshardProgress(ctx context.Context, client *mongo.Client, ns string, interval time.Duration, report func(*ReshardProgress)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		progress, err := GetReshardProgress(ctx, client, ns)
+		if err != nil {
+			log.Printf("  [WARN] reshard progress: %v", err)
+			continue
+		}
+		if !progress.InProgress {
+			if seen {
+				return
+			}
+			continue
+		}
+		seen = true
+		report(progress)
+	}
+}
+
+// PrintReshardProgress logs a one-line progress snapshot.
+func PrintReshardProgress(p *ReshardProgress) {
+	eta := "unknown"
+	if p.RemainingSecs >= 0 {
+		eta = fmt.Sprintf("%ds", p.RemainingSecs)
+	}
+	log.Printf("  [reshard] state=%s copied=%d/%d (%.1f%%) oplog applied=%d/%d elapsed=%ds eta=%s",
+		p.CoordinatorState, p.DocumentsCopied, p.DocumentsToCopy, p.PercentCopied(),
+		p.OplogEntriesApplied, p.OplogEntriesFetched, p.ElapsedSecs, eta)
+}
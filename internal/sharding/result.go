@@ -0,0 +1,52 @@
+package sharding
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DemoResult is what a strategy demo measured, for callers that want more
+// than the log output.
+type DemoResult struct {
+	Demo           string             `json:"demo"`
+	Namespace      string             `json:"namespace"`
+	Skipped        string             `json:"skipped,omitempty"` // reason the demo did not run
+	Error          string             `json:"error,omitempty"`   // set by callers when the demo failed part-way
+	Distribution   *ShardDistribution `json:"distribution,omitempty"`
+	TotalShards    int                `json:"totalShards"`
+	BalanceScore   float64            `json:"balanceScore"`             // 1 = perfectly even, 0 = one shard off by its whole share
+	TargetedShards []string           `json:"targetedShards,omitempty"` // shards hit by the demo's sample query
+	Duration       time.Duration      `json:"durationNs"`
+
+	start time.Time
+}
+
+// startDemo begins timing a demo run.
+func startDemo(name, db, coll string) *DemoResult {
+	return &DemoResult{Demo: name, Namespace: db + "." + coll, start: time.Now()}
+}
+
+// finish records the elapsed time; demos defer it.
+func (r *DemoResult) finish() {
+	r.Duration = time.Since(r.start)
+}
+
+// skip marks the demo as not run.
+func (r *DemoResult) skip(reason string) {
+	r.Skipped = reason
+}
+
+// setDistribution stores dist and scores it against the cluster's shard count.
+func (r *DemoResult) setDistribution(ctx context.Context, client *mongo.Client, dist *ShardDistribution) {
+	r.Distribution = dist
+	shardCount, err := CountShards(ctx, client)
+	if err != nil {
+		log.Printf("  [WARN] %v", err)
+		return
+	}
+	r.TotalShards = shardCount
+	r.BalanceScore = BalanceScore(dist, shardCount)
+}
@@ -0,0 +1,23 @@
+package sharding
+
+import "math"
+
+// Scale multiplies the document counts of a demo or lab in the sharding,
+// operations and ha packages: 0.1 for a quick smoke test, 100 for a
+// realistic distribution. Non-positive values, including the zero value,
+// mean 1. Demos that rely on volume (jumbo chunks, migrations,
+// defragmentation) may not reach their threshold when scaled down and then
+// report that instead of the effect.
+type Scale float64
+
+// Count scales a base document count, never below one document.
+func (s Scale) Count(base int) int {
+	return max(1, int(math.Round(float64(base)*s.factor())))
+}
+
+func (s Scale) factor() float64 {
+	if s <= 0 {
+		return 1
+	}
+	return float64(s)
+}
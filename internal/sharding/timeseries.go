@@ -0,0 +1,191 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const timeSeriesCollection = "metrics_timeseries"
+const timeSeriesDocCount = 9000
+const sensorCount = 30
+const cappedSizeBytes = 1 << 20
+
+// validGranularities lists the bucket granularities accepted by createCollection.
+var validGranularities = map[string]bool{
+	"seconds": true,
+	"minutes": true,
+	"hours":   true,
+}
+
+// RunTimeSeriesDemo demonstrates sharding a time-series collection.
+// Time-series collections must be created explicitly and can only be
+// sharded on the metaField (and/or timeField), so the shard key here
+// is { meta: 1 } — each sensor's buckets stay together on one shard.
+func RunTimeSeriesDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	docCount := scale.Count(timeSeriesDocCount)
+
+	log.Println("=== Time-Series Sharding Demo ===")
+	log.Println("Goal: Shard a time-series collection on its metaField")
+	result := startDemo("timeseries", db, coll)
+	defer result.finish()
+
+	major, minor, err := ServerVersion(ctx, adminClient)
+	if err != nil {
+		return result, fmt.Errorf("server version: %w", err)
+	}
+	if major < 5 || (major == 5 && minor < 1) {
+		log.Printf("  [SKIP] Sharded time-series collections require MongoDB 5.1+ (server is %d.%d)", major, minor)
+		log.Println("")
+		result.skip(fmt.Sprintf("requires MongoDB 5.1+ (server is %d.%d)", major, minor))
+		return result, nil
+	}
+
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
+
+	if err := CreateTimeSeriesCollection(ctx, appClient, db, coll, "ts", "meta", "minutes"); err != nil {
+		return result, fmt.Errorf("create time-series: %w", err)
+	}
+	log.Println("Created time-series collection: timeField=ts metaField=meta granularity=minutes")
+
+	if err := ShardCollection(ctx, adminClient, db, coll, bson.D{{Key: "meta", Value: 1}}); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
+	}
+	log.Println("Shard key: { meta: 1 }")
+
+	// Insert a reading a minute from many sensors, starting at DataEpoch
+	log.Printf("Inserting %d readings from %d sensors...", docCount, sensorCount)
+	base := DataEpoch
+	docs := make([]interface{}, docCount)
+	for i := 0; i < docCount; i++ {
+		docs[i] = bson.M{
+			"ts":          base.Add(time.Duration(i/sensorCount) * time.Minute),
+			"meta":        fmt.Sprintf("sensor_%03d", i%sensorCount),
+			"temperature": 15 + float64(i%200)/10,
+			"humidity":    40 + i%30,
+		}
+	}
+
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
+	}
+
+	// Time-series data lives in system.buckets.<coll>, which is what gets sharded
+	dist, err := GetShardDistribution(ctx, adminClient, db, "system.buckets."+coll)
+	if err != nil {
+		log.Printf("  [WARN] distribution: %v", err)
+	} else {
+		log.Println("Bucket distribution (one bucket holds many readings):")
+		PrintDistribution(dist)
+		result.setDistribution(ctx, adminClient, dist)
+	}
+
+	checkCappedRejected(ctx, adminClient, db, coll+"_capped")
+
+	log.Println("Result: Time-series buckets are sharded by sensor (metaField)")
+	log.Println("")
+	return result, nil
+}
+
+// CreateTimeSeriesCollection explicitly creates a time-series collection.
+// Granularity must be "seconds", "minutes", or "hours"; metaField may be empty.
+// Requires MongoDB 5.0+.
+func CreateTimeSeriesCollection(ctx context.Context, client *mongo.Client, db, coll string, timeField, metaField string, granularity string) error {
+	if timeField == "" {
+		return fmt.Errorf("create time-series %s: timeField required", coll)
+	}
+	if !validGranularities[granularity] {
+		return fmt.Errorf("create time-series %s: invalid granularity %q (want seconds, minutes or hours)", coll, granularity)
+	}
+
+	major, _, err := ServerVersion(ctx, client)
+	if err != nil {
+		return fmt.Errorf("server version: %w", err)
+	}
+	if major < 5 {
+		return fmt.Errorf("create time-series %s: requires MongoDB 5.0+ (server is %d.x)", coll, major)
+	}
+
+	ts := bson.D{
+		{Key: "timeField", Value: timeField},
+		{Key: "granularity", Value: granularity},
+	}
+	if metaField != "" {
+		ts = append(ts, bson.E{Key: "metaField", Value: metaField})
+	}
+
+	cmd := bson.D{
+		{Key: "create", Value: coll},
+		{Key: "timeseries", Value: ts},
+	}
+
+	var result bson.M
+	if err := client.Database(db).RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("create time-series %s.%s: %w", db, coll, err)
+	}
+	return nil
+}
+
+// CreateCappedCollection explicitly creates a capped collection of sizeBytes,
+// optionally limited to maxDocs documents (0 = no document limit).
+// Capped collections cannot be sharded; they always live on the primary shard.
+func CreateCappedCollection(ctx context.Context, client *mongo.Client, db, coll string, sizeBytes, maxDocs int64) error {
+	if sizeBytes <= 0 {
+		return fmt.Errorf("create capped %s: size must be positive", coll)
+	}
+
+	cmd := bson.D{
+		{Key: "create", Value: coll},
+		{Key: "capped", Value: true},
+		{Key: "size", Value: sizeBytes},
+	}
+	if maxDocs > 0 {
+		cmd = append(cmd, bson.E{Key: "max", Value: maxDocs})
+	}
+
+	var result bson.M
+	if err := client.Database(db).RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return fmt.Errorf("create capped %s.%s: %w", db, coll, err)
+	}
+	return nil
+}
+
+// checkCappedRejected creates a capped collection and shows that
+// shardCollection refuses it, unlike the time-series collection above.
+func checkCappedRejected(ctx context.Context, client *mongo.Client, db, coll string) {
+	log.Printf("Creating capped collection %s and trying to shard it...", coll)
+	capped := client.Database(db).Collection(coll)
+	capped.Drop(ctx)
+	defer capped.Drop(ctx)
+
+	if err := CreateCappedCollection(ctx, client, db, coll, cappedSizeBytes, 0); err != nil {
+		log.Printf("  [WARN] %v", err)
+		return
+	}
+	if err := ShardCollection(ctx, client, db, coll, bson.D{{Key: "meta", Value: 1}}); err != nil {
+		log.Printf("  [VERIFY] shardCollection rejected the capped collection: %v", err)
+		log.Println("  Capped collections stay unsharded on the database's primary shard")
+		return
+	}
+	log.Printf("  [WARN] shardCollection accepted capped collection %s", coll)
+}
+
+// ServerVersion returns the major and minor version reported by buildInfo.
+func ServerVersion(ctx context.Context, client *mongo.Client) (int, int, error) {
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&result); err != nil {
+		return 0, 0, fmt.Errorf("buildInfo: %w", err)
+	}
+
+	parts, ok := result["versionArray"].(bson.A)
+	if !ok || len(parts) < 2 {
+		return 0, 0, fmt.Errorf("unexpected buildInfo version format")
+	}
+	return int(ToInt64(parts[0])), int(ToInt64(parts[1])), nil
+}
@@ -0,0 +1,141 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const uniqueCollection = "accounts_unique"
+const uniqueTenantCount = 4
+const uniqueUsersPerTenant = 500
+
+// RunUniqueDemo demonstrates that MongoDB cannot enforce uniqueness on a
+// field outside the shard key. Shards on a unique { tenant_id: 1, email: 1 },
+// shows a unique index on { email: 1 } being rejected, then a unique index
+// with the whole shard key as its prefix being accepted. Emails end up
+// unique per tenant but not across tenants.
+func RunUniqueDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
+	log.Println("=== Unique Index Across Shards Demo ===")
+	log.Println("Goal: Show which unique indexes a sharded collection can enforce")
+	result := startDemo("unique", db, coll)
+	defer result.finish()
+
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
+
+	key := bson.D{
+		{Key: "tenant_id", Value: 1},
+		{Key: "email", Value: 1},
+	}
+	if err := ShardCollectionUnique(ctx, adminClient, db, coll, key); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
+	}
+	log.Println("Shard key: { tenant_id: 1, email: 1 } (unique)")
+
+	// Split on tenant boundaries so the balancer can place tenants on different shards
+	var points []bson.D
+	for t := 2; t <= uniqueTenantCount; t++ {
+		points = append(points, bson.D{{Key: "tenant_id", Value: fmt.Sprintf("tenant_%d", t)}, {Key: "email", Value: primitive.MinKey{}}})
+	}
+	if err := PreSplitChunks(ctx, adminClient, db, coll, points); err != nil {
+		log.Printf("  [WARN] presplit: %v", err)
+	}
+
+	accounts := adminClient.Database(db).Collection(coll)
+
+	// A unique index must have the whole shard key as a prefix
+	log.Println("Creating unique index on { email: 1 } (not prefixed by the shard key)...")
+	_, err := accounts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err == nil {
+		return result, fmt.Errorf("unique index on { email: 1 } was accepted on a collection sharded by %s", FormatKey(key))
+	}
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		log.Printf("  [VERIFY] Rejected with code %d (%s): %s", cmdErr.Code, cmdErr.Name, cmdErr.Message)
+	} else {
+		log.Printf("  [VERIFY] Rejected: %v", err)
+	}
+
+	log.Println("Creating unique index on { tenant_id: 1, email: 1, user_id: 1 } (prefixed by the whole shard key)...")
+	_, err = accounts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return result, fmt.Errorf("shard-key-prefixed unique index: %w", err)
+	}
+	log.Println("  [OK] Accepted: documents with equal index keys have equal shard keys, so they live in")
+	log.Println("       the same chunk and each shard can check the index locally")
+	log.Println("  Per-tenant email uniqueness itself comes from the unique shard key { tenant_id: 1, email: 1 }")
+
+	usersPerTenant := scale.Count(uniqueUsersPerTenant)
+	log.Printf("Inserting %d accounts across %d tenants...", uniqueTenantCount*usersPerTenant, uniqueTenantCount)
+	docs := make([]interface{}, 0, uniqueTenantCount*usersPerTenant)
+	for t := 1; t <= uniqueTenantCount; t++ {
+		for u := 0; u < usersPerTenant; u++ {
+			docs = append(docs, bson.M{
+				"tenant_id": fmt.Sprintf("tenant_%d", t),
+				"user_id":   fmt.Sprintf("user_%06d", u),
+				"email":     fmt.Sprintf("user%d@example.com", u),
+			})
+		}
+	}
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
+	}
+
+	appAccounts := appClient.Database(db).Collection(coll)
+
+	// Same tenant, new user_id, existing email: the unique shard key catches it
+	log.Println("Inserting a duplicate email within tenant_1...")
+	_, err = appAccounts.InsertOne(ctx, bson.M{"tenant_id": "tenant_1", "user_id": "user_dup", "email": "user0@example.com"})
+	switch {
+	case err == nil:
+		return result, fmt.Errorf("duplicate email within tenant_1 was accepted")
+	case mongo.IsDuplicateKeyError(err):
+		log.Printf("  [VERIFY] Rejected: %v", err)
+	default:
+		return result, fmt.Errorf("insert duplicate within tenant: %w", err)
+	}
+
+	// Different tenant, same email: nothing enforces uniqueness across tenants
+	log.Println("Inserting the same email into tenant_2...")
+	if _, err := appAccounts.InsertOne(ctx, bson.M{"tenant_id": "tenant_2", "user_id": "user_dup", "email": "user0@example.com"}); err != nil {
+		return result, fmt.Errorf("insert same email in another tenant: %w", err)
+	}
+	count, err := appAccounts.CountDocuments(ctx, bson.M{"email": "user0@example.com"})
+	if err != nil {
+		return result, fmt.Errorf("count email: %w", err)
+	}
+	log.Printf("  [INFO] user0@example.com now exists %d times across tenants", count)
+
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
+	if err != nil {
+		return result, fmt.Errorf("distribution: %w", err)
+	}
+	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
+
+	routing, err := ExplainQueryRouting(ctx, adminClient, db, coll, bson.D{{Key: "email", Value: "user0@example.com"}})
+	if err != nil {
+		log.Printf("  [WARN] explain: %v", err)
+	} else {
+		log.Printf("  Lookup by email alone targets %v of %d shards", routing.Shards, routing.TotalShards)
+		result.TargetedShards = routing.Shards
+	}
+
+	log.Println("Result: Global uniqueness needs the field in the shard key, or a separate collection sharded on it")
+	log.Println("")
+	return result, nil
+}
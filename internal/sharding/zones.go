@@ -9,12 +9,21 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/stats"
+	"go-mongodb-sharding-poc/internal/timeutil"
 )
 
 const zoneCollection = "customers_zones"
-const zoneDocCount = 9000
 const docsPerRegion = 3000
 
+// EUZoneShard is the shard pinned to the EU zone. The latency comparison
+// treats the client as sitting in the EU, next to this shard.
+const EUZoneShard = "shard1rs"
+
+// zoneLatencyReads is how many single-document reads are timed per region.
+const zoneLatencyReads = 50
+
 // Zone represents a geographic zone with its assigned shard.
 type Zone struct {
 	Name  string
@@ -25,15 +34,22 @@ type Zone struct {
 // Creates EU, US, and APAC zones, assigns each to a specific shard, tags
 // shard key ranges by region, inserts region-tagged data, and verifies
 // that documents land on the correct geographic shard (GDPR compliance).
-func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db string) error {
+// It then times reads per region from an EU client; with cross-region
+// latency simulated on the non-EU shards (sharding-demo -zone-latency),
+// EU reads stay fast because the zone keeps EU data on the EU shard.
+func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db, coll string, scale Scale) (*DemoResult, error) {
 	log.Println("=== Zone-Based Sharding Demo ===")
 	log.Println("Goal: Geographic data residency for GDPR compliance")
+	result := startDemo("zones", db, coll)
+	defer result.finish()
 
-	DropCollection(ctx, appClient, db, zoneCollection)
+	if err := DropShardedCollection(ctx, adminClient, appClient, db, coll); err != nil {
+		return result, err
+	}
 
 	// Define zones mapped to shards
 	zones := []Zone{
-		{Name: "EU-Zone", Shard: "shard1rs"},
+		{Name: "EU-Zone", Shard: EUZoneShard},
 		{Name: "US-Zone", Shard: "shard2rs"},
 		{Name: "APAC-Zone", Shard: "shard3rs"},
 	}
@@ -44,13 +60,8 @@ func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db s
 		{Key: "customer_id", Value: 1},
 	}
 
-	// Create supporting index before sharding
-	appClient.Database(db).Collection(zoneCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: shardKey,
-	})
-
-	if err := ShardCollection(ctx, adminClient, db, zoneCollection, shardKey); err != nil {
-		return fmt.Errorf("shard collection: %w", err)
+	if err := ShardCollection(ctx, adminClient, db, coll, shardKey); err != nil {
+		return result, fmt.Errorf("shard collection: %w", err)
 	}
 	log.Println("Shard key: { region: 1, customer_id: 1 }")
 
@@ -58,13 +69,13 @@ func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db s
 	log.Println("Creating geographic zones...")
 	for _, z := range zones {
 		if err := AddShardToZone(ctx, adminClient, z.Shard, z.Name); err != nil {
-			return fmt.Errorf("add shard to zone: %w", err)
+			return result, fmt.Errorf("add shard to zone: %w", err)
 		}
 		log.Printf("  %s → %s", z.Shard, z.Name)
 	}
 
 	// Tag shard key ranges by region
-	ns := db + "." + zoneCollection
+	ns := db + "." + coll
 	regionRanges := []struct {
 		Region string
 		Zone   string
@@ -85,25 +96,26 @@ func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db s
 			{Key: "customer_id", Value: primitive.MaxKey{}},
 		}
 		if err := UpdateZoneKeyRange(ctx, adminClient, ns, min, max, r.Zone); err != nil {
-			return fmt.Errorf("update zone range for %s: %w", r.Region, err)
+			return result, fmt.Errorf("update zone range for %s: %w", r.Region, err)
 		}
 		log.Printf("  region=%s → %s", r.Region, r.Zone)
 	}
 
 	// Insert documents with region-tagged PII
-	log.Printf("Inserting %d documents (%d per region)...", zoneDocCount, docsPerRegion)
 	regions := []string{"EU", "US", "APAC"}
-	docs := make([]interface{}, 0, zoneDocCount)
+	perRegion := scale.Count(docsPerRegion)
+	log.Printf("Inserting %d documents (%d per region)...", perRegion*len(regions), perRegion)
+	docs := make([]interface{}, 0, perRegion*len(regions))
 
 	for _, region := range regions {
-		for i := 0; i < docsPerRegion; i++ {
+		for i := 0; i < perRegion; i++ {
 			docs = append(docs, bson.M{
 				"region":      region,
 				"customer_id": fmt.Sprintf("%s-%06d", region, i),
 				"name":        fmt.Sprintf("Customer %s-%d", region, i),
 				"email":       fmt.Sprintf("customer%d@%s.example.com", i, regionToDomain(region)),
 				"phone":       fmt.Sprintf("+%s%010d", regionToPrefix(region), i),
-				"created_at":  time.Now().UTC(),
+				"created_at":  DataEpoch.Add(time.Duration(i) * time.Second),
 				"pii_data": bson.M{
 					"address":     fmt.Sprintf("%d Main St, %s", i, regionToCity(region)),
 					"postal_code": fmt.Sprintf("%05d", i%99999),
@@ -112,20 +124,23 @@ func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db s
 		}
 	}
 
-	if err := batchInsert(ctx, appClient, db, zoneCollection, docs); err != nil {
-		return fmt.Errorf("insert: %w", err)
+	if err := batchInsert(ctx, appClient, db, coll, docs); err != nil {
+		return result, fmt.Errorf("insert: %w", err)
 	}
 
 	// Wait for balancer to move chunks to correct zones
 	log.Println("Waiting for balancer to enforce zone boundaries...")
-	time.Sleep(10 * time.Second)
+	if !timeutil.Sleep(ctx, 10*time.Second) {
+		return result, ctx.Err()
+	}
 
 	// Analyze distribution
-	dist, err := GetShardDistribution(ctx, adminClient, db, zoneCollection)
+	dist, err := GetShardDistribution(ctx, adminClient, db, coll)
 	if err != nil {
-		return fmt.Errorf("distribution: %w", err)
+		return result, fmt.Errorf("distribution: %w", err)
 	}
 	PrintDistribution(dist)
+	result.setDistribution(ctx, adminClient, dist)
 
 	// Verify GDPR compliance — check region data landed on correct shard
 	log.Println("")
@@ -142,7 +157,7 @@ func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db s
 			}
 		}
 
-		counts, err := GetPerShardDocCount(ctx, adminClient, db, zoneCollection, "region", r.Region)
+		counts, err := GetPerShardDocCount(ctx, adminClient, db, coll, "region", r.Region)
 		if err != nil {
 			log.Printf("  [WARN] Could not verify %s: %v", r.Region, err)
 			continue
@@ -177,10 +192,54 @@ func RunZoneDemo(ctx context.Context, adminClient, appClient *mongo.Client, db s
 		log.Println("  Some chunks still migrating (balancer in progress)")
 	}
 
+	// Latency: EU data is read locally, other regions cross the simulated WAN
+	log.Println("")
+	log.Println("REGION READ LATENCY (client in EU)")
+	latency := make(map[string]time.Duration, len(regions))
+	for _, region := range regions {
+		p50, err := measureRegionReads(ctx, appClient, db, coll, region, perRegion)
+		if err != nil {
+			log.Printf("  [WARN] Could not time %s reads: %v", region, err)
+			continue
+		}
+		latency[region] = p50
+		log.Printf("  %-6s p50=%v over %d reads", region, p50.Round(10*time.Microsecond), zoneLatencyReads)
+	}
+	local, remote := latency["EU"], latency["US"]
+	switch {
+	case local == 0 || remote == 0:
+	case remote > 2*local:
+		log.Printf("  [VERIFY] EU reads stay on %s: %v locally vs %v cross-region",
+			EUZoneShard, local.Round(10*time.Microsecond), remote.Round(10*time.Microsecond))
+	default:
+		log.Printf("  [INFO] No cross-region latency measured (EU %v vs US %v)",
+			local.Round(10*time.Microsecond), remote.Round(10*time.Microsecond))
+		log.Println("  Run sharding-demo with -zone-latency to simulate it; if it was set, the fail point did not delay the routed finds")
+	}
+
 	log.Println("")
 	log.Println("Result: Zone-based sharding enforces geographic data residency")
 	log.Println("")
-	return nil
+	return result, nil
+}
+
+// measureRegionReads times zoneLatencyReads shard-key-targeted reads of
+// one region's perRegion customers and returns the median latency.
+func measureRegionReads(ctx context.Context, client *mongo.Client, db, coll, region string, perRegion int) (time.Duration, error) {
+	customers := client.Database(db).Collection(coll)
+	samples := make([]time.Duration, 0, zoneLatencyReads)
+	for i := 0; i < zoneLatencyReads; i++ {
+		filter := bson.D{
+			{Key: "region", Value: region},
+			{Key: "customer_id", Value: fmt.Sprintf("%s-%06d", region, i*perRegion/zoneLatencyReads)},
+		}
+		start := time.Now()
+		if err := customers.FindOne(ctx, filter).Err(); err != nil {
+			return 0, err
+		}
+		samples = append(samples, time.Since(start))
+	}
+	return stats.Percentile(samples, 0.50), nil
 }
 
 // AddShardToZone assigns a shard to a named zone.
@@ -0,0 +1,73 @@
+// Package stats summarises latency samples for the labs and benchmarks.
+package stats
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Percentile returns the p-th percentile (0 <= p <= 1) of samples, which
+// need not be sorted, interpolating linearly between the two closest
+// ranks: with 4 samples p50 is halfway between the 2nd and 3rd. p outside
+// [0, 1] is clamped, and an empty slice yields 0.
+func Percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return percentileSorted(sorted, p)
+}
+
+// Percentiles is Percentile for several p at once, sorting samples only
+// once. The results are in the order of ps.
+func Percentiles(samples []time.Duration, ps ...float64) []time.Duration {
+	out := make([]time.Duration, len(ps))
+	if len(samples) == 0 {
+		return out
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	for i, p := range ps {
+		out[i] = percentileSorted(sorted, p)
+	}
+	return out
+}
+
+func percentileSorted(sorted []time.Duration, p float64) time.Duration {
+	p = math.Max(0, math.Min(1, p))
+	rank := p * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return sorted[lo] + time.Duration(math.Round(frac*float64(sorted[hi]-sorted[lo])))
+}
+
+// Mean returns the average of samples, or 0 if empty.
+func Mean(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range samples {
+		total += d
+	}
+	return total / time.Duration(len(samples))
+}
+
+// Min returns the smallest of samples, or 0 if empty.
+func Min(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	return slices.Min(samples)
+}
+
+// Max returns the largest of samples, or 0 if empty.
+func Max(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	return slices.Max(samples)
+}
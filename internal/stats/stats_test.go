@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ms(ns ...int) []time.Duration {
+	out := make([]time.Duration, len(ns))
+	for i, n := range ns {
+		out[i] = time.Duration(n) * time.Millisecond
+	}
+	return out
+}
+
+func TestPercentile(t *testing.T) {
+	samples := ms(40, 10, 30, 20) // unsorted on purpose
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{0.5, 25 * time.Millisecond}, // halfway between 20 and 30
+		{1, 40 * time.Millisecond},
+		{0.99, 39700 * time.Microsecond},
+		{-1, 10 * time.Millisecond}, // clamped
+		{2, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Percentile(samples, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if !reflect.DeepEqual(samples, ms(40, 10, 30, 20)) {
+		t.Errorf("Percentile reordered its input: %v", samples)
+	}
+}
+
+func TestPercentileEdgeCases(t *testing.T) {
+	if got := Percentile(nil, 0.99); got != 0 {
+		t.Errorf("Percentile(nil) = %v, want 0", got)
+	}
+	if got := Percentile(ms(7), 0.99); got != 7*time.Millisecond {
+		t.Errorf("Percentile(one sample) = %v, want 7ms", got)
+	}
+	// High percentiles of a small sample interpolate between the top two
+	samples := ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	for _, p := range []float64{0.9, 0.95, 0.99, 0.999} {
+		if got := Percentile(samples, p); got < 9*time.Millisecond || got > 10*time.Millisecond {
+			t.Errorf("Percentile(%v) = %v, want within [9ms, 10ms]", p, got)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	got := Percentiles(ms(10, 20, 30, 40, 50), 0.5, 0, 1)
+	if want := ms(30, 10, 50); !reflect.DeepEqual(got, want) {
+		t.Errorf("Percentiles = %v, want %v", got, want)
+	}
+	if got := Percentiles(nil, 0.5, 0.99); !reflect.DeepEqual(got, []time.Duration{0, 0}) {
+		t.Errorf("Percentiles(nil) = %v, want zeros", got)
+	}
+}
+
+func TestMeanMinMax(t *testing.T) {
+	samples := ms(30, 10, 20)
+	if got := Mean(samples); got != 20*time.Millisecond {
+		t.Errorf("Mean = %v, want 20ms", got)
+	}
+	if got := Min(samples); got != 10*time.Millisecond {
+		t.Errorf("Min = %v, want 10ms", got)
+	}
+	if got := Max(samples); got != 30*time.Millisecond {
+		t.Errorf("Max = %v, want 30ms", got)
+	}
+	if Mean(nil) != 0 || Min(nil) != 0 || Max(nil) != 0 {
+		t.Errorf("Mean/Min/Max(nil) = %v/%v/%v, want 0", Mean(nil), Min(nil), Max(nil))
+	}
+}
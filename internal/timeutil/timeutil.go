@@ -0,0 +1,22 @@
+// Package timeutil holds the context-aware waits shared by the labs, the
+// gRPC server, and the clients.
+package timeutil
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d or until ctx is done, reporting whether the full delay
+// elapsed. Use it instead of time.Sleep in anything a caller may cancel, so
+// Ctrl-C or a deadline stops the wait instead of sleeping on.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
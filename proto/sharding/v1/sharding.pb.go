@@ -21,6 +21,55 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type BulkInsertRequest_WriteMode int32
+
+const (
+	BulkInsertRequest_INSERT  BulkInsertRequest_WriteMode = 0 // InsertMany; duplicate _id values fail
+	BulkInsertRequest_UPSERT  BulkInsertRequest_WriteMode = 1 // Replace by _id, inserting when missing
+	BulkInsertRequest_REPLACE BulkInsertRequest_WriteMode = 2 // Replace by _id, skipping when missing
+)
+
+// Enum value maps for BulkInsertRequest_WriteMode.
+var (
+	BulkInsertRequest_WriteMode_name = map[int32]string{
+		0: "INSERT",
+		1: "UPSERT",
+		2: "REPLACE",
+	}
+	BulkInsertRequest_WriteMode_value = map[string]int32{
+		"INSERT":  0,
+		"UPSERT":  1,
+		"REPLACE": 2,
+	}
+)
+
+func (x BulkInsertRequest_WriteMode) Enum() *BulkInsertRequest_WriteMode {
+	p := new(BulkInsertRequest_WriteMode)
+	*p = x
+	return p
+}
+
+func (x BulkInsertRequest_WriteMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BulkInsertRequest_WriteMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_sharding_v1_sharding_proto_enumTypes[0].Descriptor()
+}
+
+func (BulkInsertRequest_WriteMode) Type() protoreflect.EnumType {
+	return &file_proto_sharding_v1_sharding_proto_enumTypes[0]
+}
+
+func (x BulkInsertRequest_WriteMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BulkInsertRequest_WriteMode.Descriptor instead.
+func (BulkInsertRequest_WriteMode) EnumDescriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{5, 0}
+}
+
 type WatchRequest_Operation int32
 
 const (
@@ -60,11 +109,11 @@ func (x WatchRequest_Operation) String() string {
 }
 
 func (WatchRequest_Operation) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_sharding_v1_sharding_proto_enumTypes[0].Descriptor()
+	return file_proto_sharding_v1_sharding_proto_enumTypes[1].Descriptor()
 }
 
 func (WatchRequest_Operation) Type() protoreflect.EnumType {
-	return &file_proto_sharding_v1_sharding_proto_enumTypes[0]
+	return &file_proto_sharding_v1_sharding_proto_enumTypes[1]
 }
 
 func (x WatchRequest_Operation) Number() protoreflect.EnumNumber {
@@ -76,6 +125,101 @@ func (WatchRequest_Operation) EnumDescriptor() ([]byte, []int) {
 	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{7, 0}
 }
 
+type WatchRequest_Scope int32
+
+const (
+	WatchRequest_COLLECTION WatchRequest_Scope = 0 // database.collection only
+	WatchRequest_DATABASE   WatchRequest_Scope = 1 // every collection in database
+	WatchRequest_CLUSTER    WatchRequest_Scope = 2 // every database in the deployment
+)
+
+// Enum value maps for WatchRequest_Scope.
+var (
+	WatchRequest_Scope_name = map[int32]string{
+		0: "COLLECTION",
+		1: "DATABASE",
+		2: "CLUSTER",
+	}
+	WatchRequest_Scope_value = map[string]int32{
+		"COLLECTION": 0,
+		"DATABASE":   1,
+		"CLUSTER":    2,
+	}
+)
+
+func (x WatchRequest_Scope) Enum() *WatchRequest_Scope {
+	p := new(WatchRequest_Scope)
+	*p = x
+	return p
+}
+
+func (x WatchRequest_Scope) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WatchRequest_Scope) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_sharding_v1_sharding_proto_enumTypes[2].Descriptor()
+}
+
+func (WatchRequest_Scope) Type() protoreflect.EnumType {
+	return &file_proto_sharding_v1_sharding_proto_enumTypes[2]
+}
+
+func (x WatchRequest_Scope) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WatchRequest_Scope.Descriptor instead.
+func (WatchRequest_Scope) EnumDescriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{7, 1}
+}
+
+type WatchRequest_Source int32
+
+const (
+	WatchRequest_CHANGE_STREAM WatchRequest_Source = 0 // mongos change stream (majority read concern)
+	WatchRequest_OPLOG         WatchRequest_Source = 1 // tail local.oplog.rs on every shard and merge
+)
+
+// Enum value maps for WatchRequest_Source.
+var (
+	WatchRequest_Source_name = map[int32]string{
+		0: "CHANGE_STREAM",
+		1: "OPLOG",
+	}
+	WatchRequest_Source_value = map[string]int32{
+		"CHANGE_STREAM": 0,
+		"OPLOG":         1,
+	}
+)
+
+func (x WatchRequest_Source) Enum() *WatchRequest_Source {
+	p := new(WatchRequest_Source)
+	*p = x
+	return p
+}
+
+func (x WatchRequest_Source) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WatchRequest_Source) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_sharding_v1_sharding_proto_enumTypes[3].Descriptor()
+}
+
+func (WatchRequest_Source) Type() protoreflect.EnumType {
+	return &file_proto_sharding_v1_sharding_proto_enumTypes[3]
+}
+
+func (x WatchRequest_Source) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WatchRequest_Source.Descriptor instead.
+func (WatchRequest_Source) EnumDescriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{7, 2}
+}
+
 // Document represents a MongoDB document with optimized payload encoding.
 type Document struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -157,6 +301,7 @@ func (x *Document) GetMetadata() map[string]string {
 type InsertRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Document      *Document              `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	ReturnRouting bool                   `protobuf:"varint,2,opt,name=return_routing,json=returnRouting,proto3" json:"return_routing,omitempty"` // Look up the owning shard and chunk (extra round trip)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -198,12 +343,21 @@ func (x *InsertRequest) GetDocument() *Document {
 	return nil
 }
 
+func (x *InsertRequest) GetReturnRouting() bool {
+	if x != nil {
+		return x.ReturnRouting
+	}
+	return false
+}
+
 // InsertResponse confirms insertion.
 type InsertResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	InsertedId    string                 `protobuf:"bytes,1,opt,name=inserted_id,json=insertedId,proto3" json:"inserted_id,omitempty"`
 	Shard         string                 `protobuf:"bytes,2,opt,name=shard,proto3" json:"shard,omitempty"`                           // Which shard received the document
 	LatencyUs     int64                  `protobuf:"varint,3,opt,name=latency_us,json=latencyUs,proto3" json:"latency_us,omitempty"` // Server-side latency in microseconds
+	ChunkMin      string                 `protobuf:"bytes,4,opt,name=chunk_min,json=chunkMin,proto3" json:"chunk_min,omitempty"`     // Owning chunk bounds when return_routing is set
+	ChunkMax      string                 `protobuf:"bytes,5,opt,name=chunk_max,json=chunkMax,proto3" json:"chunk_max,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -259,6 +413,20 @@ func (x *InsertResponse) GetLatencyUs() int64 {
 	return 0
 }
 
+func (x *InsertResponse) GetChunkMin() string {
+	if x != nil {
+		return x.ChunkMin
+	}
+	return ""
+}
+
+func (x *InsertResponse) GetChunkMax() string {
+	if x != nil {
+		return x.ChunkMax
+	}
+	return ""
+}
+
 // QueryRequest for document queries.
 type QueryRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -267,6 +435,8 @@ type QueryRequest struct {
 	Filter        []byte                 `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"` // BSON-encoded filter (bytes for performance)
 	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
 	Skip          int32                  `protobuf:"varint,5,opt,name=skip,proto3" json:"skip,omitempty"`
+	Explain       bool                   `protobuf:"varint,6,opt,name=explain,proto3" json:"explain,omitempty"`                      // Run explain first and report shard targeting
+	BatchSize     int32                  `protobuf:"varint,7,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"` // Documents per cursor round trip (0 = server default)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -336,13 +506,29 @@ func (x *QueryRequest) GetSkip() int32 {
 	return 0
 }
 
+func (x *QueryRequest) GetExplain() bool {
+	if x != nil {
+		return x.Explain
+	}
+	return false
+}
+
+func (x *QueryRequest) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
 // QueryResponse returns matching documents.
 type QueryResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Documents     []*Document            `protobuf:"bytes,1,rep,name=documents,proto3" json:"documents,omitempty"`
 	TotalCount    int64                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
 	LatencyUs     int64                  `protobuf:"varint,3,opt,name=latency_us,json=latencyUs,proto3" json:"latency_us,omitempty"`
-	TargetedShard string                 `protobuf:"bytes,4,opt,name=targeted_shard,json=targetedShard,proto3" json:"targeted_shard,omitempty"` // Empty if scatter-gather
+	TargetedShard string                 `protobuf:"bytes,4,opt,name=targeted_shard,json=targetedShard,proto3" json:"targeted_shard,omitempty"`  // Empty if scatter-gather
+	ScatterGather bool                   `protobuf:"varint,5,opt,name=scatter_gather,json=scatterGather,proto3" json:"scatter_gather,omitempty"` // Set when explain shows every shard was queried without a shard key prefix
+	ShardsTouched int32                  `protobuf:"varint,6,opt,name=shards_touched,json=shardsTouched,proto3" json:"shards_touched,omitempty"` // Number of shards the query was routed to (explain only)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -405,13 +591,28 @@ func (x *QueryResponse) GetTargetedShard() string {
 	return ""
 }
 
+func (x *QueryResponse) GetScatterGather() bool {
+	if x != nil {
+		return x.ScatterGather
+	}
+	return false
+}
+
+func (x *QueryResponse) GetShardsTouched() int32 {
+	if x != nil {
+		return x.ShardsTouched
+	}
+	return 0
+}
+
 // BulkInsertRequest for client-streaming bulk ingestion.
 type BulkInsertRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
-	Collection    string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
-	Documents     [][]byte               `protobuf:"bytes,3,rep,name=documents,proto3" json:"documents,omitempty"`                         // Each element is a BSON-encoded document
-	BatchNumber   int32                  `protobuf:"varint,4,opt,name=batch_number,json=batchNumber,proto3" json:"batch_number,omitempty"` // Sequence number for ordering
+	state         protoimpl.MessageState      `protogen:"open.v1"`
+	Database      string                      `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection    string                      `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Documents     [][]byte                    `protobuf:"bytes,3,rep,name=documents,proto3" json:"documents,omitempty"`                         // Each element is a BSON-encoded document
+	BatchNumber   int32                       `protobuf:"varint,4,opt,name=batch_number,json=batchNumber,proto3" json:"batch_number,omitempty"` // Sequence number for ordering
+	Mode          BulkInsertRequest_WriteMode `protobuf:"varint,5,opt,name=mode,proto3,enum=sharding.v1.BulkInsertRequest_WriteMode" json:"mode,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -474,6 +675,13 @@ func (x *BulkInsertRequest) GetBatchNumber() int32 {
 	return 0
 }
 
+func (x *BulkInsertRequest) GetMode() BulkInsertRequest_WriteMode {
+	if x != nil {
+		return x.Mode
+	}
+	return BulkInsertRequest_INSERT
+}
+
 // BulkInsertResponse summarizes the bulk operation.
 type BulkInsertResponse struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
@@ -481,6 +689,10 @@ type BulkInsertResponse struct {
 	BatchesReceived int32                  `protobuf:"varint,2,opt,name=batches_received,json=batchesReceived,proto3" json:"batches_received,omitempty"`
 	TotalLatencyUs  int64                  `protobuf:"varint,3,opt,name=total_latency_us,json=totalLatencyUs,proto3" json:"total_latency_us,omitempty"`
 	PerShardCount   map[string]int64       `protobuf:"bytes,4,rep,name=per_shard_count,json=perShardCount,proto3" json:"per_shard_count,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Distribution across shards
+	MatchedCount    int64                  `protobuf:"varint,5,opt,name=matched_count,json=matchedCount,proto3" json:"matched_count,omitempty"`                                                                                // UPSERT/REPLACE: documents matched by _id
+	ModifiedCount   int64                  `protobuf:"varint,6,opt,name=modified_count,json=modifiedCount,proto3" json:"modified_count,omitempty"`                                                                             // UPSERT/REPLACE: documents changed
+	UpsertedCount   int64                  `protobuf:"varint,7,opt,name=upserted_count,json=upsertedCount,proto3" json:"upserted_count,omitempty"`                                                                             // UPSERT: documents inserted
+	SubBatches      int32                  `protobuf:"varint,8,opt,name=sub_batches,json=subBatches,proto3" json:"sub_batches,omitempty"`                                                                                      // Writes issued after splitting oversized batches
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -543,13 +755,45 @@ func (x *BulkInsertResponse) GetPerShardCount() map[string]int64 {
 	return nil
 }
 
+func (x *BulkInsertResponse) GetMatchedCount() int64 {
+	if x != nil {
+		return x.MatchedCount
+	}
+	return 0
+}
+
+func (x *BulkInsertResponse) GetModifiedCount() int64 {
+	if x != nil {
+		return x.ModifiedCount
+	}
+	return 0
+}
+
+func (x *BulkInsertResponse) GetUpsertedCount() int64 {
+	if x != nil {
+		return x.UpsertedCount
+	}
+	return 0
+}
+
+func (x *BulkInsertResponse) GetSubBatches() int32 {
+	if x != nil {
+		return x.SubBatches
+	}
+	return 0
+}
+
 // WatchRequest for bidirectional change stream.
 type WatchRequest struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	Database        string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
 	Collection      string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
-	Filter          []byte                 `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"` // BSON pipeline filter
+	Filter          []byte                 `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"` // BSON match on fullDocument fields, e.g. {status: "paid"}
 	OperationFilter WatchRequest_Operation `protobuf:"varint,4,opt,name=operation_filter,json=operationFilter,proto3,enum=sharding.v1.WatchRequest_Operation" json:"operation_filter,omitempty"`
+	ResumeAfter     []byte                 `protobuf:"bytes,5,opt,name=resume_after,json=resumeAfter,proto3" json:"resume_after,omitempty"` // Resume token from a previous WatchEvent
+	Scope           WatchRequest_Scope     `protobuf:"varint,6,opt,name=scope,proto3,enum=sharding.v1.WatchRequest_Scope" json:"scope,omitempty"`
+	Fields          []string               `protobuf:"bytes,7,rep,name=fields,proto3" json:"fields,omitempty"`                                       // fullDocument fields to send (empty = whole document)
+	Source          WatchRequest_Source    `protobuf:"varint,8,opt,name=source,proto3,enum=sharding.v1.WatchRequest_Source" json:"source,omitempty"` // Where events are read from
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -612,6 +856,34 @@ func (x *WatchRequest) GetOperationFilter() WatchRequest_Operation {
 	return WatchRequest_ALL
 }
 
+func (x *WatchRequest) GetResumeAfter() []byte {
+	if x != nil {
+		return x.ResumeAfter
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetScope() WatchRequest_Scope {
+	if x != nil {
+		return x.Scope
+	}
+	return WatchRequest_COLLECTION
+}
+
+func (x *WatchRequest) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetSource() WatchRequest_Source {
+	if x != nil {
+		return x.Source
+	}
+	return WatchRequest_CHANGE_STREAM
+}
+
 // WatchEvent streams real-time changes.
 type WatchEvent struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -621,6 +893,8 @@ type WatchEvent struct {
 	Collection    string                 `protobuf:"bytes,4,opt,name=collection,proto3" json:"collection,omitempty"`
 	Shard         string                 `protobuf:"bytes,5,opt,name=shard,proto3" json:"shard,omitempty"`
 	TimestampMs   int64                  `protobuf:"varint,6,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"` // Cluster time in milliseconds
+	ResumeToken   []byte                 `protobuf:"bytes,7,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`  // Pass back as resume_after to continue after this event
+	Database      string                 `protobuf:"bytes,8,opt,name=database,proto3" json:"database,omitempty"`                           // Source database (from the event's ns)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -697,6 +971,264 @@ func (x *WatchEvent) GetTimestampMs() int64 {
 	return 0
 }
 
+func (x *WatchEvent) GetResumeToken() []byte {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return nil
+}
+
+func (x *WatchEvent) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+// ReshardProgressRequest identifies the collection being resharded.
+type ReshardProgressRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection    string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReshardProgressRequest) Reset() {
+	*x = ReshardProgressRequest{}
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReshardProgressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReshardProgressRequest) ProtoMessage() {}
+
+func (x *ReshardProgressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReshardProgressRequest.ProtoReflect.Descriptor instead.
+func (*ReshardProgressRequest) Descriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReshardProgressRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *ReshardProgressRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+// ReshardProgressResponse summarizes resharding progress across all shards.
+type ReshardProgressResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	InProgress          bool                   `protobuf:"varint,1,opt,name=in_progress,json=inProgress,proto3" json:"in_progress,omitempty"`
+	CoordinatorState    string                 `protobuf:"bytes,2,opt,name=coordinator_state,json=coordinatorState,proto3" json:"coordinator_state,omitempty"`
+	DocumentsCopied     int64                  `protobuf:"varint,3,opt,name=documents_copied,json=documentsCopied,proto3" json:"documents_copied,omitempty"`
+	DocumentsToCopy     int64                  `protobuf:"varint,4,opt,name=documents_to_copy,json=documentsToCopy,proto3" json:"documents_to_copy,omitempty"`
+	OplogEntriesFetched int64                  `protobuf:"varint,5,opt,name=oplog_entries_fetched,json=oplogEntriesFetched,proto3" json:"oplog_entries_fetched,omitempty"`
+	OplogEntriesApplied int64                  `protobuf:"varint,6,opt,name=oplog_entries_applied,json=oplogEntriesApplied,proto3" json:"oplog_entries_applied,omitempty"`
+	ElapsedSecs         int64                  `protobuf:"varint,7,opt,name=elapsed_secs,json=elapsedSecs,proto3" json:"elapsed_secs,omitempty"`
+	RemainingSecs       int64                  `protobuf:"varint,8,opt,name=remaining_secs,json=remainingSecs,proto3" json:"remaining_secs,omitempty"` // Server estimate; -1 when unknown
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ReshardProgressResponse) Reset() {
+	*x = ReshardProgressResponse{}
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReshardProgressResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReshardProgressResponse) ProtoMessage() {}
+
+func (x *ReshardProgressResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReshardProgressResponse.ProtoReflect.Descriptor instead.
+func (*ReshardProgressResponse) Descriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReshardProgressResponse) GetInProgress() bool {
+	if x != nil {
+		return x.InProgress
+	}
+	return false
+}
+
+func (x *ReshardProgressResponse) GetCoordinatorState() string {
+	if x != nil {
+		return x.CoordinatorState
+	}
+	return ""
+}
+
+func (x *ReshardProgressResponse) GetDocumentsCopied() int64 {
+	if x != nil {
+		return x.DocumentsCopied
+	}
+	return 0
+}
+
+func (x *ReshardProgressResponse) GetDocumentsToCopy() int64 {
+	if x != nil {
+		return x.DocumentsToCopy
+	}
+	return 0
+}
+
+func (x *ReshardProgressResponse) GetOplogEntriesFetched() int64 {
+	if x != nil {
+		return x.OplogEntriesFetched
+	}
+	return 0
+}
+
+func (x *ReshardProgressResponse) GetOplogEntriesApplied() int64 {
+	if x != nil {
+		return x.OplogEntriesApplied
+	}
+	return 0
+}
+
+func (x *ReshardProgressResponse) GetElapsedSecs() int64 {
+	if x != nil {
+		return x.ElapsedSecs
+	}
+	return 0
+}
+
+func (x *ReshardProgressResponse) GetRemainingSecs() int64 {
+	if x != nil {
+		return x.RemainingSecs
+	}
+	return 0
+}
+
+// PingRequest is empty; Ping takes no arguments.
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{11}
+}
+
+// PingResponse reports server uptime and MongoDB round-trip latency.
+type PingResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	UptimeSecs     int64                  `protobuf:"varint,1,opt,name=uptime_secs,json=uptimeSecs,proto3" json:"uptime_secs,omitempty"`               // Seconds since the server started
+	MongoLatencyUs int64                  `protobuf:"varint,2,opt,name=mongo_latency_us,json=mongoLatencyUs,proto3" json:"mongo_latency_us,omitempty"` // MongoDB ping round trip in microseconds
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sharding_v1_sharding_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_proto_sharding_v1_sharding_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PingResponse) GetUptimeSecs() int64 {
+	if x != nil {
+		return x.UptimeSecs
+	}
+	return 0
+}
+
+func (x *PingResponse) GetMongoLatencyUs() int64 {
+	if x != nil {
+		return x.MongoLatencyUs
+	}
+	return 0
+}
+
 var File_proto_sharding_v1_sharding_proto protoreflect.FileDescriptor
 
 const file_proto_sharding_v1_sharding_proto_rawDesc = "" +
@@ -712,15 +1244,18 @@ const file_proto_sharding_v1_sharding_proto_rawDesc = "" +
 	"\bmetadata\x18\x05 \x03(\v2#.sharding.v1.Document.MetadataEntryR\bmetadata\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"B\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"i\n" +
 	"\rInsertRequest\x121\n" +
-	"\bdocument\x18\x01 \x01(\v2\x15.sharding.v1.DocumentR\bdocument\"f\n" +
+	"\bdocument\x18\x01 \x01(\v2\x15.sharding.v1.DocumentR\bdocument\x12%\n" +
+	"\x0ereturn_routing\x18\x02 \x01(\bR\rreturnRouting\"\xa0\x01\n" +
 	"\x0eInsertResponse\x12\x1f\n" +
 	"\vinserted_id\x18\x01 \x01(\tR\n" +
 	"insertedId\x12\x14\n" +
 	"\x05shard\x18\x02 \x01(\tR\x05shard\x12\x1d\n" +
 	"\n" +
-	"latency_us\x18\x03 \x01(\x03R\tlatencyUs\"\x8c\x01\n" +
+	"latency_us\x18\x03 \x01(\x03R\tlatencyUs\x12\x1b\n" +
+	"\tchunk_min\x18\x04 \x01(\tR\bchunkMin\x12\x1b\n" +
+	"\tchunk_max\x18\x05 \x01(\tR\bchunkMax\"\xc5\x01\n" +
 	"\fQueryRequest\x12\x1a\n" +
 	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
 	"\n" +
@@ -728,36 +1263,57 @@ const file_proto_sharding_v1_sharding_proto_rawDesc = "" +
 	"collection\x12\x16\n" +
 	"\x06filter\x18\x03 \x01(\fR\x06filter\x12\x14\n" +
 	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x12\n" +
-	"\x04skip\x18\x05 \x01(\x05R\x04skip\"\xab\x01\n" +
+	"\x04skip\x18\x05 \x01(\x05R\x04skip\x12\x18\n" +
+	"\aexplain\x18\x06 \x01(\bR\aexplain\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\a \x01(\x05R\tbatchSize\"\xf9\x01\n" +
 	"\rQueryResponse\x123\n" +
 	"\tdocuments\x18\x01 \x03(\v2\x15.sharding.v1.DocumentR\tdocuments\x12\x1f\n" +
 	"\vtotal_count\x18\x02 \x01(\x03R\n" +
 	"totalCount\x12\x1d\n" +
 	"\n" +
 	"latency_us\x18\x03 \x01(\x03R\tlatencyUs\x12%\n" +
-	"\x0etargeted_shard\x18\x04 \x01(\tR\rtargetedShard\"\x90\x01\n" +
+	"\x0etargeted_shard\x18\x04 \x01(\tR\rtargetedShard\x12%\n" +
+	"\x0escatter_gather\x18\x05 \x01(\bR\rscatterGather\x12%\n" +
+	"\x0eshards_touched\x18\x06 \x01(\x05R\rshardsTouched\"\x80\x02\n" +
 	"\x11BulkInsertRequest\x12\x1a\n" +
 	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
 	"\n" +
 	"collection\x18\x02 \x01(\tR\n" +
 	"collection\x12\x1c\n" +
 	"\tdocuments\x18\x03 \x03(\fR\tdocuments\x12!\n" +
-	"\fbatch_number\x18\x04 \x01(\x05R\vbatchNumber\"\xae\x02\n" +
+	"\fbatch_number\x18\x04 \x01(\x05R\vbatchNumber\x12<\n" +
+	"\x04mode\x18\x05 \x01(\x0e2(.sharding.v1.BulkInsertRequest.WriteModeR\x04mode\"0\n" +
+	"\tWriteMode\x12\n" +
+	"\n" +
+	"\x06INSERT\x10\x00\x12\n" +
+	"\n" +
+	"\x06UPSERT\x10\x01\x12\v\n" +
+	"\aREPLACE\x10\x02\"\xc2\x03\n" +
 	"\x12BulkInsertResponse\x12%\n" +
 	"\x0etotal_inserted\x18\x01 \x01(\x03R\rtotalInserted\x12)\n" +
 	"\x10batches_received\x18\x02 \x01(\x05R\x0fbatchesReceived\x12(\n" +
 	"\x10total_latency_us\x18\x03 \x01(\x03R\x0etotalLatencyUs\x12Z\n" +
-	"\x0fper_shard_count\x18\x04 \x03(\v22.sharding.v1.BulkInsertResponse.PerShardCountEntryR\rperShardCount\x1a@\n" +
+	"\x0fper_shard_count\x18\x04 \x03(\v22.sharding.v1.BulkInsertResponse.PerShardCountEntryR\rperShardCount\x12#\n" +
+	"\rmatched_count\x18\x05 \x01(\x03R\fmatchedCount\x12%\n" +
+	"\x0emodified_count\x18\x06 \x01(\x03R\rmodifiedCount\x12%\n" +
+	"\x0eupserted_count\x18\a \x01(\x03R\rupsertedCount\x12\x1f\n" +
+	"\vsub_batches\x18\b \x01(\x05R\n" +
+	"subBatches\x1a@\n" +
 	"\x12PerShardCountEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xf9\x01\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\x81\x04\n" +
 	"\fWatchRequest\x12\x1a\n" +
 	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
 	"\n" +
 	"collection\x18\x02 \x01(\tR\n" +
 	"collection\x12\x16\n" +
 	"\x06filter\x18\x03 \x01(\fR\x06filter\x12N\n" +
-	"\x10operation_filter\x18\x04 \x01(\x0e2#.sharding.v1.WatchRequest.OperationR\x0foperationFilter\"E\n" +
+	"\x10operation_filter\x18\x04 \x01(\x0e2#.sharding.v1.WatchRequest.OperationR\x0foperationFilter\x12!\n" +
+	"\fresume_after\x18\x05 \x01(\fR\vresumeAfter\x125\n" +
+	"\x05scope\x18\x06 \x01(\x0e2\x1f.sharding.v1.WatchRequest.ScopeR\x05scope\x12\x16\n" +
+	"\x06fields\x18\a \x03(\tR\x06fields\x128\n" +
+	"\x06source\x18\b \x01(\x0e2 .sharding.v1.WatchRequest.SourceR\x06source\"E\n" +
 	"\tOperation\x12\a\n" +
 	"\x03ALL\x10\x00\x12\n" +
 	"\n" +
@@ -766,7 +1322,15 @@ const file_proto_sharding_v1_sharding_proto_rawDesc = "" +
 	"\x06UPDATE\x10\x02\x12\n" +
 	"\n" +
 	"\x06DELETE\x10\x03\x12\v\n" +
-	"\aREPLACE\x10\x04\"\xc9\x01\n" +
+	"\aREPLACE\x10\x04\"2\n" +
+	"\x05Scope\x12\x0e\n" +
+	"\n" +
+	"COLLECTION\x10\x00\x12\f\n" +
+	"\bDATABASE\x10\x01\x12\v\n" +
+	"\aCLUSTER\x10\x02\"&\n" +
+	"\x06Source\x12\x11\n" +
+	"\rCHANGE_STREAM\x10\x00\x12\t\n" +
+	"\x05OPLOG\x10\x01\"\x88\x02\n" +
 	"\n" +
 	"WatchEvent\x12\x1c\n" +
 	"\toperation\x18\x01 \x01(\tR\toperation\x12\x1f\n" +
@@ -777,13 +1341,37 @@ const file_proto_sharding_v1_sharding_proto_rawDesc = "" +
 	"collection\x18\x04 \x01(\tR\n" +
 	"collection\x12\x14\n" +
 	"\x05shard\x18\x05 \x01(\tR\x05shard\x12!\n" +
-	"\ftimestamp_ms\x18\x06 \x01(\x03R\vtimestampMs2\xbe\x02\n" +
+	"\ftimestamp_ms\x18\x06 \x01(\x03R\vtimestampMs\x12!\n" +
+	"\fresume_token\x18\a \x01(\fR\vresumeToken\x12\x1a\n" +
+	"\bdatabase\x18\b \x01(\tR\bdatabase\"T\n" +
+	"\x16ReshardProgressRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\"\xf0\x02\n" +
+	"\x17ReshardProgressResponse\x12\x1f\n" +
+	"\vin_progress\x18\x01 \x01(\bR\n" +
+	"inProgress\x12+\n" +
+	"\x11coordinator_state\x18\x02 \x01(\tR\x10coordinatorState\x12)\n" +
+	"\x10documents_copied\x18\x03 \x01(\x03R\x0fdocumentsCopied\x12*\n" +
+	"\x11documents_to_copy\x18\x04 \x01(\x03R\x0fdocumentsToCopy\x122\n" +
+	"\x15oplog_entries_fetched\x18\x05 \x01(\x03R\x13oplogEntriesFetched\x122\n" +
+	"\x15oplog_entries_applied\x18\x06 \x01(\x03R\x13oplogEntriesApplied\x12!\n" +
+	"\felapsed_secs\x18\a \x01(\x03R\velapsedSecs\x12%\n" +
+	"\x0eremaining_secs\x18\b \x01(\x03R\rremainingSecs\"\r\n" +
+	"\vPingRequest\"Y\n" +
+	"\fPingResponse\x12\x1f\n" +
+	"\vuptime_secs\x18\x01 \x01(\x03R\n" +
+	"uptimeSecs\x12(\n" +
+	"\x10mongo_latency_us\x18\x02 \x01(\x03R\x0emongoLatencyUs2\xdc\x03\n" +
 	"\x0fShardingService\x12I\n" +
 	"\x0eInsertDocument\x12\x1a.sharding.v1.InsertRequest\x1a\x1b.sharding.v1.InsertResponse\x12G\n" +
 	"\x0eQueryDocuments\x12\x19.sharding.v1.QueryRequest\x1a\x1a.sharding.v1.QueryResponse\x12O\n" +
 	"\n" +
 	"BulkInsert\x12\x1e.sharding.v1.BulkInsertRequest\x1a\x1f.sharding.v1.BulkInsertResponse(\x01\x12F\n" +
-	"\fWatchUpdates\x12\x19.sharding.v1.WatchRequest\x1a\x17.sharding.v1.WatchEvent(\x010\x01B6Z4go-mongodb-sharding-poc/proto/sharding/v1;shardingv1b\x06proto3"
+	"\fWatchUpdates\x12\x19.sharding.v1.WatchRequest\x1a\x17.sharding.v1.WatchEvent(\x010\x01\x12_\n" +
+	"\x12GetReshardProgress\x12#.sharding.v1.ReshardProgressRequest\x1a$.sharding.v1.ReshardProgressResponse\x12;\n" +
+	"\x04Ping\x12\x18.sharding.v1.PingRequest\x1a\x19.sharding.v1.PingResponseB6Z4go-mongodb-sharding-poc/proto/sharding/v1;shardingv1b\x06proto3"
 
 var (
 	file_proto_sharding_v1_sharding_proto_rawDescOnce sync.Once
@@ -797,41 +1385,55 @@ func file_proto_sharding_v1_sharding_proto_rawDescGZIP() []byte {
 	return file_proto_sharding_v1_sharding_proto_rawDescData
 }
 
-var file_proto_sharding_v1_sharding_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_sharding_v1_sharding_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_proto_sharding_v1_sharding_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_proto_sharding_v1_sharding_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_proto_sharding_v1_sharding_proto_goTypes = []any{
-	(WatchRequest_Operation)(0), // 0: sharding.v1.WatchRequest.Operation
-	(*Document)(nil),            // 1: sharding.v1.Document
-	(*InsertRequest)(nil),       // 2: sharding.v1.InsertRequest
-	(*InsertResponse)(nil),      // 3: sharding.v1.InsertResponse
-	(*QueryRequest)(nil),        // 4: sharding.v1.QueryRequest
-	(*QueryResponse)(nil),       // 5: sharding.v1.QueryResponse
-	(*BulkInsertRequest)(nil),   // 6: sharding.v1.BulkInsertRequest
-	(*BulkInsertResponse)(nil),  // 7: sharding.v1.BulkInsertResponse
-	(*WatchRequest)(nil),        // 8: sharding.v1.WatchRequest
-	(*WatchEvent)(nil),          // 9: sharding.v1.WatchEvent
-	nil,                         // 10: sharding.v1.Document.MetadataEntry
-	nil,                         // 11: sharding.v1.BulkInsertResponse.PerShardCountEntry
+	(BulkInsertRequest_WriteMode)(0), // 0: sharding.v1.BulkInsertRequest.WriteMode
+	(WatchRequest_Operation)(0),      // 1: sharding.v1.WatchRequest.Operation
+	(WatchRequest_Scope)(0),          // 2: sharding.v1.WatchRequest.Scope
+	(WatchRequest_Source)(0),         // 3: sharding.v1.WatchRequest.Source
+	(*Document)(nil),                 // 4: sharding.v1.Document
+	(*InsertRequest)(nil),            // 5: sharding.v1.InsertRequest
+	(*InsertResponse)(nil),           // 6: sharding.v1.InsertResponse
+	(*QueryRequest)(nil),             // 7: sharding.v1.QueryRequest
+	(*QueryResponse)(nil),            // 8: sharding.v1.QueryResponse
+	(*BulkInsertRequest)(nil),        // 9: sharding.v1.BulkInsertRequest
+	(*BulkInsertResponse)(nil),       // 10: sharding.v1.BulkInsertResponse
+	(*WatchRequest)(nil),             // 11: sharding.v1.WatchRequest
+	(*WatchEvent)(nil),               // 12: sharding.v1.WatchEvent
+	(*ReshardProgressRequest)(nil),   // 13: sharding.v1.ReshardProgressRequest
+	(*ReshardProgressResponse)(nil),  // 14: sharding.v1.ReshardProgressResponse
+	(*PingRequest)(nil),              // 15: sharding.v1.PingRequest
+	(*PingResponse)(nil),             // 16: sharding.v1.PingResponse
+	nil,                              // 17: sharding.v1.Document.MetadataEntry
+	nil,                              // 18: sharding.v1.BulkInsertResponse.PerShardCountEntry
 }
 var file_proto_sharding_v1_sharding_proto_depIdxs = []int32{
-	10, // 0: sharding.v1.Document.metadata:type_name -> sharding.v1.Document.MetadataEntry
-	1,  // 1: sharding.v1.InsertRequest.document:type_name -> sharding.v1.Document
-	1,  // 2: sharding.v1.QueryResponse.documents:type_name -> sharding.v1.Document
-	11, // 3: sharding.v1.BulkInsertResponse.per_shard_count:type_name -> sharding.v1.BulkInsertResponse.PerShardCountEntry
-	0,  // 4: sharding.v1.WatchRequest.operation_filter:type_name -> sharding.v1.WatchRequest.Operation
-	2,  // 5: sharding.v1.ShardingService.InsertDocument:input_type -> sharding.v1.InsertRequest
-	4,  // 6: sharding.v1.ShardingService.QueryDocuments:input_type -> sharding.v1.QueryRequest
-	6,  // 7: sharding.v1.ShardingService.BulkInsert:input_type -> sharding.v1.BulkInsertRequest
-	8,  // 8: sharding.v1.ShardingService.WatchUpdates:input_type -> sharding.v1.WatchRequest
-	3,  // 9: sharding.v1.ShardingService.InsertDocument:output_type -> sharding.v1.InsertResponse
-	5,  // 10: sharding.v1.ShardingService.QueryDocuments:output_type -> sharding.v1.QueryResponse
-	7,  // 11: sharding.v1.ShardingService.BulkInsert:output_type -> sharding.v1.BulkInsertResponse
-	9,  // 12: sharding.v1.ShardingService.WatchUpdates:output_type -> sharding.v1.WatchEvent
-	9,  // [9:13] is the sub-list for method output_type
-	5,  // [5:9] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	17, // 0: sharding.v1.Document.metadata:type_name -> sharding.v1.Document.MetadataEntry
+	4,  // 1: sharding.v1.InsertRequest.document:type_name -> sharding.v1.Document
+	4,  // 2: sharding.v1.QueryResponse.documents:type_name -> sharding.v1.Document
+	0,  // 3: sharding.v1.BulkInsertRequest.mode:type_name -> sharding.v1.BulkInsertRequest.WriteMode
+	18, // 4: sharding.v1.BulkInsertResponse.per_shard_count:type_name -> sharding.v1.BulkInsertResponse.PerShardCountEntry
+	1,  // 5: sharding.v1.WatchRequest.operation_filter:type_name -> sharding.v1.WatchRequest.Operation
+	2,  // 6: sharding.v1.WatchRequest.scope:type_name -> sharding.v1.WatchRequest.Scope
+	3,  // 7: sharding.v1.WatchRequest.source:type_name -> sharding.v1.WatchRequest.Source
+	5,  // 8: sharding.v1.ShardingService.InsertDocument:input_type -> sharding.v1.InsertRequest
+	7,  // 9: sharding.v1.ShardingService.QueryDocuments:input_type -> sharding.v1.QueryRequest
+	9,  // 10: sharding.v1.ShardingService.BulkInsert:input_type -> sharding.v1.BulkInsertRequest
+	11, // 11: sharding.v1.ShardingService.WatchUpdates:input_type -> sharding.v1.WatchRequest
+	13, // 12: sharding.v1.ShardingService.GetReshardProgress:input_type -> sharding.v1.ReshardProgressRequest
+	15, // 13: sharding.v1.ShardingService.Ping:input_type -> sharding.v1.PingRequest
+	6,  // 14: sharding.v1.ShardingService.InsertDocument:output_type -> sharding.v1.InsertResponse
+	8,  // 15: sharding.v1.ShardingService.QueryDocuments:output_type -> sharding.v1.QueryResponse
+	10, // 16: sharding.v1.ShardingService.BulkInsert:output_type -> sharding.v1.BulkInsertResponse
+	12, // 17: sharding.v1.ShardingService.WatchUpdates:output_type -> sharding.v1.WatchEvent
+	14, // 18: sharding.v1.ShardingService.GetReshardProgress:output_type -> sharding.v1.ReshardProgressResponse
+	16, // 19: sharding.v1.ShardingService.Ping:output_type -> sharding.v1.PingResponse
+	14, // [14:20] is the sub-list for method output_type
+	8,  // [8:14] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_proto_sharding_v1_sharding_proto_init() }
@@ -844,8 +1446,8 @@ func file_proto_sharding_v1_sharding_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_sharding_v1_sharding_proto_rawDesc), len(file_proto_sharding_v1_sharding_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   11,
+			NumEnums:      4,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,10 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ShardingService_InsertDocument_FullMethodName = "/sharding.v1.ShardingService/InsertDocument"
-	ShardingService_QueryDocuments_FullMethodName = "/sharding.v1.ShardingService/QueryDocuments"
-	ShardingService_BulkInsert_FullMethodName     = "/sharding.v1.ShardingService/BulkInsert"
-	ShardingService_WatchUpdates_FullMethodName   = "/sharding.v1.ShardingService/WatchUpdates"
+	ShardingService_InsertDocument_FullMethodName     = "/sharding.v1.ShardingService/InsertDocument"
+	ShardingService_QueryDocuments_FullMethodName     = "/sharding.v1.ShardingService/QueryDocuments"
+	ShardingService_BulkInsert_FullMethodName         = "/sharding.v1.ShardingService/BulkInsert"
+	ShardingService_WatchUpdates_FullMethodName       = "/sharding.v1.ShardingService/WatchUpdates"
+	ShardingService_GetReshardProgress_FullMethodName = "/sharding.v1.ShardingService/GetReshardProgress"
+	ShardingService_Ping_FullMethodName               = "/sharding.v1.ShardingService/Ping"
 )
 
 // ShardingServiceClient is the client API for ShardingService service.
@@ -41,6 +43,11 @@ type ShardingServiceClient interface {
 	// WatchUpdates maintains a bidirectional stream for real-time change events.
 	// Client sends watch filters, server streams matching change events.
 	WatchUpdates(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[WatchRequest, WatchEvent], error)
+	// GetReshardProgress reports progress of an in-flight reshardCollection (unary).
+	GetReshardProgress(ctx context.Context, in *ReshardProgressRequest, opts ...grpc.CallOption) (*ReshardProgressResponse, error)
+	// Ping checks that the server and its MongoDB connection are alive (unary).
+	// It touches no collection, so it is cheap enough for liveness probes.
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 }
 
 type shardingServiceClient struct {
@@ -97,6 +104,26 @@ func (c *shardingServiceClient) WatchUpdates(ctx context.Context, opts ...grpc.C
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type ShardingService_WatchUpdatesClient = grpc.BidiStreamingClient[WatchRequest, WatchEvent]
 
+func (c *shardingServiceClient) GetReshardProgress(ctx context.Context, in *ReshardProgressRequest, opts ...grpc.CallOption) (*ReshardProgressResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReshardProgressResponse)
+	err := c.cc.Invoke(ctx, ShardingService_GetReshardProgress_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardingServiceClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, ShardingService_Ping_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShardingServiceServer is the server API for ShardingService service.
 // All implementations must embed UnimplementedShardingServiceServer
 // for forward compatibility.
@@ -113,6 +140,11 @@ type ShardingServiceServer interface {
 	// WatchUpdates maintains a bidirectional stream for real-time change events.
 	// Client sends watch filters, server streams matching change events.
 	WatchUpdates(grpc.BidiStreamingServer[WatchRequest, WatchEvent]) error
+	// GetReshardProgress reports progress of an in-flight reshardCollection (unary).
+	GetReshardProgress(context.Context, *ReshardProgressRequest) (*ReshardProgressResponse, error)
+	// Ping checks that the server and its MongoDB connection are alive (unary).
+	// It touches no collection, so it is cheap enough for liveness probes.
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	mustEmbedUnimplementedShardingServiceServer()
 }
 
@@ -135,6 +167,12 @@ func (UnimplementedShardingServiceServer) BulkInsert(grpc.ClientStreamingServer[
 func (UnimplementedShardingServiceServer) WatchUpdates(grpc.BidiStreamingServer[WatchRequest, WatchEvent]) error {
 	return status.Errorf(codes.Unimplemented, "method WatchUpdates not implemented")
 }
+func (UnimplementedShardingServiceServer) GetReshardProgress(context.Context, *ReshardProgressRequest) (*ReshardProgressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReshardProgress not implemented")
+}
+func (UnimplementedShardingServiceServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
 func (UnimplementedShardingServiceServer) mustEmbedUnimplementedShardingServiceServer() {}
 func (UnimplementedShardingServiceServer) testEmbeddedByValue()                         {}
 
@@ -206,6 +244,42 @@ func _ShardingService_WatchUpdates_Handler(srv interface{}, stream grpc.ServerSt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type ShardingService_WatchUpdatesServer = grpc.BidiStreamingServer[WatchRequest, WatchEvent]
 
+func _ShardingService_GetReshardProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReshardProgressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardingServiceServer).GetReshardProgress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShardingService_GetReshardProgress_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardingServiceServer).GetReshardProgress(ctx, req.(*ReshardProgressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShardingService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardingServiceServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShardingService_Ping_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardingServiceServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ShardingService_ServiceDesc is the grpc.ServiceDesc for ShardingService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -221,6 +295,14 @@ var ShardingService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "QueryDocuments",
 			Handler:    _ShardingService_QueryDocuments_Handler,
 		},
+		{
+			MethodName: "GetReshardProgress",
+			Handler:    _ShardingService_GetReshardProgress_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _ShardingService_Ping_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
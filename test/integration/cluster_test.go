@@ -0,0 +1,413 @@
+//go:build integration
+
+// Package integration stands up a minimal sharded cluster in Docker and
+// exercises the cluster, security, and sharding packages against it.
+//
+// Run with: make integration (or go test -tags integration ./test/integration/)
+//
+// Topology: 1-member config RS + 2 single-member shards + 1 mongos, started
+// with testcontainers-go on a private Docker network. Members reach each
+// other by network alias; the test process reaches them through their
+// mapped ports, so it works on Docker Desktop as well as Linux.
+//
+// Without a Docker daemon every test is skipped; set
+// INTEGRATION_REQUIRE_DOCKER=1 (as CI should) to fail instead.
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"github.com/testcontainers/testcontainers-go/network"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"go-mongodb-sharding-poc/internal/cluster"
+	"go-mongodb-sharding-poc/internal/config"
+	"go-mongodb-sharding-poc/internal/security"
+	"go-mongodb-sharding-poc/internal/sharding"
+)
+
+const (
+	adminUser     = "itestAdmin"
+	adminPassword = "itest123"
+	appUser       = "itestApp"
+	appPassword   = "itestApp123"
+	readUser      = "itestRead"
+	readPassword  = "itestRead123"
+	appDatabase   = "itest"
+)
+
+var (
+	// Member hosts are network aliases, as the replica set configs and
+	// addShard see them from inside the Docker network.
+	configRS = config.ReplicaSet{Name: "itestcfg", Members: []config.Member{{Host: "cfg", Port: "27019"}}}
+	shards   = []config.ReplicaSet{
+		{Name: "itest1", Members: []config.Member{{Host: "shard1", Port: "27018"}}},
+		{Name: "itest2", Members: []config.Member{{Host: "shard2", Port: "27018"}}},
+	}
+	mongosMember = config.Member{Host: "mongos", Port: "27017"}
+
+	// mongosHost is mongos' mapped address, reachable from the test process.
+	mongosHost string
+
+	// mongosClient is the authenticated admin connection shared by tests.
+	mongosClient *mongo.Client
+
+	// clusterKey is the shared keyfile content for internal auth.
+	clusterKey string
+
+	// skipReason is set when no Docker daemon is available; every test
+	// then skips with it.
+	skipReason string
+)
+
+func TestMain(m *testing.M) {
+	if err := dockerAvailable(); err != nil {
+		if os.Getenv("INTEGRATION_REQUIRE_DOCKER") != "" {
+			log.Printf("[FAIL] integration tests need a Docker daemon: %v", err)
+			os.Exit(1)
+		}
+		skipReason = fmt.Sprintf("integration tests need a Docker daemon: %v", err)
+		os.Exit(m.Run())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	h := &harness{}
+	code := 1
+	if err := h.start(ctx); err != nil {
+		log.Printf("[ERROR] start cluster: %v", err)
+	} else {
+		code = m.Run()
+	}
+
+	if mongosClient != nil {
+		mongosClient.Disconnect(context.Background())
+	}
+	cancel()
+	h.stop()
+	os.Exit(code)
+}
+
+// requireCluster skips t when there is no Docker daemon to run the cluster.
+func requireCluster(t *testing.T) {
+	t.Helper()
+	if skipReason != "" {
+		t.Skip(skipReason)
+	}
+}
+
+// dockerAvailable reports why testcontainers cannot reach a Docker daemon.
+func dockerAvailable() (err error) {
+	// testcontainers panics when it finds no Docker host at all
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return provider.Health(ctx)
+}
+
+func TestVerifyCluster(t *testing.T) {
+	requireCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := cluster.VerifyCluster(ctx, mongosClient, len(shards)); err != nil {
+		t.Fatalf("VerifyCluster: %v", err)
+	}
+
+	status, err := cluster.GetClusterStatus(ctx, mongosClient)
+	if err != nil {
+		t.Fatalf("GetClusterStatus: %v", err)
+	}
+	for _, s := range status.Shards {
+		if len(s.Members) != 1 || s.ReplicaSet != s.ID {
+			t.Errorf("shard %s: replicaSet=%q members=%v", s.ID, s.ReplicaSet, s.Members)
+		}
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	requireCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := security.VerifyAppUser(ctx, mongosHost, appDatabase, appUser, appPassword); err != nil {
+		t.Errorf("VerifyAppUser: %v", err)
+	}
+	if err := security.VerifyReadOnlyUser(ctx, mongosHost, appDatabase, readUser, readPassword); err != nil {
+		t.Errorf("VerifyReadOnlyUser: %v", err)
+	}
+}
+
+func TestHashedCollectionDistributes(t *testing.T) {
+	requireCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	const coll = "hashed_itest"
+	const docCount = 2000
+
+	if err := sharding.ShardCollectionHashed(ctx, mongosClient, appDatabase, coll, "_id"); err != nil {
+		t.Fatalf("ShardCollectionHashed: %v", err)
+	}
+	defer sharding.DropCollection(ctx, mongosClient, appDatabase, coll)
+
+	docs := make([]interface{}, docCount)
+	for i := range docs {
+		docs[i] = bson.M{"_id": fmt.Sprintf("doc-%05d", i), "n": i}
+	}
+	if _, err := mongosClient.Database(appDatabase).Collection(coll).InsertMany(ctx, docs); err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+
+	dist, err := sharding.GetShardDistribution(ctx, mongosClient, appDatabase, coll)
+	if err != nil {
+		t.Fatalf("GetShardDistribution: %v", err)
+	}
+	if dist.Total != docCount {
+		t.Errorf("total = %d, want %d", dist.Total, docCount)
+	}
+	// Hashed _id presplits evenly; each shard should hold a real share
+	if err := sharding.CheckEvenDistribution(dist, len(shards), sharding.DefaultMaxDeviation); err != nil {
+		t.Errorf("CheckEvenDistribution: %v (%v)", err, dist.Shards)
+	}
+}
+
+func TestDropShardedCollectionAllowsNewKey(t *testing.T) {
+	requireCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const coll = "redrop_itest"
+	ns := appDatabase + "." + coll
+
+	if err := sharding.ShardCollection(ctx, mongosClient, appDatabase, coll, bson.D{{Key: "a", Value: 1}}); err != nil {
+		t.Fatalf("ShardCollection {a: 1}: %v", err)
+	}
+	if err := sharding.DropShardedCollection(ctx, mongosClient, mongosClient, appDatabase, coll); err != nil {
+		t.Fatalf("DropShardedCollection: %v", err)
+	}
+
+	n, err := mongosClient.Database("config").Collection("collections").CountDocuments(ctx, bson.D{
+		{Key: "_id", Value: ns},
+		{Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}},
+	})
+	if err != nil {
+		t.Fatalf("count config.collections: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("%s still in config.collections after drop", ns)
+	}
+
+	if err := sharding.ShardCollection(ctx, mongosClient, appDatabase, coll, bson.D{{Key: "b", Value: 1}}); err != nil {
+		t.Fatalf("ShardCollection {b: 1} after drop: %v", err)
+	}
+	if err := sharding.DropShardedCollection(ctx, mongosClient, mongosClient, appDatabase, coll); err != nil {
+		t.Errorf("DropShardedCollection cleanup: %v", err)
+	}
+}
+
+func TestShardCollectionRejectsMonotonicKey(t *testing.T) {
+	requireCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const coll = "monotonic_itest"
+	if err := sharding.DropShardedCollection(ctx, mongosClient, mongosClient, appDatabase, coll); err != nil {
+		t.Fatalf("DropShardedCollection: %v", err)
+	}
+	docs := make([]interface{}, 50)
+	for i := range docs {
+		docs[i] = bson.M{"seq": i, "created_at": time.Now().Add(time.Duration(i) * time.Second)}
+	}
+	if _, err := mongosClient.Database(appDatabase).Collection(coll).InsertMany(ctx, docs); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	err := sharding.ShardCollection(ctx, mongosClient, appDatabase, coll,
+		bson.D{{Key: "created_at", Value: 1}}, sharding.WithKeyValidation(50))
+	if !errors.Is(err, sharding.ErrUnsafeShardKey) {
+		t.Fatalf("ShardCollection {created_at: 1} = %v, want ErrUnsafeShardKey", err)
+	}
+
+	// Hashing the same field spreads the inserts, so validation passes
+	if err := sharding.ShardCollection(ctx, mongosClient, appDatabase, coll,
+		bson.D{{Key: "created_at", Value: "hashed"}}, sharding.WithKeyValidation(50)); err != nil {
+		t.Fatalf("ShardCollection {created_at: hashed}: %v", err)
+	}
+	if err := sharding.DropShardedCollection(ctx, mongosClient, mongosClient, appDatabase, coll); err != nil {
+		t.Errorf("DropShardedCollection cleanup: %v", err)
+	}
+}
+
+// harness owns the cluster's Docker network and containers.
+type harness struct {
+	network    *testcontainers.DockerNetwork
+	containers []testcontainers.Container
+}
+
+// start launches the cluster with a shared keyfile and sets it up the way
+// cmd/sharding-poc does: init replica sets, create users, start mongos,
+// add shards, and create the RBAC users.
+func (h *harness) start(ctx context.Context) error {
+	raw := make([]byte, 756)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("generate keyfile: %w", err)
+	}
+	clusterKey = base64.StdEncoding.EncodeToString(raw)
+
+	var err error
+	if h.network, err = network.New(ctx); err != nil {
+		return fmt.Errorf("create network: %w", err)
+	}
+
+	for i, rs := range append([]config.ReplicaSet{configRS}, shards...) {
+		role := "--shardsvr"
+		if i == 0 {
+			role = "--configsvr"
+		}
+		m := rs.Members[0]
+		c, hostAddr, err := h.run(ctx, m,
+			fmt.Sprintf("mongod %s --replSet %s --port %s", role, rs.Name, m.Port))
+		if err != nil {
+			return err
+		}
+		if err := cluster.WaitForHost(ctx, hostAddr, time.Minute); err != nil {
+			return err
+		}
+		if err := bootstrapReplicaSet(ctx, c, rs, i == 0); err != nil {
+			return err
+		}
+		if err := cluster.WaitForPrimary(ctx, hostAddr, time.Minute); err != nil {
+			return err
+		}
+	}
+
+	_, hostAddr, err := h.run(ctx, mongosMember,
+		fmt.Sprintf("mongos --configdb %s/%s --port %s", configRS.Name, configRS.Members[0].Addr(), mongosMember.Port))
+	if err != nil {
+		return err
+	}
+	mongosHost = hostAddr
+	if err := cluster.WaitForHost(ctx, mongosHost, time.Minute); err != nil {
+		return err
+	}
+
+	mongosClient, err = cluster.ConnectMongos(ctx, mongosHost, adminUser, adminPassword)
+	if err != nil {
+		return err
+	}
+	for _, rs := range shards {
+		if err := cluster.AddShard(ctx, mongosClient, rs.Name, rs.Members); err != nil {
+			return err
+		}
+	}
+	if err := cluster.EnableSharding(ctx, mongosClient, appDatabase); err != nil {
+		return err
+	}
+	if err := security.CreateAppUser(ctx, mongosClient, appDatabase, appUser, appPassword); err != nil {
+		return err
+	}
+	return security.CreateReadOnlyUser(ctx, mongosClient, appDatabase, readUser, readPassword)
+}
+
+// run starts one container reachable as m on the harness network and
+// returns it with its mapped host address. The keyfile is written inside
+// the container so no host file permissions (mongod requires 0400) are
+// involved.
+func (h *harness) run(ctx context.Context, m config.Member, command string) (testcontainers.Container, string, error) {
+	port := nat.Port(m.Port + "/tcp")
+	script := fmt.Sprintf(`printf '%%s' "$KEY" > /tmp/keyfile && chmod 400 /tmp/keyfile && exec %s --keyFile /tmp/keyfile --bind_ip_all`, command)
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:          mongoImage(),
+			Entrypoint:     []string{"bash", "-c", script},
+			Env:            map[string]string{"KEY": clusterKey},
+			ExposedPorts:   []string{string(port)},
+			Networks:       []string{h.network.Name},
+			NetworkAliases: map[string][]string{h.network.Name: {m.Host}},
+			WaitingFor:     wait.ForListeningPort(port).WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if c != nil {
+		h.containers = append(h.containers, c)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("start %s: %w", m.Host, err)
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s host: %w", m.Host, err)
+	}
+	mapped, err := c.MappedPort(ctx, port)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s mapped port: %w", m.Host, err)
+	}
+	return c, fmt.Sprintf("%s:%s", host, mapped.Port()), nil
+}
+
+// stop removes every harness container and the network, ignoring errors.
+func (h *harness) stop() {
+	ctx := context.Background()
+	for _, c := range h.containers {
+		c.Terminate(ctx)
+	}
+	if h.network != nil {
+		h.network.Remove(ctx)
+	}
+}
+
+// bootstrapReplicaSet initiates rs and creates the admin user from inside
+// its container. Through a mapped port the test process is not on
+// localhost, so the localhost exception that cluster.InitReplicaSet and
+// cluster.CreateAdminUser rely on does not apply.
+func bootstrapReplicaSet(ctx context.Context, c testcontainers.Container, rs config.ReplicaSet, isConfigSvr bool) error {
+	m := rs.Members[0]
+	script := fmt.Sprintf(`rs.initiate({_id: %q, configsvr: %t, members: [{_id: 0, host: %q}]});
+while (!db.hello().isWritablePrimary) { sleep(100); }
+db.getSiblingDB("admin").createUser({user: %q, pwd: %q, roles: ["root"]});`,
+		rs.Name, isConfigSvr, m.Addr(), adminUser, adminPassword)
+
+	code, out, err := c.Exec(ctx, []string{"mongosh", "--quiet", "--port", m.Port, "--eval", script}, tcexec.Multiplexed())
+	if err != nil {
+		return fmt.Errorf("bootstrap %s: %w", rs.Name, err)
+	}
+	if code != 0 {
+		output, _ := io.ReadAll(out)
+		return fmt.Errorf("bootstrap %s: mongosh exited %d: %s", rs.Name, code, strings.TrimSpace(string(output)))
+	}
+	log.Printf("[OK] Replica set '%s' initialized with admin user", rs.Name)
+	return nil
+}
+
+// mongoImage matches docker-compose.yml unless MONGO_IMAGE overrides it.
+func mongoImage() string {
+	if img := os.Getenv("MONGO_IMAGE"); img != "" {
+		return img
+	}
+	return "mongo:7.0"
+}